@@ -0,0 +1,41 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command jsclient generates the JS client for the GUI API
+// from the actions that are registered in guiapi.Handlers.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/mbertschler/bunny/pkg/guiapi"
+)
+
+func main() {
+	out := flag.String("o", "guiapi.js", "output file")
+	flag.Parse()
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = guiapi.WriteJSClient(f, guiapi.Handlers().Describe())
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = f.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/mbertschler/bunny/pkg/config"
+	"github.com/mbertschler/bunny/pkg/data"
+	"github.com/mbertschler/bunny/pkg/importer"
+)
+
+// command is a subcommand of the bunny binary like "bunny export".
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"compact": {
+		usage: "compact            rewrite the data file without old values",
+		run:   compactCommand,
+	},
+	"export": {
+		usage: "export [-o file]   write the workspace as JSON",
+		run:   exportCommand,
+	},
+	"item": {
+		usage: "item add [-list id] [-body text] [-due date] title\n" +
+			"  item done id...    complete items\n" +
+			"  item show id       show an item",
+		run: itemCommand,
+	},
+	"fsck": {
+		usage: "fsck [-repair]     check the data file for broken references",
+		run:   fsckCommand,
+	},
+	"focus": {
+		usage: "focus              show what you focus on now, later and watch",
+		run:   focusCommand,
+	},
+	"migrate": {
+		usage: "migrate            upgrade the data file to the current schema",
+		run:   migrateCommand,
+	},
+	"list": {
+		usage: "list show id       show a list and its items",
+		run:   listCommand,
+	},
+	"import": {
+		usage: "import file        restore an export into an empty data file\n" +
+			"  import -from trello|todoist|markdown [-apply] file\n" +
+			"                     preview or apply an import from another tool",
+		run: importCommand,
+	},
+}
+
+func runCommand(name string, args []string) error {
+	switch name {
+	case "help", "-h", "-help", "--help":
+		usage(os.Stdout)
+		return nil
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		usage(os.Stderr)
+		return fmt.Errorf("unknown command %q", name)
+	}
+	config.SetupEnv()
+	return cmd.run(args)
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: bunny [command]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Without a command the server is started. Commands:")
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintln(w, "  "+commands[name].usage)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "The data file is set with $BUNNY_DATA or the -data flag.")
+	fmt.Fprintln(w, "The item, focus, list and export commands talk to a running server")
+	fmt.Fprintln(w, "instead if $BUNNY_SERVER and $BUNNY_TOKEN or -server and -token are")
+	fmt.Fprintln(w, "set. They print JSON with -json.")
+}
+
+func sortedKeys(m map[string]func([]string) error) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// openData opens the data file that is configured with -data
+// or $BUNNY_DATA. Commands that change data need a data file,
+// otherwise they would only change the in-memory example data.
+func openData(path string) error {
+	if path == "" {
+		return errors.New("no data file, set $BUNNY_DATA or use -data")
+	}
+	return data.OpenFile(path)
+}
+
+func exportCommand(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	cf := addClientFlags(flags)
+	out := flags.String("o", "", "output file, stdout if empty")
+	flags.Parse(args)
+
+	c, err := cf.open()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return c.Export(w)
+}
+
+func importCommand(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	dataFile := flags.String("data", config.Data, "data file, has to be empty for Bunny exports")
+	from := flags.String("from", "", "import from another tool: "+strings.Join(importer.Formats(), ", "))
+	apply := flags.Bool("apply", false, "apply the import from another tool instead of only showing a preview")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return errors.New("usage: bunny import [-data file] [-from format [-apply]] file")
+	}
+	if *from != "" {
+		return importFrom(*from, flags.Arg(0), *dataFile, *apply)
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	e, err := data.ReadExport(f)
+	if err != nil {
+		return err
+	}
+	err = openData(*dataFile)
+	if err != nil {
+		return err
+	}
+	err = data.ImportWorkspace(e)
+	if err != nil {
+		data.Close()
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "imported %d users, %d items, %d lists and %d areas\n",
+		len(e.Users), len(e.Items), len(e.Lists), len(e.Areas))
+	return data.Close()
+}
+
+// importFrom prints a preview of an import from another tool
+// and applies it if apply is set.
+func importFrom(format, path, dataFile string, apply bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	res, err := importer.Parse(format, path, f)
+	if err != nil {
+		return err
+	}
+	err = res.WriteReport(os.Stdout)
+	if err != nil || !apply {
+		return err
+	}
+	err = openData(dataFile)
+	if err != nil {
+		return err
+	}
+	_, err = res.Apply(data.Tx{})
+	if err != nil {
+		data.Close()
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "import applied")
+	return data.Close()
+}
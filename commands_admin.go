@@ -0,0 +1,117 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/mbertschler/bunny/pkg/config"
+	"github.com/mbertschler/bunny/pkg/data"
+)
+
+func fsckCommand(args []string) error {
+	flags := flag.NewFlagSet("fsck", flag.ExitOnError)
+	dataFile := flags.String("data", config.Data, "data file")
+	repair := flags.Bool("repair", false, "repair the problems instead of only reporting them")
+	flags.Parse(args)
+
+	err := openData(*dataFile)
+	if err != nil {
+		return err
+	}
+	defer data.Close()
+	problems, err := data.Check(*repair)
+	if err != nil {
+		return err
+	}
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	switch {
+	case len(problems) == 0:
+		fmt.Println("no problems found")
+	case *repair:
+		fmt.Printf("repaired %d problems\n", len(problems))
+	default:
+		return fmt.Errorf("found %d problems, run with -repair to fix them", len(problems))
+	}
+	return nil
+}
+
+func compactCommand(args []string) error {
+	flags := flag.NewFlagSet("compact", flag.ExitOnError)
+	dataFile := flags.String("data", config.Data, "data file")
+	flags.Parse(args)
+
+	before, err := fileSize(*dataFile)
+	if err != nil {
+		return err
+	}
+	err = openData(*dataFile)
+	if err != nil {
+		return err
+	}
+	err = data.Compact()
+	if err != nil {
+		data.Close()
+		return err
+	}
+	err = data.Close()
+	if err != nil {
+		return err
+	}
+	after, err := fileSize(*dataFile)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("compacted %s from %d to %d bytes\n", *dataFile, before, after)
+	return nil
+}
+
+func migrateCommand(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dataFile := flags.String("data", config.Data, "data file")
+	flags.Parse(args)
+
+	err := openData(*dataFile)
+	if err != nil {
+		return err
+	}
+	n, err := data.Migrate()
+	if err != nil {
+		data.Close()
+		return err
+	}
+	version, err := data.SchemaVersion()
+	if err != nil {
+		data.Close()
+		return err
+	}
+	fmt.Printf("rewrote %d values with schema version %d\n", n, version)
+	return data.Close()
+}
+
+func fileSize(path string) (int64, error) {
+	if path == "" {
+		return 0, errors.New("no data file, set $BUNNY_DATA or use -data")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
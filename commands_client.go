@@ -0,0 +1,290 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mbertschler/bunny/pkg/client"
+	"github.com/mbertschler/bunny/pkg/config"
+	"github.com/mbertschler/bunny/pkg/data"
+)
+
+// clientFlags select where a command gets its data from
+// and how the result is printed.
+type clientFlags struct {
+	data   *string
+	server *string
+	token  *string
+	json   *bool
+}
+
+func addClientFlags(flags *flag.FlagSet) clientFlags {
+	return clientFlags{
+		data:   flags.String("data", config.Data, "data file, used if there is no server"),
+		server: flags.String("server", config.Server, "URL of a running server"),
+		token:  flags.String("token", config.Token, "API token for the server"),
+		json:   flags.Bool("json", false, "print JSON instead of text"),
+	}
+}
+
+// open returns an HTTP client if a server is set, otherwise a
+// client that works on the data file.
+func (f clientFlags) open() (client.Client, error) {
+	if *f.server != "" {
+		if *f.token == "" {
+			return nil, errors.New("no API token, set $BUNNY_TOKEN or use -token")
+		}
+		return client.NewHTTP(*f.server, *f.token), nil
+	}
+	if *f.data == "" {
+		return nil, errors.New("no server or data file, set $BUNNY_SERVER or $BUNNY_DATA")
+	}
+	return client.OpenFile(*f.data)
+}
+
+// print writes v as JSON if -json is set, otherwise it calls text.
+func (f clientFlags) print(v interface{}, text func(w io.Writer)) error {
+	if *f.json {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	text(os.Stdout)
+	return nil
+}
+
+// parseFlags parses flags that can appear before, between and
+// after the positional arguments, and returns the positional ones.
+// Everything after "--" is positional.
+func parseFlags(flags *flag.FlagSet, args []string) []string {
+	var pos []string
+	for {
+		flags.Parse(args)
+		rest := flags.Args()
+		consumed := args[:len(args)-len(rest)]
+		if len(consumed) > 0 && consumed[len(consumed)-1] == "--" {
+			return append(pos, rest...)
+		}
+		if len(rest) == 0 {
+			return pos
+		}
+		pos = append(pos, rest[0])
+		args = rest[1:]
+	}
+}
+
+// subcommand runs the function for the first argument,
+// like "add" in "bunny item add".
+func subcommand(name string, args []string, subs map[string]func([]string) error) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: bunny %s %s", name, strings.Join(sortedKeys(subs), "|"))
+	}
+	fn, ok := subs[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q, use one of: bunny %s %s",
+			args[0], name, strings.Join(sortedKeys(subs), "|"))
+	}
+	return fn(args[1:])
+}
+
+func itemCommand(args []string) error {
+	return subcommand("item", args, map[string]func([]string) error{
+		"add":  itemAddCommand,
+		"done": itemDoneCommand,
+		"show": itemShowCommand,
+	})
+}
+
+func itemAddCommand(args []string) error {
+	flags := flag.NewFlagSet("item add", flag.ExitOnError)
+	cf := addClientFlags(flags)
+	list := flags.Int("list", 1, "ID of the list that the item is added to")
+	body := flags.String("body", "", "description of the item")
+	due := flags.String("due", "", "due date like 2018-12-31")
+	title := strings.Join(parseFlags(flags, args), " ")
+	if title == "" {
+		return errors.New("usage: bunny item add [-list id] [-body text] [-due date] title")
+	}
+	dueDate, err := data.ParseDue(*due)
+	if err != nil {
+		return err
+	}
+	c, err := cf.open()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	item, err := c.AddItem(*list, data.Item{Title: title, Body: *body, Due: dueDate})
+	if err != nil {
+		return err
+	}
+	return cf.print(item, func(w io.Writer) {
+		fmt.Fprintf(w, "added item %d to list %d\n", item.ID, *list)
+	})
+}
+
+func itemDoneCommand(args []string) error {
+	flags := flag.NewFlagSet("item done", flag.ExitOnError)
+	cf := addClientFlags(flags)
+	ids, err := parseIDs(parseFlags(flags, args))
+	if err != nil || len(ids) == 0 {
+		return errors.New("usage: bunny item done id...")
+	}
+	c, err := cf.open()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	var done []data.Item
+	for _, id := range ids {
+		item, err := c.CompleteItem(id)
+		if err != nil {
+			return fmt.Errorf("item %d: %v", id, err)
+		}
+		done = append(done, item)
+	}
+	return cf.print(done, func(w io.Writer) {
+		for _, i := range done {
+			fmt.Fprintln(w, itemLine(i))
+		}
+	})
+}
+
+func itemShowCommand(args []string) error {
+	flags := flag.NewFlagSet("item show", flag.ExitOnError)
+	cf := addClientFlags(flags)
+	ids, err := parseIDs(parseFlags(flags, args))
+	if err != nil || len(ids) != 1 {
+		return errors.New("usage: bunny item show id")
+	}
+	c, err := cf.open()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	item, err := c.Item(ids[0])
+	if err != nil {
+		return err
+	}
+	return cf.print(item, func(w io.Writer) {
+		fmt.Fprintln(w, itemLine(item))
+		focus, _ := item.Focus.MarshalText()
+		fmt.Fprintf(w, "\nfocus: %s\n", focus)
+		if item.Body != "" {
+			fmt.Fprintf(w, "\n%s\n", item.Body)
+		}
+	})
+}
+
+func focusCommand(args []string) error {
+	flags := flag.NewFlagSet("focus", flag.ExitOnError)
+	cf := addClientFlags(flags)
+	if len(parseFlags(flags, args)) != 0 {
+		return errors.New("usage: bunny focus")
+	}
+	c, err := cf.open()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	focus, err := c.Focus()
+	if err != nil {
+		return err
+	}
+	return cf.print(focus, func(w io.Writer) {
+		printSection(w, "Now", focus.Focus)
+		printSection(w, "Later", focus.Later)
+		printSection(w, "Watch", focus.Watch)
+	})
+}
+
+func listCommand(args []string) error {
+	return subcommand("list", args, map[string]func([]string) error{
+		"show": listShowCommand,
+	})
+}
+
+func listShowCommand(args []string) error {
+	flags := flag.NewFlagSet("list show", flag.ExitOnError)
+	cf := addClientFlags(flags)
+	ids, err := parseIDs(parseFlags(flags, args))
+	if err != nil || len(ids) != 1 {
+		return errors.New("usage: bunny list show id")
+	}
+	c, err := cf.open()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	list, err := c.List(ids[0])
+	if err != nil {
+		return err
+	}
+	return cf.print(list, func(w io.Writer) {
+		fmt.Fprintf(w, "%s (list %d)\n", list.Title, list.ID)
+		if list.Body != "" {
+			fmt.Fprintln(w, list.Body)
+		}
+		fmt.Fprintln(w)
+		for _, i := range list.Items {
+			fmt.Fprintln(w, itemLine(i))
+		}
+	})
+}
+
+func printSection(w io.Writer, title string, items []data.Item) {
+	fmt.Fprintln(w, title)
+	if len(items) == 0 {
+		fmt.Fprintln(w, "  -")
+	}
+	for _, i := range items {
+		fmt.Fprintln(w, "  "+itemLine(i))
+	}
+}
+
+// itemLine formats an item like "[x]   12  Title (due 2018-12-31)".
+func itemLine(i data.Item) string {
+	box := "[ ]"
+	switch i.State {
+	case data.ItemComplete:
+		box = "[x]"
+	case data.ItemArchived:
+		box = "[a]"
+	}
+	line := fmt.Sprintf("%s %4d  %s", box, i.ID, i.Title)
+	if i.HasDue() {
+		line += " (due " + i.Due.Format(data.DueFormat) + ")"
+	}
+	return line
+}
+
+func parseIDs(args []string) ([]int, error) {
+	var ids []int
+	for _, a := range args {
+		id, err := strconv.Atoi(a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ID %q", a)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -12,6 +12,7 @@
 // limitations under the License.
 
 enableSorting()
+startPomodoroTimer()
 
 function enableSorting() {
 	activateList("item-list", sortUpdate)
@@ -20,7 +21,7 @@ function enableSorting() {
 
 function activateList(id, cb) {
 	var el = document.getElementById(id);
-	if (el) {
+	if (el && el.dataset.sortable === "true") {
 		var options = {
 			animation: 150,
 			onUpdate: cb,
@@ -30,70 +31,316 @@ function activateList(id, cb) {
 }
 
 function sortUpdate(event) {
-	callGuiAPI("listSort",{
+	guiapi.listSort({
 		Item: parseInt(event.item.dataset.itemId, 10),
 		Pos: event.newIndex+1,
 	})
 }
 
 function sortFocusUpdate(event) {
-	callGuiAPI("focusSort",{
+	guiapi.focusSort({
 		Old: event.oldIndex,
 		New: event.newIndex,
 	})
 }
 
 function itemFocus(id, status) {
-	callGuiAPI("itemFocus",{
+	guiapi.itemFocus({
 		ID: id,
 		Focus: status,
 	})
 }
 
-function listView() {
-	callGuiAPI("listView", null)
+function listView(id) {
+	guiapi.listView({
+		ID: id || 1,
+	})
+}
+
+function listFilter(id) {
+	var data = {
+		ID: id,
+	}
+	$(".listViewForm").each(function(i, el){
+		data[el.name] = el.value
+	})
+	data.Sort = parseInt(data.Sort, 10) || 0
+	data.Filter = parseInt(data.Filter, 10) || 0
+	data.Desc = data.Desc === "true"
+	guiapi.listView(data)
 }
 
 function areaView() {
-	callGuiAPI("areaView", null)
+	guiapi.areaView()
+}
+
+function listMore(id, cursor, archived) {
+	guiapi.listMore({
+		ID: id,
+		Cursor: cursor,
+		Archived: archived,
+	})
+}
+
+function areaMore(id, cursor, archived) {
+	guiapi.areaMore({
+		ID: id,
+		Cursor: cursor,
+		Archived: archived,
+	})
+}
+
+function areaFields(id) {
+	guiapi.areaFields({
+		ID: id,
+	})
+}
+
+function fieldAdd(area) {
+	var data = {
+		Area: area,
+	}
+	$(".fieldForm").each(function(i, el){
+		data[el.name] = el.value
+	})
+	guiapi.fieldAdd(data)
+}
+
+function fieldDelete(area, id) {
+	guiapi.fieldDelete({
+		Area: area,
+		ID: id,
+	})
 }
 
 function itemEdit(id) {
-	callGuiAPI("itemEdit", id)
+	guiapi.itemEdit({
+		ID: id,
+	})
 }
 
 function itemNew() {
-	callGuiAPI("itemNew", null)
+	guiapi.itemNew()
 }
 
-function itemSave(id, isNew) {
+function itemSave(id, isNew, version) {
 	var data = {
 		ID: id,
 		New: isNew,
+		Version: version,
 	}
 	$(".itemForm").each(function(i, el){
 		data[el.name] = el.value
 	})
-	callGuiAPI("itemSave", data)
+	data.Fields = {}
+	$(".itemField").each(function(i, el){
+		data.Fields[el.dataset.fieldId] = el.value
+	})
+	guiapi.itemSave(data)
 }
 
 function itemDelete(id) {
-	callGuiAPI("itemDelete", id)
+	guiapi.itemDelete({
+		ID: id,
+	})
 }
 
 function itemView(id) {
-	callGuiAPI("itemView", id)
+	guiapi.itemView({
+		ID: id,
+	})
+}
+
+function itemPriority(id, priority) {
+	guiapi.itemPriority({
+		ID: id,
+		Priority: priority,
+	})
+}
+
+function listOrder(id, order) {
+	guiapi.listOrder({
+		ID: id,
+		Order: order,
+	})
+}
+
+function areaOrder(id, order) {
+	guiapi.areaOrder({
+		ID: id,
+		Order: order,
+	})
 }
 
 function itemState(id, state) {
-	callGuiAPI("itemState", {
+	guiapi.itemState({
 		ID: id,
 		State: state,
 	})
 }
 
-function focusView(id) {
-	callGuiAPI("focusView", id)
+function focusView() {
+	guiapi.focusView()
+}
+
+// pomodoroInterval counts down the session on the focus page.
+var pomodoroInterval = null
+
+// startPomodoroTimer counts down to the end of the running session
+// and tells the server when it is over. It stops when the focus
+// page is left, the session is finished when it is shown again.
+function startPomodoroTimer() {
+	clearInterval(pomodoroInterval)
+	pomodoroInterval = null
+	var el = document.getElementById("pomodoro")
+	if (!el || !el.dataset.end) {
+		return
+	}
+	var end = parseInt(el.dataset.end, 10) * 1000
+	var tick = function() {
+		if (!document.body.contains(el)) {
+			clearInterval(pomodoroInterval)
+			pomodoroInterval = null
+			return
+		}
+		var left = Math.max(0, Math.round((end - Date.now()) / 1000))
+		var sec = left % 60
+		$("#pomodoro-time").text(Math.floor(left / 60) + ":" + (sec < 10 ? "0" : "") + sec)
+		if (left == 0) {
+			clearInterval(pomodoroInterval)
+			pomodoroInterval = null
+			guiapi.pomodoroDone()
+		}
+	}
+	tick()
+	pomodoroInterval = setInterval(tick, 1000)
+}
+
+// pomodoroPrompt asks what to do next when a session is over.
+function pomodoroPrompt(args) {
+	if (args.Break) {
+		if (confirm("The break is over. Start the next pomodoro?")) {
+			pomodoroStart()
+		}
+		return
+	}
+	if (confirm("Pomodoro " + args.Count + " on \"" + args.Title + "\" is done. Is the item complete?")) {
+		guiapi.pomodoroNext({
+			ID: args.Item,
+			Next: "complete",
+		})
+	} else if (args.Next && confirm("Switch the focus to \"" + args.NextTitle + "\"?")) {
+		guiapi.pomodoroNext({
+			ID: args.Next,
+			Next: "switch",
+		})
+	} else if (confirm("Take a break?")) {
+		pomodoroBreak()
+	}
+}
+
+function pomodoroStart() {
+	guiapi.pomodoroStart()
+}
+
+function pomodoroBreak() {
+	guiapi.pomodoroBreak()
+}
+
+function pomodoroStop() {
+	guiapi.pomodoroStop()
+}
+
+function pomodoroLengths() {
+	var data = {}
+	$(".pomodoroForm").each(function(i, el){
+		data[el.name] = parseInt(el.value, 10) || 0
+	})
+	guiapi.pomodoroLengths(data)
+}
+
+function timesheetView(week) {
+	guiapi.timesheetView({
+		Week: week,
+	})
+}
+
+function entryEdit(id) {
+	guiapi.entryEdit({
+		ID: id,
+	})
+}
+
+function entrySave(id) {
+	var data = {
+		ID: id,
+	}
+	$(".entryForm").each(function(i, el){
+		data[el.name] = el.value
+	})
+	guiapi.entrySave(data)
+}
+
+function entryAdd() {
+	var data = {}
+	$(".newEntryForm").each(function(i, el){
+		data[el.name] = el.value
+	})
+	data.Item = parseInt(data.Item, 10) || 0
+	guiapi.entryAdd(data)
+}
+
+function entryDelete(id) {
+	guiapi.entryDelete({
+		ID: id,
+	})
+}
+
+function settingsView() {
+	guiapi.settingsView()
+}
+
+function tokenCreate() {
+	var data = {}
+	$(".tokenForm").each(function(i, el){
+		data[el.name] = el.value
+	})
+	data.Days = parseInt(data.Days, 10) || 0
+	guiapi.tokenCreate(data)
+}
+
+// pendingImport keeps the file of the import preview
+// so that it can be sent again to apply the import.
+var pendingImport = null
+
+function importPreview() {
+	var file = $("#import-file")[0].files[0]
+	if (!file) {
+		return
+	}
+	var reader = new FileReader()
+	reader.onload = function() {
+		pendingImport = {
+			Format: $("#import-format").val(),
+			Name: file.name,
+			Content: reader.result,
+		}
+		guiapi.importPreview(pendingImport)
+	}
+	reader.readAsText(file)
+}
+
+function importApply() {
+	if (pendingImport) {
+		guiapi.importApply(pendingImport)
+		pendingImport = null
+	}
+}
+
+function tokenRevoke(id) {
+	guiapi.tokenRevoke({
+		ID: id,
+	})
 }
 
 function callGuiAPI(name, args) {
@@ -106,6 +353,7 @@ function callGuiAPI(name, args) {
 	$.ajax({
 		method: "POST",
 		url: "/gui/",
+		contentType: "application/json",
 		data: JSON.stringify(req),
 		success: function (data) {
 			var ret = JSON.parse(data)
@@ -120,6 +368,8 @@ function callGuiAPI(name, args) {
 var callableFunctions = {
 	"setURL": setURL,
 	"enableSorting": enableSorting,
+	"startPomodoroTimer": startPomodoroTimer,
+	"pomodoroPrompt": pomodoroPrompt,
 }
 
 function setURL(args) {
@@ -129,11 +379,16 @@ function setURL(args) {
 function handleResponse(resp) {
 	for (var i=0; i< resp.Results.length; i++) {
 		var r = resp.Results[i]
+		if (r.Error) {
+			console.error(r.Name, "failed:", r.Error.Code, r.Error.Message)
+		}
 		if (r.HTML) {
 			for (var j=0; j< r.HTML.length; j++) {
 				var update = r.HTML[j]
 				if (update.Operation == 1) {
 					$(update.Selector).html(update.Content)
+				} else if (update.Operation == 3) {
+					$(update.Selector).append(update.Content)
 				} else {
 					console.warn("update type not implemented :(", update)
 				}
@@ -0,0 +1,409 @@
+// Code generated by "go generate github.com/mbertschler/bunny/pkg/guiapi"; DO NOT EDIT.
+
+var guiapi = {}
+
+/**
+ * @typedef {Object} AreaFieldsArgs
+ * @property {number} ID - min 1
+ */
+
+/**
+ * @param {AreaFieldsArgs} args
+ */
+guiapi.areaFields = function(args) {
+	callGuiAPI("areaFields", args)
+}
+
+/**
+ * @typedef {Object} AreaMoreArgs
+ * @property {number} ID - min 1
+ * @property {string} [Cursor] - max 32
+ * @property {boolean} [Archived]
+ */
+
+/**
+ * @param {AreaMoreArgs} args
+ */
+guiapi.areaMore = function(args) {
+	callGuiAPI("areaMore", args)
+}
+
+/**
+ * @typedef {Object} AreaOrderArgs
+ * @property {number} ID - min 1
+ * @property {("manual"|"priority"|"due"|"updated")} Order
+ */
+
+/**
+ * @param {AreaOrderArgs} args
+ */
+guiapi.areaOrder = function(args) {
+	callGuiAPI("areaOrder", args)
+}
+
+guiapi.areaView = function() {
+	callGuiAPI("areaView", null)
+}
+
+/**
+ * @typedef {Object} EntryAddArgs
+ * @property {number} Item - min 1
+ * @property {string} Start - max 20
+ * @property {string} End - max 20
+ */
+
+/**
+ * @param {EntryAddArgs} args
+ */
+guiapi.entryAdd = function(args) {
+	callGuiAPI("entryAdd", args)
+}
+
+/**
+ * @typedef {Object} EntryDeleteArgs
+ * @property {number} ID - min 1
+ */
+
+/**
+ * @param {EntryDeleteArgs} args
+ */
+guiapi.entryDelete = function(args) {
+	callGuiAPI("entryDelete", args)
+}
+
+/**
+ * @typedef {Object} EntryEditArgs
+ * @property {number} ID - min 1
+ */
+
+/**
+ * @param {EntryEditArgs} args
+ */
+guiapi.entryEdit = function(args) {
+	callGuiAPI("entryEdit", args)
+}
+
+/**
+ * @typedef {Object} EntrySaveArgs
+ * @property {number} ID - min 1
+ * @property {string} Start - max 20
+ * @property {string} [End] - max 20
+ */
+
+/**
+ * @param {EntrySaveArgs} args
+ */
+guiapi.entrySave = function(args) {
+	callGuiAPI("entrySave", args)
+}
+
+/**
+ * @typedef {Object} FieldAddArgs
+ * @property {number} Area - min 1
+ * @property {string} Name - max 100
+ * @property {("text"|"number"|"date"|"select"|"url")} Type
+ * @property {string} [Options] - max 1000
+ */
+
+/**
+ * @param {FieldAddArgs} args
+ */
+guiapi.fieldAdd = function(args) {
+	callGuiAPI("fieldAdd", args)
+}
+
+/**
+ * @typedef {Object} FieldDeleteArgs
+ * @property {number} Area - min 1
+ * @property {number} ID - min 1
+ */
+
+/**
+ * @param {FieldDeleteArgs} args
+ */
+guiapi.fieldDelete = function(args) {
+	callGuiAPI("fieldDelete", args)
+}
+
+/**
+ * @typedef {Object} FocusSortArgs
+ * @property {string} [Type]
+ * @property {number} [Old] - min 0
+ * @property {number} [New] - min 0
+ */
+
+/**
+ * @param {FocusSortArgs} args
+ */
+guiapi.focusSort = function(args) {
+	callGuiAPI("focusSort", args)
+}
+
+guiapi.focusView = function() {
+	callGuiAPI("focusView", null)
+}
+
+/**
+ * @typedef {Object} ImportApplyArgs
+ * @property {("markdown"|"todoist"|"trello")} Format
+ * @property {string} [Name] - max 255
+ * @property {string} Content - max 5000000
+ */
+
+/**
+ * @param {ImportApplyArgs} args
+ */
+guiapi.importApply = function(args) {
+	callGuiAPI("importApply", args)
+}
+
+/**
+ * @typedef {Object} ImportPreviewArgs
+ * @property {("markdown"|"todoist"|"trello")} Format
+ * @property {string} [Name] - max 255
+ * @property {string} Content - max 5000000
+ */
+
+/**
+ * @param {ImportPreviewArgs} args
+ */
+guiapi.importPreview = function(args) {
+	callGuiAPI("importPreview", args)
+}
+
+/**
+ * @typedef {Object} ItemDeleteArgs
+ * @property {number} ID - min 1
+ */
+
+/**
+ * @param {ItemDeleteArgs} args
+ */
+guiapi.itemDelete = function(args) {
+	callGuiAPI("itemDelete", args)
+}
+
+/**
+ * @typedef {Object} ItemEditArgs
+ * @property {number} ID - min 1
+ */
+
+/**
+ * @param {ItemEditArgs} args
+ */
+guiapi.itemEdit = function(args) {
+	callGuiAPI("itemEdit", args)
+}
+
+/**
+ * @typedef {Object} ItemFocusArgs
+ * @property {number} ID - min 1
+ * @property {("later"|"focus"|"watch")} Focus
+ */
+
+/**
+ * @param {ItemFocusArgs} args
+ */
+guiapi.itemFocus = function(args) {
+	callGuiAPI("itemFocus", args)
+}
+
+guiapi.itemNew = function() {
+	callGuiAPI("itemNew", null)
+}
+
+/**
+ * @typedef {Object} ItemPriorityArgs
+ * @property {number} ID - min 1
+ * @property {("none"|"low"|"medium"|"high"|"urgent")} Priority
+ */
+
+/**
+ * @param {ItemPriorityArgs} args
+ */
+guiapi.itemPriority = function(args) {
+	callGuiAPI("itemPriority", args)
+}
+
+/**
+ * @typedef {Object} ItemSaveArgs
+ * @property {number} [ID] - min 0
+ * @property {boolean} [New]
+ * @property {number} [Version] - min 0
+ * @property {string} [Title] - max 500
+ * @property {string} [Body]
+ * @property {string} [Due] - max 10
+ * @property {Object} [Fields]
+ */
+
+/**
+ * @param {ItemSaveArgs} args
+ */
+guiapi.itemSave = function(args) {
+	callGuiAPI("itemSave", args)
+}
+
+/**
+ * @typedef {Object} ItemStateArgs
+ * @property {number} ID - min 1
+ * @property {("open"|"complete"|"archived")} State
+ */
+
+/**
+ * @param {ItemStateArgs} args
+ */
+guiapi.itemState = function(args) {
+	callGuiAPI("itemState", args)
+}
+
+/**
+ * @typedef {Object} ItemViewArgs
+ * @property {number} ID - min 1
+ */
+
+/**
+ * @param {ItemViewArgs} args
+ */
+guiapi.itemView = function(args) {
+	callGuiAPI("itemView", args)
+}
+
+/**
+ * @typedef {Object} ListMoreArgs
+ * @property {number} ID - min 1
+ * @property {string} [Cursor] - max 32
+ * @property {boolean} [Archived]
+ */
+
+/**
+ * @param {ListMoreArgs} args
+ */
+guiapi.listMore = function(args) {
+	callGuiAPI("listMore", args)
+}
+
+/**
+ * @typedef {Object} ListOrderArgs
+ * @property {number} ID - min 1
+ * @property {("manual"|"priority"|"due"|"updated")} Order
+ */
+
+/**
+ * @param {ListOrderArgs} args
+ */
+guiapi.listOrder = function(args) {
+	callGuiAPI("listOrder", args)
+}
+
+/**
+ * @typedef {Object} ListSortArgs
+ * @property {number} Item - min 1
+ * @property {number} Pos - min 1
+ */
+
+/**
+ * @param {ListSortArgs} args
+ */
+guiapi.listSort = function(args) {
+	callGuiAPI("listSort", args)
+}
+
+/**
+ * @typedef {Object} ListViewArgs
+ * @property {number} [ID] - min 0
+ * @property {number} [Sort] - min 0
+ * @property {boolean} [Desc]
+ * @property {number} [Filter] - min 0
+ * @property {string} [Value] - max 1000
+ */
+
+/**
+ * @param {ListViewArgs} args
+ */
+guiapi.listView = function(args) {
+	callGuiAPI("listView", args)
+}
+
+guiapi.pomodoroBreak = function() {
+	callGuiAPI("pomodoroBreak", null)
+}
+
+guiapi.pomodoroDone = function() {
+	callGuiAPI("pomodoroDone", null)
+}
+
+/**
+ * @typedef {Object} PomodoroLengthsArgs
+ * @property {number} Work - min 1, max 120
+ * @property {number} Break - min 1, max 60
+ */
+
+/**
+ * @param {PomodoroLengthsArgs} args
+ */
+guiapi.pomodoroLengths = function(args) {
+	callGuiAPI("pomodoroLengths", args)
+}
+
+/**
+ * @typedef {Object} PomodoroNextArgs
+ * @property {number} ID - min 1
+ * @property {("complete"|"switch")} Next
+ */
+
+/**
+ * @param {PomodoroNextArgs} args
+ */
+guiapi.pomodoroNext = function(args) {
+	callGuiAPI("pomodoroNext", args)
+}
+
+guiapi.pomodoroStart = function() {
+	callGuiAPI("pomodoroStart", null)
+}
+
+guiapi.pomodoroStop = function() {
+	callGuiAPI("pomodoroStop", null)
+}
+
+guiapi.settingsView = function() {
+	callGuiAPI("settingsView", null)
+}
+
+/**
+ * @typedef {Object} TimesheetViewArgs
+ * @property {string} [Week] - max 10
+ */
+
+/**
+ * @param {TimesheetViewArgs} args
+ */
+guiapi.timesheetView = function(args) {
+	callGuiAPI("timesheetView", args)
+}
+
+/**
+ * @typedef {Object} TokenCreateArgs
+ * @property {string} Name - max 100
+ * @property {("read"|"write")} Scope
+ * @property {number} [Days] - min 0, max 3650
+ */
+
+/**
+ * @param {TokenCreateArgs} args
+ */
+guiapi.tokenCreate = function(args) {
+	callGuiAPI("tokenCreate", args)
+}
+
+/**
+ * @typedef {Object} TokenRevokeArgs
+ * @property {number} ID - min 1
+ */
+
+/**
+ * @param {TokenRevokeArgs} args
+ */
+guiapi.tokenRevoke = function(args) {
+	callGuiAPI("tokenRevoke", args)
+}
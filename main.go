@@ -14,19 +14,72 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"net/http"
+	"os"
 
 	"github.com/mbertschler/bunny/pkg/config"
+	"github.com/mbertschler/bunny/pkg/data"
+	"github.com/mbertschler/bunny/pkg/mail"
 	"github.com/mbertschler/bunny/pkg/router"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		err := runCommand(os.Args[1], os.Args[2:])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "bunny:", err)
+			os.Exit(1)
+		}
+		return
+	}
 	err := config.Setup()
 	if err != nil {
 		log.Fatal(err)
 	}
+	if config.Data != "" {
+		err = data.OpenFile(config.Data)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = data.SetupExampleData()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if config.SMTPAddr != "" {
+		err = startSMTP()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	trusted, err := router.ParseNetworks(config.TrustedNetworks)
+	if err != nil {
+		log.Fatal(err)
+	}
 	log.Println("Bunny :) running at port", config.Port)
 	log.Println(http.ListenAndServe(":"+config.Port,
-		router.Router(config.Root)))
+		router.Router(config.Root, trusted...)))
+}
+
+func startSMTP() error {
+	mailboxes, err := mail.ParseMailboxes(config.SMTPMailboxes)
+	if err != nil {
+		return err
+	}
+	senders, err := mail.ParseSenders(config.SMTPSenders)
+	if err != nil {
+		return err
+	}
+	srv := &mail.Server{
+		Domain:    config.SMTPDomain,
+		Mailboxes: mailboxes,
+		Senders:   senders,
+	}
+	log.Println("Bunny SMTP listener running at", config.SMTPAddr)
+	go func() {
+		log.Println(srv.ListenAndServe(config.SMTPAddr))
+	}()
+	return nil
 }
@@ -0,0 +1,210 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package api implements the JSON REST API that is mounted at /api/v1/.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/mbertschler/bunny/pkg/data"
+)
+
+func Router() *chi.Mux {
+	r := chi.NewRouter()
+	r.Route("/items", func(r chi.Router) {
+		r.Method("GET", "/", handler(listItems))
+		r.Method("POST", "/", handler(createItem))
+		r.Method("GET", "/{id}", handler(getItem))
+		r.Method("PUT", "/{id}", handler(updateItem))
+		r.Method("DELETE", "/{id}", handler(deleteItem))
+	})
+	r.Route("/lists", func(r chi.Router) {
+		r.Method("GET", "/", handler(listLists))
+		r.Method("POST", "/", handler(createList))
+		r.Method("GET", "/{id}", handler(getList))
+		r.Method("PUT", "/{id}", handler(updateList))
+		r.Method("DELETE", "/{id}", handler(deleteList))
+		r.Method("PUT", "/{id}/items/{item}", handler(moveListItem))
+	})
+	r.Route("/areas", func(r chi.Router) {
+		r.Method("GET", "/", handler(listAreas))
+		r.Method("POST", "/", handler(createArea))
+		r.Method("GET", "/{id}", handler(getArea))
+		r.Method("PUT", "/{id}", handler(updateArea))
+		r.Method("DELETE", "/{id}", handler(deleteArea))
+		r.Method("PUT", "/{id}/things", handler(moveAreaThing))
+	})
+	r.Route("/focus", func(r chi.Router) {
+		r.Method("GET", "/", handler(getFocus))
+		r.Method("PUT", "/{item}", handler(setFocus))
+	})
+	r.Route("/users", func(r chi.Router) {
+		r.Method("GET", "/", handler(listUsers))
+		r.Method("GET", "/{id}", handler(getUser))
+	})
+	r.Route("/webhooks", func(r chi.Router) {
+		r.Method("GET", "/", handler(listWebhooks))
+		r.Method("POST", "/", handler(createWebhook))
+		r.Method("GET", "/{id}", handler(getWebhook))
+		r.Method("DELETE", "/{id}", handler(deleteWebhook))
+		r.Method("GET", "/{id}/deliveries", handler(listDeliveries))
+	})
+	r.NotFound(handler(notFound).ServeHTTP)
+	r.MethodNotAllowed(handler(methodNotAllowed).ServeHTTP)
+	return r
+}
+
+// handler returns the value that is encoded as the JSON response.
+// Returning nil results in 204 No Content, wrapping the value with
+// created results in 201 Created.
+type handler func(r *http.Request) (interface{}, error)
+
+func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v, err := h(r)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+	status := http.StatusOK
+	if c, ok := v.(created); ok {
+		status = http.StatusCreated
+		v = c.v
+	}
+	if v == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSON(w, r, status, v)
+}
+
+type created struct {
+	v interface{}
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(v)
+	if err != nil {
+		log.Printf("api error: method=%s path=%q error=%q", r.Method, r.URL.Path, err)
+	}
+}
+
+func notFound(r *http.Request) (interface{}, error) {
+	return nil, Error{
+		Status:  http.StatusNotFound,
+		Code:    "notFound",
+		Message: "there is no API endpoint at " + r.URL.Path,
+	}
+}
+
+func methodNotAllowed(r *http.Request) (interface{}, error) {
+	return nil, Error{
+		Status:  http.StatusMethodNotAllowed,
+		Code:    "methodNotAllowed",
+		Message: r.Method + " is not allowed for " + r.URL.Path,
+	}
+}
+
+type tokenKey struct{}
+
+// WithToken returns a copy of ctx that carries the API token the
+// request was authenticated with. The API expects the token to be
+// set by an authentication middleware.
+func WithToken(ctx context.Context, t data.Token) context.Context {
+	return context.WithValue(ctx, tokenKey{}, t)
+}
+
+// TokenFromContext returns the token that was set with WithToken.
+func TokenFromContext(ctx context.Context) (data.Token, bool) {
+	t, ok := ctx.Value(tokenKey{}).(data.Token)
+	return t, ok
+}
+
+// user returns the ID of the user that made the request.
+func user(r *http.Request) int {
+	t, _ := TokenFromContext(r.Context())
+	return t.User
+}
+
+func intParam(r *http.Request, name string) (int, error) {
+	str := chi.URLParam(r, name)
+	i, err := strconv.Atoi(str)
+	if err != nil {
+		return 0, invalid(name + " has to be a number")
+	}
+	return i, nil
+}
+
+func decodeBody(r *http.Request, v interface{}) error {
+	if r.Body == nil {
+		return invalid("request body is missing")
+	}
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err != nil {
+		return invalid("invalid JSON body: " + err.Error())
+	}
+	return nil
+}
+
+func invalid(message string) error {
+	return Error{
+		Status:  http.StatusBadRequest,
+		Code:    "invalidArgs",
+		Message: message,
+	}
+}
+
+// Error is returned as {"Error": {...}} in the response body.
+type Error struct {
+	Status  int `json:"-"`
+	Code    string
+	Message string
+}
+
+func (e Error) Error() string {
+	return e.Message
+}
+
+// WriteError writes err as a JSON error response. Errors of type
+// Error are written as they are, data errors are mapped to a status
+// code and all other errors result in 500 Internal Server Error.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	e, ok := err.(Error)
+	switch {
+	case ok:
+	case data.IsNotFound(err):
+		e = Error{Status: http.StatusNotFound, Code: "notFound"}
+	case data.IsInvalid(err):
+		e = Error{Status: http.StatusBadRequest, Code: "invalidArgs"}
+	case data.IsConflict(err):
+		e = Error{Status: http.StatusConflict, Code: "conflict"}
+	case data.IsForbidden(err):
+		e = Error{Status: http.StatusForbidden, Code: "forbidden"}
+	default:
+		e = Error{Status: http.StatusInternalServerError, Code: "error", Message: "internal error"}
+		log.Printf("api error: method=%s path=%q error=%q", r.Method, r.URL.Path, err)
+	}
+	if e.Message == "" {
+		e.Message = err.Error()
+	}
+	writeJSON(w, r, e.Status, struct{ Error Error }{e})
+}
@@ -0,0 +1,263 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mbertschler/bunny/pkg/data"
+)
+
+func do(t *testing.T, method, path, body string, status int, v interface{}) {
+	var req *http.Request
+	if body == "" {
+		req = httptest.NewRequest(method, path, nil)
+	} else {
+		req = httptest.NewRequest(method, path, strings.NewReader(body))
+	}
+	req = req.WithContext(WithToken(req.Context(), data.Token{User: 1, Scope: data.ScopeWrite}))
+	w := httptest.NewRecorder()
+	Router().ServeHTTP(w, req)
+	if w.Code != status {
+		t.Fatal(method, path, "returned", w.Code, "should be", status, w.Body.String())
+	}
+	if v != nil {
+		err := json.Unmarshal(w.Body.Bytes(), v)
+		if err != nil {
+			t.Fatal(method, path, err)
+		}
+	}
+}
+
+func TestItems(t *testing.T) {
+	var item data.Item
+	do(t, "POST", "/items", `{"Title":"from CI","Body":"build failed"}`, 201, &item)
+	if item.ID == 0 || item.Title != "from CI" || item.State != data.ItemOpen {
+		t.Error("unexpected item", item)
+	}
+
+	var got data.Item
+	do(t, "GET", "/items/"+itoa(item.ID), "", 200, &got)
+	if !reflect.DeepEqual(got, item) {
+		t.Error("got", got, "should be", item)
+	}
+
+	update := `{"Version":0,"Title":"fixed","State":"complete"}`
+	do(t, "PUT", "/items/"+itoa(item.ID), update, 200, &got)
+	if got.Title != "fixed" || got.State != data.ItemComplete || got.Version != 1 {
+		t.Error("item was not updated", got)
+	}
+	var apiErr struct{ Error Error }
+	do(t, "PUT", "/items/"+itoa(item.ID), update, 409, &apiErr)
+	if apiErr.Error.Code != "conflict" {
+		t.Error("expected a conflict", apiErr)
+	}
+
+	var list data.List
+	do(t, "GET", "/lists/1", "", 200, &list)
+	if len(list.Items) == 0 || list.Items[0].ID != item.ID {
+		t.Error("new item should be at the top of list 1", list.Items)
+	}
+
+	do(t, "DELETE", "/items/"+itoa(item.ID), "", 204, nil)
+	do(t, "GET", "/items/"+itoa(item.ID), "", 404, nil)
+	do(t, "DELETE", "/items/"+itoa(item.ID), "", 404, nil)
+}
+
+func TestItemFilters(t *testing.T) {
+	var page struct {
+		Page
+		Results []data.Item
+	}
+	do(t, "GET", "/items?state=archived", "", 200, &page)
+	for _, i := range page.Results {
+		if i.State != data.ItemArchived {
+			t.Error("item should be archived", i)
+		}
+	}
+	if page.Total == 0 {
+		t.Error("expected archived items")
+	}
+
+	do(t, "GET", "/items?q=BUNNY", "", 200, &page)
+	for _, i := range page.Results {
+		if !strings.Contains(strings.ToLower(i.Title+i.Body), "bunny") {
+			t.Error("item doesn't match the query", i)
+		}
+	}
+
+	do(t, "GET", "/items?limit=2&offset=1", "", 200, &page)
+	if len(page.Results) != 2 || page.Offset != 1 || page.Limit != 2 {
+		t.Error("wrong page", page)
+	}
+	if page.Results[0].ID != 2 {
+		t.Error("expected page to start with item 2", page.Results)
+	}
+
+	do(t, "GET", "/items?list=1", "", 200, &page)
+	if page.Total < 5 {
+		t.Error("expected at least 5 items in list 1", page.Total)
+	}
+
+	do(t, "GET", "/items?state=unknown", "", 400, nil)
+	do(t, "GET", "/items?limit=0", "", 400, nil)
+	do(t, "GET", "/items?offset=-1", "", 400, nil)
+}
+
+func TestListsAndAreas(t *testing.T) {
+	var list data.List
+	do(t, "POST", "/lists", `{"Title":"CI","Area":1}`, 201, &list)
+	do(t, "PUT", "/lists/"+itoa(list.ID)+"/items/3", `{"Position":1}`, 200, &list)
+	if len(list.Items) != 1 || list.Items[0].ID != 3 {
+		t.Error("expected item 3 in the list", list.Items)
+	}
+
+	var area Area
+	do(t, "GET", "/areas/1", "", 200, &area)
+	first := area.Things[0]
+	if first.Type != "list" || first.List == nil || first.List.ID != list.ID {
+		t.Error("new list should be at the top of area 1", first)
+	}
+	do(t, "PUT", "/areas/1/things", `{"Type":"item","ID":5,"Position":1}`, 200, &area)
+	first = area.Things[0]
+	if first.Type != "item" || first.Item == nil || first.Item.ID != 5 {
+		t.Error("item 5 should be at the top of area 1", first)
+	}
+	do(t, "PUT", "/areas/1/things", `{"Type":"thing","ID":5,"Position":1}`, 400, nil)
+
+	do(t, "POST", "/areas", `{"Title":"Second"}`, 201, &area)
+	do(t, "PUT", "/areas/"+itoa(area.ID), `{"Title":"Renamed"}`, 200, &area)
+	if area.Title != "Renamed" {
+		t.Error("area was not renamed", area)
+	}
+	do(t, "DELETE", "/areas/"+itoa(area.ID), "", 204, nil)
+	do(t, "GET", "/areas/"+itoa(area.ID), "", 404, nil)
+}
+
+func TestItemFields(t *testing.T) {
+	var area Area
+	do(t, "POST", "/areas", `{"Title":"Fields","Fields":[{"Name":"Points","Type":"number"}]}`, 201, &area)
+	if len(area.Fields) != 1 || area.Fields[0].ID == 0 || area.Fields[0].Type != data.FieldNumber {
+		t.Fatal("expected a number field", area.Fields)
+	}
+	points := itoa(area.Fields[0].ID)
+	do(t, "PUT", "/areas/"+itoa(area.ID), `{"Title":"Renamed"}`, 200, &area)
+	if len(area.Fields) != 1 {
+		t.Error("renaming the area should keep its fields", area.Fields)
+	}
+
+	var list data.List
+	do(t, "POST", "/lists", `{"Title":"Sprint","Area":`+itoa(area.ID)+`}`, 201, &list)
+	var a, b data.Item
+	do(t, "POST", "/items", `{"Title":"big","List":`+itoa(list.ID)+`,"Fields":{"`+points+`":"8"}}`, 201, &a)
+	do(t, "POST", "/items", `{"Title":"small","List":`+itoa(list.ID)+`,"Fields":{"`+points+`":"2"}}`, 201, &b)
+	do(t, "POST", "/items", `{"Title":"bad","List":`+itoa(list.ID)+`,"Fields":{"`+points+`":"many"}}`, 400, nil)
+
+	var page struct {
+		Page
+		Results []data.Item
+	}
+	do(t, "GET", "/items?list="+itoa(list.ID)+"&sort="+points, "", 200, &page)
+	if len(page.Results) != 2 || page.Results[0].ID != b.ID || page.Results[1].Fields[area.Fields[0].ID] != "8" {
+		t.Error("expected items sorted by points", page.Results)
+	}
+	do(t, "GET", "/items?list="+itoa(list.ID)+"&filter="+points+"&value=8", "", 200, &page)
+	if len(page.Results) != 1 || page.Results[0].ID != a.ID {
+		t.Error("expected only the big item", page.Results)
+	}
+	do(t, "GET", "/items?list="+itoa(list.ID)+"&sort=x", "", 400, nil)
+
+	update := `{"Version":0,"Title":"big","Fields":{"` + points + `":""}}`
+	var updated data.Item
+	do(t, "PUT", "/items/"+itoa(a.ID), update, 200, &updated)
+	if len(updated.Fields) != 0 {
+		t.Error("empty values should remove the field", updated.Fields)
+	}
+}
+
+func TestItemPriority(t *testing.T) {
+	var item data.Item
+	do(t, "POST", "/items", `{"Title":"outage","Priority":"urgent"}`, 201, &item)
+	if item.Priority != data.PriorityUrgent || item.Updated.IsZero() {
+		t.Error("expected an urgent item with an update time", item)
+	}
+	do(t, "POST", "/items", `{"Title":"outage","Priority":"asap"}`, 400, nil)
+	var updated data.Item
+	do(t, "PUT", "/items/"+itoa(item.ID), `{"Version":0,"Title":"outage","Priority":"low"}`, 200, &updated)
+	if updated.Priority != data.PriorityLow {
+		t.Error("expected the priority to be replaced", updated.Priority)
+	}
+}
+
+func TestFocus(t *testing.T) {
+	var item data.Item
+	do(t, "PUT", "/focus/5", `{"Focus":"watch"}`, 200, &item)
+	if item.Focus != data.FocusWatch {
+		t.Error("expected focus watch", item.Focus)
+	}
+	var focus data.FocusData
+	do(t, "GET", "/focus", "", 200, &focus)
+	found := false
+	for _, i := range focus.Watch {
+		found = found || i.ID == 5
+	}
+	if !found {
+		t.Error("item 5 should be watched", focus.Watch)
+	}
+	do(t, "PUT", "/focus/5", `{"Focus":"none"}`, 200, &item)
+	if item.Focus != data.FocusNone {
+		t.Error("expected focus none", item.Focus)
+	}
+	do(t, "PUT", "/focus/5", `{"Focus":"sometimes"}`, 400, nil)
+}
+
+func TestErrors(t *testing.T) {
+	do(t, "GET", "/items/abc", "", 400, nil)
+	do(t, "POST", "/items", `{"Title":`, 400, nil)
+	do(t, "POST", "/items", `{"Body":"no title"}`, 400, nil)
+	do(t, "GET", "/nothing", "", 404, nil)
+	do(t, "GET", "/users/1", "", 200, nil)
+	do(t, "GET", "/users/99", "", 404, nil)
+}
+
+func TestWebhooks(t *testing.T) {
+	var hook Webhook
+	body := `{"URL":"https://example.com/hook","Events":["item.completed"]}`
+	do(t, "POST", "/webhooks", body, 201, &hook)
+	if hook.ID == 0 || hook.Secret == "" {
+		t.Error("unexpected webhook", hook)
+	}
+	var got Webhook
+	do(t, "GET", "/webhooks/"+itoa(hook.ID), "", 200, &got)
+	if got.Secret != "" || got.URL != hook.URL {
+		t.Error("secret should only be returned on creation", got)
+	}
+	var log Page
+	do(t, "GET", "/webhooks/"+itoa(hook.ID)+"/deliveries", "", 200, &log)
+	if log.Total != 0 {
+		t.Error("there shouldn't be any deliveries", log)
+	}
+	do(t, "POST", "/webhooks", `{"URL":"https://example.com","Events":["nope"]}`, 400, nil)
+	do(t, "DELETE", "/webhooks/"+itoa(hook.ID), "", 204, nil)
+	do(t, "GET", "/webhooks/"+itoa(hook.ID), "", 404, nil)
+}
+
+func itoa(i int) string {
+	return strconv.Itoa(i)
+}
@@ -0,0 +1,179 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package api
+
+import (
+	"net/http"
+
+	"github.com/mbertschler/bunny/pkg/data"
+)
+
+// Area is the JSON representation of data.Area.
+type Area struct {
+	ID     int
+	Title  string
+	Body   string
+	Things []Thing      `json:",omitempty"`
+	Fields []data.Field `json:",omitempty"` // custom fields of the items
+}
+
+// Thing is either an item or a list inside of an area.
+type Thing struct {
+	Type string     // item or list
+	Item *data.Item `json:",omitempty"`
+	List *data.List `json:",omitempty"`
+}
+
+func toArea(a data.Area, things []data.Thing) Area {
+	out := Area{
+		ID:     a.ID,
+		Title:  a.Title,
+		Body:   a.Body,
+		Fields: a.Fields,
+	}
+	for _, t := range things {
+		switch t := t.(type) {
+		case data.Item:
+			out.Things = append(out.Things, Thing{Type: "item", Item: &t})
+		case data.List:
+			out.Things = append(out.Things, Thing{Type: "list", List: &t})
+		}
+	}
+	return out
+}
+
+// listAreas returns all areas without their things.
+func listAreas(r *http.Request) (interface{}, error) {
+	p, err := parsePagination(r)
+	if err != nil {
+		return nil, err
+	}
+	areas, err := data.Areas()
+	if err != nil {
+		return nil, err
+	}
+	out := []Area{}
+	for _, a := range areas {
+		out = append(out, toArea(a, nil))
+	}
+	start, end := p.bounds(len(out))
+	return p.page(len(out), out[start:end]), nil
+}
+
+func createArea(r *http.Request) (interface{}, error) {
+	var in Area
+	err := decodeBody(r, &in)
+	if err != nil {
+		return nil, err
+	}
+	if in.Title == "" {
+		return nil, invalid("Title is required")
+	}
+	area, err := data.NewArea(data.Area{
+		Title:  in.Title,
+		Body:   in.Body,
+		Fields: in.Fields,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created{toArea(area, nil)}, nil
+}
+
+// getArea returns the area with all of its things.
+func getArea(r *http.Request) (interface{}, error) {
+	id, err := intParam(r, "id")
+	if err != nil {
+		return nil, err
+	}
+	area, things, err := data.UserArea(user(r), id)
+	if err != nil {
+		return nil, err
+	}
+	return toArea(area, things), nil
+}
+
+// updateArea replaces title and body of the area.
+func updateArea(r *http.Request) (interface{}, error) {
+	id, err := intParam(r, "id")
+	if err != nil {
+		return nil, err
+	}
+	var in Area
+	err = decodeBody(r, &in)
+	if err != nil {
+		return nil, err
+	}
+	if in.Title == "" {
+		return nil, invalid("Title is required")
+	}
+	err = data.SetArea(data.Area{
+		ID:    id,
+		Title: in.Title,
+		Body:  in.Body,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return getArea(r)
+}
+
+func deleteArea(r *http.Request) (interface{}, error) {
+	id, err := intParam(r, "id")
+	if err != nil {
+		return nil, err
+	}
+	return nil, data.DeleteArea(id)
+}
+
+type thingPosition struct {
+	Type     string // item or list
+	ID       int
+	Position int // 1 is the top
+}
+
+// moveAreaThing adds the item or list to the area or moves
+// it to a new position if it is already in the area.
+func moveAreaThing(r *http.Request) (interface{}, error) {
+	id, err := intParam(r, "id")
+	if err != nil {
+		return nil, err
+	}
+	var in thingPosition
+	err = decodeBody(r, &in)
+	if err != nil {
+		return nil, err
+	}
+	if in.Position < 1 {
+		return nil, invalid("Position has to be at least 1")
+	}
+	var typ data.ThingType
+	switch in.Type {
+	case "item":
+		typ = data.TypeItem
+		_, err = data.ItemByID(in.ID)
+	case "list":
+		typ = data.TypeList
+		_, err = data.ListByID(in.ID)
+	default:
+		return nil, invalid("Type has to be item or list")
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = data.SetAreaThingPosition(id, typ, in.ID, in.Position)
+	if err != nil {
+		return nil, err
+	}
+	return getArea(r)
+}
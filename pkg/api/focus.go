@@ -0,0 +1,44 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package api
+
+import (
+	"net/http"
+
+	"github.com/mbertschler/bunny/pkg/data"
+)
+
+func getFocus(r *http.Request) (interface{}, error) {
+	return data.FocusList(user(r))
+}
+
+type focus struct {
+	Focus data.FocusState // none, now, later or watch
+}
+
+func setFocus(r *http.Request) (interface{}, error) {
+	item, err := intParam(r, "item")
+	if err != nil {
+		return nil, err
+	}
+	var in focus
+	err = decodeBody(r, &in)
+	if err != nil {
+		return nil, err
+	}
+	err = data.SetFocus(user(r), item, in.Focus)
+	if err != nil {
+		return nil, err
+	}
+	return data.UserItemByID(user(r), item)
+}
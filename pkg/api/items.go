@@ -0,0 +1,245 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mbertschler/bunny/pkg/data"
+)
+
+// listItems returns all items. They can be filtered with the
+// query parameters state (open, complete or archived), list (ID
+// of the containing list) and q (text in the title or body).
+// Items of a list can also be sorted by the custom field with the
+// ID sort (descending if desc is true) and filtered by the custom
+// field with the ID filter and the value.
+func listItems(r *http.Request) (interface{}, error) {
+	p, err := parsePagination(r)
+	if err != nil {
+		return nil, err
+	}
+	q := r.URL.Query()
+	var items []data.Item
+	if str := q.Get("list"); str != "" {
+		list, err := strconv.Atoi(str)
+		if err != nil {
+			return nil, invalid("list has to be a number")
+		}
+		view, err := listView(r)
+		if err != nil {
+			return nil, err
+		}
+		if view.Active() {
+			_, _, items, err = data.UserListView(user(r), list, view)
+		} else {
+			items, err = data.UserItemList(user(r), list)
+		}
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		items, err = userItems(user(r))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if str := q.Get("state"); str != "" {
+		state, err := data.ParseItemState(str)
+		if err != nil {
+			return nil, err
+		}
+		items = filterItems(items, func(i data.Item) bool {
+			return i.State == state
+		})
+	}
+	if str := q.Get("q"); str != "" {
+		str = strings.ToLower(str)
+		items = filterItems(items, func(i data.Item) bool {
+			return strings.Contains(strings.ToLower(i.Title), str) ||
+				strings.Contains(strings.ToLower(i.Body), str)
+		})
+	}
+	start, end := p.bounds(len(items))
+	return p.page(len(items), items[start:end]), nil
+}
+
+// listView reads the sort and filter parameters of listItems.
+func listView(r *http.Request) (data.ListView, error) {
+	var v data.ListView
+	q := r.URL.Query()
+	for _, p := range []struct {
+		name string
+		id   *int
+	}{{"sort", &v.Sort}, {"filter", &v.Filter}} {
+		str := q.Get(p.name)
+		if str == "" {
+			continue
+		}
+		id, err := strconv.Atoi(str)
+		if err != nil || id < 1 {
+			return v, invalid(p.name + " has to be a field ID")
+		}
+		*p.id = id
+	}
+	v.Desc = q.Get("desc") == "true"
+	v.Value = q.Get("value")
+	return v, nil
+}
+
+// userItems returns all items with the focus of the user.
+func userItems(user int) ([]data.Item, error) {
+	items, err := data.Items()
+	if err != nil {
+		return nil, err
+	}
+	focus, err := data.FocusList(user)
+	if err != nil {
+		return nil, err
+	}
+	focusByID := map[int]data.FocusState{}
+	for _, list := range [][]data.Item{focus.Focus, focus.Later, focus.Watch} {
+		for _, i := range list {
+			focusByID[i.ID] = i.Focus
+		}
+	}
+	for i := range items {
+		items[i].Focus = focusByID[items[i].ID]
+	}
+	return items, nil
+}
+
+func filterItems(in []data.Item, keep func(data.Item) bool) []data.Item {
+	out := []data.Item{}
+	for _, i := range in {
+		if keep(i) {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+type newItem struct {
+	Title string
+	Body  string
+	State data.ItemState
+	Due   time.Time
+	List  int // defaults to list 1
+
+	Priority data.Priority
+
+	// values of custom fields by field ID
+	Fields map[int]string
+}
+
+func createItem(r *http.Request) (interface{}, error) {
+	var in newItem
+	err := decodeBody(r, &in)
+	if err != nil {
+		return nil, err
+	}
+	if in.Title == "" {
+		return nil, invalid("Title is required")
+	}
+	if in.List == 0 {
+		in.List = 1
+	}
+	item := data.Item{
+		Title:    in.Title,
+		Body:     in.Body,
+		State:    in.State,
+		Due:      in.Due,
+		Priority: in.Priority,
+	}
+	if len(in.Fields) > 0 {
+		fields, err := data.ListFields(in.List)
+		if err != nil {
+			return nil, err
+		}
+		err = item.SetFields(fields, in.Fields)
+		if err != nil {
+			return nil, err
+		}
+	}
+	item, err = data.NewListItem(in.List, item)
+	if err != nil {
+		return nil, err
+	}
+	item, err = data.UserItemByID(user(r), item.ID)
+	if err != nil {
+		return nil, err
+	}
+	return created{item}, nil
+}
+
+func getItem(r *http.Request) (interface{}, error) {
+	id, err := intParam(r, "id")
+	if err != nil {
+		return nil, err
+	}
+	return data.UserItemByID(user(r), id)
+}
+
+// updateItem replaces title, body, state, due date and priority of the
+// item and sets the values of the custom fields in Fields. The Version
+// has to match the stored item, otherwise it fails with 409 Conflict.
+func updateItem(r *http.Request) (interface{}, error) {
+	id, err := intParam(r, "id")
+	if err != nil {
+		return nil, err
+	}
+	var in data.Item
+	err = decodeBody(r, &in)
+	if err != nil {
+		return nil, err
+	}
+	if in.Title == "" {
+		return nil, invalid("Title is required")
+	}
+	item, err := data.ItemByID(id)
+	if err != nil {
+		return nil, err
+	}
+	item.Version = in.Version
+	item.Title = in.Title
+	item.Body = in.Body
+	item.State = in.State
+	item.Due = in.Due
+	item.Priority = in.Priority
+	if len(in.Fields) > 0 {
+		fields, err := data.ItemFields(id)
+		if err != nil {
+			return nil, err
+		}
+		err = item.SetFields(fields, in.Fields)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = data.SetItem(item)
+	if err != nil {
+		return nil, err
+	}
+	return data.UserItemByID(user(r), id)
+}
+
+func deleteItem(r *http.Request) (interface{}, error) {
+	id, err := intParam(r, "id")
+	if err != nil {
+		return nil, err
+	}
+	return nil, data.DeleteItem(id)
+}
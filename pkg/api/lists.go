@@ -0,0 +1,166 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package api
+
+import (
+	"net/http"
+
+	"github.com/mbertschler/bunny/pkg/data"
+)
+
+// listLists returns all lists without their items. They can be
+// filtered with the query parameter state.
+func listLists(r *http.Request) (interface{}, error) {
+	p, err := parsePagination(r)
+	if err != nil {
+		return nil, err
+	}
+	lists, err := data.Lists()
+	if err != nil {
+		return nil, err
+	}
+	if str := r.URL.Query().Get("state"); str != "" {
+		state, err := data.ParseItemState(str)
+		if err != nil {
+			return nil, err
+		}
+		filtered := []data.List{}
+		for _, l := range lists {
+			if l.State == state {
+				filtered = append(filtered, l)
+			}
+		}
+		lists = filtered
+	}
+	start, end := p.bounds(len(lists))
+	return p.page(len(lists), lists[start:end]), nil
+}
+
+type newList struct {
+	Title string
+	Body  string
+	State data.ItemState
+	Area  int // optional area that the list is added to
+}
+
+func createList(r *http.Request) (interface{}, error) {
+	var in newList
+	err := decodeBody(r, &in)
+	if err != nil {
+		return nil, err
+	}
+	if in.Title == "" {
+		return nil, invalid("Title is required")
+	}
+	list := data.List{
+		Title: in.Title,
+		Body:  in.Body,
+		State: in.State,
+	}
+	if in.Area == 0 {
+		list, err = data.NewList(list)
+	} else {
+		list, err = data.NewAreaList(in.Area, list)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return created{list}, nil
+}
+
+// getList returns the list with all of its items.
+func getList(r *http.Request) (interface{}, error) {
+	id, err := intParam(r, "id")
+	if err != nil {
+		return nil, err
+	}
+	list, err := data.ListByID(id)
+	if err != nil {
+		return nil, err
+	}
+	list.Items, err = data.UserItemList(user(r), id)
+	return list, err
+}
+
+// updateList replaces title, body and state of the list. The
+// Version has to match the stored list, otherwise it fails with
+// 409 Conflict.
+func updateList(r *http.Request) (interface{}, error) {
+	id, err := intParam(r, "id")
+	if err != nil {
+		return nil, err
+	}
+	var in data.List
+	err = decodeBody(r, &in)
+	if err != nil {
+		return nil, err
+	}
+	if in.Title == "" {
+		return nil, invalid("Title is required")
+	}
+	list, err := data.ListByID(id)
+	if err != nil {
+		return nil, err
+	}
+	list.Version = in.Version
+	list.Title = in.Title
+	list.Body = in.Body
+	list.State = in.State
+	err = data.SetList(list)
+	if err != nil {
+		return nil, err
+	}
+	return data.ListByID(id)
+}
+
+func deleteList(r *http.Request) (interface{}, error) {
+	id, err := intParam(r, "id")
+	if err != nil {
+		return nil, err
+	}
+	return nil, data.DeleteList(id)
+}
+
+type position struct {
+	Position int // 1 is the top
+}
+
+// moveListItem adds the item to the list or moves it
+// to a new position if it is already in the list.
+func moveListItem(r *http.Request) (interface{}, error) {
+	id, err := intParam(r, "id")
+	if err != nil {
+		return nil, err
+	}
+	item, err := intParam(r, "item")
+	if err != nil {
+		return nil, err
+	}
+	var in position
+	err = decodeBody(r, &in)
+	if err != nil {
+		return nil, err
+	}
+	if in.Position < 1 {
+		return nil, invalid("Position has to be at least 1")
+	}
+	_, err = data.ItemByID(item)
+	if err != nil {
+		return nil, err
+	}
+	err = data.SetListItemPosition(id, item, in.Position)
+	if err != nil {
+		return nil, err
+	}
+	return getList(r)
+}
@@ -0,0 +1,80 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultLimit = 50
+	maxLimit     = 500
+)
+
+// Page is returned by all endpoints that list resources.
+type Page struct {
+	Total   int         // number of results before pagination
+	Offset  int         // index of the first result
+	Limit   int         // maximum number of results
+	Results interface{} // slice of resources
+}
+
+type pagination struct {
+	offset int
+	limit  int
+}
+
+// parsePagination reads the offset and limit query parameters.
+func parsePagination(r *http.Request) (pagination, error) {
+	p := pagination{limit: defaultLimit}
+	q := r.URL.Query()
+	if str := q.Get("offset"); str != "" {
+		n, err := strconv.Atoi(str)
+		if err != nil || n < 0 {
+			return p, invalid("offset has to be a positive number")
+		}
+		p.offset = n
+	}
+	if str := q.Get("limit"); str != "" {
+		n, err := strconv.Atoi(str)
+		if err != nil || n < 1 || n > maxLimit {
+			return p, invalid("limit has to be between 1 and " + strconv.Itoa(maxLimit))
+		}
+		p.limit = n
+	}
+	return p, nil
+}
+
+// bounds returns the slice bounds of the page for n results.
+func (p pagination) bounds(n int) (int, int) {
+	start := p.offset
+	if start > n {
+		start = n
+	}
+	end := start + p.limit
+	if end > n {
+		end = n
+	}
+	return start, end
+}
+
+func (p pagination) page(total int, results interface{}) Page {
+	return Page{
+		Total:   total,
+		Offset:  p.offset,
+		Limit:   p.limit,
+		Results: results,
+	}
+}
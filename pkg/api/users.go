@@ -0,0 +1,40 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package api
+
+import (
+	"net/http"
+
+	"github.com/mbertschler/bunny/pkg/data"
+)
+
+func listUsers(r *http.Request) (interface{}, error) {
+	p, err := parsePagination(r)
+	if err != nil {
+		return nil, err
+	}
+	users, err := data.Users()
+	if err != nil {
+		return nil, err
+	}
+	start, end := p.bounds(len(users))
+	return p.page(len(users), users[start:end]), nil
+}
+
+func getUser(r *http.Request) (interface{}, error) {
+	id, err := intParam(r, "id")
+	if err != nil {
+		return nil, err
+	}
+	return data.UserByID(id)
+}
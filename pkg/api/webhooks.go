@@ -0,0 +1,124 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/mbertschler/bunny/pkg/data"
+)
+
+// Webhook is the JSON representation of a data.Webhook. The secret
+// is only included in the response that created the webhook.
+type Webhook struct {
+	ID      int
+	URL     string
+	Events  []string
+	Secret  string `json:",omitempty"`
+	Created time.Time
+}
+
+func toWebhook(w data.Webhook) Webhook {
+	return Webhook{
+		ID:      w.ID,
+		URL:     w.URL,
+		Events:  w.Events,
+		Created: w.Created,
+	}
+}
+
+func listWebhooks(r *http.Request) (interface{}, error) {
+	p, err := parsePagination(r)
+	if err != nil {
+		return nil, err
+	}
+	hooks, err := data.UserWebhooks(user(r))
+	if err != nil {
+		return nil, err
+	}
+	out := []Webhook{}
+	for _, w := range hooks {
+		out = append(out, toWebhook(w))
+	}
+	start, end := p.bounds(len(out))
+	return p.page(len(out), out[start:end]), nil
+}
+
+type newWebhook struct {
+	URL    string
+	Events []string
+	Secret string // optional, generated if empty
+}
+
+func createWebhook(r *http.Request) (interface{}, error) {
+	var in newWebhook
+	err := decodeBody(r, &in)
+	if err != nil {
+		return nil, err
+	}
+	w, err := data.NewWebhook(data.Webhook{
+		User:   user(r),
+		URL:    in.URL,
+		Events: in.Events,
+		Secret: in.Secret,
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := toWebhook(w)
+	out.Secret = w.Secret
+	return created{out}, nil
+}
+
+func getWebhook(r *http.Request) (interface{}, error) {
+	id, err := intParam(r, "id")
+	if err != nil {
+		return nil, err
+	}
+	w, err := data.UserWebhookByID(user(r), id)
+	if err != nil {
+		return nil, err
+	}
+	return toWebhook(w), nil
+}
+
+func deleteWebhook(r *http.Request) (interface{}, error) {
+	id, err := intParam(r, "id")
+	if err != nil {
+		return nil, err
+	}
+	return nil, data.DeleteWebhook(user(r), id)
+}
+
+// listDeliveries returns the delivery log of a webhook, newest first.
+func listDeliveries(r *http.Request) (interface{}, error) {
+	id, err := intParam(r, "id")
+	if err != nil {
+		return nil, err
+	}
+	p, err := parsePagination(r)
+	if err != nil {
+		return nil, err
+	}
+	log, err := data.WebhookDeliveries(user(r), id)
+	if err != nil {
+		return nil, err
+	}
+	if log == nil {
+		log = []data.Delivery{}
+	}
+	start, end := p.bounds(len(log))
+	return p.page(len(log), log[start:end]), nil
+}
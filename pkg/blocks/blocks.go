@@ -22,7 +22,7 @@ import (
 )
 
 func menuBlock() html.Block {
-	return html.Div(html.Class("ui two item menu"),
+	return html.Div(html.Class("ui four item menu"),
 		// html.A(append(html.Class("item"),
 		// 	html.AttrPair{Key: "onclick", Value: "listView()"}),
 		// 	html.I(html.Class("comments purple icon")),
@@ -35,6 +35,14 @@ func menuBlock() html.Block {
 			html.AttrPair{Key: "onclick", Value: "listView()"}),
 			html.I(html.Class("clone violet icon")),
 			html.Text("Workspace")),
+		html.A(append(html.Class("item"),
+			html.AttrPair{Key: "onclick", Value: "timesheetView('')"}),
+			html.I(html.Class("clock outline teal icon")),
+			html.Text("Timesheet")),
+		html.A(append(html.Class("item"),
+			html.AttrPair{Key: "onclick", Value: "settingsView()"}),
+			html.I(html.Class("settings grey icon")),
+			html.Text("Settings")),
 	)
 }
 
@@ -49,6 +57,11 @@ func listItemBlock(item data.Thing) html.Block {
 }
 
 func itemBlock(item data.Item) html.Block {
+	return itemRowBlock(item, nil)
+}
+
+// itemRowBlock is itemBlock with a label after the title.
+func itemRowBlock(item data.Item, label html.Block) html.Block {
 	var iconClass string
 	switch item.State {
 	case data.ItemOpen:
@@ -67,12 +80,19 @@ func itemBlock(item data.Item) html.Block {
 		focusIcon = html.I(html.Class("large middle aligned icon " + focusWatchIcon).Styles("padding-left:10px"))
 	}
 
+	var priorityIcon html.Block
+	if color, ok := priorityColors[item.Priority]; ok {
+		priorityIcon = html.I(html.Class("middle aligned flag icon " + color).Styles("padding-left:10px"))
+	}
+
 	return html.Div(append(html.Class("item").Data("item-id", item.ID),
 		html.AttrPair{Key: "onclick", Value: fmt.Sprintf("itemView(%d)", item.ID)}),
 		html.I(html.Class("large middle aligned icon "+iconClass)),
 		focusIcon,
+		priorityIcon,
 		html.Div(html.Class("middle aligned content").Styles("color:rgba(0,0,0,0.87)"),
 			html.Text(item.Title),
+			label,
 		),
 	)
 }
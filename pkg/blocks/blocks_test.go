@@ -14,9 +14,14 @@
 package blocks
 
 import (
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/mbertschler/blocks/html"
+	"github.com/mbertschler/bunny/pkg/data"
+	"github.com/mbertschler/bunny/pkg/importer"
 )
 
 func TestLayout(t *testing.T) {
@@ -30,3 +35,158 @@ func testRender(t *testing.T, block html.Block) {
 		t.Error(err)
 	}
 }
+
+func TestSettingsPage(t *testing.T) {
+	tokens := []data.Token{
+		{ID: 1, Name: "script", Scope: data.ScopeRead},
+		{ID: 2, Name: "sync", Scope: data.ScopeWrite, Expires: time.Now()},
+	}
+	testRender(t, ViewSettingsPage(tokens, "bunny_secret"))
+	testRender(t, ViewSettingsPage(nil, ""))
+}
+
+func TestImportPage(t *testing.T) {
+	res := &importer.Result{
+		Format: importer.FormatMarkdown,
+		Areas: []importer.Area{{
+			Title: "Moving",
+			Lists: []importer.List{{Title: "Packing", Items: []importer.Item{{Title: "Books"}}}},
+			Items: []importer.Item{{Title: "Van", State: data.ItemComplete}},
+		}},
+		Skipped:  map[string]int{"lines": 2},
+		Warnings: []string{"something"},
+	}
+	testRender(t, ViewImportPage(res, false))
+	testRender(t, ViewImportPage(res, true))
+}
+
+func TestPagedLists(t *testing.T) {
+	items := []data.Item{{ID: 1, Title: "one"}, {ID: 2, Title: "two", Focus: data.FocusNow}}
+	testRender(t, ViewListPage(1, nil, data.ListView{}, data.ItemPage{Items: items, Next: "i2"}))
+	testRender(t, ViewListPage(1, nil, data.ListView{}, data.ItemPage{}))
+	things := []data.Thing{items[0], data.List{ID: 3, Title: "list"}}
+	testRender(t, ViewAreaPage(1, data.OrderManual, data.ThingPage{Things: things}))
+	testRender(t, ViewAreaPage(1, data.OrderDue, data.ThingPage{Things: things}))
+
+	action := moreAction("listMore", 4, "i12", true)
+	if action != "listMore(4, 'i12', true)" {
+		t.Error("load more button calls", action)
+	}
+	if MoreButton("listMore", 4, "", false) != nil {
+		t.Error("there should be no button after the last page")
+	}
+}
+
+func TestFieldPages(t *testing.T) {
+	fields := []data.Field{
+		{ID: 1, Name: "Estimate", Type: data.FieldNumber},
+		{ID: 2, Name: "Stage", Type: data.FieldSelect, Options: []string{"todo", "done"}},
+		{ID: 3, Name: "Link", Type: data.FieldURL},
+		{ID: 4, Name: "Start", Type: data.FieldDate},
+		{ID: 5, Name: "Notes", Type: data.FieldText},
+	}
+	item := data.Item{ID: 1, Title: "one", Fields: map[int]string{1: "3", 2: "done", 3: "https://example.com"}}
+	testRender(t, EditItemPage(item, fields, false))
+	testRender(t, ConflictItemPage(item, data.Item{ID: 1}, fields))
+	testRender(t, ViewItemPage(item, fields))
+	testRender(t, ViewItemPage(item, nil))
+	view := data.ListView{Sort: 1, Desc: true, Filter: 2, Value: "done"}
+	testRender(t, ViewListPage(1, fields, view, data.ItemPage{Items: []data.Item{item}}))
+	testRender(t, ViewAreaFieldsPage(data.Area{ID: 1, Title: "area", Fields: fields}))
+	testRender(t, ViewAreaFieldsPage(data.Area{ID: 1, Title: "area"}))
+
+	out, err := html.RenderString(fieldInput(fields[1], "done"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, `value="done" selected="selected"`) {
+		t.Error("the current option should be selected", out)
+	}
+}
+
+func TestPriorityAndOrder(t *testing.T) {
+	out, err := html.RenderString(itemBlock(data.Item{ID: 1, Priority: data.PriorityUrgent}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "flag icon red") {
+		t.Error("urgent items should have a red flag", out)
+	}
+	out, err = html.RenderString(itemBlock(data.Item{ID: 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out, "flag icon") {
+		t.Error("items without priority should have no flag", out)
+	}
+	testRender(t, ViewItemPage(data.Item{ID: 1, Priority: data.PriorityLow}, nil))
+
+	for order, sortable := range map[data.ViewOrder]string{
+		data.OrderManual:  `data-sortable="true"`,
+		data.OrderUpdated: `data-sortable="false"`,
+	} {
+		out, err = html.RenderString(ViewListPage(1, nil, data.ListView{Order: order}, data.ItemPage{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out, sortable) {
+			t.Errorf("order %d should render %s", order, sortable)
+		}
+	}
+}
+
+func TestTimesheetPage(t *testing.T) {
+	week := data.WeekStart(time.Now())
+	ts := data.Timesheet{
+		Week: week,
+		Rows: []data.TimesheetRow{
+			{Area: data.Area{ID: 1, Title: "Work"}, List: data.List{ID: 2, Title: "Reports"}, Total: 90 * time.Minute},
+			{Total: time.Hour},
+		},
+		Total: data.TimesheetRow{Total: 150 * time.Minute},
+		Entries: []data.TimeEntry{
+			{ID: 1, Item: 1, Start: week, End: week.Add(90 * time.Minute)},
+			{ID: 2, Item: 2, Start: week.Add(2 * time.Hour)},
+		},
+		Items: map[int]data.Item{1: {ID: 1, Title: "Write report"}},
+	}
+	ts.Rows[0].Days[0] = 90 * time.Minute
+	out, err := html.RenderString(ViewTimesheetPage(ts, []data.Item{{ID: 1, Title: "Write report"}}, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Work / Reports", "No area", "1:30", "2:30", "Deleted item", "entrySave(1)", "entryEdit(2)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("timesheet should contain %q", want)
+		}
+	}
+	testRender(t, ViewTimesheetPage(data.Timesheet{Week: week}, nil, 0))
+}
+
+func TestFocusPage(t *testing.T) {
+	focus := data.FocusData{Focus: []data.Item{{ID: 1, Title: "Write report"}}}
+	p := data.Pomodoro{Work: 25 * time.Minute, Break: 5 * time.Minute, Counts: map[int]int{1: 3}}
+	out, err := html.RenderString(ViewFocusPage(focus, p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "25:00") || !strings.Contains(out, "3 pomodoros") || !strings.Contains(out, "pomodoroStart()") {
+		t.Error("expected the idle pomodoro of the item", out)
+	}
+	now := time.Now()
+	p.Session = &data.PomodoroSession{Item: 1, Start: now, End: now.Add(10 * time.Minute)}
+	out, err = html.RenderString(ViewFocusPage(focus, p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, fmt.Sprintf(`data-end="%d"`, p.Session.End.Unix())) || !strings.Contains(out, "pomodoroStop()") {
+		t.Error("expected the running pomodoro", out)
+	}
+	out, err = html.RenderString(ViewFocusPage(data.FocusData{}, data.Pomodoro{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out, "pomodoro") {
+		t.Error("no pomodoro without an item in focus now", out)
+	}
+}
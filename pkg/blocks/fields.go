@@ -0,0 +1,225 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blocks
+
+import (
+	"encoding"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mbertschler/blocks/html"
+	"github.com/mbertschler/bunny/pkg/data"
+)
+
+// fieldInputs are the inputs of the custom fields in the item form.
+// app.js collects the values of all .itemField elements by their
+// data-field-id.
+func fieldInputs(fields []data.Field, values map[int]string) html.Block {
+	var out html.Blocks
+	for _, f := range fields {
+		out.Add(html.Div(html.Class("inline field"),
+			html.Label(nil, html.Text(f.Name)),
+			fieldInput(f, values[f.ID]),
+		))
+	}
+	return out
+}
+
+func fieldInput(f data.Field, value string) html.Block {
+	attr := html.Class("itemField").Data("field-id", f.ID)
+	switch f.Type {
+	case data.FieldSelect:
+		options := html.Blocks{selectOption("", "None", value)}
+		for _, o := range f.Options {
+			options.Add(selectOption(o, o, value))
+		}
+		return html.Select(append(attr, html.Class("ui dropdown")...), options)
+	case data.FieldNumber:
+		attr = attr.Type("number").Value(value)
+		attr = append(attr, html.AttrPair{Key: "step", Value: "any"})
+	case data.FieldDate:
+		attr = attr.Type("date").Value(value)
+	case data.FieldURL:
+		attr = attr.Type("url").Value(value)
+		attr = append(attr, html.AttrPair{Key: "placeholder", Value: "https://"})
+	default:
+		attr = attr.Type("text").Value(value)
+	}
+	return html.Input(attr)
+}
+
+func selectOption(value, text, selected string) html.Block {
+	attr := html.Attr{{Key: "value", Value: value}}
+	if value == selected {
+		attr = append(attr, html.AttrPair{Key: "selected", Value: "selected"})
+	}
+	return html.Option(attr, html.Text(text))
+}
+
+// fieldValuesBlock shows the fields of the item that have a value.
+func fieldValuesBlock(fields []data.Field, values map[int]string) html.Block {
+	var rows html.Blocks
+	for _, f := range fields {
+		value, ok := values[f.ID]
+		if !ok {
+			continue
+		}
+		rows.Add(html.Tr(nil,
+			html.Td(html.Class("collapsing"), html.Text(f.Name)),
+			html.Td(nil, fieldValue(f, value)),
+		))
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return html.Table(html.Class("ui very basic compact table"), rows)
+}
+
+func fieldValue(f data.Field, value string) html.Block {
+	if f.Type == data.FieldURL {
+		return html.A(append(html.Href(value), html.AttrPair{Key: "target", Value: "_blank"}),
+			html.Text(value))
+	}
+	return html.Text(value)
+}
+
+// listViewBlock lets the user sort and filter the list by the custom
+// fields. Nothing is shown if the list has no fields.
+func listViewBlock(list int, fields []data.Field, view data.ListView) html.Block {
+	if len(fields) == 0 {
+		return nil
+	}
+	sortOptions := html.Blocks{selectOption("0", "No sort field", strconv.Itoa(view.Sort))}
+	filterOptions := html.Blocks{selectOption("0", "All items", strconv.Itoa(view.Filter))}
+	for _, f := range fields {
+		id := strconv.Itoa(f.ID)
+		sortOptions.Add(selectOption(id, "Sort by "+f.Name, strconv.Itoa(view.Sort)))
+		filterOptions.Add(selectOption(id, f.Name+" is", strconv.Itoa(view.Filter)))
+	}
+	return html.Div(html.Class("ui form").Styles("padding-top:15px"),
+		html.Div(html.Class("four fields"),
+			html.Div(html.Class("field"),
+				html.Select(html.Class("ui dropdown listViewForm").Name("Sort"), sortOptions),
+			),
+			html.Div(html.Class("field"),
+				html.Select(html.Class("ui dropdown listViewForm").Name("Desc"),
+					selectOption("false", "Ascending", strconv.FormatBool(view.Desc)),
+					selectOption("true", "Descending", strconv.FormatBool(view.Desc)),
+				),
+			),
+			html.Div(html.Class("field"),
+				html.Select(html.Class("ui dropdown listViewForm").Name("Filter"), filterOptions),
+			),
+			html.Div(html.Class("field"),
+				html.Input(append(html.Class("listViewForm").Name("Value").Type("text").Value(view.Value),
+					html.AttrPair{Key: "placeholder", Value: "Filter value"})),
+			),
+		),
+		html.Button(append(html.Class("ui compact button"),
+			html.AttrPair{Key: "onclick", Value: fmt.Sprintf("listFilter(%d)", list)}),
+			html.Text("Apply")),
+		html.Button(append(html.Class("ui basic compact button"),
+			html.AttrPair{Key: "onclick", Value: fmt.Sprintf("listView(%d)", list)}),
+			html.Text("Reset")),
+	)
+}
+
+// viewItemsBlock renders the items of an active list view. The
+// value of the sort field is shown next to the title.
+func viewItemsBlock(items []data.Item, view data.ListView) html.Block {
+	var out html.Blocks
+	for _, item := range items {
+		var label html.Block
+		if value := item.Fields[view.Sort]; view.Sort != 0 && value != "" {
+			label = html.Div(html.Class("ui basic label"), html.Text(value))
+		}
+		out.Add(itemRowBlock(item, label))
+	}
+	return out
+}
+
+// ViewAreaFieldsPage lets the user add and delete the custom
+// fields of the area.
+func ViewAreaFieldsPage(area data.Area) html.Block {
+	return html.Div(html.Class("ui text container"),
+		menuBlock(),
+		gridColumnBlock(
+			floatedIconButton("left", "areaView()", "chevron left", "Area"),
+		),
+		html.H2(nil, html.Text("Fields of "+area.Title)),
+		fieldTableBlock(area),
+		html.H3(nil, html.Text("New field")),
+		fieldFormBlock(area.ID),
+	)
+}
+
+func fieldTableBlock(area data.Area) html.Block {
+	if len(area.Fields) == 0 {
+		return html.P(nil, html.Text("This area has no custom fields yet."))
+	}
+	rows := html.Blocks{
+		html.Tr(nil,
+			html.Th(nil, html.Text("Name")),
+			html.Th(nil, html.Text("Type")),
+			html.Th(nil, html.Text("Options")),
+			html.Th(nil),
+		),
+	}
+	for _, f := range area.Fields {
+		rows.Add(html.Tr(nil,
+			html.Td(nil, html.Text(f.Name)),
+			html.Td(nil, html.Text(textName(f.Type))),
+			html.Td(nil, html.Text(strings.Join(f.Options, ", "))),
+			html.Td(nil, compactIconButton("red",
+				fmt.Sprintf("fieldDelete(%d, %d)", area.ID, f.ID), "trash", "Delete")),
+		))
+	}
+	return html.Table(html.Class("ui compact table"), rows)
+}
+
+func fieldFormBlock(area int) html.Block {
+	types := html.Blocks{}
+	for _, t := range []data.FieldType{data.FieldText, data.FieldNumber, data.FieldDate, data.FieldSelect, data.FieldURL} {
+		name := textName(t)
+		types.Add(html.Option(html.Attr{{Key: "value", Value: name}}, html.Text(name)))
+	}
+	return html.Div(html.Class("ui form"),
+		html.Div(html.Class("three fields"),
+			html.Div(html.Class("field"),
+				html.Input(append(html.Class("fieldForm").Name("Name").Type("text"),
+					html.AttrPair{Key: "placeholder", Value: "Field name"})),
+			),
+			html.Div(html.Class("field"),
+				html.Select(html.Class("ui dropdown fieldForm").Name("Type"), types),
+			),
+			html.Div(html.Class("field"),
+				html.Input(append(html.Class("fieldForm").Name("Options").Type("text"),
+					html.AttrPair{Key: "placeholder", Value: "Options of select fields, comma separated"})),
+			),
+		),
+		html.Button(append(html.Class("ui positive button"),
+			html.AttrPair{Key: "onclick", Value: fmt.Sprintf("fieldAdd(%d)", area)}),
+			html.Text("Add field")),
+	)
+}
+
+// textName returns the name of an enum like data.FieldType.
+func textName(v encoding.TextMarshaler) string {
+	name, err := v.MarshalText()
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(name)
+}
@@ -48,6 +48,7 @@ func layoutBody(content html.Block) html.Block {
 		html.Script(html.Src("/static/jquery/dist/jquery.min.js")),
 		html.Script(html.Src("/static/semantic-ui-css/semantic.min.js")),
 		html.Script(html.Src("/static/sortablejs/Sortable.min.js")),
+		html.Script(html.Src("/js/guiapi.js")),
 		html.Script(html.Src("/js/app.js")),
 	)
 }
@@ -15,17 +15,21 @@ package blocks
 
 import (
 	"fmt"
+	"net/http"
+	"strings"
 
 	"github.com/mbertschler/blocks/html"
 	"github.com/mbertschler/bunny/pkg/data"
 )
 
-func EditItemPage(data data.Item, new bool) html.Block {
+// EditItemPage is the form for the item and the values of its
+// custom fields.
+func EditItemPage(data data.Item, fields []data.Field, new bool) html.Block {
 	cancelFunc := fmt.Sprintf("itemView(%d)", data.ID)
 	if new {
 		cancelFunc = "listView()"
 	}
-	saveFunc := fmt.Sprintf("itemSave(%d, %t)", data.ID, new)
+	saveFunc := fmt.Sprintf("itemSave(%d, %t, %d)", data.ID, new, data.Version)
 	return html.Div(html.Class("ui text container"),
 		gridColumnBlock(
 			floatedButton("positive right", saveFunc, "Save"),
@@ -37,6 +41,11 @@ func EditItemPage(data data.Item, new bool) html.Block {
 					html.AttrPair{Key: "placeholder", Value: "Item title"})),
 			),
 			html.Div(html.Class("ui divider")),
+			html.Div(html.Class("inline field"),
+				html.Label(nil, html.Text("Due")),
+				html.Input(html.Class("itemForm").Name("Due").Type("date").Value(dueValue(data))),
+			),
+			fieldInputs(fields, data.Fields),
 			html.Div(html.Class("field"),
 				html.Textarea(append(html.Class("itemForm").Name("Body").Styles("font:inherit;"),
 					html.AttrPair{Key: "placeholder", Value: "Item description"},
@@ -48,7 +57,42 @@ func EditItemPage(data data.Item, new bool) html.Block {
 	)
 }
 
-func ViewItemPage(d data.Item) html.Block {
+// ConflictItemPage is shown when mine was saved on top of a version
+// that was changed in the meantime. The form starts out with mine and
+// saving it overwrites theirs.
+func ConflictItemPage(mine, theirs data.Item, fields []data.Field) html.Block {
+	return html.Div(html.Class("ui text container"),
+		html.Div(html.Class("ui warning message"),
+			html.Div(html.Class("header"),
+				html.Text("This item was changed while you were editing it"),
+			),
+			html.P(nil, html.Text("Merge your changes with the current version and save again.")),
+		),
+		html.Div(html.Class("ui two column grid"),
+			html.Div(html.Class("column"),
+				html.H4(nil, html.Text("Current version")),
+				html.H3(nil, html.Text(theirs.Title)),
+				html.P(nil, html.Text(theirs.Body)),
+			),
+			html.Div(html.Class("column"),
+				html.H4(nil, html.Text("Your version")),
+				html.H3(nil, html.Text(mine.Title)),
+				html.P(nil, html.Text(mine.Body)),
+			),
+		),
+		html.Div(html.Class("ui divider")),
+		EditItemPage(data.Item{
+			ID:      theirs.ID,
+			Version: theirs.Version,
+			Title:   mine.Title,
+			Body:    mine.Body,
+			Due:     mine.Due,
+			Fields:  mine.Fields,
+		}, fields, false),
+	)
+}
+
+func ViewItemPage(d data.Item, fields []data.Field) html.Block {
 	var status, statusButton html.Block
 	var archiveButton, archiveLabel html.Block
 	switch d.State {
@@ -100,11 +144,14 @@ func ViewItemPage(d data.Item) html.Block {
 			floatedIconButton(watchClass,
 				fmt.Sprintf("itemFocus(%d, 'watch')", d.ID), "unhide", "Watch"),
 		),
+		priorityButtons(d),
 		html.H2(nil,
 			status,
 			archiveLabel,
 			html.Text(d.Title),
 		),
+		dueBlock(d),
+		fieldValuesBlock(fields, d.Fields),
 		html.Div(html.Class("ui divider")),
 		html.P(nil, html.Text(d.Body)),
 		html.Div(html.Class("ui divider")),
@@ -117,50 +164,84 @@ func ViewItemPage(d data.Item) html.Block {
 	)
 }
 
-func ViewAreaPage(d []data.Thing) html.Block {
-	var list, archived html.Blocks
-	for _, t := range d {
-		block := listItemBlock(t)
-		if t.Archived() {
-			if len(archived) == 0 {
-				archived.Add(html.H4(html.Styles("padding-left:48px"),
-					html.Text("Archived"),
-				))
+// priorityButtons set the priority of the item, the current
+// priority is shown in its color.
+func priorityButtons(d data.Item) html.Block {
+	var buttons []html.Block
+	for p := data.PriorityNone; p <= data.PriorityUrgent; p++ {
+		class := "basic"
+		if p == d.Priority {
+			class = priorityColors[p]
+			if class == "" {
+				class = "grey"
 			}
-			archived.Add(block)
-		} else {
-			list.Add(block)
 		}
+		name := textName(p)
+		buttons = append(buttons, compactIconButton(class,
+			fmt.Sprintf("itemPriority(%d, '%s')", d.ID, name), "flag", strings.Title(name)))
+	}
+	return buttonGroupBlock(buttons...)
+}
+
+// orderButtons switch the order in which the list or area is shown.
+func orderButtons(action string, id int, current data.ViewOrder) html.Block {
+	var buttons []html.Block
+	for o := data.OrderManual; o <= data.OrderUpdated; o++ {
+		class := "basic"
+		if o == current {
+			class = "active"
+		}
+		name := textName(o)
+		buttons = append(buttons, compactIconButton(class,
+			fmt.Sprintf("%s(%d, '%s')", action, id, name), orderIcons[o], strings.Title(name)))
+	}
+	return buttonGroupBlock(buttons...)
+}
+
+func dueValue(d data.Item) string {
+	if !d.HasDue() {
+		return ""
+	}
+	return d.Due.Format(data.DueFormat)
+}
+
+func dueBlock(d data.Item) html.Block {
+	if !d.HasDue() {
+		return nil
 	}
+	return html.Div(html.Class("ui basic label"),
+		html.I(html.Class("calendar icon")),
+		html.Text("Due "+d.Due.Format("Mon, Jan 2 2006")),
+	)
+}
+
+// ViewAreaPage shows the first page of the area. Further pages and
+// the archived lists and items are loaded with areaMore. In orders
+// other than the manual one the page holds all things that are not
+// archived.
+func ViewAreaPage(area int, order data.ViewOrder, page data.ThingPage) html.Block {
 	return html.Div(html.Class("ui text container"),
 		menuBlock(),
 		gridColumnBlock(
+			floatedIconButton("left", fmt.Sprintf("areaFields(%d)", area), "options", "Fields"),
 			floatedButton("positive right", "itemNew()", "New item"),
 			floatedButton("purple right", "listNew()", "New list"),
 		),
-		html.Div(html.Id("item-list").Class("ui relaxed selection list"),
-			list,
-		),
-		html.Div(html.Id("archive-list").Class("ui relaxed selection list"),
-			archived,
-		),
+		orderButtons("areaOrder", area, order),
+		pagedListBlock("areaMore", area, ThingsBlock(page.Things), page.Next, order == data.OrderManual),
 	)
 }
 
-func ViewListPage(d []data.Item) html.Block {
-	var list, archived html.Blocks
-	for _, t := range d {
-		block := listItemBlock(t)
-		if t.Archived() {
-			if len(archived) == 0 {
-				archived.Add(html.H4(html.Styles("padding-left:48px"),
-					html.Text("Archived"),
-				))
-			}
-			archived.Add(block)
-		} else {
-			list.Add(block)
-		}
+// ViewListPage shows the first page of the list in view.Order. Further
+// pages and the archived items are loaded with listMore. If the view
+// is active the page holds all matching items and the archive is not
+// shown.
+func ViewListPage(list int, fields []data.Field, view data.ListView, page data.ItemPage) html.Block {
+	content := pagedListBlock("listMore", list, ItemsBlock(page.Items), page.Next, view.Order == data.OrderManual)
+	if view.Active() {
+		content = html.Div(html.Id("item-list").Class("ui relaxed selection list"),
+			viewItemsBlock(page.Items, view),
+		)
 	}
 	return html.Div(html.Class("ui text container"),
 		menuBlock(),
@@ -168,16 +249,82 @@ func ViewListPage(d []data.Item) html.Block {
 			floatedIconButton("left", "areaView()", "chevron left", "Area"),
 			floatedButton("positive right", "itemNew()", "New item"),
 		),
-		html.Div(html.Id("item-list").Class("ui relaxed selection list"),
-			list,
+		orderButtons("listOrder", list, view.Order),
+		listViewBlock(list, fields, view),
+		content,
+	)
+}
+
+// pagedListBlock lays out #item-list with the first page, followed
+// by the collapsed archive. The contents of #item-more, #archive-head
+// and #archive-more are replaced when more pages are loaded. Only
+// sortable lists can be reordered by dragging.
+func pagedListBlock(action string, id int, first html.Block, next string, sortable bool) html.Block {
+	return html.Blocks{
+		html.Div(html.Id("item-list").Class("ui relaxed selection list").Data("sortable", sortable),
+			first,
+		),
+		html.Div(html.Id("item-more"),
+			MoreButton(action, id, next, false),
 		),
-		html.Div(html.Id("archive-list").Class("ui relaxed selection list"),
-			archived,
+		html.Div(html.Id("archive-head"),
+			gridColumnBlock(
+				compactIconButton("basic", moreAction(action, id, "", true), "archive", "Show archived"),
+			),
 		),
+		html.Div(html.Id("archive-list").Class("ui relaxed selection list"), nil),
+		html.Div(html.Id("archive-more"), nil),
+	}
+}
+
+// ItemsBlock renders the items of a page for #item-list or #archive-list.
+func ItemsBlock(items []data.Item) html.Block {
+	var out html.Blocks
+	for _, item := range items {
+		out.Add(listItemBlock(item))
+	}
+	return out
+}
+
+// ThingsBlock is ItemsBlock for the lists and items of an area.
+func ThingsBlock(things []data.Thing) html.Block {
+	var out html.Blocks
+	for _, thing := range things {
+		out.Add(listItemBlock(thing))
+	}
+	return out
+}
+
+// MoreButton loads the page after cursor next with the GUI action,
+// nothing is shown after the last page.
+func MoreButton(action string, id int, next string, archived bool) html.Block {
+	if next == "" {
+		return nil
+	}
+	return gridColumnBlock(
+		compactIconButton("basic", moreAction(action, id, next, archived), "angle down", "Load more"),
+	)
+}
+
+// ArchivedHeader replaces the "Show archived" button once the first
+// archived page was loaded.
+func ArchivedHeader(empty bool) html.Block {
+	text := "Archived"
+	if empty {
+		text = "Nothing is archived"
+	}
+	return html.H4(html.Styles("padding-left:48px"),
+		html.Text(text),
 	)
 }
 
-func ViewFocusPage(focus data.FocusData) html.Block {
+func moreAction(action string, id int, next string, archived bool) string {
+	return fmt.Sprintf("%s(%d, '%s', %t)", action, id, next, archived)
+}
+
+// ViewFocusPage shows the focus of the user with the pomodoro
+// timer of the item in focus now.
+func ViewFocusPage(focus data.FocusData, pomodoro data.Pomodoro) html.Block {
 	var list html.Blocks
 	if len(focus.Focus) > 0 {
 		list.Add(html.H4(html.Styles("padding-left:10px; margin: 32px 0 0;"),
@@ -211,8 +358,21 @@ func ViewFocusPage(focus data.FocusData) html.Block {
 	}
 	return html.Div(html.Class("ui text container"),
 		menuBlock(),
+		pomodoroBlock(focus, pomodoro),
 		html.Div(html.Id("focus-list").Class("ui relaxed selection list"),
 			list,
 		),
 	)
 }
+
+func ErrorPage(status int, message string) html.Block {
+	return html.Div(html.Class("ui text container"),
+		menuBlock(),
+		html.Div(html.Class("ui negative message"),
+			html.Div(html.Class("header"),
+				html.Text(fmt.Sprint(status, " ", http.StatusText(status))),
+			),
+			html.P(nil, html.Text(message)),
+		),
+	)
+}
@@ -0,0 +1,95 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blocks
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mbertschler/blocks/html"
+	"github.com/mbertschler/bunny/pkg/data"
+)
+
+// pomodoroBlock is the timer of the item in focus now. The server
+// keeps the end of the session, startPomodoroTimer counts down to
+// it in the browser and reports when it is over.
+func pomodoroBlock(focus data.FocusData, p data.Pomodoro) html.Block {
+	s := p.Session
+	if len(focus.Focus) == 0 && (s == nil || !s.Break) {
+		return nil
+	}
+	attr := html.Id("pomodoro").Class("ui center aligned segment")
+	title, remaining := "Pomodoro", p.Work
+	var buttons html.Block
+	if s != nil {
+		attr = attr.Data("end", s.End.Unix())
+		remaining = s.Remaining(time.Now())
+		if s.Break {
+			title = "Break"
+		}
+		buttons = compactIconButton("", "pomodoroStop()", "stop", "Stop")
+	} else {
+		buttons = html.Blocks{
+			compactIconButton("red", "pomodoroStart()", "play", "Start pomodoro"),
+			compactIconButton("", "pomodoroBreak()", "coffee", "Take a break"),
+		}
+	}
+	var count html.Block
+	if len(focus.Focus) > 0 && (s == nil || !s.Break) {
+		item := focus.Focus[0]
+		count = html.P(nil, html.Text(fmt.Sprintf("%s: %s done", item.Title, pomodoroCount(p.Counts[item.ID]))))
+	}
+	return html.Div(attr,
+		html.H4(html.Class("ui header"), html.Text(title)),
+		html.Div(html.Id("pomodoro-time").Class("ui huge header"), html.Text(clockText(remaining))),
+		count,
+		buttons,
+		pomodoroFormBlock(p),
+	)
+}
+
+func pomodoroCount(n int) string {
+	if n == 1 {
+		return "1 pomodoro"
+	}
+	return fmt.Sprintf("%d pomodoros", n)
+}
+
+// clockText formats d as minutes and seconds like a timer.
+func clockText(d time.Duration) string {
+	sec := int(d.Round(time.Second) / time.Second)
+	return fmt.Sprintf("%d:%02d", sec/60, sec%60)
+}
+
+func pomodoroFormBlock(p data.Pomodoro) html.Block {
+	return html.Div(html.Class("ui small form").Styles("margin-top:16px"),
+		html.Div(html.Class("inline fields").Styles("justify-content:center"),
+			html.Div(html.Class("field"),
+				html.Label(nil, html.Text("Work")),
+				html.Input(html.Class("pomodoroForm").Name("Work").Type("number").
+					Value(fmt.Sprint(int(p.Work/time.Minute))).Styles("width:5em")),
+			),
+			html.Div(html.Class("field"),
+				html.Label(nil, html.Text("Break")),
+				html.Input(html.Class("pomodoroForm").Name("Break").Type("number").
+					Value(fmt.Sprint(int(p.Break/time.Minute))).Styles("width:5em")),
+			),
+			html.Div(html.Class("field"),
+				html.Button(append(html.Class("ui compact button"),
+					html.AttrPair{Key: "onclick", Value: "pomodoroLengths()"}),
+					html.Text("Save minutes")),
+			),
+		),
+	)
+}
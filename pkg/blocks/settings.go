@@ -0,0 +1,225 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blocks
+
+import (
+	"fmt"
+
+	"github.com/mbertschler/blocks/html"
+	"github.com/mbertschler/bunny/pkg/data"
+	"github.com/mbertschler/bunny/pkg/importer"
+)
+
+const dateFormat = "2006-01-02"
+
+// ViewSettingsPage lists the API tokens of the user. secret is only
+// set right after a token was created, it can't be shown again later.
+func ViewSettingsPage(tokens []data.Token, secret string) html.Block {
+	var secretBlock html.Block
+	if secret != "" {
+		secretBlock = html.Div(html.Class("ui positive message"),
+			html.Div(html.Class("header"),
+				html.Text("Copy your new token now, it won't be shown again"),
+			),
+			html.P(nil, html.Code(nil, html.Text(secret))),
+		)
+	}
+	return html.Div(html.Class("ui text container"),
+		menuBlock(),
+		html.H3(nil, html.Text("API tokens")),
+		html.P(nil, html.Text("Tokens authenticate requests to the JSON API with an \"Authorization: Bearer <token>\" header.")),
+		secretBlock,
+		tokenTableBlock(tokens),
+		tokenFormBlock(),
+		html.Div(html.Class("ui divider")),
+		html.H3(nil, html.Text("Calendar")),
+		html.P(nil, html.Text("Subscribe to your due items and focus in a calendar app with the URL below. Any of your tokens can be used.")),
+		html.P(nil, html.Code(nil, html.Text(calendarURL(secret)))),
+		html.Div(html.Class("ui divider")),
+		html.H3(nil, html.Text("Export")),
+		html.P(nil, html.Text("Download all users, items, lists and areas as a JSON file. It can be restored with \"bunny import\".")),
+		html.A(html.Class("ui button").Href("/export"),
+			html.I(html.Class("download icon")),
+			html.Text("Download export")),
+		html.Div(html.Class("ui divider")),
+		html.H3(nil, html.Text("Import")),
+		html.P(nil, html.Text("Import a Trello board (JSON), a Todoist project (CSV) or a Markdown checklist. You'll see a preview before anything is imported.")),
+		importFormBlock(),
+	)
+}
+
+func calendarURL(secret string) string {
+	if secret == "" {
+		secret = "<token>"
+	}
+	return "/calendar.ics?token=" + secret
+}
+
+func importFormBlock() html.Block {
+	return html.Div(html.Class("ui form"),
+		html.Div(html.Class("two fields"),
+			html.Div(html.Class("field"),
+				html.Select(html.Class("ui dropdown").Id("import-format"),
+					html.Option(html.Attr{{Key: "value", Value: "trello"}}, html.Text("Trello board JSON")),
+					html.Option(html.Attr{{Key: "value", Value: "todoist"}}, html.Text("Todoist CSV")),
+					html.Option(html.Attr{{Key: "value", Value: "markdown"}}, html.Text("Markdown checklist")),
+				),
+			),
+			html.Div(html.Class("field"),
+				html.Input(html.Id("import-file").Type("file")),
+			),
+		),
+		html.Button(append(html.Class("ui button"),
+			html.AttrPair{Key: "onclick", Value: "importPreview()"}),
+			html.I(html.Class("upload icon")),
+			html.Text("Preview import")),
+	)
+}
+
+// ViewImportPage shows what an import creates and which fields
+// are skipped. Before the import is applied it has buttons to
+// apply or cancel it.
+func ViewImportPage(res *importer.Result, applied bool) html.Block {
+	areas, lists, items := res.Counts()
+	summary := fmt.Sprintf("%d areas, %d lists and %d items", areas, lists, items)
+	var header, buttons html.Block
+	if applied {
+		header = html.Div(html.Class("ui positive message"),
+			html.Div(html.Class("header"), html.Text("Imported "+summary)),
+		)
+	} else {
+		header = html.Div(html.Class("ui info message"),
+			html.Div(html.Class("header"), html.Text("This import will create "+summary)),
+		)
+		buttons = gridColumnBlock(
+			floatedButton("positive right", "importApply()", "Import"),
+			floatedButton("right", "settingsView()", "Cancel"),
+		)
+	}
+	var tree html.Blocks
+	for _, a := range res.Areas {
+		var things html.Blocks
+		for _, l := range a.Lists {
+			var listItems html.Blocks
+			for _, i := range l.Items {
+				listItems.Add(importItemBlock(i))
+			}
+			things.Add(html.Div(html.Class("item"),
+				html.I(html.Class("list icon")),
+				html.Div(html.Class("content"),
+					html.Div(html.Class("header"), html.Text(l.Title+importStateSuffix(l.State))),
+					html.Div(html.Class("list"), listItems),
+				),
+			))
+		}
+		for _, i := range a.Items {
+			things.Add(importItemBlock(i))
+		}
+		tree.Add(html.H4(nil, html.Text(a.Title)))
+		tree.Add(html.Div(html.Class("ui list"), things))
+	}
+	var report html.Block
+	if len(res.Skipped) > 0 || len(res.Warnings) > 0 {
+		var rows html.Blocks
+		for _, f := range res.SkippedFields() {
+			rows.Add(html.Li(nil, html.Text(fmt.Sprintf("%d× %s", res.Skipped[f], f))))
+		}
+		for _, w := range res.Warnings {
+			rows.Add(html.Li(nil, html.Text(w)))
+		}
+		report = html.Div(html.Class("ui warning message"),
+			html.Div(html.Class("header"), html.Text("Not everything can be imported")),
+			html.Ul(html.Class("list"), rows),
+		)
+	}
+	return html.Div(html.Class("ui text container"),
+		menuBlock(),
+		buttons,
+		header,
+		report,
+		tree,
+	)
+}
+
+func importItemBlock(i importer.Item) html.Block {
+	icon := openItemElement
+	if i.State != data.ItemOpen {
+		icon = completeItemElement
+	}
+	return html.Div(html.Class("item"),
+		icon,
+		html.Div(html.Class("content"), html.Text(i.Title+importStateSuffix(i.State))),
+	)
+}
+
+func importStateSuffix(s data.ItemState) string {
+	if s == data.ItemArchived {
+		return " (archived)"
+	}
+	return ""
+}
+
+func tokenTableBlock(tokens []data.Token) html.Block {
+	if len(tokens) == 0 {
+		return html.P(nil, html.Text("You don't have any API tokens yet."))
+	}
+	rows := html.Blocks{
+		html.Tr(nil,
+			html.Th(nil, html.Text("Name")),
+			html.Th(nil, html.Text("Scope")),
+			html.Th(nil, html.Text("Created")),
+			html.Th(nil, html.Text("Expires")),
+			html.Th(nil),
+		),
+	}
+	for _, t := range tokens {
+		expires := "never"
+		if !t.Expires.IsZero() {
+			expires = t.Expires.Format(dateFormat)
+		}
+		rows.Add(html.Tr(nil,
+			html.Td(nil, html.Text(t.Name)),
+			html.Td(nil, html.Text(t.Scope.String())),
+			html.Td(nil, html.Text(t.Created.Format(dateFormat))),
+			html.Td(nil, html.Text(expires)),
+			html.Td(nil, compactIconButton("red", fmt.Sprintf("tokenRevoke(%d)", t.ID), "trash", "Revoke")),
+		))
+	}
+	return html.Table(html.Class("ui compact table"), rows)
+}
+
+func tokenFormBlock() html.Block {
+	return html.Div(html.Class("ui form"),
+		html.Div(html.Class("three fields"),
+			html.Div(html.Class("field"),
+				html.Input(append(html.Class("tokenForm").Name("Name").Type("text"),
+					html.AttrPair{Key: "placeholder", Value: "Token name"})),
+			),
+			html.Div(html.Class("field"),
+				html.Select(html.Class("ui dropdown tokenForm").Name("Scope"),
+					html.Option(html.Attr{{Key: "value", Value: "read"}}, html.Text("Read only")),
+					html.Option(html.Attr{{Key: "value", Value: "write"}}, html.Text("Read and write")),
+				),
+			),
+			html.Div(html.Class("field"),
+				html.Input(append(html.Class("tokenForm").Name("Days").Type("number"),
+					html.AttrPair{Key: "min", Value: "0"},
+					html.AttrPair{Key: "placeholder", Value: "Expires after days, empty for never"})),
+			),
+		),
+		html.Button(append(html.Class("ui positive button"),
+			html.AttrPair{Key: "onclick", Value: "tokenCreate()"}),
+			html.Text("Create token")),
+	)
+}
@@ -0,0 +1,190 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blocks
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mbertschler/blocks/html"
+	"github.com/mbertschler/bunny/pkg/data"
+)
+
+// ViewTimesheetPage shows the time of the week per area and list
+// and the time entries for corrections. The entry with the ID edit
+// is shown as a form, items are the choices for new entries.
+func ViewTimesheetPage(ts data.Timesheet, items []data.Item, edit int) html.Block {
+	week := ts.Week.Format(dateFormat)
+	return html.Div(html.Class("ui text container"),
+		menuBlock(),
+		gridColumnBlock(
+			floatedIconButton("left", timesheetAction(ts.Week.AddDate(0, 0, -7)), "chevron left", "Previous"),
+			floatedIconButton("right", timesheetAction(ts.Week.AddDate(0, 0, 7)), "chevron right", "Next"),
+		),
+		html.H2(nil, html.Text("Week of "+week)),
+		timesheetTableBlock(ts),
+		html.H3(nil, html.Text("Time entries")),
+		html.P(nil, html.Text("The timer runs while an item is your focus now. Times are in UTC.")),
+		entryTableBlock(ts, edit),
+		html.H3(nil, html.Text("Add time")),
+		entryFormBlock(items, ts.Week),
+	)
+}
+
+func timesheetAction(week time.Time) string {
+	return fmt.Sprintf("timesheetView('%s')", week.Format(dateFormat))
+}
+
+func timesheetTableBlock(ts data.Timesheet) html.Block {
+	if len(ts.Rows) == 0 {
+		return html.P(nil, html.Text("No time was tracked in this week."))
+	}
+	header := html.Blocks{html.Th(nil)}
+	for _, d := range ts.Days {
+		header.Add(html.Th(nil, html.Text(d.Format("Mon 2"))))
+	}
+	header.Add(html.Th(nil, html.Text("Total")))
+	rows := html.Blocks{html.Tr(nil, header)}
+	for _, r := range ts.Rows {
+		rows.Add(timesheetRowBlock(timesheetRowName(r), r, false))
+	}
+	rows.Add(timesheetRowBlock("Total", ts.Total, true))
+	return html.Table(html.Class("ui compact small table"), rows)
+}
+
+func timesheetRowName(r data.TimesheetRow) string {
+	switch {
+	case r.Area.ID == 0:
+		return "No area"
+	case r.List.ID == 0:
+		return r.Area.Title
+	}
+	return r.Area.Title + " / " + r.List.Title
+}
+
+func timesheetRowBlock(name string, r data.TimesheetRow, total bool) html.Block {
+	cell := html.Td
+	if total {
+		cell = html.Th
+	}
+	cells := html.Blocks{cell(nil, html.Text(name))}
+	for _, d := range r.Days {
+		cells.Add(cell(nil, html.Text(durationText(d))))
+	}
+	cells.Add(cell(nil, html.Text(durationText(r.Total))))
+	return html.Tr(nil, cells)
+}
+
+// durationText formats d as hours and minutes, empty for zero.
+func durationText(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	m := int(d.Round(time.Minute) / time.Minute)
+	return fmt.Sprintf("%d:%02d", m/60, m%60)
+}
+
+func entryTableBlock(ts data.Timesheet, edit int) html.Block {
+	if len(ts.Entries) == 0 {
+		return nil
+	}
+	rows := html.Blocks{
+		html.Tr(nil,
+			html.Th(nil, html.Text("Item")),
+			html.Th(nil, html.Text("Start")),
+			html.Th(nil, html.Text("End")),
+			html.Th(nil, html.Text("Time")),
+			html.Th(nil),
+		),
+	}
+	now := time.Now()
+	for _, e := range ts.Entries {
+		title := "Deleted item"
+		if i, ok := ts.Items[e.Item]; ok {
+			title = i.Title
+		}
+		item := html.Td(nil, html.A(html.Attr{{Key: "onclick", Value: fmt.Sprintf("itemView(%d)", e.Item)}},
+			html.Text(title)))
+		if e.ID == edit {
+			rows.Add(html.Tr(nil,
+				item,
+				html.Td(nil, timeInput("entryForm", "Start", e.Start)),
+				html.Td(nil, entryEndInput(e)),
+				html.Td(nil, html.Text(durationText(e.Duration(now)))),
+				html.Td(nil,
+					compactIconButton("positive", fmt.Sprintf("entrySave(%d)", e.ID), "check", "Save"),
+					compactIconButton("", timesheetAction(ts.Week), "close", "Cancel"),
+				),
+			))
+			continue
+		}
+		end := "running"
+		if !e.Running() {
+			end = e.End.Format("2006-01-02 15:04")
+		}
+		rows.Add(html.Tr(nil,
+			item,
+			html.Td(nil, html.Text(e.Start.Format("2006-01-02 15:04"))),
+			html.Td(nil, html.Text(end)),
+			html.Td(nil, html.Text(durationText(e.Duration(now)))),
+			html.Td(nil,
+				compactIconButton("", fmt.Sprintf("entryEdit(%d)", e.ID), "pencil", "Edit"),
+				compactIconButton("red", fmt.Sprintf("entryDelete(%d)", e.ID), "trash", "Delete"),
+			),
+		))
+	}
+	return html.Div(html.Class("ui form"),
+		html.Table(html.Class("ui compact small table"), rows),
+	)
+}
+
+// entryEndInput can't change the end of the running entry.
+func entryEndInput(e data.TimeEntry) html.Block {
+	if e.Running() {
+		return html.Text("running")
+	}
+	return timeInput("entryForm", "End", e.End)
+}
+
+func timeInput(class, name string, t time.Time) html.Block {
+	var value string
+	if !t.IsZero() {
+		value = t.UTC().Format(data.EntryTimeFormat)
+	}
+	return html.Input(html.Class(class).Name(name).Type("datetime-local").Value(value))
+}
+
+func entryFormBlock(items []data.Item, week time.Time) html.Block {
+	options := html.Blocks{}
+	for _, i := range items {
+		options.Add(html.Option(html.Attr{{Key: "value", Value: fmt.Sprint(i.ID)}}, html.Text(i.Title)))
+	}
+	start := week.Add(9 * time.Hour)
+	return html.Div(html.Class("ui form"),
+		html.Div(html.Class("three fields"),
+			html.Div(html.Class("field"),
+				html.Select(html.Class("ui dropdown newEntryForm").Name("Item"), options),
+			),
+			html.Div(html.Class("field"),
+				timeInput("newEntryForm", "Start", start),
+			),
+			html.Div(html.Class("field"),
+				timeInput("newEntryForm", "End", start.Add(time.Hour)),
+			),
+		),
+		html.Button(append(html.Class("ui positive button"),
+			html.AttrPair{Key: "onclick", Value: "entryAdd()"}),
+			html.Text("Add time")),
+	)
+}
@@ -13,13 +13,32 @@
 
 package blocks
 
-import "github.com/mbertschler/blocks/html"
+import (
+	"github.com/mbertschler/blocks/html"
+	"github.com/mbertschler/bunny/pkg/data"
+)
 
 var (
 	focusNowIcon   = "yellow star"
 	focusLaterIcon = "red wait"
 	focusWatchIcon = "blue unhide"
 
+	// colors of the priorities, items without a priority
+	// have no indicator
+	priorityColors = map[data.Priority]string{
+		data.PriorityLow:    "blue",
+		data.PriorityMedium: "yellow",
+		data.PriorityHigh:   "orange",
+		data.PriorityUrgent: "red",
+	}
+
+	orderIcons = map[data.ViewOrder]string{
+		data.OrderManual:   "sort",
+		data.OrderPriority: "flag",
+		data.OrderDue:      "calendar",
+		data.OrderUpdated:  "history",
+	}
+
 	completeItemElement = html.I(
 		html.Class("checkmark icon green").
 			Styles("display:inline-block"))
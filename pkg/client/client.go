@@ -0,0 +1,41 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package client is used by the bunny subcommands to work with
+// items, lists and the focus of a user. It either talks to a running
+// server over the JSON API or works directly on a data file.
+package client
+
+import (
+	"io"
+
+	"github.com/mbertschler/bunny/pkg/data"
+)
+
+// Client is implemented by the HTTP and the local client.
+type Client interface {
+	// AddItem creates a new item at the top of the list.
+	AddItem(list int, in data.Item) (data.Item, error)
+	// CompleteItem marks the item as complete.
+	CompleteItem(id int) (data.Item, error)
+	// Item returns a single item.
+	Item(id int) (data.Item, error)
+	// Focus returns the items the user focuses on.
+	Focus() (data.FocusData, error)
+	// List returns the list with all of its items.
+	List(id int) (data.List, error)
+	// Export writes a JSON export of the workspace to w.
+	Export(w io.Writer) error
+	// Close releases the data file or connections.
+	Close() error
+}
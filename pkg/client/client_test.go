@@ -0,0 +1,95 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mbertschler/bunny/pkg/data"
+	"github.com/mbertschler/bunny/pkg/router"
+)
+
+func TestClients(t *testing.T) {
+	_, secret, err := data.NewToken(1, "cli", data.ScopeWrite, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(router.Router("/"))
+	defer srv.Close()
+
+	clients := map[string]Client{
+		"local": NewLocal(),
+		"http":  NewHTTP(srv.URL+"/", secret),
+	}
+	for name, c := range clients {
+		testClient(t, name, c)
+	}
+}
+
+func testClient(t *testing.T, name string, c Client) {
+	due := time.Date(2018, 12, 31, 0, 0, 0, 0, time.UTC)
+	item, err := c.AddItem(1, data.Item{Title: "from " + name, Due: due})
+	if err != nil {
+		t.Fatal(name, err)
+	}
+	if item.ID == 0 || item.Title != "from "+name || item.State != data.ItemOpen || !item.Due.Equal(due) {
+		t.Error(name, "unexpected item", item)
+	}
+
+	list, err := c.List(1)
+	if err != nil {
+		t.Fatal(name, err)
+	}
+	if len(list.Items) == 0 || list.Items[0].ID != item.ID {
+		t.Error(name, "item should be at the top of list 1", list.Items)
+	}
+
+	done, err := c.CompleteItem(item.ID)
+	if err != nil {
+		t.Fatal(name, err)
+	}
+	if done.State != data.ItemComplete || done.Version != item.Version+1 {
+		t.Error(name, "item should be complete", done)
+	}
+
+	_, err = c.Item(9999)
+	if !data.IsNotFound(err) {
+		t.Error(name, "missing item should not be found, got", err)
+	}
+
+	_, err = c.Focus()
+	if err != nil {
+		t.Error(name, err)
+	}
+
+	var buf bytes.Buffer
+	err = c.Export(&buf)
+	if err != nil {
+		t.Fatal(name, err)
+	}
+	e, err := data.ReadExport(&buf)
+	if err != nil {
+		t.Fatal(name, err)
+	}
+	if len(e.Items) == 0 {
+		t.Error(name, "export has no items")
+	}
+	err = c.Close()
+	if err != nil {
+		t.Error(name, err)
+	}
+}
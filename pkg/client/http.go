@@ -0,0 +1,185 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mbertschler/bunny/pkg/data"
+	"github.com/mbertschler/bunny/pkg/data/stored"
+)
+
+type remote struct {
+	server string
+	token  string
+	client *http.Client
+}
+
+// NewHTTP returns a client that talks to the server at the URL
+// server, for example "http://localhost:3080". It authenticates
+// with the API token.
+func NewHTTP(server, token string) Client {
+	return remote{
+		server: strings.TrimSuffix(server, "/"),
+		token:  token,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Error is an error response of the server.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("server returned %d %s: %s", e.Status, e.Code, e.Message)
+}
+
+type newItem struct {
+	Title string
+	Body  string
+	State data.ItemState
+	Due   time.Time
+	List  int
+}
+
+func (c remote) AddItem(list int, in data.Item) (data.Item, error) {
+	var out data.Item
+	err := c.do("POST", "/api/v1/items", newItem{
+		Title: in.Title,
+		Body:  in.Body,
+		State: in.State,
+		Due:   in.Due,
+		List:  list,
+	}, &out)
+	return out, err
+}
+
+func (c remote) CompleteItem(id int) (data.Item, error) {
+	item, err := c.Item(id)
+	if err != nil {
+		return item, err
+	}
+	item.State = data.ItemComplete
+	var out data.Item
+	err = c.do("PUT", fmt.Sprintf("/api/v1/items/%d", id), item, &out)
+	return out, err
+}
+
+func (c remote) Item(id int) (data.Item, error) {
+	var out data.Item
+	err := c.do("GET", fmt.Sprintf("/api/v1/items/%d", id), nil, &out)
+	return out, err
+}
+
+func (c remote) Focus() (data.FocusData, error) {
+	var out data.FocusData
+	err := c.do("GET", "/api/v1/focus", nil, &out)
+	return out, err
+}
+
+func (c remote) List(id int) (data.List, error) {
+	var out data.List
+	err := c.do("GET", fmt.Sprintf("/api/v1/lists/%d", id), nil, &out)
+	return out, err
+}
+
+func (c remote) Export(w io.Writer) error {
+	resp, err := c.request("GET", "/export", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+func (c remote) Close() error {
+	return nil
+}
+
+// do sends in as the JSON body and decodes the response into out.
+// Both can be nil.
+func (c remote) do(method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		buf, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(buf)
+	}
+	resp, err := c.request(method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// request sends the request and turns error responses into errors.
+func (c remote) request(method, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.server+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 400 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	return nil, responseError(resp)
+}
+
+// responseError decodes the API error and attaches the stored.Cause
+// that matches the status, so that data.IsNotFound and friends
+// work with errors of both clients.
+func responseError(resp *http.Response) error {
+	var body struct{ Error Error }
+	err := json.NewDecoder(resp.Body).Decode(&body)
+	e := body.Error
+	if err != nil || e.Message == "" {
+		e.Message = http.StatusText(resp.StatusCode)
+	}
+	e.Status = resp.StatusCode
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return stored.WithCause(e, stored.CauseNotFound)
+	case http.StatusBadRequest:
+		return stored.WithCause(e, stored.CauseInvalid)
+	case http.StatusConflict:
+		return stored.WithCause(e, stored.CauseConflict)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return stored.WithCause(e, stored.CauseForbidden)
+	}
+	return e
+}
@@ -0,0 +1,93 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"io"
+
+	"github.com/mbertschler/bunny/pkg/data"
+)
+
+// localUser is the user that the local client acts as, the same
+// user that the GUI uses.
+const localUser = 1
+
+type local struct {
+	opened bool // the data file was opened by the client
+}
+
+// NewLocal returns a client that works on the data that is
+// currently loaded by the data package.
+func NewLocal() Client {
+	return local{}
+}
+
+// OpenFile opens the data file at path and returns a client that
+// works on it. The server must not be running on the same file.
+func OpenFile(path string) (Client, error) {
+	err := data.OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return local{opened: true}, nil
+}
+
+func (l local) AddItem(list int, in data.Item) (data.Item, error) {
+	item, err := data.NewListItem(list, in)
+	if err != nil {
+		return item, err
+	}
+	return data.UserItemByID(localUser, item.ID)
+}
+
+func (l local) CompleteItem(id int) (data.Item, error) {
+	item, err := data.ItemByID(id)
+	if err != nil {
+		return item, err
+	}
+	item.State = data.ItemComplete
+	err = data.SetItem(item)
+	if err != nil {
+		return item, err
+	}
+	return data.UserItemByID(localUser, id)
+}
+
+func (l local) Item(id int) (data.Item, error) {
+	return data.UserItemByID(localUser, id)
+}
+
+func (l local) Focus() (data.FocusData, error) {
+	return data.FocusList(localUser)
+}
+
+func (l local) List(id int) (data.List, error) {
+	list, err := data.ListByID(id)
+	if err != nil {
+		return list, err
+	}
+	list.Items, err = data.UserItemList(localUser, id)
+	return list, err
+}
+
+func (l local) Export(w io.Writer) error {
+	return data.WriteExport(w)
+}
+
+func (l local) Close() error {
+	if !l.opened {
+		return nil
+	}
+	return data.Close()
+}
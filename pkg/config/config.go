@@ -23,12 +23,27 @@ import (
 var (
 	Port string // $BUNNY_PORT
 	Root string // $BUNNY_ROOT
+	Data string // $BUNNY_DATA, database file, in memory with example data if empty
+
+	// The GUI trusts requests from the trusted networks like requests
+	// from the machine that runs the server.
+	TrustedNetworks string // $BUNNY_TRUSTED_NETWORKS, for example "172.17.0.1,192.168.1.0/24"
+
+	// The SMTP listener that turns mail into items is only
+	// started if SMTPAddr is set.
+	SMTPAddr      string // $BUNNY_SMTP_ADDR, for example ":2525"
+	SMTPDomain    string // $BUNNY_SMTP_DOMAIN, domain of the mailbox addresses
+	SMTPMailboxes string // $BUNNY_SMTP_MAILBOXES, for example "inbox=1:list/1,ideas=1:area/2"
+	SMTPSenders   string // $BUNNY_SMTP_SENDERS, for example "martin@example.com=1"
+
+	// Subcommands talk to the server at Server if it is set,
+	// otherwise they work on the Data file.
+	Server string // $BUNNY_SERVER, for example "http://localhost:3080"
+	Token  string // $BUNNY_TOKEN, API token for Server
 )
 
 func Setup() error {
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
-	Port = envOrFallback("BUNNY_PORT", "3080")
-	Root = envOrFallback("BUNNY_ROOT", "")
+	SetupEnv()
 	if Root == "" {
 		var err error
 		Root, err = findProjectFolder()
@@ -37,6 +52,23 @@ func Setup() error {
 	return nil
 }
 
+// SetupEnv reads the configuration from the environment without
+// looking for the project folder. It is enough for subcommands
+// that don't serve files.
+func SetupEnv() {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+	Port = envOrFallback("BUNNY_PORT", "3080")
+	Root = envOrFallback("BUNNY_ROOT", "")
+	Data = envOrFallback("BUNNY_DATA", "")
+	TrustedNetworks = envOrFallback("BUNNY_TRUSTED_NETWORKS", "")
+	SMTPAddr = envOrFallback("BUNNY_SMTP_ADDR", "")
+	SMTPDomain = envOrFallback("BUNNY_SMTP_DOMAIN", "localhost")
+	SMTPMailboxes = envOrFallback("BUNNY_SMTP_MAILBOXES", "")
+	SMTPSenders = envOrFallback("BUNNY_SMTP_SENDERS", "")
+	Server = envOrFallback("BUNNY_SERVER", "")
+	Token = envOrFallback("BUNNY_TOKEN", "")
+}
+
 func envOrFallback(name, fallback string) string {
 	val, ok := os.LookupEnv(name)
 	if ok {
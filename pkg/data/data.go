@@ -17,6 +17,8 @@ package data
 
 import (
 	"log"
+	"sort"
+	"time"
 
 	"github.com/mbertschler/bunny/pkg/data/memory"
 	"github.com/mbertschler/bunny/pkg/data/stored"
@@ -29,6 +31,33 @@ func init() {
 	setupTestdata()
 }
 
+// OpenFile replaces the in-memory database with the database file
+// at path. The file is created if it doesn't exist.
+func OpenFile(path string) error {
+	d, err := memory.OpenFile(path)
+	if err != nil {
+		return err
+	}
+	db = d
+	return nil
+}
+
+// Close closes the database. Data files are synced to disk.
+func Close() error {
+	return db.Close()
+}
+
+// SetupExampleData fills the database with example data
+// if it is empty.
+func SetupExampleData() error {
+	empty, err := isEmpty()
+	if err != nil || !empty {
+		return err
+	}
+	setupTestdata()
+	return nil
+}
+
 func setupTestdata() {
 	logErr(forceSetUser(User{
 		ID:   1,
@@ -117,17 +146,65 @@ type FocusData struct {
 	Watch []Item
 }
 
+// PageSize is the number of entries in a page if no limit is set.
+const PageSize = 50
+
+// PageQuery selects one page of a list or an area. Archived entries
+// and the other ones are paged separately, so that archived entries
+// can be loaded on demand.
+type PageQuery struct {
+	Cursor   string // Next of the previous page, empty for the first page
+	Limit    int    // PageSize if it is 0
+	Archived bool   // only archived entries instead of only the others
+
+	// Order other than OrderManual returns all entries that are not
+	// archived on a single page. Archived entries are always paged in
+	// the manual order.
+	Order ViewOrder
+}
+
+func (q PageQuery) stored() memory.Page {
+	if q.Limit == 0 {
+		q.Limit = PageSize
+	}
+	return memory.Page{
+		Cursor:   q.Cursor,
+		Limit:    q.Limit,
+		Archived: q.Archived,
+	}
+}
+
+// ItemPage is one page of the items of a list.
+type ItemPage struct {
+	Items []Item
+	Next  string // cursor of the next page, empty for the last page
+}
+
+// ThingPage is one page of the lists and items of an area.
+type ThingPage struct {
+	Things []Thing
+	Next   string // cursor of the next page, empty for the last page
+}
+
 type User struct {
 	ID   int
 	Name string
 }
 
 type Item struct {
-	ID    int
-	State ItemState
-	Focus FocusState
-	Title string
-	Body  string
+	ID      int
+	Version int
+	State   ItemState
+	Focus   FocusState
+	Title   string
+	Body    string
+	Due     time.Time // day the item is due, zero if it has none
+	Updated time.Time // set when the item is saved, zero if unknown
+
+	Priority Priority
+
+	// values of custom fields by field ID, see Item.SetFields
+	Fields map[int]string `json:",omitempty"`
 }
 
 func (Item) thingType() ThingType { return TypeItem }
@@ -136,12 +213,19 @@ func (i Item) Archived() bool {
 	return i.State == ItemArchived
 }
 
+// HasDue reports whether the item has a due date.
+func (i Item) HasDue() bool {
+	return !i.Due.IsZero()
+}
+
 type List struct {
-	ID    int
-	State ItemState
-	Title string
-	Body  string
-	Items []Item
+	ID      int
+	Version int
+	State   ItemState
+	Title   string
+	Body    string
+	Updated time.Time // set when the list is saved, zero if unknown
+	Items   []Item
 }
 
 func (List) thingType() ThingType { return TypeList }
@@ -163,10 +247,11 @@ const (
 )
 
 type Area struct {
-	ID    int
-	Title string
-	Body  string
-	List  []Thing
+	ID     int
+	Title  string
+	Body   string
+	List   []Thing
+	Fields []Field // custom fields of the items in the area
 }
 
 type ItemState int8
@@ -186,24 +271,96 @@ const (
 	FocusWatch FocusState = stored.FocusWatch
 )
 
-func ItemByID(id int) (Item, error) {
-	stored, err := db.ItemByID(id)
+type Priority int8
+
+const (
+	PriorityNone   Priority = stored.PriorityNone
+	PriorityLow    Priority = stored.PriorityLow
+	PriorityMedium Priority = stored.PriorityMedium
+	PriorityHigh   Priority = stored.PriorityHigh
+	PriorityUrgent Priority = stored.PriorityUrgent
+)
+
+// Tx reads and writes the data in the transaction of a Batch. Its
+// methods work like the package functions with the same names,
+// which run outside of a batch. The zero Tx isn't part of a batch.
+type Tx struct {
+	batch *memory.DB
+}
+
+func (tx Tx) db() *memory.DB {
+	if tx.batch == nil {
+		return db
+	}
+	return tx.batch
+}
+
+// Batch runs fn in a single storage transaction. All changes that
+// fn makes through tx are rolled back if it returns an error or
+// panics. Events are only sent to webhooks after the batch was
+// committed. Calls that don't go through tx aren't part of the
+// batch, they wait for it. Only use it if several writes have to
+// be atomic, single writes already run in their own transaction.
+func Batch(fn func(tx Tx) error) error {
+	return Tx{}.Batch(fn)
+}
+
+// Batch runs fn in the batch of tx, or in a new batch if tx isn't
+// part of one.
+func (tx Tx) Batch(fn func(tx Tx) error) error {
+	return tx.db().Batch(func(d *memory.DB) error {
+		return fn(Tx{batch: d})
+	})
+}
+
+func (tx Tx) ItemByID(id int) (Item, error) {
+	stored, err := tx.db().ItemByID(id)
 	i := restoreItem(stored)
 	return i, err
 }
 
-func UserItemByID(user, id int) (Item, error) {
-	stored, err := db.UserItemByID(user, id)
+func (tx Tx) UserItemByID(user, id int) (Item, error) {
+	stored, err := tx.db().UserItemByID(user, id)
 	i := restoreItem(stored)
 	return i, err
 }
 
-func SetItem(in Item) error {
-	return db.SetItem(storedItem(in))
+// SetItem saves the item if its Version matches the stored one.
+// Stale writes fail with an error caused by stored.CauseConflict.
+func (tx Tx) SetItem(in Item) error {
+	in.Updated = time.Now().UTC().Truncate(time.Second)
+	saved := storedItem(in)
+	old, err := tx.db().SetItem(saved)
+	if err != nil {
+		return err
+	}
+	saved.Version++
+	item := restoreItem(saved)
+	tx.emitItem(EventItemUpdated, 0, item)
+	if e := itemStateEvent(ItemState(old.State), item.State); e != "" {
+		tx.emitItem(e, 0, item)
+	}
+	return nil
+}
+
+func IsConflict(err error) bool {
+	return stored.HasCause(err, stored.CauseConflict)
+}
+
+func IsNotFound(err error) bool {
+	return stored.HasCause(err, stored.CauseNotFound)
 }
 
-func forceSetItem(in Item) error {
-	return db.ForceSetItem(storedItem(in))
+func IsInvalid(err error) bool {
+	return stored.HasCause(err, stored.CauseInvalid)
+}
+
+func IsForbidden(err error) bool {
+	return stored.HasCause(err, stored.CauseForbidden)
+}
+
+func (tx Tx) forceSetItem(in Item) error {
+	return tx.db().ForceSetItem(storedItem(in))
 }
 
 func storedUser(in User) stored.User {
@@ -222,54 +379,70 @@ func restoreUser(in stored.User) User {
 
 func storedArea(in Area) stored.Area {
 	return stored.Area{
-		ID:    in.ID,
-		Title: in.Title,
-		Body:  in.Body,
+		ID:     in.ID,
+		Title:  in.Title,
+		Body:   in.Body,
+		Fields: storedFields(in.Fields),
 	}
 }
 
 func restoreArea(in stored.Area) Area {
 	return Area{
-		ID:    in.ID,
-		Title: in.Title,
-		Body:  in.Body,
+		ID:     in.ID,
+		Title:  in.Title,
+		Body:   in.Body,
+		Fields: restoreFields(in.Fields),
 	}
 }
 
 func storedItem(in Item) stored.Item {
 	return stored.Item{
-		ID:    in.ID,
-		State: int(in.State),
-		Title: in.Title,
-		Body:  in.Body,
+		ID:       in.ID,
+		Version:  in.Version,
+		State:    int(in.State),
+		Title:    in.Title,
+		Body:     in.Body,
+		Due:      dueDay(in.Due),
+		Updated:  in.Updated,
+		Priority: int(in.Priority),
+		Fields:   copyValues(in.Fields),
 	}
 }
 
 func restoreItem(in stored.Item) Item {
 	return Item{
-		ID:    in.ID,
-		State: ItemState(in.State),
-		Title: in.Title,
-		Body:  in.Body,
-		Focus: FocusState(in.Focus),
+		ID:       in.ID,
+		Version:  in.Version,
+		State:    ItemState(in.State),
+		Title:    in.Title,
+		Body:     in.Body,
+		Due:      dueDay(in.Due),
+		Updated:  in.Updated,
+		Priority: Priority(in.Priority),
+		Fields:   copyValues(in.Fields),
+		Focus:    FocusState(in.Focus),
 	}
 }
 
 func storedList(in List) stored.List {
 	return stored.List{
-		ID:    in.ID,
-		State: int(in.State),
-		Title: in.Title,
-		Body:  in.Body,
+		ID:      in.ID,
+		Version: in.Version,
+		State:   int(in.State),
+		Title:   in.Title,
+		Body:    in.Body,
+		Updated: in.Updated,
 	}
 }
 
 func restoreList(in stored.List) List {
 	return List{
-		ID:    in.ID,
-		State: ItemState(in.State),
-		Title: in.Title,
-		Body:  in.Body,
+		ID:      in.ID,
+		Version: in.Version,
+		State:   ItemState(in.State),
+		Title:   in.Title,
+		Body:    in.Body,
+		Updated: in.Updated,
 	}
 }
 
@@ -293,32 +466,60 @@ func restoreThing(in stored.Thing) Thing {
 	return nil
 }
 
-func NewItem() (Item, error) {
-	i := Item{}
+func (tx Tx) NewItem() (Item, error) {
+	return tx.NewListItem(1, Item{})
+}
+
+// NewListItem creates a new item with the contents of in
+// and adds it to the top of the list.
+func (tx Tx) NewListItem(list int, in Item) (Item, error) {
+	in.Version = 0
+	in.Updated = time.Now().UTC().Truncate(time.Second)
 	var err error
-	i.ID, err = db.NewItem(storedItem(i))
-	if err != nil {
-		return i, err
+	in.ID, err = tx.db().NewListItem(list, storedItem(in))
+	if err == nil {
+		tx.emitItem(EventItemCreated, 0, in)
 	}
-	err = db.SetListItemPosition(1, i.ID, 1)
-	return i, err
+	return in, err
 }
 
-func SortFocusItem(user, id, after int) error {
-	return db.SortUserFocusAfter(user, id, after)
+// NewAreaItem creates a new item with the contents of in
+// and adds it to the top of the area.
+func (tx Tx) NewAreaItem(area int, in Item) (Item, error) {
+	in.Version = 0
+	in.Updated = time.Now().UTC().Truncate(time.Second)
+	var err error
+	in.ID, err = tx.db().NewAreaItem(area, storedItem(in))
+	if err == nil {
+		tx.emitItem(EventItemCreated, 0, in)
+	}
+	return in, err
 }
 
-func SetFocus(user, id int, focus FocusState) error {
-	return db.SetUserFocus(user, id, int(focus))
+func (tx Tx) SortFocusItem(user, id, after int) error {
+	return tx.db().SortUserFocusAfter(user, id, after)
 }
 
-func DeleteItem(id int) error {
-	return db.DeleteItem(id)
+func (tx Tx) SetFocus(user, id int, focus FocusState) error {
+	return tx.SetUserFocus(user, id, focus)
 }
 
-func ItemList(id int) ([]Item, error) {
+func (tx Tx) DeleteItem(id int) error {
+	item, err := tx.db().ItemByID(id)
+	if err != nil {
+		return err
+	}
+	err = tx.db().DeleteItem(id)
+	if err != nil {
+		return err
+	}
+	tx.emitItem(EventItemDeleted, 0, restoreItem(item))
+	return nil
+}
+
+func (tx Tx) ItemList(id int) ([]Item, error) {
 	var out []Item
-	_, items, err := db.ItemList(id)
+	_, items, err := tx.db().ItemList(id)
 	if err != nil {
 		return nil, err
 	}
@@ -328,9 +529,9 @@ func ItemList(id int) ([]Item, error) {
 	return out, nil
 }
 
-func UserItemList(user, id int) ([]Item, error) {
+func (tx Tx) UserItemList(user, id int) ([]Item, error) {
 	var out []Item
-	_, items, err := db.UserItemList(user, id)
+	_, items, err := tx.db().UserItemList(user, id)
 	if err != nil {
 		return nil, err
 	}
@@ -340,9 +541,9 @@ func UserItemList(user, id int) ([]Item, error) {
 	return out, nil
 }
 
-func UserArea(user, id int) (Area, []Thing, error) {
+func (tx Tx) UserArea(user, id int) (Area, []Thing, error) {
 	var out []Thing
-	area, items, err := db.UserArea(user, id)
+	area, items, err := tx.db().UserArea(user, id)
 	if err != nil {
 		return restoreArea(area), nil, err
 	}
@@ -352,9 +553,65 @@ func UserArea(user, id int) (Area, []Thing, error) {
 	return restoreArea(area), out, nil
 }
 
-func FocusList(user int) (FocusData, error) {
+// UserItemListPage returns one page of the items of the list with
+// the focus of the user. A cursor that is malformed or points to an
+// item that is no longer in the list results in an invalid error.
+func (tx Tx) UserItemListPage(user, id int, q PageQuery) (ItemPage, error) {
+	var out ItemPage
+	if q.Order != OrderManual && !q.Archived {
+		items, err := tx.UserItemList(user, id)
+		if err != nil {
+			return out, err
+		}
+		for _, i := range items {
+			if !i.Archived() {
+				out.Items = append(out.Items, i)
+			}
+		}
+		SortItems(out.Items, q.Order)
+		return out, nil
+	}
+	_, items, next, err := tx.db().UserItemListPage(user, id, q.stored())
+	if err != nil {
+		return out, err
+	}
+	for _, i := range items {
+		out.Items = append(out.Items, restoreItem(i))
+	}
+	out.Next = next
+	return out, nil
+}
+
+// UserAreaPage is UserItemListPage for the lists and items of an area.
+func (tx Tx) UserAreaPage(user, id int, q PageQuery) (Area, ThingPage, error) {
+	var out ThingPage
+	if q.Order != OrderManual && !q.Archived {
+		area, things, err := tx.UserArea(user, id)
+		if err != nil {
+			return area, out, err
+		}
+		for _, t := range things {
+			if !t.Archived() {
+				out.Things = append(out.Things, t)
+			}
+		}
+		SortThings(out.Things, q.Order)
+		return area, out, nil
+	}
+	area, things, next, err := tx.db().UserAreaPage(user, id, q.stored())
+	if err != nil {
+		return restoreArea(area), out, err
+	}
+	for _, t := range things {
+		out.Things = append(out.Things, restoreThing(t))
+	}
+	out.Next = next
+	return restoreArea(area), out, nil
+}
+
+func (tx Tx) FocusList(user int) (FocusData, error) {
 	var out FocusData
-	list, err := db.FocusList(user)
+	list, err := tx.db().FocusList(user)
 	if err != nil {
 		return out, err
 	}
@@ -371,52 +628,271 @@ func FocusList(user int) (FocusData, error) {
 	return out, nil
 }
 
-func SetListItemPosition(list, item, pos int) error {
-	return db.SetListItemPosition(list, item, pos)
+func (tx Tx) SetListItemPosition(list, item, pos int) error {
+	err := tx.db().SetListItemPosition(list, item, pos)
+	if err != nil {
+		return err
+	}
+	l, err := tx.db().ListByID(list)
+	if err != nil {
+		return err
+	}
+	i, err := tx.db().ItemByID(item)
+	if err != nil {
+		return err
+	}
+	rl, ri := restoreList(l), restoreItem(i)
+	tx.emit(Event{Type: EventListSorted, List: &rl, Item: &ri, Position: pos})
+	return nil
 }
 
-func SetAreaThingPosition(area int, typ ThingType, id, pos int) error {
-	return db.SetAreaThingPosition(area, stored.ThingType(typ), id, pos)
+func (tx Tx) SetAreaThingPosition(area int, typ ThingType, id, pos int) error {
+	err := tx.db().SetAreaThingPosition(area, stored.ThingType(typ), id, pos)
+	if err != nil {
+		return err
+	}
+	a, err := tx.db().AreaByID(area)
+	if err != nil {
+		return err
+	}
+	ra := restoreArea(a)
+	e := Event{Type: EventAreaSorted, Area: &ra, Position: pos}
+	switch typ {
+	case TypeItem:
+		e.Item = &Item{ID: id}
+	case TypeList:
+		e.List = &List{ID: id}
+	}
+	tx.emit(e)
+	return nil
 }
 
-func SetUserFocus(user, item int, focus FocusState) error {
-	return db.SetUserFocus(user, item, int(focus))
+func (tx Tx) SetUserFocus(user, item int, focus FocusState) error {
+	err := tx.db().SetUserFocus(user, item, int(focus))
+	if err != nil {
+		return err
+	}
+	i, err := tx.db().UserItemByID(user, item)
+	if err != nil {
+		return err
+	}
+	tx.emitItem(EventItemFocusChanged, user, restoreItem(i))
+	return nil
 }
 
-func ListByID(id int) (List, error) {
-	stored, err := db.ListByID(id)
+func (tx Tx) ListByID(id int) (List, error) {
+	stored, err := tx.db().ListByID(id)
 	i := restoreList(stored)
 	return i, err
 }
 
-func SetList(in List) error {
-	return db.SetList(storedList(in))
+func (tx Tx) SetList(in List) error {
+	in.Updated = time.Now().UTC().Truncate(time.Second)
+	err := tx.db().SetList(storedList(in))
+	if err != nil {
+		return err
+	}
+	l, err := tx.db().ListByID(in.ID)
+	if err != nil {
+		return err
+	}
+	tx.emitList(EventListUpdated, restoreList(l))
+	return nil
 }
 
-func forceSetList(in List) error {
-	return db.ForceSetList(storedList(in))
+func (tx Tx) forceSetList(in List) error {
+	return tx.db().ForceSetList(storedList(in))
 }
 
-func forceSetArea(in Area) error {
-	return db.ForceSetArea(storedArea(in))
+func (tx Tx) forceSetArea(in Area) error {
+	return tx.db().ForceSetArea(storedArea(in))
 }
 
-func forceSetUser(in User) error {
-	return db.ForceSetUser(storedUser(in))
+func (tx Tx) forceSetUser(in User) error {
+	return tx.db().ForceSetUser(storedUser(in))
 }
 
-func debugItemList(list int) ([]stored.OrderedListItem, error) {
-	return db.DebugItemList(list)
+func (tx Tx) debugItemList(list int) ([]stored.OrderedListItem, error) {
+	return tx.db().DebugItemList(list)
 }
 
-func UserByID(id int) (User, error) {
-	stored, err := db.UserByID(id)
+func (tx Tx) UserByID(id int) (User, error) {
+	stored, err := tx.db().UserByID(id)
 	i := restoreUser(stored)
 	return i, err
 }
 
-func AreaByID(id int) (Area, error) {
-	stored, err := db.AreaByID(id)
+func (tx Tx) AreaByID(id int) (Area, error) {
+	stored, err := tx.db().AreaByID(id)
 	a := restoreArea(stored)
 	return a, err
 }
+
+func (tx Tx) Items() ([]Item, error) {
+	var out []Item
+	items, err := tx.db().Items()
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range items {
+		out = append(out, restoreItem(i))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+// ItemsByState returns all items with the state sorted by ID.
+func (tx Tx) ItemsByState(state ItemState) ([]Item, error) {
+	var out []Item
+	items, err := tx.db().ItemsByState(int(state))
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range items {
+		out = append(out, restoreItem(i))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+// DueItems returns all open items that have a due date,
+// ordered by due date.
+func (tx Tx) DueItems() ([]Item, error) {
+	items, err := tx.Items()
+	if err != nil {
+		return nil, err
+	}
+	var out []Item
+	for _, i := range items {
+		if i.HasDue() && i.State == ItemOpen {
+			out = append(out, i)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Due.Before(out[j].Due) })
+	return out, nil
+}
+
+func (tx Tx) Lists() ([]List, error) {
+	var out []List
+	lists, err := tx.db().Lists()
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range lists {
+		out = append(out, restoreList(l))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func (tx Tx) Areas() ([]Area, error) {
+	var out []Area
+	areas, err := tx.db().Areas()
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range areas {
+		out = append(out, restoreArea(a))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func (tx Tx) Users() ([]User, error) {
+	var out []User
+	users, err := tx.db().Users()
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range users {
+		out = append(out, restoreUser(u))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+// NewUser creates a new user with the name of in.
+func (tx Tx) NewUser(in User) (User, error) {
+	var err error
+	in.ID, err = tx.db().NewUser(storedUser(in))
+	return in, err
+}
+
+func (tx Tx) NewList(in List) (List, error) {
+	in.Version = 0
+	in.Updated = time.Now().UTC().Truncate(time.Second)
+	var err error
+	in.ID, err = tx.db().NewList(storedList(in))
+	if err == nil {
+		tx.emitList(EventListCreated, in)
+	}
+	return in, err
+}
+
+// NewAreaList creates a new list with the contents of in
+// and adds it to the top of the area.
+func (tx Tx) NewAreaList(area int, in List) (List, error) {
+	in.Version = 0
+	in.Updated = time.Now().UTC().Truncate(time.Second)
+	var err error
+	in.ID, err = tx.db().NewAreaList(area, storedList(in))
+	if err == nil {
+		tx.emitList(EventListCreated, in)
+	}
+	return in, err
+}
+
+func (tx Tx) DeleteList(id int) error {
+	l, err := tx.db().ListByID(id)
+	if err != nil {
+		return err
+	}
+	err = tx.db().DeleteList(id)
+	if err != nil {
+		return err
+	}
+	tx.emitList(EventListDeleted, restoreList(l))
+	return nil
+}
+
+func (tx Tx) NewArea(in Area) (Area, error) {
+	err := ValidateFields(in.Fields)
+	if err != nil {
+		return in, err
+	}
+	in.ID, err = tx.db().NewArea(storedArea(in))
+	if err != nil {
+		return in, err
+	}
+	a, err := tx.db().AreaByID(in.ID)
+	if err != nil {
+		return in, err
+	}
+	in = restoreArea(a)
+	tx.emitArea(EventAreaCreated, in)
+	return in, nil
+}
+
+// SetArea saves title and body of the area. Its things and
+// fields are kept.
+func (tx Tx) SetArea(in Area) error {
+	err := tx.db().SetArea(storedArea(in))
+	if err != nil {
+		return err
+	}
+	tx.emitArea(EventAreaUpdated, in)
+	return nil
+}
+
+func (tx Tx) DeleteArea(id int) error {
+	a, err := tx.db().AreaByID(id)
+	if err != nil {
+		return err
+	}
+	err = tx.db().DeleteArea(id)
+	if err != nil {
+		return err
+	}
+	tx.emitArea(EventAreaDeleted, restoreArea(a))
+	return nil
+}
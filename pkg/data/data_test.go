@@ -14,9 +14,12 @@
 package data
 
 import (
+	"fmt"
 	"log"
 	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/mbertschler/bunny/pkg/data/memory"
 	"github.com/mbertschler/bunny/pkg/data/stored"
@@ -55,7 +58,7 @@ func TestResetDB(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	if i != wantItem {
+	if !reflect.DeepEqual(i, wantItem) {
 		t.Error("not equal")
 	}
 
@@ -89,8 +92,8 @@ func TestItemByID(t *testing.T) {
 		t.Error("item and target are not equal", item, target)
 	}
 	_, err = ItemByID(22)
-	if err == nil {
-		t.Error("should cause an error")
+	if !IsNotFound(err) {
+		t.Error("should cause a not found error", err)
 	}
 }
 
@@ -160,6 +163,41 @@ func TestDeleteItem(t *testing.T) {
 	}
 }
 
+// TestDeletedItemSkipped checks that items which are still
+// referenced after they were deleted are never returned.
+func TestDeletedItemSkipped(t *testing.T) {
+	resetDB()
+	for _, id := range []int{1, 3} {
+		err := db.DeleteItem(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	focus, err := FocusList(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(focus.Focus) != 0 || len(focus.Watch) != 0 || len(focus.Later) != 2 {
+		t.Error("deleted items should be skipped in the focus", focus)
+	}
+	items, err := ItemList(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 3 {
+		t.Error("deleted items should be skipped in the list", items)
+	}
+	for _, i := range append(items, focus.Later...) {
+		if i.ID == 0 {
+			t.Error("got a zero item")
+		}
+	}
+	_, err = StartPomodoro(1)
+	if !IsInvalid(err) {
+		t.Error("a pomodoro needs an existing item", err)
+	}
+}
+
 func TestSetItem(t *testing.T) {
 	resetDB()
 	item, err := ItemByID(2)
@@ -185,6 +223,140 @@ func TestSetItem(t *testing.T) {
 	}
 }
 
+func TestSetItemConflict(t *testing.T) {
+	resetDB()
+	first, err := ItemByID(2)
+	if err != nil {
+		t.Error(err)
+	}
+	second := first
+	first.Title = "first"
+	err = SetItem(first)
+	if err != nil {
+		t.Error(err)
+	}
+	second.Title = "second"
+	err = SetItem(second)
+	if !IsConflict(err) {
+		t.Error("expected a conflict error", err)
+	}
+	item, err := ItemByID(2)
+	if err != nil {
+		t.Error(err)
+	}
+	if item.Title != "first" {
+		t.Error("stale write was saved", item.Title)
+	}
+	if item.Version != first.Version+1 {
+		t.Error("expected version to be increased", item.Version)
+	}
+	item.Title = "third"
+	err = SetItem(item)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSetListConflict(t *testing.T) {
+	resetDB()
+	first, err := ListByID(1)
+	if err != nil {
+		t.Error(err)
+	}
+	second := first
+	err = SetList(first)
+	if err != nil {
+		t.Error(err)
+	}
+	err = SetList(second)
+	if !IsConflict(err) {
+		t.Error("expected a conflict error", err)
+	}
+	items, err := ItemList(1)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(items) != 5 {
+		t.Error("expected list items to be kept", len(items))
+	}
+}
+
+func TestBatch(t *testing.T) {
+	resetDB()
+	err := Batch(func(tx Tx) error {
+		item, err := tx.ItemByID(2)
+		if err != nil {
+			return err
+		}
+		item.Title = "rolled back"
+		err = tx.SetItem(item)
+		if err != nil {
+			return err
+		}
+		return tx.SetItem(Item{ID: 22})
+	})
+	if !IsNotFound(err) {
+		t.Error("expected a not found error", err)
+	}
+	item, err := ItemByID(2)
+	if err != nil {
+		t.Error(err)
+	}
+	if item.Title == "rolled back" {
+		t.Error("batch was not rolled back")
+	}
+
+	err = Batch(func(tx Tx) error {
+		item.Title = "committed"
+		return tx.SetItem(item)
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	item, err = ItemByID(2)
+	if err != nil {
+		t.Error(err)
+	}
+	if item.Title != "committed" {
+		t.Error("batch was not committed", item.Title)
+	}
+}
+
+// TestConcurrentWrites creates and saves items from several
+// goroutines, run it with -race.
+func TestConcurrentWrites(t *testing.T) {
+	resetDB()
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for n := 0; n < 8; n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			item, err := NewItem()
+			if err != nil {
+				errs <- err
+				return
+			}
+			item.Title = fmt.Sprint("item ", n)
+			errs <- SetItem(item)
+		}(n)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	list, err := ItemList(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 13 {
+		t.Error("expected 13 items in the list, got", len(list))
+	}
+}
+
 func TestNewItem(t *testing.T) {
 	resetDB()
 	item1, err := NewItem()
@@ -473,3 +645,159 @@ func isConsistent(list []stored.OrderedListItem) bool {
 	}
 	return true
 }
+
+func TestDueItems(t *testing.T) {
+	resetDB()
+	later, err := NewListItem(1, Item{Title: "later", Due: time.Date(2018, 12, 24, 18, 30, 0, 0, time.Local)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sooner, err := NewListItem(1, Item{Title: "sooner", Due: time.Date(2018, 11, 1, 0, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewListItem(1, Item{Title: "done", State: ItemComplete, Due: time.Date(2018, 10, 1, 0, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := DueItems()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].ID != sooner.ID || items[1].ID != later.ID {
+		t.Fatalf("wrong due items %+v", items)
+	}
+	want := time.Date(2018, 12, 24, 0, 0, 0, 0, time.UTC)
+	if items[1].Due != want {
+		t.Error("due date should be stripped of the time, got", items[1].Due)
+	}
+
+	due, err := ParseDue("2018-11-01")
+	if err != nil || due != sooner.Due {
+		t.Error("ParseDue returned", due, err)
+	}
+	due, err = ParseDue("")
+	if err != nil || !due.IsZero() {
+		t.Error("empty due date should be zero, got", due, err)
+	}
+	_, err = ParseDue("tomorrow")
+	if !IsInvalid(err) {
+		t.Error("invalid due date should fail, got", err)
+	}
+}
+
+func TestNewItemsPastTen(t *testing.T) {
+	resetDB()
+	ids := map[int]bool{}
+	for i := 0; i < 15; i++ {
+		item, err := NewListItem(1, Item{Title: "new"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ids[item.ID] {
+			t.Fatal("ID was handed out twice:", item.ID)
+		}
+		ids[item.ID] = true
+	}
+	items, err := Items()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 7 items from the test data
+	if len(items) != 7+15 {
+		t.Error("items were overwritten, there are", len(items))
+	}
+	user, err := NewUser(User{Name: "second"})
+	if err != nil || user.ID != 2 {
+		t.Error("new user got", user, err)
+	}
+}
+
+func TestUserItemListPage(t *testing.T) {
+	resetDB()
+	for i := 0; i < 12; i++ {
+		item, err := NewListItem(1, Item{Title: "new"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i%4 == 0 {
+			item.State = ItemArchived
+			err = SetItem(item)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	all, err := UserItemList(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, archived := range []bool{false, true} {
+		var want, got []Item
+		for _, item := range all {
+			if item.Archived() == archived {
+				want = append(want, item)
+			}
+		}
+		q := PageQuery{Limit: 4, Archived: archived}
+		for pages := 0; ; pages++ {
+			if pages > len(all) {
+				t.Fatal("paging doesn't end, cursor", q.Cursor)
+			}
+			page, err := UserItemListPage(1, 1, q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(page.Items) > q.Limit {
+				t.Error("page has", len(page.Items), "items, limit is", q.Limit)
+			}
+			got = append(got, page.Items...)
+			if page.Next == "" {
+				break
+			}
+			q.Cursor = page.Next
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("archived=%v: pages returned\n%v\nexpected\n%v", archived, got, want)
+		}
+	}
+
+	_, err = UserItemListPage(1, 1, PageQuery{Cursor: "i9999"})
+	if !IsInvalid(err) {
+		t.Error("cursor of an item outside of the list should be invalid, got", err)
+	}
+	_, err = UserItemListPage(1, 1, PageQuery{Cursor: "x1"})
+	if !IsInvalid(err) {
+		t.Error("malformed cursor should be invalid, got", err)
+	}
+}
+
+func TestUserAreaPage(t *testing.T) {
+	resetDB()
+	_, things, err := UserArea(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []Thing
+	q := PageQuery{Limit: 1}
+	for {
+		_, page, err := UserAreaPage(1, 1, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, page.Things...)
+		if page.Next == "" {
+			break
+		}
+		q.Cursor = page.Next
+	}
+	var want []Thing
+	for _, thing := range things {
+		if !thing.Archived() {
+			want = append(want, thing)
+		}
+	}
+	if len(want) < 2 || !reflect.DeepEqual(got, want) {
+		t.Errorf("pages returned\n%v\nexpected\n%v", got, want)
+	}
+}
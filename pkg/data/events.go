@@ -0,0 +1,149 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"strings"
+	"time"
+)
+
+// Event types that webhooks can subscribe to.
+const (
+	EventItemCreated      = "item.created"
+	EventItemUpdated      = "item.updated"
+	EventItemCompleted    = "item.completed"
+	EventItemArchived     = "item.archived"
+	EventItemReopened     = "item.reopened"
+	EventItemDeleted      = "item.deleted"
+	EventItemFocusChanged = "item.focus_changed"
+	EventListCreated      = "list.created"
+	EventListUpdated      = "list.updated"
+	EventListDeleted      = "list.deleted"
+	EventListSorted       = "list.sorted"
+	EventAreaCreated      = "area.created"
+	EventAreaUpdated      = "area.updated"
+	EventAreaDeleted      = "area.deleted"
+	EventAreaSorted       = "area.sorted"
+)
+
+var eventTypes = []string{
+	EventItemCreated,
+	EventItemUpdated,
+	EventItemCompleted,
+	EventItemArchived,
+	EventItemReopened,
+	EventItemDeleted,
+	EventItemFocusChanged,
+	EventListCreated,
+	EventListUpdated,
+	EventListDeleted,
+	EventListSorted,
+	EventAreaCreated,
+	EventAreaUpdated,
+	EventAreaDeleted,
+	EventAreaSorted,
+}
+
+// EventTypes returns the names of all events.
+func EventTypes() []string {
+	return append([]string(nil), eventTypes...)
+}
+
+// Event describes a change of the data. It is the payload of
+// webhook deliveries.
+type Event struct {
+	ID       string
+	Type     string `json:"Event"`
+	Time     time.Time
+	User     int   `json:",omitempty"` // set for user specific events like focus changes
+	Item     *Item `json:",omitempty"`
+	List     *List `json:",omitempty"`
+	Area     *Area `json:",omitempty"`
+	Position int   `json:",omitempty"` // new position for sort events
+}
+
+// matchEvent reports if the event type matches the filter. Filters
+// are either event types, a wildcard for a kind like "item.*" or "*"
+// for all events.
+func matchEvent(filter, typ string) bool {
+	if filter == "*" || filter == typ {
+		return true
+	}
+	if strings.HasSuffix(filter, ".*") {
+		return strings.HasPrefix(typ, strings.TrimSuffix(filter, "*"))
+	}
+	return false
+}
+
+func validEventFilter(filter string) bool {
+	for _, t := range eventTypes {
+		if matchEvent(filter, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// emit sends the event to the matching webhooks. Inside of a
+// Batch the event is only sent after the batch was committed and
+// dropped if it is rolled back.
+func (tx Tx) emit(e Event) {
+	e.ID = newEventID()
+	e.Time = time.Now().UTC()
+	tx.db().AfterCommit(func() {
+		webhooks.dispatch(e)
+	})
+}
+
+func newEventID() string {
+	buf := make([]byte, 12)
+	_, err := rand.Read(buf)
+	if err != nil {
+		log.Println("event ID error:", err)
+	}
+	return hex.EncodeToString(buf)
+}
+
+func (tx Tx) emitItem(typ string, user int, i Item) {
+	tx.emit(Event{Type: typ, User: user, Item: &i})
+}
+
+func (tx Tx) emitList(typ string, l List) {
+	tx.emit(Event{Type: typ, List: &l})
+}
+
+func (tx Tx) emitArea(typ string, a Area) {
+	tx.emit(Event{Type: typ, Area: &a})
+}
+
+// itemStateEvent returns the event for a change of the item state.
+func itemStateEvent(old, new ItemState) string {
+	if old == new {
+		return ""
+	}
+	switch new {
+	case ItemComplete:
+		if old == ItemOpen {
+			return EventItemCompleted
+		}
+	case ItemArchived:
+		return EventItemArchived
+	case ItemOpen:
+		return EventItemReopened
+	}
+	return ""
+}
@@ -0,0 +1,478 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mbertschler/bunny/pkg/data/stored"
+)
+
+// ExportVersion is the version of the export format that is written
+// by WriteExport. It is increased whenever the format changes, so
+// that older versions of Bunny reject newer exports instead of
+// silently dropping what they don't know. Version 2 added custom
+// fields, priorities, orders, time entries and pomodoros.
+const ExportVersion = 2
+
+// Export is a snapshot of the whole workspace. API tokens, webhooks
+// and the webhook delivery log are not part of it.
+type Export struct {
+	Version  int
+	Exported time.Time
+	Users    []ExportUser
+	Items    []ExportItem
+	Lists    []ExportList
+	Areas    []ExportArea
+	Time     []TimeEntry `json:",omitempty"` // time entries of all users
+}
+
+type ExportUser struct {
+	ID    int
+	Name  string
+	Focus map[FocusState][]int `json:",omitempty"` // ordered item IDs per focus state
+
+	// orders in which the user views lists and areas by ID
+	ListOrder map[int]ViewOrder `json:",omitempty"`
+	AreaOrder map[int]ViewOrder `json:",omitempty"`
+
+	// pomodoro lengths in minutes and completed pomodoros by item ID
+	PomodoroWork  int         `json:",omitempty"`
+	PomodoroBreak int         `json:",omitempty"`
+	Pomodoros     map[int]int `json:",omitempty"`
+}
+
+type ExportItem struct {
+	ID      int
+	Version int
+	State   ItemState
+	Title   string
+	Body    string
+	Due     time.Time
+	Updated time.Time
+
+	Priority Priority       `json:",omitempty"`
+	Fields   map[int]string `json:",omitempty"` // values of custom fields
+}
+
+type ExportList struct {
+	ID      int
+	Version int
+	State   ItemState
+	Title   string
+	Body    string
+	Updated time.Time
+	Items   []int // ordered item IDs
+}
+
+type ExportArea struct {
+	ID     int
+	Title  string
+	Body   string
+	Things []ExportThing // ordered lists and items
+	Fields []Field       `json:",omitempty"`
+}
+
+type ExportThing struct {
+	Type ThingType
+	ID   int
+}
+
+// ExportWorkspace returns a consistent snapshot of the workspace.
+func (tx Tx) ExportWorkspace() (Export, error) {
+	out := Export{
+		Version:  ExportVersion,
+		Exported: time.Now().UTC().Truncate(time.Second),
+		Users:    []ExportUser{},
+		Items:    []ExportItem{},
+		Lists:    []ExportList{},
+		Areas:    []ExportArea{},
+	}
+	snap, err := tx.db().Snapshot()
+	if err != nil {
+		return out, err
+	}
+	for _, u := range snap.Users {
+		eu := ExportUser{ID: u.ID, Name: u.Name}
+		for state, ids := range u.Focus {
+			if len(ids) == 0 {
+				continue
+			}
+			if eu.Focus == nil {
+				eu.Focus = map[FocusState][]int{}
+			}
+			eu.Focus[FocusState(state)] = ids
+		}
+		eu.ListOrder = exportOrders(u.ListOrder)
+		eu.AreaOrder = exportOrders(u.AreaOrder)
+		eu.PomodoroWork, eu.PomodoroBreak = u.PomodoroWork, u.PomodoroBreak
+		eu.Pomodoros = copyCounts(u.Pomodoros)
+		out.Users = append(out.Users, eu)
+	}
+	for _, i := range snap.Items {
+		out.Items = append(out.Items, ExportItem{
+			ID:       i.ID,
+			Version:  i.Version,
+			State:    ItemState(i.State),
+			Title:    i.Title,
+			Body:     i.Body,
+			Due:      i.Due,
+			Updated:  i.Updated,
+			Priority: Priority(i.Priority),
+			Fields:   copyValues(i.Fields),
+		})
+	}
+	for _, l := range snap.Lists {
+		out.Lists = append(out.Lists, ExportList{
+			ID:      l.ID,
+			Version: l.Version,
+			State:   ItemState(l.State),
+			Title:   l.Title,
+			Body:    l.Body,
+			Updated: l.Updated,
+			Items:   l.Items,
+		})
+	}
+	for _, e := range snap.Entries {
+		out.Time = append(out.Time, restoreTimeEntry(e))
+	}
+	for _, a := range snap.Areas {
+		ea := ExportArea{ID: a.ID, Title: a.Title, Body: a.Body, Fields: restoreFields(a.Fields)}
+		for _, t := range a.Things {
+			ea.Things = append(ea.Things, ExportThing{Type: ThingType(t.Type), ID: t.ID})
+		}
+		out.Areas = append(out.Areas, ea)
+	}
+	sort.Slice(out.Users, func(i, j int) bool { return out.Users[i].ID < out.Users[j].ID })
+	sort.Slice(out.Items, func(i, j int) bool { return out.Items[i].ID < out.Items[j].ID })
+	sort.Slice(out.Lists, func(i, j int) bool { return out.Lists[i].ID < out.Lists[j].ID })
+	sort.Slice(out.Areas, func(i, j int) bool { return out.Areas[i].ID < out.Areas[j].ID })
+	sort.Slice(out.Time, func(i, j int) bool { return out.Time[i].ID < out.Time[j].ID })
+	return out, nil
+}
+
+// WriteExport writes a snapshot of the workspace as JSON to w.
+func (tx Tx) WriteExport(w io.Writer) error {
+	e, err := tx.ExportWorkspace()
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(e)
+}
+
+// ReadExport decodes an export and checks that its version
+// is supported.
+func ReadExport(r io.Reader) (Export, error) {
+	var e Export
+	err := json.NewDecoder(r).Decode(&e)
+	if err != nil {
+		return e, stored.WithCause(fmt.Errorf("invalid export: %v", err), stored.CauseInvalid)
+	}
+	if e.Version < 1 || e.Version > ExportVersion {
+		return e, stored.WithCause(fmt.Errorf("unsupported export version %d", e.Version), stored.CauseInvalid)
+	}
+	return e, nil
+}
+
+// ValidateExport checks that all IDs are unique and that lists,
+// areas and focus maps only reference items and lists that are
+// part of the export. Field IDs are unique across all areas.
+// Time entries belong to a user and item of the export and
+// each user has at most one running entry.
+func ValidateExport(e Export) error {
+	var problems []string
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	uniqueIDs := func(kind string, ids []int) map[int]bool {
+		seen := map[int]bool{}
+		for _, id := range ids {
+			if id < 1 {
+				problem("%s has invalid ID %d", kind, id)
+			} else if seen[id] {
+				problem("duplicate %s %d", kind, id)
+			}
+			seen[id] = true
+		}
+		return seen
+	}
+	// noDuplicates reports items that are referenced twice by owner
+	noDuplicates := func(owner string, kind string, ids []int, exists map[int]bool) {
+		seen := map[int]bool{}
+		for _, id := range ids {
+			if !exists[id] {
+				problem("%s references missing %s %d", owner, kind, id)
+			}
+			if seen[id] {
+				problem("%s references %s %d twice", owner, kind, id)
+			}
+			seen[id] = true
+		}
+	}
+
+	var ids []int
+	for _, u := range e.Users {
+		ids = append(ids, u.ID)
+	}
+	users := uniqueIDs("user", ids)
+	ids = nil
+	for _, i := range e.Items {
+		ids = append(ids, i.ID)
+		if _, ok := itemStateNames[i.State]; !ok {
+			problem("item %d has unknown state %d", i.ID, i.State)
+		}
+		if _, ok := priorityNames[i.Priority]; !ok {
+			problem("item %d has unknown priority %d", i.ID, i.Priority)
+		}
+	}
+	items := uniqueIDs("item", ids)
+	ids = nil
+	for _, l := range e.Lists {
+		ids = append(ids, l.ID)
+		if _, ok := itemStateNames[l.State]; !ok {
+			problem("list %d has unknown state %d", l.ID, l.State)
+		}
+	}
+	lists := uniqueIDs("list", ids)
+	ids = nil
+	var fieldIDs []int
+	for _, a := range e.Areas {
+		ids = append(ids, a.ID)
+		for _, f := range a.Fields {
+			fieldIDs = append(fieldIDs, f.ID)
+		}
+		if err := ValidateFields(a.Fields); err != nil {
+			problem("area %d: %v", a.ID, err)
+		}
+	}
+	uniqueIDs("area", ids)
+	uniqueIDs("field", fieldIDs)
+
+	for _, l := range e.Lists {
+		noDuplicates(fmt.Sprint("list ", l.ID), "item", l.Items, items)
+	}
+	for _, a := range e.Areas {
+		var areaItems, areaLists []int
+		for _, t := range a.Things {
+			switch t.Type {
+			case TypeItem:
+				areaItems = append(areaItems, t.ID)
+			case TypeList:
+				areaLists = append(areaLists, t.ID)
+			default:
+				problem("area %d has a thing with unknown type %d", a.ID, t.Type)
+			}
+		}
+		noDuplicates(fmt.Sprint("area ", a.ID), "item", areaItems, items)
+		noDuplicates(fmt.Sprint("area ", a.ID), "list", areaLists, lists)
+	}
+	for _, u := range e.Users {
+		var all []int
+		for state, ids := range u.Focus {
+			if _, ok := focusStateNames[state]; !ok {
+				problem("user %d has unknown focus state %d", u.ID, state)
+			}
+			all = append(all, ids...)
+		}
+		noDuplicates(fmt.Sprint("focus of user ", u.ID), "item", all, items)
+		for _, orders := range []map[int]ViewOrder{u.ListOrder, u.AreaOrder} {
+			for _, o := range orders {
+				if _, ok := viewOrderNames[o]; !ok {
+					problem("user %d has unknown order %d", u.ID, o)
+				}
+			}
+		}
+		if u.PomodoroWork < 0 || time.Duration(u.PomodoroWork)*time.Minute > MaxPomodoroWork ||
+			u.PomodoroBreak < 0 || time.Duration(u.PomodoroBreak)*time.Minute > MaxPomodoroBreak {
+			problem("user %d has invalid pomodoro lengths", u.ID)
+		}
+		// counts of deleted items are kept, like their time entries
+		for id, n := range u.Pomodoros {
+			if n < 1 {
+				problem("user %d has %d pomodoros on item %d", u.ID, n, id)
+			}
+		}
+	}
+	ids = nil
+	running := map[int]bool{}
+	for _, t := range e.Time {
+		ids = append(ids, t.ID)
+		if !users[t.User] {
+			problem("time entry %d references missing user %d", t.ID, t.User)
+		}
+		if !items[t.Item] {
+			problem("time entry %d references missing item %d", t.ID, t.Item)
+		}
+		switch {
+		case t.Start.IsZero():
+			problem("time entry %d has no start", t.ID)
+		case t.Running() && running[t.User]:
+			problem("user %d has more than one running time entry", t.User)
+		case !t.Running() && !t.End.After(t.Start):
+			problem("time entry %d ends before it starts", t.ID)
+		}
+		if t.Running() {
+			running[t.User] = true
+		}
+	}
+	uniqueIDs("time entry", ids)
+
+	if len(problems) > 0 {
+		return stored.WithCause(errors.New("invalid export: "+strings.Join(problems, "; ")), stored.CauseInvalid)
+	}
+	return nil
+}
+
+// ImportWorkspace validates the export and restores it. The
+// database has to be empty, otherwise a conflict error is returned.
+func (tx Tx) ImportWorkspace(e Export) error {
+	if e.Version < 1 || e.Version > ExportVersion {
+		return stored.WithCause(fmt.Errorf("unsupported export version %d", e.Version), stored.CauseInvalid)
+	}
+	err := ValidateExport(e)
+	if err != nil {
+		return err
+	}
+	return tx.Batch(func(tx Tx) error {
+		empty, err := tx.isEmpty()
+		if err != nil {
+			return err
+		}
+		if !empty {
+			return stored.WithCause(errors.New("the database is not empty"), stored.CauseConflict)
+		}
+		for _, u := range e.Users {
+			su := stored.User{ID: u.ID, Name: u.Name}
+			if len(u.Focus) > 0 {
+				su.Focus = map[int][]int{}
+				for state, ids := range u.Focus {
+					su.Focus[int(state)] = ids
+				}
+			}
+			su.ListOrder = importOrders(u.ListOrder)
+			su.AreaOrder = importOrders(u.AreaOrder)
+			su.PomodoroWork, su.PomodoroBreak = u.PomodoroWork, u.PomodoroBreak
+			su.Pomodoros = copyCounts(u.Pomodoros)
+			err = tx.db().ForceSetUser(su)
+			if err != nil {
+				return err
+			}
+		}
+		for _, i := range e.Items {
+			err = tx.db().ForceSetItem(stored.Item{
+				ID:       i.ID,
+				Version:  i.Version,
+				State:    int(i.State),
+				Title:    i.Title,
+				Body:     i.Body,
+				Due:      dueDay(i.Due),
+				Updated:  i.Updated,
+				Priority: int(i.Priority),
+				Fields:   copyValues(i.Fields),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		for _, l := range e.Lists {
+			err = tx.db().ForceSetList(stored.List{
+				ID:      l.ID,
+				Version: l.Version,
+				State:   int(l.State),
+				Title:   l.Title,
+				Body:    l.Body,
+				Updated: l.Updated,
+				Items:   l.Items,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		for _, a := range e.Areas {
+			sa := stored.Area{ID: a.ID, Title: a.Title, Body: a.Body, Fields: storedFields(a.Fields)}
+			for _, t := range a.Things {
+				sa.Things = append(sa.Things, stored.ThingID{Type: stored.ThingType(t.Type), ID: t.ID})
+			}
+			err = tx.db().ForceSetArea(sa)
+			if err != nil {
+				return err
+			}
+		}
+		for _, t := range e.Time {
+			err = tx.db().ForceSetTimeEntry(storedTimeEntry(t))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func exportOrders(in map[int]int) map[int]ViewOrder {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make(map[int]ViewOrder, len(in))
+	for id, o := range in {
+		out[id] = ViewOrder(o)
+	}
+	return out
+}
+
+func importOrders(in map[int]ViewOrder) map[int]int {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make(map[int]int, len(in))
+	for id, o := range in {
+		out[id] = int(o)
+	}
+	return out
+}
+
+func copyCounts(in map[int]int) map[int]int {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make(map[int]int, len(in))
+	for id, n := range in {
+		out[id] = n
+	}
+	return out
+}
+
+func (tx Tx) isEmpty() (bool, error) {
+	users, err := tx.db().Users()
+	if err != nil || len(users) > 0 {
+		return false, err
+	}
+	items, err := tx.db().Items()
+	if err != nil || len(items) > 0 {
+		return false, err
+	}
+	lists, err := tx.db().Lists()
+	if err != nil || len(lists) > 0 {
+		return false, err
+	}
+	areas, err := tx.db().Areas()
+	return len(areas) == 0, err
+}
@@ -0,0 +1,141 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mbertschler/bunny/pkg/data/memory"
+)
+
+func TestExportImport(t *testing.T) {
+	resetDB()
+	err := SetListItemPosition(1, 3, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	err = WriteExport(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"Type": "list"`) || !strings.Contains(buf.String(), `"later": [`) {
+		t.Error("export should use names for types and states", buf.String())
+	}
+	exported, err := ReadExport(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db = memory.Open()
+	err = ImportWorkspace(exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reimported, err := ExportWorkspace()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reimported.Exported = exported.Exported
+	if !reflect.DeepEqual(exported, reimported) {
+		t.Error("export after import differs\n", exported, "\n", reimported)
+	}
+	items, err := ItemList(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if items[0].ID != 3 {
+		t.Error("list order should be restored", items)
+	}
+	focus, err := FocusList(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(focus.Focus) != 1 || focus.Focus[0].ID != 1 {
+		t.Error("focus should be restored", focus)
+	}
+
+	err = ImportWorkspace(exported)
+	if !IsConflict(err) {
+		t.Error("import into a non-empty database should fail with a conflict", err)
+	}
+}
+
+func TestImportValidation(t *testing.T) {
+	start := time.Date(2018, 3, 5, 9, 0, 0, 0, time.UTC)
+	valid := func() Export {
+		return Export{
+			Version: ExportVersion,
+			Users:   []ExportUser{{ID: 1, Name: "martin", Focus: map[FocusState][]int{FocusNow: {1}}}},
+			Items:   []ExportItem{{ID: 1, State: ItemOpen}, {ID: 2, State: ItemOpen}},
+			Lists:   []ExportList{{ID: 1, State: ItemOpen, Items: []int{1, 2}}},
+			Areas:   []ExportArea{{ID: 1, Things: []ExportThing{{TypeList, 1}, {TypeItem, 2}}}},
+			Time:    []TimeEntry{{ID: 1, User: 1, Item: 1, Start: start}},
+		}
+	}
+	err := ValidateExport(valid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]func(e *Export){
+		"version":           func(e *Export) { e.Version = 99 },
+		"duplicate item":    func(e *Export) { e.Items = append(e.Items, ExportItem{ID: 1}) },
+		"zero ID":           func(e *Export) { e.Lists[0].ID = 0 },
+		"missing item":      func(e *Export) { e.Lists[0].Items = append(e.Lists[0].Items, 9) },
+		"item twice":        func(e *Export) { e.Lists[0].Items = append(e.Lists[0].Items, 1) },
+		"missing list":      func(e *Export) { e.Areas[0].Things[0].ID = 9 },
+		"unknown type":      func(e *Export) { e.Areas[0].Things[0].Type = 9 },
+		"missing focus":     func(e *Export) { e.Users[0].Focus[FocusLater] = []int{9} },
+		"focus twice":       func(e *Export) { e.Users[0].Focus[FocusLater] = []int{1} },
+		"unknown state":     func(e *Export) { e.Items[0].State = 9 },
+		"duplicate user":    func(e *Export) { e.Users = append(e.Users, ExportUser{ID: 1}) },
+		"duplicate area":    func(e *Export) { e.Areas = append(e.Areas, ExportArea{ID: 1}) },
+		"bad focus state":   func(e *Export) { e.Users[0].Focus[FocusState(9)] = []int{2} },
+		"missing area item": func(e *Export) { e.Areas[0].Things[1].ID = 9 },
+		"entry user":        func(e *Export) { e.Time[0].User = 9 },
+		"entry end":         func(e *Export) { e.Time[0].End = start },
+		"two running":       func(e *Export) { e.Time = append(e.Time, TimeEntry{ID: 2, User: 1, Item: 2, Start: start}) },
+	}
+	for name, change := range cases {
+		resetDB()
+		db = memory.Open()
+		e := valid()
+		change(&e)
+		err := ImportWorkspace(e)
+		if !IsInvalid(err) {
+			t.Error(name, "should be invalid, got", err)
+		}
+		users, _ := Users()
+		if len(users) != 0 {
+			t.Error(name, "shouldn't import anything")
+		}
+	}
+
+	_, err = ReadExport(strings.NewReader(`{"Version": 3}`))
+	if !IsInvalid(err) {
+		t.Error("unsupported versions should be invalid", err)
+	}
+	_, err = ReadExport(strings.NewReader(`{"Version": 1}`))
+	if err != nil {
+		t.Error("older versions should be supported", err)
+	}
+	_, err = ReadExport(strings.NewReader(`{"Version": 1, "Items": [{"State": "nope"}]}`))
+	if !IsInvalid(err) {
+		t.Error("unknown states should be invalid", err)
+	}
+}
@@ -0,0 +1,393 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mbertschler/bunny/pkg/data/stored"
+)
+
+type FieldType int8
+
+const (
+	FieldText   FieldType = stored.FieldText
+	FieldNumber FieldType = stored.FieldNumber
+	FieldDate   FieldType = stored.FieldDate
+	FieldSelect FieldType = stored.FieldSelect
+	FieldURL    FieldType = stored.FieldURL
+)
+
+// MaxFieldValue is the maximum length of a field value in bytes.
+const MaxFieldValue = 1000
+
+// Field is a custom field of the items in an area. Items in a
+// list have the fields of all areas that contain the list.
+type Field struct {
+	ID      int
+	Name    string
+	Type    FieldType
+	Options []string `json:",omitempty"` // choices of select fields
+}
+
+func invalidField(format string, args ...interface{}) error {
+	return stored.WithCause(fmt.Errorf(format, args...), stored.CauseInvalid)
+}
+
+// Parse checks that value is valid for the field and returns its
+// canonical form. An empty value means that the field is not set.
+func (f Field) Parse(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+	if len(value) > MaxFieldValue {
+		return "", invalidField("value of %q is longer than %d bytes", f.Name, MaxFieldValue)
+	}
+	switch f.Type {
+	case FieldText:
+		return value, nil
+	case FieldNumber:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+			return "", invalidField("value of %q is not a number: %q", f.Name, value)
+		}
+		return strconv.FormatFloat(n, 'f', -1, 64), nil
+	case FieldDate:
+		t, err := ParseDue(value)
+		if err != nil {
+			return "", invalidField("value of %q is not a date: %q", f.Name, value)
+		}
+		return t.Format(DueFormat), nil
+	case FieldSelect:
+		if f.option(value) < 0 {
+			return "", invalidField("value of %q is not one of its options: %q", f.Name, value)
+		}
+		return value, nil
+	case FieldURL:
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "", invalidField("value of %q is not an http or https URL: %q", f.Name, value)
+		}
+		return u.String(), nil
+	}
+	return "", invalidField("field %q has unknown type %d", f.Name, f.Type)
+}
+
+func (f Field) option(value string) int {
+	for i, o := range f.Options {
+		if o == value {
+			return i
+		}
+	}
+	return -1
+}
+
+// less reports whether the valid value a sorts before b. Numbers and
+// dates sort by value, select fields in the order of their options
+// and texts ignore case.
+func (f Field) less(a, b string) bool {
+	switch f.Type {
+	case FieldNumber:
+		x, _ := strconv.ParseFloat(a, 64)
+		y, _ := strconv.ParseFloat(b, 64)
+		return x < y
+	case FieldDate:
+		return a < b
+	case FieldSelect:
+		return f.option(a) < f.option(b)
+	}
+	return strings.ToLower(a) < strings.ToLower(b)
+}
+
+// matches reports whether the value matches the filter. Texts and
+// URLs match if they contain the filter, other types have to be equal.
+func (f Field) matches(value, filter string) bool {
+	switch f.Type {
+	case FieldText, FieldURL:
+		return strings.Contains(strings.ToLower(value), strings.ToLower(filter))
+	}
+	return value == filter
+}
+
+// ValidateFields checks the definitions of the fields of an area.
+// New fields have the ID 0 and get their ID when they are saved.
+func ValidateFields(fields []Field) error {
+	ids := map[int]bool{}
+	names := map[string]bool{}
+	for _, f := range fields {
+		if f.ID < 0 || (f.ID > 0 && ids[f.ID]) {
+			return invalidField("invalid field ID %d", f.ID)
+		}
+		ids[f.ID] = true
+		name := strings.ToLower(strings.TrimSpace(f.Name))
+		if name == "" {
+			return invalidField("field name is required")
+		}
+		if names[name] {
+			return invalidField("duplicate field name %q", f.Name)
+		}
+		names[name] = true
+		if _, ok := fieldTypeNames[f.Type]; !ok {
+			return invalidField("field %q has unknown type %d", f.Name, f.Type)
+		}
+		if f.Type == FieldSelect && len(f.Options) == 0 {
+			return invalidField("select field %q needs options", f.Name)
+		}
+		if f.Type != FieldSelect && len(f.Options) > 0 {
+			return invalidField("only select fields have options")
+		}
+	}
+	return nil
+}
+
+// SetFields sets the values of the fields of the item. Values
+// for fields that are not in fields are invalid, empty values
+// remove the value. Values of other fields are kept.
+func (i *Item) SetFields(fields []Field, values map[int]string) error {
+	out := copyValues(i.Fields)
+	for id, value := range values {
+		f, ok := fieldByID(fields, id)
+		if !ok {
+			return invalidField("item %d has no field %d", i.ID, id)
+		}
+		value, err := f.Parse(value)
+		if err != nil {
+			return err
+		}
+		if value == "" {
+			delete(out, id)
+			continue
+		}
+		if out == nil {
+			out = map[int]string{}
+		}
+		out[id] = value
+	}
+	i.Fields = out
+	return nil
+}
+
+func fieldByID(fields []Field, id int) (Field, bool) {
+	for _, f := range fields {
+		if f.ID == id {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+// ItemFields returns the custom fields of the item. These are the
+// fields of the areas that contain the item or one of its lists.
+func (tx Tx) ItemFields(id int) ([]Field, error) {
+	areas, err := tx.db().ItemAreas(id)
+	if err != nil {
+		return nil, err
+	}
+	lists, err := tx.db().ItemLists(id)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range lists {
+		la, err := tx.db().ListAreas(l)
+		if err != nil {
+			return nil, err
+		}
+		areas = append(areas, la...)
+	}
+	return tx.areaFields(areas)
+}
+
+// ListFields returns the custom fields of the items in the list.
+func (tx Tx) ListFields(id int) ([]Field, error) {
+	areas, err := tx.db().ListAreas(id)
+	if err != nil {
+		return nil, err
+	}
+	return tx.areaFields(areas)
+}
+
+func (tx Tx) areaFields(areas []int) ([]Field, error) {
+	sort.Ints(areas)
+	var out []Field
+	for i, id := range areas {
+		if i > 0 && areas[i-1] == id {
+			continue
+		}
+		a, err := tx.db().AreaByID(id)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, restoreFields(a.Fields)...)
+	}
+	return out, nil
+}
+
+// AddAreaField adds a new field to the area and returns it
+// with its ID.
+func (tx Tx) AddAreaField(area int, f Field) (Field, error) {
+	f.ID = 0
+	saved, err := tx.db().SetAreaFields(area, func(old []stored.Field) ([]stored.Field, error) {
+		fields := append(restoreFields(old), f)
+		return storedFields(fields), ValidateFields(fields)
+	})
+	if err != nil {
+		return f, err
+	}
+	a := restoreArea(saved)
+	tx.emitArea(EventAreaUpdated, a)
+	return a.Fields[len(a.Fields)-1], nil
+}
+
+// DeleteAreaField removes the field from the area. Values that
+// items have for the field are kept, but no longer shown.
+func (tx Tx) DeleteAreaField(area, id int) error {
+	saved, err := tx.db().SetAreaFields(area, func(old []stored.Field) ([]stored.Field, error) {
+		var fields []stored.Field
+		for _, f := range old {
+			if f.ID != id {
+				fields = append(fields, f)
+			}
+		}
+		if len(fields) == len(old) {
+			return nil, stored.WithCause(fmt.Errorf("area %d has no field %d", area, id), stored.CauseNotFound)
+		}
+		return fields, nil
+	})
+	if err != nil {
+		return err
+	}
+	tx.emitArea(EventAreaUpdated, restoreArea(saved))
+	return nil
+}
+
+// ListView sorts and filters the items of a list by custom fields.
+type ListView struct {
+	Order  ViewOrder // used if there is no sort field
+	Sort   int       // ID of the field to sort by, 0 sorts by Order
+	Desc   bool      // sort in descending order
+	Filter int       // ID of the field to filter by, 0 shows all items
+	Value  string    // value that the filter field has to match
+}
+
+// Active reports whether the view sorts or filters by custom fields.
+func (v ListView) Active() bool {
+	return v.Sort != 0 || v.Filter != 0
+}
+
+// Apply returns the items that are not archived and match the
+// filter, sorted by the sort field or else by Order. Items without
+// a value for the sort field come last.
+func (v ListView) Apply(items []Item, fields []Field) ([]Item, error) {
+	var filter, sorted Field
+	var ok bool
+	if v.Filter != 0 {
+		filter, ok = fieldByID(fields, v.Filter)
+		if !ok {
+			return nil, invalidField("unknown filter field %d", v.Filter)
+		}
+	}
+	if v.Sort != 0 {
+		sorted, ok = fieldByID(fields, v.Sort)
+		if !ok {
+			return nil, invalidField("unknown sort field %d", v.Sort)
+		}
+	}
+	out := []Item{}
+	for _, i := range items {
+		if i.Archived() {
+			continue
+		}
+		if v.Filter != 0 && !filter.matches(i.Fields[v.Filter], v.Value) {
+			continue
+		}
+		out = append(out, i)
+	}
+	if v.Sort == 0 {
+		SortItems(out, v.Order)
+		return out, nil
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i].Fields[v.Sort], out[j].Fields[v.Sort]
+		if a == "" || b == "" {
+			return a != "" && b == ""
+		}
+		if v.Desc {
+			return sorted.less(b, a)
+		}
+		return sorted.less(a, b)
+	})
+	return out, nil
+}
+
+// UserListView returns the list and its fields and the items of
+// the list with the view applied.
+func (tx Tx) UserListView(user, list int, v ListView) (List, []Field, []Item, error) {
+	l, err := tx.ListByID(list)
+	if err != nil {
+		return l, nil, nil, err
+	}
+	fields, err := tx.ListFields(list)
+	if err != nil {
+		return l, nil, nil, err
+	}
+	items, err := tx.UserItemList(user, list)
+	if err != nil {
+		return l, nil, nil, err
+	}
+	items, err = v.Apply(items, fields)
+	return l, fields, items, err
+}
+
+func storedFields(in []Field) []stored.Field {
+	var out []stored.Field
+	for _, f := range in {
+		out = append(out, stored.Field{
+			ID:      f.ID,
+			Name:    f.Name,
+			Type:    int(f.Type),
+			Options: append([]string(nil), f.Options...),
+		})
+	}
+	return out
+}
+
+func restoreFields(in []stored.Field) []Field {
+	var out []Field
+	for _, f := range in {
+		out = append(out, Field{
+			ID:      f.ID,
+			Name:    f.Name,
+			Type:    FieldType(f.Type),
+			Options: append([]string(nil), f.Options...),
+		})
+	}
+	return out
+}
+
+func copyValues(in map[int]string) map[int]string {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make(map[int]string, len(in))
+	for k, v := range in {
+		out[k] = v
+	}
+	return out
+}
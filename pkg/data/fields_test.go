@@ -0,0 +1,207 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/mbertschler/bunny/pkg/data/memory"
+)
+
+func TestFieldParse(t *testing.T) {
+	cases := []struct {
+		field Field
+		in    string
+		out   string
+		ok    bool
+	}{
+		{Field{Type: FieldText}, " some text ", "some text", true},
+		{Field{Type: FieldText}, "", "", true},
+		{Field{Type: FieldNumber}, "1.50", "1.5", true},
+		{Field{Type: FieldNumber}, "-3", "-3", true},
+		{Field{Type: FieldNumber}, "three", "", false},
+		{Field{Type: FieldNumber}, "NaN", "", false},
+		{Field{Type: FieldDate}, "2018-12-31", "2018-12-31", true},
+		{Field{Type: FieldDate}, "31.12.2018", "", false},
+		{Field{Type: FieldSelect, Options: []string{"low", "high"}}, "high", "high", true},
+		{Field{Type: FieldSelect, Options: []string{"low", "high"}}, "medium", "", false},
+		{Field{Type: FieldURL}, "https://example.com/a", "https://example.com/a", true},
+		{Field{Type: FieldURL}, "javascript:alert(1)", "", false},
+		{Field{Type: FieldURL}, "example.com", "", false},
+	}
+	for _, c := range cases {
+		out, err := c.field.Parse(c.in)
+		if c.ok && (err != nil || out != c.out) {
+			t.Errorf("%d %q: got %q, %v, want %q", c.field.Type, c.in, out, err, c.out)
+		}
+		if !c.ok && !IsInvalid(err) {
+			t.Errorf("%d %q should be invalid, got %q, %v", c.field.Type, c.in, out, err)
+		}
+	}
+}
+
+func TestValidateFields(t *testing.T) {
+	valid := []Field{
+		{Name: "Estimate", Type: FieldNumber},
+		{ID: 3, Name: "Stage", Type: FieldSelect, Options: []string{"a"}},
+	}
+	if err := ValidateFields(valid); err != nil {
+		t.Error(err)
+	}
+	for _, fields := range [][]Field{
+		{{Name: "", Type: FieldText}},
+		{{Name: "Size", Type: 0}},
+		{{Name: "Stage", Type: FieldSelect}},
+		{{Name: "Link", Type: FieldURL, Options: []string{"a"}}},
+		{{Name: "a", Type: FieldText}, {Name: "A", Type: FieldDate}},
+		{{ID: 2, Name: "a", Type: FieldText}, {ID: 2, Name: "b", Type: FieldText}},
+	} {
+		if err := ValidateFields(fields); !IsInvalid(err) {
+			t.Error("expected invalid fields", fields, err)
+		}
+	}
+}
+
+func TestListView(t *testing.T) {
+	fields := []Field{
+		{ID: 1, Name: "Estimate", Type: FieldNumber},
+		{ID: 2, Name: "Stage", Type: FieldSelect, Options: []string{"todo", "doing", "done"}},
+		{ID: 3, Name: "Notes", Type: FieldText},
+	}
+	items := []Item{
+		{ID: 1, Fields: map[int]string{1: "10", 2: "done"}},
+		{ID: 2, Fields: map[int]string{1: "9", 2: "todo", 3: "Waiting for Bob"}},
+		{ID: 3, Fields: map[int]string{2: "doing"}},
+		{ID: 4, Fields: map[int]string{1: "1"}, State: ItemArchived},
+		{ID: 5, Fields: map[int]string{1: "100", 3: "bob said no"}},
+	}
+	ids := func(items []Item) []int {
+		var out []int
+		for _, i := range items {
+			out = append(out, i.ID)
+		}
+		return out
+	}
+	cases := []struct {
+		view ListView
+		want []int
+	}{
+		{ListView{}, []int{1, 2, 3, 5}},
+		{ListView{Sort: 1}, []int{2, 1, 5, 3}},
+		{ListView{Sort: 1, Desc: true}, []int{5, 1, 2, 3}},
+		{ListView{Sort: 2}, []int{2, 3, 1, 5}},
+		{ListView{Filter: 3, Value: "BOB"}, []int{2, 5}},
+		{ListView{Filter: 2, Value: "do"}, nil},
+		{ListView{Sort: 1, Filter: 2, Value: "done"}, []int{1}},
+	}
+	for _, c := range cases {
+		got, err := c.view.Apply(items, fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(ids(got), c.want) {
+			t.Errorf("%+v: got %v, want %v", c.view, ids(got), c.want)
+		}
+	}
+	_, err := ListView{Sort: 9}.Apply(items, fields)
+	if !IsInvalid(err) {
+		t.Error("sorting by an unknown field should be invalid", err)
+	}
+}
+
+func TestAreaFields(t *testing.T) {
+	resetDB()
+	stage, err := AddAreaField(1, Field{Name: "Stage", Type: FieldSelect, Options: []string{"todo", "done"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	link, err := AddAreaField(1, Field{Name: "Link", Type: FieldURL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stage.ID == 0 || link.ID == stage.ID {
+		t.Error("fields should get unique IDs", stage.ID, link.ID)
+	}
+	_, err = AddAreaField(1, Field{Name: "stage", Type: FieldText})
+	if !IsInvalid(err) {
+		t.Error("expected duplicate name to be invalid", err)
+	}
+
+	// item 3 is in list 1, which is in area 1
+	fields, err := ItemFields(3)
+	if err != nil || len(fields) != 2 {
+		t.Fatal("unexpected fields", fields, err)
+	}
+	item, err := ItemByID(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = item.SetFields(fields, map[int]string{stage.ID: "done", link.ID: "https://example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = SetItem(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = item.SetFields(fields, map[int]string{99: "x"})
+	if !IsInvalid(err) {
+		t.Error("expected unknown field to be invalid", err)
+	}
+
+	_, _, items, err := UserListView(1, 1, ListView{Filter: stage.ID, Value: "done"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].ID != 3 || items[0].Fields[link.ID] != "https://example.com" {
+		t.Error("unexpected filtered items", items)
+	}
+
+	var buf bytes.Buffer
+	err = WriteExport(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exported, err := ReadExport(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db = memory.Open()
+	err = ImportWorkspace(exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, err := ItemByID(3)
+	if err != nil || imported.Fields[stage.ID] != "done" {
+		t.Error("field values should survive export and import", imported.Fields, err)
+	}
+	next, err := AddAreaField(1, Field{Name: "Size", Type: FieldNumber})
+	if err != nil || next.ID <= link.ID {
+		t.Error("imported field IDs should not be reused", next.ID, err)
+	}
+
+	err = DeleteAreaField(1, stage.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields, err = ItemFields(3)
+	if err != nil || len(fields) != 2 {
+		t.Error("expected deleted field to be gone", fields, err)
+	}
+	if !IsNotFound(DeleteAreaField(1, stage.ID)) {
+		t.Error("deleting a missing field should fail")
+	}
+}
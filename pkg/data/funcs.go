@@ -0,0 +1,320 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data
+
+import (
+	"io"
+	"time"
+
+	"github.com/mbertschler/bunny/pkg/data/stored"
+)
+
+// The package functions run outside of a batch, each of them in its
+// own transaction. They are the methods of the zero Tx.
+
+func ItemByID(id int) (Item, error) {
+	return Tx{}.ItemByID(id)
+}
+
+func UserItemByID(user, id int) (Item, error) {
+	return Tx{}.UserItemByID(user, id)
+}
+
+func SetItem(in Item) error {
+	return Tx{}.SetItem(in)
+}
+
+func forceSetItem(in Item) error {
+	return Tx{}.forceSetItem(in)
+}
+
+func NewItem() (Item, error) {
+	return Tx{}.NewItem()
+}
+
+func NewListItem(list int, in Item) (Item, error) {
+	return Tx{}.NewListItem(list, in)
+}
+
+func NewAreaItem(area int, in Item) (Item, error) {
+	return Tx{}.NewAreaItem(area, in)
+}
+
+func SortFocusItem(user, id, after int) error {
+	return Tx{}.SortFocusItem(user, id, after)
+}
+
+func SetFocus(user, id int, focus FocusState) error {
+	return Tx{}.SetFocus(user, id, focus)
+}
+
+func DeleteItem(id int) error {
+	return Tx{}.DeleteItem(id)
+}
+
+func ItemList(id int) ([]Item, error) {
+	return Tx{}.ItemList(id)
+}
+
+func UserItemList(user, id int) ([]Item, error) {
+	return Tx{}.UserItemList(user, id)
+}
+
+func UserArea(user, id int) (Area, []Thing, error) {
+	return Tx{}.UserArea(user, id)
+}
+
+func UserItemListPage(user, id int, q PageQuery) (ItemPage, error) {
+	return Tx{}.UserItemListPage(user, id, q)
+}
+
+func UserAreaPage(user, id int, q PageQuery) (Area, ThingPage, error) {
+	return Tx{}.UserAreaPage(user, id, q)
+}
+
+func FocusList(user int) (FocusData, error) {
+	return Tx{}.FocusList(user)
+}
+
+func SetListItemPosition(list, item, pos int) error {
+	return Tx{}.SetListItemPosition(list, item, pos)
+}
+
+func SetAreaThingPosition(area int, typ ThingType, id, pos int) error {
+	return Tx{}.SetAreaThingPosition(area, typ, id, pos)
+}
+
+func SetUserFocus(user, item int, focus FocusState) error {
+	return Tx{}.SetUserFocus(user, item, focus)
+}
+
+func ListByID(id int) (List, error) {
+	return Tx{}.ListByID(id)
+}
+
+func SetList(in List) error {
+	return Tx{}.SetList(in)
+}
+
+func forceSetList(in List) error {
+	return Tx{}.forceSetList(in)
+}
+
+func forceSetArea(in Area) error {
+	return Tx{}.forceSetArea(in)
+}
+
+func forceSetUser(in User) error {
+	return Tx{}.forceSetUser(in)
+}
+
+func debugItemList(list int) ([]stored.OrderedListItem, error) {
+	return Tx{}.debugItemList(list)
+}
+
+func UserByID(id int) (User, error) {
+	return Tx{}.UserByID(id)
+}
+
+func AreaByID(id int) (Area, error) {
+	return Tx{}.AreaByID(id)
+}
+
+func Items() ([]Item, error) {
+	return Tx{}.Items()
+}
+
+func ItemsByState(state ItemState) ([]Item, error) {
+	return Tx{}.ItemsByState(state)
+}
+
+func DueItems() ([]Item, error) {
+	return Tx{}.DueItems()
+}
+
+func Lists() ([]List, error) {
+	return Tx{}.Lists()
+}
+
+func Areas() ([]Area, error) {
+	return Tx{}.Areas()
+}
+
+func Users() ([]User, error) {
+	return Tx{}.Users()
+}
+
+func NewUser(in User) (User, error) {
+	return Tx{}.NewUser(in)
+}
+
+func NewList(in List) (List, error) {
+	return Tx{}.NewList(in)
+}
+
+func NewAreaList(area int, in List) (List, error) {
+	return Tx{}.NewAreaList(area, in)
+}
+
+func DeleteList(id int) error {
+	return Tx{}.DeleteList(id)
+}
+
+func NewArea(in Area) (Area, error) {
+	return Tx{}.NewArea(in)
+}
+
+func SetArea(in Area) error {
+	return Tx{}.SetArea(in)
+}
+
+func DeleteArea(id int) error {
+	return Tx{}.DeleteArea(id)
+}
+
+func ExportWorkspace() (Export, error) {
+	return Tx{}.ExportWorkspace()
+}
+
+func WriteExport(w io.Writer) error {
+	return Tx{}.WriteExport(w)
+}
+
+func ImportWorkspace(e Export) error {
+	return Tx{}.ImportWorkspace(e)
+}
+
+func isEmpty() (bool, error) {
+	return Tx{}.isEmpty()
+}
+
+func ItemFields(id int) ([]Field, error) {
+	return Tx{}.ItemFields(id)
+}
+
+func ListFields(id int) ([]Field, error) {
+	return Tx{}.ListFields(id)
+}
+
+func AddAreaField(area int, f Field) (Field, error) {
+	return Tx{}.AddAreaField(area, f)
+}
+
+func DeleteAreaField(area, id int) error {
+	return Tx{}.DeleteAreaField(area, id)
+}
+
+func UserListView(user, list int, v ListView) (List, []Field, []Item, error) {
+	return Tx{}.UserListView(user, list, v)
+}
+
+func UserListOrder(user, list int) (ViewOrder, error) {
+	return Tx{}.UserListOrder(user, list)
+}
+
+func SetUserListOrder(user, list int, o ViewOrder) error {
+	return Tx{}.SetUserListOrder(user, list, o)
+}
+
+func UserAreaOrder(user, area int) (ViewOrder, error) {
+	return Tx{}.UserAreaOrder(user, area)
+}
+
+func SetUserAreaOrder(user, area int, o ViewOrder) error {
+	return Tx{}.SetUserAreaOrder(user, area, o)
+}
+
+func UserPomodoro(user int) (Pomodoro, error) {
+	return Tx{}.UserPomodoro(user)
+}
+
+func SetPomodoroLengths(user int, work, brk time.Duration) error {
+	return Tx{}.SetPomodoroLengths(user, work, brk)
+}
+
+func StartPomodoro(user int) (PomodoroSession, error) {
+	return Tx{}.StartPomodoro(user)
+}
+
+func StartPomodoroBreak(user int) (PomodoroSession, error) {
+	return Tx{}.StartPomodoroBreak(user)
+}
+
+func StopPomodoro(user int) error {
+	return Tx{}.StopPomodoro(user)
+}
+
+func FinishPomodoro(user int) (PomodoroSession, error) {
+	return Tx{}.FinishPomodoro(user)
+}
+
+func UserTimeEntries(user int, from, to time.Time) ([]TimeEntry, error) {
+	return Tx{}.UserTimeEntries(user, from, to)
+}
+
+func TimeEntryByID(user, id int) (TimeEntry, error) {
+	return Tx{}.TimeEntryByID(user, id)
+}
+
+func NewTimeEntry(user int, in TimeEntry) (TimeEntry, error) {
+	return Tx{}.NewTimeEntry(user, in)
+}
+
+func SetTimeEntry(user int, in TimeEntry) error {
+	return Tx{}.SetTimeEntry(user, in)
+}
+
+func DeleteTimeEntry(user, id int) error {
+	return Tx{}.DeleteTimeEntry(user, id)
+}
+
+func UserTimesheet(user int, t time.Time) (Timesheet, error) {
+	return Tx{}.UserTimesheet(user, t)
+}
+
+func NewToken(user int, name string, scope TokenScope, expires time.Time) (Token, string, error) {
+	return Tx{}.NewToken(user, name, scope, expires)
+}
+
+func UserTokens(user int) ([]Token, error) {
+	return Tx{}.UserTokens(user)
+}
+
+func RevokeToken(user, id int) error {
+	return Tx{}.RevokeToken(user, id)
+}
+
+func Authenticate(secret string) (Token, error) {
+	return Tx{}.Authenticate(secret)
+}
+
+func NewWebhook(in Webhook) (Webhook, error) {
+	return Tx{}.NewWebhook(in)
+}
+
+func UserWebhooks(user int) ([]Webhook, error) {
+	return Tx{}.UserWebhooks(user)
+}
+
+func UserWebhookByID(user, id int) (Webhook, error) {
+	return Tx{}.UserWebhookByID(user, id)
+}
+
+func DeleteWebhook(user, id int) error {
+	return Tx{}.DeleteWebhook(user, id)
+}
+
+func WebhookDeliveries(user, id int) ([]Delivery, error) {
+	return Tx{}.WebhookDeliveries(user, id)
+}
@@ -0,0 +1,64 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data
+
+// Problem is an inconsistency that was found by Check.
+type Problem struct {
+	Key      string // storage key of the broken value
+	Message  string
+	Repaired bool
+}
+
+func (p Problem) String() string {
+	s := p.Key + ": " + p.Message
+	if p.Repaired {
+		s += " (repaired)"
+	}
+	return s
+}
+
+// Check looks for malformed values and broken or duplicate references
+// between items, lists, areas and users. If repair is set, the
+// problems are fixed: malformed values are moved to the lost/ prefix
+// and broken references are removed.
+func Check(repair bool) ([]Problem, error) {
+	problems, err := db.Check(repair)
+	var out []Problem
+	for _, p := range problems {
+		out = append(out, Problem{
+			Key:      p.Key,
+			Message:  p.Message,
+			Repaired: p.Repaired,
+		})
+	}
+	return out, err
+}
+
+// Compact rewrites the data file so that it only contains the
+// current values. It has no effect on in-memory databases.
+func Compact() error {
+	return db.Shrink()
+}
+
+// Migrate stores all items, lists, areas and users again in the
+// current format. It returns the number of migrated values. Schema
+// migrations already run when the data file is opened.
+func Migrate() (int, error) {
+	return db.Rewrite()
+}
+
+// SchemaVersion returns the schema version of the stored data.
+func SchemaVersion() (int, error) {
+	return db.SchemaVersion()
+}
@@ -44,19 +44,89 @@ func (t *areasTx) Get(id int) (stored.Area, error) {
 	var a stored.Area
 	val, err := t.tx.Get(t.Key(id))
 	if err != nil {
-		return a, err
+		return a, storageErr(err)
 	}
 	err = decode(val, &a)
+	if err != nil {
+		return a, err
+	}
+	a.Things, err = orderedThings(t.tx, areaPrefix, id)
 	return a, err
 }
 
+// Set stores the area and makes its things match a.Things. The
+// order of the things is kept in order records like for lists.
+// Fields without an ID get a new one.
 func (t *areasTx) Set(a stored.Area) error {
+	things := a.Things
+	a.Things = nil
+	a.Fields = append([]stored.Field(nil), a.Fields...)
+	for i, f := range a.Fields {
+		var err error
+		if f.ID == 0 {
+			a.Fields[i].ID, err = nextID(t.tx, fieldPrefix)
+		} else {
+			err = observeID(t.tx, fieldPrefix, f.ID)
+		}
+		if err != nil {
+			return err
+		}
+	}
 	val, err := encode(a)
 	if err != nil {
 		return err
 	}
+	err = observeID(t.tx, areaPrefix, a.ID)
+	if err != nil {
+		return err
+	}
 	_, _, err = t.tx.Set(t.Key(a.ID), val, nil)
-	return err
+	if err != nil {
+		return err
+	}
+	return setOrder(t.tx, areaPrefix, a.ID, things)
+}
+
+func (t *areasTx) New(a stored.Area) (int, error) {
+	id, err := nextID(t.tx, areaPrefix)
+	if err != nil {
+		return 0, err
+	}
+	a.ID = id
+	err = t.Set(a)
+	return id, err
+}
+
+func (t *areasTx) Delete(id int) error {
+	_, err := t.tx.Delete(t.Key(id))
+	if err != nil {
+		return storageErr(err)
+	}
+	return setOrder(t.tx, areaPrefix, id, nil)
+}
+
+func (t *areasTx) All() ([]stored.Area, error) {
+	var out []stored.Area
+	var err error
+	iterErr := t.tx.AscendKeys(areaPrefix+"*", func(key, val string) bool {
+		var area stored.Area
+		err = decode(val, &area)
+		out = append(out, area)
+		return err == nil
+	})
+	if iterErr != nil {
+		return out, iterErr
+	}
+	if err != nil {
+		return out, err
+	}
+	for i := range out {
+		out[i].Things, err = orderedThings(t.tx, areaPrefix, out[i].ID)
+		if err != nil {
+			return out, err
+		}
+	}
+	return out, nil
 }
 
 func (t *areasTx) UserThings(user, area int) ([]stored.Thing, error) {
@@ -68,43 +138,46 @@ func (t *areasTx) UserThings(user, area int) ([]stored.Thing, error) {
 	if err != nil {
 		return nil, err
 	}
+	var itemIDs []int
+	for _, id := range a.Things {
+		if id.Type == stored.TypeItem {
+			itemIDs = append(itemIDs, id.ID)
+		}
+	}
+	items, err := t.parent.items.UserItems(user, itemIDs)
+	if err != nil {
+		return nil, err
+	}
 	var out []stored.Thing
 	for _, id := range a.Things {
 		switch id.Type {
 		case stored.TypeList:
 			list, err := t.parent.lists.Get(id.ID)
 			if err != nil {
-				log.Println("oh no, error in a loop :(", user, id, err)
+				log.Println("skipping missing list in area:", user, id, err)
+				continue
 			}
 			out = append(out, list)
 		case stored.TypeItem:
-			item, err := t.parent.items.UserItem(user, id.ID)
-			if err != nil {
-				log.Println("oh no, error in a loop :(", user, id, err)
+			// missing items were skipped by UserItems
+			if len(items) == 0 || items[0].ID != id.ID {
+				continue
 			}
-			out = append(out, item)
+			out = append(out, items[0])
+			items = items[1:]
 		default:
 			log.Println("wtf, unknown type :O")
 		}
-
 	}
-	return out, err
+	return out, nil
 }
 
+// SetThingPos moves the thing to pos of the area like SetItemPos.
 func (t *areasTx) SetThingPos(area int, typ stored.ThingType, id, pos int) error {
-	a, err := t.Get(area)
+	_, err := t.tx.Get(t.Key(area))
 	if err != nil {
-		return err
+		return storageErr(err)
 	}
 	thing := stored.ThingID{Type: typ, ID: id}
-	i, ok := findInThingArray(a.Things, thing)
-	if !ok {
-		i = len(a.Things)
-		a.Things = append(a.Things, thing)
-	}
-	a.Things, err = sortThingArray(a.Things, i, pos-1) // 0 indexed not 1
-	if err != nil {
-		return err
-	}
-	return t.Set(a)
+	return moveMember(t.tx, areaPrefix, area, thing, pos-1) // 0 indexed not 1
 }
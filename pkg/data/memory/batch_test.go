@@ -0,0 +1,266 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mbertschler/bunny/pkg/data/stored"
+)
+
+func TestUserItems(t *testing.T) {
+	d := Open()
+	var ids []int
+	for i := 0; i < 12; i++ {
+		id, err := d.NewItem(stored.Item{Title: "item"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	_, err := d.NewUser(stored.User{Focus: map[int][]int{
+		stored.FocusNow:   {2},
+		stored.FocusLater: {11, 5},
+		stored.FocusWatch: {12},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = d.NewList(stored.List{Items: []int{12, 2, 5, 1, 11}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = d.NewArea(stored.Area{Things: []stored.ThingID{
+		{Type: stored.TypeItem, ID: 5},
+		{Type: stored.TypeList, ID: 1},
+		{Type: stored.TypeItem, ID: 2},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the batch getters have to return the same as single reads
+	_, items, err := d.UserItemList(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []stored.Item
+	for _, id := range []int{12, 2, 5, 1, 11} {
+		item, err := d.UserItemByID(1, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, item)
+	}
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("got list items\n%+v\nwant\n%+v", items, want)
+	}
+	if items[0].Focus != stored.FocusWatch || items[1].Focus != stored.FocusNow || items[3].Focus != stored.FocusNone {
+		t.Error("wrong focus", items)
+	}
+
+	_, things, err := d.UserArea(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(things) != 3 {
+		t.Fatal("wrong number of things", things)
+	}
+	if i, ok := things[0].(stored.Item); !ok || i.ID != 5 || i.Focus != stored.FocusLater {
+		t.Error("first thing should be item 5", things[0])
+	}
+	if l, ok := things[1].(stored.List); !ok || l.ID != 1 {
+		t.Error("second thing should be list 1", things[1])
+	}
+	if i, ok := things[2].(stored.Item); !ok || i.ID != 2 || i.Focus != stored.FocusNow {
+		t.Error("third thing should be item 2", things[2])
+	}
+
+	// missing items are skipped instead of returned as zero items
+	err = d.DeleteItem(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, items, err = d.UserItemList(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = append(want[:2], want[3:]...)
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("got list items\n%+v\nwant\n%+v", items, want)
+	}
+	_, things, err = d.UserArea(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(things) != 2 {
+		t.Fatal("wrong number of things", things)
+	}
+	if i, ok := things[1].(stored.Item); !ok || i.ID != 2 {
+		t.Error("second thing should be item 2", things[1])
+	}
+}
+
+// TestBatchOtherDB writes through the DB outside of the batch while
+// the batch runs. The write waits for the batch and isn't rolled
+// back with it.
+func TestBatchOtherDB(t *testing.T) {
+	d := Open()
+	errRollback := errors.New("rollback")
+	done := make(chan error)
+	err := d.Batch(func(tx *DB) error {
+		_, err := tx.NewItem(stored.Item{Title: "batch"})
+		if err != nil {
+			return err
+		}
+		go func() {
+			done <- d.ForceSetItem(stored.Item{ID: 10, Title: "other"})
+		}()
+		return errRollback
+	})
+	if err != errRollback {
+		t.Fatal(err)
+	}
+	err = <-done
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.ItemByID(1); !stored.HasCause(err, stored.CauseNotFound) {
+		t.Error("the item of the batch should be rolled back", err)
+	}
+	item, err := d.ItemByID(10)
+	if err != nil || item.Title != "other" {
+		t.Error("the item of the other goroutine should be kept", item, err)
+	}
+}
+
+// TestBatchGoroutine writes through the batch from a goroutine that
+// fn waits for, the write is part of the batch.
+func TestBatchGoroutine(t *testing.T) {
+	d := Open()
+	err := d.Batch(func(tx *DB) error {
+		done := make(chan error)
+		go func() {
+			_, err := tx.NewItem(stored.Item{Title: "goroutine"})
+			done <- err
+		}()
+		return <-done
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, err := d.ItemByID(1)
+	if err != nil || item.Title != "goroutine" {
+		t.Error("the item of the goroutine should be committed", item, err)
+	}
+}
+
+// TestBatchPanic checks that a panicking batch is rolled back and
+// doesn't keep the write lock.
+func TestBatchPanic(t *testing.T) {
+	d := Open()
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("the panic should go on")
+			}
+		}()
+		d.Batch(func(tx *DB) error {
+			_, err := tx.NewItem(stored.Item{Title: "batch"})
+			if err != nil {
+				return err
+			}
+			panic("oh no")
+		})
+	}()
+	if _, err := d.ItemByID(1); !stored.HasCause(err, stored.CauseNotFound) {
+		t.Error("the item of the batch should be rolled back", err)
+	}
+	_, err := d.NewItem(stored.Item{Title: "after"})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// benchList creates a list with 5000 items of which the
+// user focuses on 1000.
+func benchList(b *testing.B) *DB {
+	d := Open()
+	err := d.Batch(func(d *DB) error {
+		list := stored.List{}
+		user := stored.User{Focus: map[int][]int{}}
+		for i := 0; i < 5000; i++ {
+			id, err := d.NewItem(stored.Item{Title: "item"})
+			if err != nil {
+				return err
+			}
+			list.Items = append(list.Items, id)
+			if i%5 == 0 {
+				user.Focus[stored.FocusLater] = append(user.Focus[stored.FocusLater], id)
+			}
+		}
+		_, err := d.NewList(list)
+		if err != nil {
+			return err
+		}
+		_, err = d.NewUser(user)
+		return err
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	return d
+}
+
+func BenchmarkUserItemListBatch(b *testing.B) {
+	d := benchList(b)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		_, items, err := d.UserItemList(1, 1)
+		if err != nil || len(items) != 5000 {
+			b.Fatal(len(items), err)
+		}
+	}
+}
+
+// BenchmarkUserItemListSingle reads every item on its own, which
+// decodes the user for every item. It's how lists used to be read.
+func BenchmarkUserItemListSingle(b *testing.B) {
+	d := benchList(b)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		tx, err := d.View()
+		if err != nil {
+			b.Fatal(err)
+		}
+		l, err := tx.lists.Get(1)
+		if err != nil {
+			b.Fatal(err)
+		}
+		var items []stored.Item
+		for _, id := range l.Items {
+			item, err := tx.items.UserItem(1, id)
+			if err != nil {
+				b.Fatal(err)
+			}
+			items = append(items, item)
+		}
+		tx.Close()
+		if len(items) != 5000 {
+			b.Fatal(len(items))
+		}
+	}
+}
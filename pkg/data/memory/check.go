@@ -0,0 +1,334 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mbertschler/bunny/pkg/data/stored"
+	"github.com/tidwall/buntdb"
+)
+
+// lostPrefix is where Check moves values that can't be decoded,
+// so that they can be inspected and restored by hand.
+const lostPrefix = "lost/"
+
+// Problem is an inconsistency that Check found in the database.
+type Problem struct {
+	Key      string
+	Message  string
+	Repaired bool
+}
+
+// Check walks all items, lists, areas, order records and users and
+// looks for malformed values, IDs that don't match their keys,
+// references to things that don't exist, things that are referenced
+// twice and an outdated membership index.
+// If repair is set, the problems are fixed in one transaction:
+// malformed values are moved to the lost/ prefix, broken and
+// duplicate references are removed and the index is rebuilt.
+func (d *DB) Check(repair bool) ([]Problem, error) {
+	var tx Tx
+	var err error
+	if repair {
+		tx, err = d.Update()
+	} else {
+		tx, err = d.View()
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := checker{tx: tx.rawTx, repair: repair}
+	err = c.run()
+	if err != nil {
+		tx.Rollback()
+		return c.problems, err
+	}
+	tx.Close()
+	return c.problems, nil
+}
+
+type checker struct {
+	tx       *buntdb.Tx
+	repair   bool
+	problems []Problem
+
+	items map[int]bool
+	lists map[int]bool
+	areas map[int]bool
+}
+
+func (c *checker) report(key string, format string, args ...interface{}) {
+	c.problems = append(c.problems, Problem{
+		Key:      key,
+		Message:  fmt.Sprintf(format, args...),
+		Repaired: c.repair,
+	})
+}
+
+func (c *checker) run() error {
+	current, err := currentMembers(c.tx)
+	if err != nil {
+		return err
+	}
+	expected, err := expectedMembers(c.tx)
+	if err != nil {
+		return err
+	}
+	if !membersEqual(current, expected) {
+		c.report(memberPrefix, "the membership and focus index is out of date")
+	}
+	err = c.runChecks()
+	if err != nil || !c.repair {
+		return err
+	}
+	// the repairs don't update the index
+	_, err = rebuildMembers(c.tx)
+	return err
+}
+
+func (c *checker) runChecks() error {
+	var err error
+	c.items, err = c.ids(itemPrefix, func(val string) (int, error) {
+		var i stored.Item
+		err := decode(val, &i)
+		return i.ID, err
+	})
+	if err != nil {
+		return err
+	}
+	c.lists, err = c.ids(listPrefix, func(val string) (int, error) {
+		var l stored.List
+		err := decode(val, &l)
+		return l.ID, err
+	})
+	if err != nil {
+		return err
+	}
+	c.areas, err = c.ids(areaPrefix, func(val string) (int, error) {
+		var a stored.Area
+		err := decode(val, &a)
+		return a.ID, err
+	})
+	if err != nil {
+		return err
+	}
+	_, err = c.ids(userPrefix, func(val string) (int, error) {
+		var u stored.User
+		err := decode(val, &u)
+		return u.ID, err
+	})
+	if err != nil {
+		return err
+	}
+	err = c.checkOrder()
+	if err != nil {
+		return err
+	}
+	return c.checkUsers()
+}
+
+// ids decodes the ID of all values with the prefix and returns the
+// ones that are well formed. Malformed values are moved away, IDs
+// that don't match the key are set to the ID of the key.
+func (c *checker) ids(prefix string, decodeID func(val string) (int, error)) (map[int]bool, error) {
+	out := map[int]bool{}
+	err := c.each(prefix, func(key, val string) error {
+		keyID, err := strconv.Atoi(strings.TrimPrefix(key, prefix))
+		if err != nil {
+			c.report(key, "key doesn't end with a numeric ID, moved to %s%s", lostPrefix, key)
+			return c.moveToLost(key, val)
+		}
+		id, err := decodeID(val)
+		if err != nil {
+			c.report(key, "malformed JSON, moved to %s%s: %v", lostPrefix, key, err)
+			return c.moveToLost(key, val)
+		}
+		out[keyID] = true
+		if id != keyID {
+			c.report(key, "stored ID %d doesn't match the key", id)
+			return c.fixID(key, val, keyID)
+		}
+		return nil
+	})
+	return out, err
+}
+
+func (c *checker) moveToLost(key, val string) error {
+	if !c.repair {
+		return nil
+	}
+	_, _, err := c.tx.Set(lostPrefix+key, val, nil)
+	if err != nil {
+		return err
+	}
+	_, err = c.tx.Delete(key)
+	return err
+}
+
+// fixID sets the ID field of the JSON object val to id. A generic
+// map is used so that no fields of newer versions are lost.
+func (c *checker) fixID(key, val string, id int) error {
+	if !c.repair {
+		return nil
+	}
+	var m map[string]interface{}
+	err := decode(val, &m)
+	if err != nil {
+		return err
+	}
+	m["ID"] = id
+	return c.set(key, m)
+}
+
+func (c *checker) set(key string, v interface{}) error {
+	if !c.repair {
+		return nil
+	}
+	val, err := encode(v)
+	if err != nil {
+		return err
+	}
+	_, _, err = c.tx.Set(key, val, nil)
+	return err
+}
+
+// checkOrder looks at the order records of lists and areas. Records
+// that can't be parsed are moved away, records of containers or
+// things that don't exist and repeated things of a container are
+// removed.
+func (c *checker) checkOrder() error {
+	seen := map[string]bool{}
+	return c.each(orderPrefix, func(key, val string) error {
+		container, cid, rank, ok := parseOrderKey(key)
+		thing, valid := parseThingKey(val)
+		if !ok || !validRank(rank) || !valid || (container == listPrefix && thing.Type != stored.TypeItem) {
+			c.report(key, "malformed order record, moved to %s%s", lostPrefix, key)
+			return c.moveToLost(key, val)
+		}
+		owner := container + strconv.Itoa(cid)
+		kind, exists := "item", c.items[thing.ID]
+		if thing.Type == stored.TypeList {
+			kind, exists = "list", c.lists[thing.ID]
+		}
+		containerExists := c.lists[cid]
+		if container == areaPrefix {
+			containerExists = c.areas[cid]
+		}
+		switch {
+		case !containerExists:
+			c.report(owner, "doesn't exist, but has %s %d", kind, thing.ID)
+		case !exists:
+			c.report(owner, "%s %d doesn't exist", kind, thing.ID)
+		case seen[owner+"/"+val]:
+			c.report(owner, "%s %d is referenced twice", kind, thing.ID)
+		default:
+			seen[owner+"/"+val] = true
+			return nil
+		}
+		return c.delete(key)
+	})
+}
+
+func (c *checker) delete(key string) error {
+	if !c.repair {
+		return nil
+	}
+	_, err := c.tx.Delete(key)
+	return err
+}
+
+func (c *checker) checkUsers() error {
+	return c.each(userPrefix, func(key, val string) error {
+		var u stored.User
+		err := decode(val, &u)
+		if err != nil {
+			return nil // reported by ids
+		}
+		// an item can only have one focus state
+		seen := map[int]bool{}
+		var changed bool
+		var states []int
+		for state := range u.Focus {
+			states = append(states, state)
+		}
+		sort.Ints(states)
+		for _, state := range states {
+			if state != stored.FocusNow && state != stored.FocusLater && state != stored.FocusWatch {
+				c.report(key, "unknown focus state %d", state)
+				delete(u.Focus, state)
+				changed = true
+				continue
+			}
+			var ch bool
+			u.Focus[state], ch = c.filterIDs(key, "focus item", u.Focus[state], c.items, seen)
+			changed = changed || ch
+		}
+		if !changed {
+			return nil
+		}
+		return c.set(key, u)
+	})
+}
+
+// filterIDs removes IDs that don't exist or were already seen.
+func (c *checker) filterIDs(key, kind string, ids []int, exists, seen map[int]bool) ([]int, bool) {
+	var out []int
+	for _, id := range ids {
+		switch {
+		case !exists[id]:
+			c.report(key, "%s %d doesn't exist", kind, id)
+		case seen[id]:
+			c.report(key, "%s %d is referenced twice", kind, id)
+		default:
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out, len(out) != len(ids)
+}
+
+func (c *checker) each(prefix string, fn func(key, val string) error) error {
+	return eachKey(c.tx, prefix, fn)
+}
+
+// eachKey calls fn for all keys with the prefix. The keys are
+// collected first, so that fn can change the values.
+func eachKey(tx *buntdb.Tx, prefix string, fn func(key, val string) error) error {
+	var keys, vals []string
+	err := tx.AscendKeys(prefix+"*", func(key, val string) bool {
+		keys = append(keys, key)
+		vals = append(vals, val)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	for i := range keys {
+		err = fn(keys[i], vals[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Shrink rewrites the data file so that it only contains the
+// current values instead of the log of all changes.
+func (d *DB) Shrink() error {
+	return d.db.Shrink()
+}
@@ -0,0 +1,158 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mbertschler/bunny/pkg/data/stored"
+	"github.com/tidwall/buntdb"
+)
+
+func setRaw(t *testing.T, d *DB, kv map[string]string) {
+	err := d.db.Update(func(tx *buntdb.Tx) error {
+		for k, v := range kv {
+			_, _, err := tx.Set(k, v, nil)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	d := Open()
+	setRaw(t, d, map[string]string{
+		"i/1":      `{"ID":1,"Title":"ok"}`,
+		"i/2":      `{"ID":5,"Title":"wrong ID"}`,
+		"i/3":      `{"ID":3,"Title":`,
+		"l/1":      `{"ID":1}`,
+		"o/l/1/a0": "i/1",
+		"o/l/1/a1": "i/2",
+		"o/l/1/a2": "i/1",
+		"o/l/1/a3": "i/3",
+		"o/l/1/a4": "i/4",
+		"o/l/1/a5": "bogus",
+		"o/l/2/a0": "i/1",
+		"a/1":      `{"ID":1}`,
+		"o/a/1/a0": "l/1",
+		"o/a/1/a1": "i/1",
+		"o/a/1/a2": "l/1",
+		"o/a/1/a3": "l/7",
+		"u/1":      `{"ID":1,"Focus":{"1":[2],"2":[1,2,9],"7":[1]}}`,
+	})
+
+	problems, err := d.Check(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"m/",         // index doesn't know about the raw values
+		"i/2", "i/3", // wrong ID, malformed
+		"a/1", "a/1", // duplicate list 1, missing list 7
+		"l/1", "l/1", "l/1", // duplicate 1, missing 3 and 4
+		"o/l/1/a5",          // malformed
+		"l/2",               // missing list
+		"u/1", "u/1", "u/1", // duplicate 2, missing 9, unknown state 7
+	}
+	var keys []string
+	for _, p := range problems {
+		if p.Repaired {
+			t.Error("problem shouldn't be repaired", p)
+		}
+		keys = append(keys, p.Key)
+	}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("got problems %v, want keys %v", problems, want)
+	}
+
+	problems, err = d.Check(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != len(want) || !problems[0].Repaired {
+		t.Errorf("repair should fix all problems, got %v", problems)
+	}
+	problems, err = d.Check(false)
+	if err != nil || len(problems) != 0 {
+		t.Errorf("problems after repair: %v %v", problems, err)
+	}
+
+	tx, err := d.View()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Close()
+	item, err := tx.items.Get(2)
+	if err != nil || item.ID != 2 || item.Title != "wrong ID" {
+		t.Error("ID of item 2 should be fixed", item, err)
+	}
+	lost, err := tx.rawTx.Get(lostPrefix + "i/3")
+	if err != nil || lost != `{"ID":3,"Title":` {
+		t.Error("malformed item should be moved to lost/", lost, err)
+	}
+	lost, err = tx.rawTx.Get(lostPrefix + "o/l/1/a5")
+	if err != nil || lost != "bogus" {
+		t.Error("malformed order record should be moved to lost/", lost, err)
+	}
+	list, err := tx.lists.Get(1)
+	if err != nil || !reflect.DeepEqual(list.Items, []int{1, 2}) {
+		t.Error("wrong list items", list.Items, err)
+	}
+	area, err := tx.areas.Get(1)
+	wantThings := []stored.ThingID{{Type: stored.TypeList, ID: 1}, {Type: stored.TypeItem, ID: 1}}
+	if err != nil || !reflect.DeepEqual(area.Things, wantThings) {
+		t.Error("wrong area things", area.Things, err)
+	}
+	user, err := tx.users.Get(1)
+	wantFocus := map[int][]int{stored.FocusNow: {2}, stored.FocusLater: {1}}
+	if err != nil || !reflect.DeepEqual(user.Focus, wantFocus) {
+		t.Error("wrong focus", user.Focus, err)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	d := Open()
+	setRaw(t, d, map[string]string{
+		"i/1": `{"ID":1,"Title":"old","Unknown":true}`,
+		"i/2": `{broken`,
+		"l/1": `{"ID":1}`,
+	})
+	n, err := d.Rewrite()
+	if err != nil || n != 2 {
+		t.Fatal("rewrite returned", n, err)
+	}
+	tx, err := d.View()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Close()
+	val, err := tx.rawTx.Get("i/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var item stored.Item
+	err = decode(val, &item)
+	if err != nil || item.Title != "old" {
+		t.Error("item wasn't rewritten", val, err)
+	}
+	if val == `{"ID":1,"Title":"old","Unknown":true}` {
+		t.Error("item should be in the current format", val)
+	}
+}
@@ -15,7 +15,9 @@ package memory
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
+	"time"
 
 	"github.com/mbertschler/bunny/pkg/data/stored"
 	"github.com/tidwall/buntdb"
@@ -23,62 +25,210 @@ import (
 
 // "tables" or "buckets"
 const (
-	itemPrefix = "i/"
-	listPrefix = "l/"
-	areaPrefix = "a/"
-	userPrefix = "u/"
+	itemPrefix  = "i/"
+	listPrefix  = "l/"
+	areaPrefix  = "a/"
+	userPrefix  = "u/"
+	tokenPrefix = "t/"
+
+	webhookPrefix  = "w/"
+	deliveryPrefix = "d/"
+	entryPrefix    = "e/"
+
+	// fieldPrefix is only used for the sequence of field IDs,
+	// the fields are stored in their area.
+	fieldPrefix = "f/"
 )
 
+// deliveryTTL is how long webhook deliveries are kept in the log.
+const deliveryTTL = 7 * 24 * time.Hour
+
+// indexes
+const (
+	tokenHashIndex = "token_hash"
+	itemStateIndex = "item_state"
+	entryUserIndex = "entry_user"
+)
+
+// Open returns a new in-memory database.
 func Open() *DB {
-	db, err := buntdb.Open(":memory:")
+	db, err := OpenFile(":memory:")
 	if err != nil {
 		log.Fatal(err)
 	}
-	return &DB{
+	return db
+}
+
+// OpenFile opens the database file at path and creates
+// it if it doesn't exist yet. Values that were stored with an
+// older schema version are migrated to the current one.
+func OpenFile(path string) (*DB, error) {
+	db, err := buntdb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	err = db.CreateIndex(tokenHashIndex, tokenPrefix+"*", buntdb.IndexJSON("Hash"))
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	err = db.CreateIndex(itemStateIndex, itemPrefix+"*", buntdb.IndexJSON("State"))
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	err = db.CreateIndex(entryUserIndex, entryPrefix+"*", buntdb.IndexJSON("User"))
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	d := &DB{
 		db: db,
 	}
+	from, to, err := d.migrate()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if from != to {
+		log.Printf("migrated %s from schema version %d to %d", path, from, to)
+	}
+	return d, nil
+}
+
+// Close closes the database and syncs it to disk.
+func (d *DB) Close() error {
+	return d.db.Close()
 }
 
 type DB struct {
 	db *buntdb.DB
+
+	// batch is set on the DB that Batch passes to its fn
+	batch *batch
+}
+
+// batch is the writable transaction of a running Batch.
+type batch struct {
+	tx        *buntdb.Tx
+	committed []func() // run after the batch was committed
 }
 
 func (d *DB) Existing(tx *buntdb.Tx, writable bool) Tx {
-	return makeTx(tx, writable)
+	return makeTx(tx, writable, false)
 }
 
 func (d *DB) View() (Tx, error) {
+	if d.batch != nil {
+		return makeTx(d.batch.tx, true, true), nil
+	}
 	tx, err := d.db.Begin(false)
-	return makeTx(tx, false), err
+	return makeTx(tx, false, false), err
 }
 
 func (d *DB) Update() (Tx, error) {
+	if d.batch != nil {
+		return makeTx(d.batch.tx, true, true), nil
+	}
 	tx, err := d.db.Begin(true)
-	return makeTx(tx, true), err
+	return makeTx(tx, true, false), err
 }
 
-func makeTx(tx *buntdb.Tx, writable bool) Tx {
+// Batch runs fn inside of a single writable transaction. fn gets
+// a DB whose View and Update calls join this transaction instead
+// of starting their own. If fn returns an error or panics the whole
+// batch is rolled back, otherwise it is committed.
+//
+// Only the DB that is passed to fn is part of the batch. Calls on
+// other DBs get their own transactions, which wait until the batch
+// is done. Batch on the DB of a batch runs fn in the same batch.
+func (d *DB) Batch(fn func(tx *DB) error) error {
+	if d.batch != nil {
+		return fn(d)
+	}
+	tx, err := d.db.Begin(true)
+	if err != nil {
+		return err
+	}
+	b := &batch{tx: tx}
+	done := false
+	defer func() {
+		if done {
+			return
+		}
+		// fn panicked, release the write lock before the panic
+		// goes on
+		rerr := tx.Rollback()
+		if rerr != nil {
+			log.Println("TX ERROR:", rerr)
+		}
+	}()
+	err = fn(&DB{db: d.db, batch: b})
+	if err != nil {
+		done = true
+		rerr := tx.Rollback()
+		if rerr != nil {
+			log.Println("TX ERROR:", rerr)
+		}
+		return err
+	}
+	done = true
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	for _, fn := range b.committed {
+		fn()
+	}
+	return nil
+}
+
+// AfterCommit runs fn once the batch of d is committed, or drops it
+// if the batch is rolled back. Outside of a batch fn runs right away.
+func (d *DB) AfterCommit(fn func()) {
+	if d.batch != nil {
+		d.batch.committed = append(d.batch.committed, fn)
+		return
+	}
+	fn()
+}
+
+func makeTx(tx *buntdb.Tx, writable, shared bool) Tx {
 	t := Tx{
 		rawTx:    tx,
 		writable: writable,
+		shared:   shared,
 	}
 	t.items = itemsTx{tx: tx, parent: &t}
 	t.lists = listsTx{tx: tx, parent: &t}
 	t.areas = areasTx{tx: tx, parent: &t}
 	t.users = usersTx{tx: tx, parent: &t}
+	t.tokens = tokensTx{tx: tx, parent: &t}
+	t.webhooks = webhooksTx{tx: tx, parent: &t}
+	t.deliveries = deliveriesTx{tx: tx, parent: &t}
+	t.entries = entriesTx{tx: tx, parent: &t}
 	return t
 }
 
 type Tx struct {
 	rawTx    *buntdb.Tx
 	writable bool
+	shared   bool // part of a batch, closed by DB.Batch
 	items    itemsTx
 	lists    listsTx
 	areas    areasTx
 	users    usersTx
+	tokens   tokensTx
+
+	webhooks   webhooksTx
+	deliveries deliveriesTx
+	entries    entriesTx
 }
 
 func (t *Tx) Close() {
+	if t.shared {
+		return
+	}
 	var err error
 	if t.writable {
 		err = t.rawTx.Commit()
@@ -91,6 +241,9 @@ func (t *Tx) Close() {
 }
 
 func (t *Tx) Rollback() {
+	if t.shared {
+		return
+	}
 	err := t.rawTx.Rollback()
 	if err != nil {
 		log.Println("TX ERROR:", err)
@@ -98,6 +251,14 @@ func (t *Tx) Rollback() {
 	return
 }
 
+// storageErr attaches a stored.Cause to errors returned by buntdb.
+func storageErr(err error) error {
+	if err == buntdb.ErrNotFound {
+		return stored.WithCause(err, stored.CauseNotFound)
+	}
+	return err
+}
+
 func encode(in interface{}) (string, error) {
 	out, err := json.Marshal(in)
 	if err != nil {
@@ -113,3 +274,9 @@ func decode(in string, dest interface{}) error {
 	}
 	return nil
 }
+
+func conflictErr(kind string, id, version, current int) error {
+	err := fmt.Errorf("%s %d was changed: version %d is stale, current is %d",
+		kind, id, version, current)
+	return stored.WithCause(err, stored.CauseConflict)
+}
@@ -0,0 +1,161 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mbertschler/bunny/pkg/data/stored"
+	"github.com/tidwall/buntdb"
+)
+
+// now returns the time at which timers start and stop. It is
+// replaced in tests.
+var now = func() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+type entriesTx struct {
+	parent *Tx
+	tx     *buntdb.Tx
+}
+
+func (t *entriesTx) Key(id int) string {
+	return entryPrefix + strconv.Itoa(id)
+}
+
+func (t *entriesTx) ID(key string) int {
+	key = strings.TrimPrefix(key, entryPrefix)
+	i, err := strconv.Atoi(key)
+	if err != nil {
+		log.Println("KEY ERROR:", err)
+	}
+	return i
+}
+
+func (t *entriesTx) Get(id int) (stored.TimeEntry, error) {
+	var e stored.TimeEntry
+	val, err := t.tx.Get(t.Key(id))
+	if err != nil {
+		return e, storageErr(err)
+	}
+	err = decode(val, &e)
+	return e, err
+}
+
+func (t *entriesTx) Set(e stored.TimeEntry) error {
+	val, err := encode(e)
+	if err != nil {
+		return err
+	}
+	err = observeID(t.tx, entryPrefix, e.ID)
+	if err != nil {
+		return err
+	}
+	_, _, err = t.tx.Set(t.Key(e.ID), val, nil)
+	return err
+}
+
+func (t *entriesTx) New(e stored.TimeEntry) (int, error) {
+	id, err := nextID(t.tx, entryPrefix)
+	if err != nil {
+		return 0, err
+	}
+	e.ID = id
+	err = t.Set(e)
+	return id, err
+}
+
+func (t *entriesTx) Delete(id int) error {
+	_, err := t.tx.Delete(t.Key(id))
+	return storageErr(err)
+}
+
+// UserEntries returns the entries of the user sorted by start
+// using the entry_user index.
+func (t *entriesTx) UserEntries(user int) ([]stored.TimeEntry, error) {
+	var out []stored.TimeEntry
+	pivot, err := encode(stored.TimeEntry{User: user})
+	if err != nil {
+		return nil, err
+	}
+	iterErr := t.tx.AscendEqual(entryUserIndex, pivot, func(key, val string) bool {
+		var e stored.TimeEntry
+		err = decode(val, &e)
+		out = append(out, e)
+		return err == nil
+	})
+	if iterErr != nil {
+		return out, iterErr
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Start.Before(out[j].Start)
+	})
+	return out, err
+}
+
+func (t *entriesTx) All() ([]stored.TimeEntry, error) {
+	var out []stored.TimeEntry
+	var err error
+	iterErr := t.tx.AscendKeys(entryPrefix+"*", func(key, val string) bool {
+		var e stored.TimeEntry
+		err = decode(val, &e)
+		out = append(out, e)
+		return err == nil
+	})
+	if iterErr != nil {
+		return out, iterErr
+	}
+	return out, err
+}
+
+// Start stops the running timer of the user and starts a
+// new one for the item.
+func (t *entriesTx) Start(user, item int) error {
+	err := t.Stop(user)
+	if err != nil {
+		return err
+	}
+	_, err = t.New(stored.TimeEntry{User: user, Item: item, Start: now()})
+	return err
+}
+
+// Stop ends the running timer of the user. Timers that ran
+// for less than a second are dropped.
+func (t *entriesTx) Stop(user int) error {
+	entries, err := t.UserEntries(user)
+	if err != nil {
+		return err
+	}
+	end := now()
+	for _, e := range entries {
+		if !e.End.IsZero() {
+			continue
+		}
+		if !end.After(e.Start) {
+			err = t.Delete(e.ID)
+		} else {
+			e.End = end
+			err = t.Set(e)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mbertschler/bunny/pkg/data/stored"
+)
+
+func TestFocusTimer(t *testing.T) {
+	clock := time.Date(2018, 3, 5, 9, 0, 0, 0, time.UTC)
+	defer func(old func() time.Time) { now = old }(now)
+	now = func() time.Time { return clock }
+
+	d := Open()
+	user, _ := d.NewUser(stored.User{Name: "test"})
+	var items []int
+	for i := 0; i < 3; i++ {
+		id, _ := d.NewItem(stored.Item{Title: "item"})
+		items = append(items, id)
+	}
+	steps := []struct {
+		item, focus int
+	}{
+		{items[0], stored.FocusNow},
+		{items[1], stored.FocusWatch}, // doesn't touch the timer
+		{items[1], stored.FocusNow},   // moves item 0 to later
+		{items[2], stored.FocusNow},   // item 1 ran for 0s, dropped
+		{items[2], stored.FocusLater}, // stops the timer
+		{items[0], stored.FocusNow},   // still running at the end
+		{items[0], stored.FocusNow},   // keeps running
+		{items[1], stored.FocusNone},  // not the focus now
+	}
+	for i, s := range steps {
+		if i != 3 {
+			clock = clock.Add(time.Hour)
+		}
+		err := d.SetUserFocus(user, s.item, s.focus)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	at := func(h int) time.Time {
+		return time.Date(2018, 3, 5, 9+h, 0, 0, 0, time.UTC)
+	}
+	want := []stored.TimeEntry{
+		{ID: 1, User: user, Item: items[0], Start: at(1), End: at(3)},
+		{ID: 3, User: user, Item: items[2], Start: at(3), End: at(4)},
+		{ID: 4, User: user, Item: items[0], Start: at(5)},
+	}
+	got, err := d.UserTimeEntries(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d entries, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("entry %d is %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/mbertschler/bunny/pkg/data/stored"
+	"github.com/tidwall/buntdb"
+)
+
+// memberPrefix holds the reverse index of container membership and
+// focus. There is a key "m/i/<item>/l/<list>" for every item in a
+// list, "m/i/<item>/a/<area>" and "m/l/<list>/a/<area>" for every
+// thing in an area, all with the rank of the thing as value, and
+// "m/i/<item>/u/<user>" with the focus state as value for every item
+// a user focuses on. The keys are maintained together with the order
+// records and by the Set and Delete methods of users in the same
+// transaction as the change.
+const memberPrefix = "m/"
+
+// members maps the index keys of a container to their values.
+type members map[string]string
+
+func memberKey(typ string, id int, container string, cid int) string {
+	return memberPrefix + typ + strconv.Itoa(id) + "/" + container + strconv.Itoa(cid)
+}
+
+func userMembers(u stored.User) members {
+	m := members{}
+	for state, ids := range u.Focus {
+		for _, id := range ids {
+			m[memberKey(itemPrefix, id, userPrefix, u.ID)] = strconv.Itoa(state)
+		}
+	}
+	return m
+}
+
+// updateMembers changes the index entries of a container from old to new.
+func updateMembers(tx *buntdb.Tx, old, new members) error {
+	for key := range old {
+		if _, ok := new[key]; ok {
+			continue
+		}
+		_, err := tx.Delete(key)
+		if err != nil && err != buntdb.ErrNotFound {
+			return err
+		}
+	}
+	for key, val := range new {
+		if oldVal, ok := old[key]; ok && oldVal == val {
+			continue
+		}
+		_, _, err := tx.Set(key, val, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// previousMembers decodes the replaced value of a container with the
+// function of its type. Values that can't be decoded have no members.
+func previousMembers(prev string, replaced bool, fn func(string) members) members {
+	if !replaced {
+		return nil
+	}
+	return fn(prev)
+}
+
+func decodedUserMembers(val string) members {
+	var u stored.User
+	if decode(val, &u) != nil {
+		return nil
+	}
+	return userMembers(u)
+}
+
+// expectedMembers returns the index entries of all order records and users.
+func expectedMembers(tx *buntdb.Tx) (members, error) {
+	all := members{}
+	err := tx.AscendKeys(orderPrefix+"*", func(key, val string) bool {
+		container, cid, rank, ok := parseOrderKey(key)
+		thing, valid := parseThingKey(val)
+		if ok && valid {
+			all[orderMemberKey(thing, container, cid)] = rank
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = tx.AscendKeys(userPrefix+"*", func(key, val string) bool {
+		for k, v := range decodedUserMembers(val) {
+			all[k] = v
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+// currentMembers returns the index entries that are stored.
+func currentMembers(tx *buntdb.Tx) (members, error) {
+	m := members{}
+	err := tx.AscendKeys(memberPrefix+"*", func(key, val string) bool {
+		m[key] = val
+		return true
+	})
+	return m, err
+}
+
+// rebuildMembers makes the index match the stored containers.
+// It returns true if the index had to be changed.
+func rebuildMembers(tx *buntdb.Tx) (bool, error) {
+	current, err := currentMembers(tx)
+	if err != nil {
+		return false, err
+	}
+	expected, err := expectedMembers(tx)
+	if err != nil {
+		return false, err
+	}
+	if membersEqual(current, expected) {
+		return false, nil
+	}
+	return true, updateMembers(tx, current, expected)
+}
+
+func membersEqual(a, b members) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
+
+// containers returns the IDs of the containers of type container
+// that the thing is in, and the values of the index entries.
+func containers(tx *buntdb.Tx, typ string, id int, container string) ([]int, []string, error) {
+	prefix := memberPrefix + typ + strconv.Itoa(id) + "/" + container
+	var ids []int
+	var vals []string
+	err := tx.AscendKeys(prefix+"*", func(key, val string) bool {
+		cid, err := strconv.Atoi(strings.TrimPrefix(key, prefix))
+		if err == nil {
+			ids = append(ids, cid)
+			vals = append(vals, val)
+		}
+		return true
+	})
+	return ids, vals, err
+}
+
+// ItemsByState returns all items with the state using the
+// item_state index.
+func (d *DB) ItemsByState(state int) ([]stored.Item, error) {
+	tx, err := d.View()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	pivot, err := encode(stored.Item{State: state})
+	if err != nil {
+		return nil, err
+	}
+	var out []stored.Item
+	iterErr := tx.rawTx.AscendEqual(itemStateIndex, pivot, func(key, val string) bool {
+		var item stored.Item
+		err = decode(val, &item)
+		out = append(out, item)
+		return err == nil
+	})
+	if iterErr != nil {
+		return out, iterErr
+	}
+	return out, err
+}
+
+// ItemLists returns the IDs of the lists that contain the item.
+func (d *DB) ItemLists(item int) ([]int, error) {
+	return d.containers(itemPrefix, item, listPrefix)
+}
+
+// ItemAreas returns the IDs of the areas that directly contain the item.
+func (d *DB) ItemAreas(item int) ([]int, error) {
+	return d.containers(itemPrefix, item, areaPrefix)
+}
+
+// ListAreas returns the IDs of the areas that contain the list.
+func (d *DB) ListAreas(list int) ([]int, error) {
+	return d.containers(listPrefix, list, areaPrefix)
+}
+
+// ItemFocus returns the focus state of the item for all
+// users that focus on it, keyed by user ID.
+func (d *DB) ItemFocus(item int) (map[int]int, error) {
+	tx, err := d.View()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	users, states, err := containers(tx.rawTx, itemPrefix, item, userPrefix)
+	if err != nil {
+		return nil, err
+	}
+	out := map[int]int{}
+	for i, u := range users {
+		out[u], err = strconv.Atoi(states[i])
+		if err != nil {
+			return nil, stored.WithCause(err, stored.CauseMalformed)
+		}
+	}
+	return out, nil
+}
+
+func (d *DB) containers(typ string, id int, container string) ([]int, error) {
+	tx, err := d.View()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	ids, _, err := containers(tx.rawTx, typ, id, container)
+	return ids, err
+}
@@ -0,0 +1,203 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/mbertschler/bunny/pkg/data/stored"
+)
+
+func TestIndexes(t *testing.T) {
+	d := Open()
+	for i := 1; i <= 12; i++ {
+		state := stored.ItemOpen
+		if i%3 == 0 {
+			state = stored.ItemComplete
+		}
+		_, err := d.NewItem(stored.Item{Title: fmt.Sprint("item ", i), State: state})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, l := range []stored.List{{Items: []int{1, 2, 3}}, {Items: []int{3, 4}}} {
+		_, err := d.NewList(l)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := d.NewArea(stored.Area{Things: []stored.ThingID{
+		{Type: stored.TypeList, ID: 2},
+		{Type: stored.TypeItem, ID: 3},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = d.NewUser(stored.User{Name: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.SetUserFocus(1, 3, stored.FocusLater)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	complete, err := d.ItemsByState(stored.ItemComplete)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int
+	for _, i := range complete {
+		ids = append(ids, i.ID)
+	}
+	sort.Ints(ids)
+	if !reflect.DeepEqual(ids, []int{3, 6, 9, 12}) {
+		t.Error("wrong complete items", ids)
+	}
+
+	expectInts(t, "lists of item 3", d.ItemLists, 3, []int{1, 2})
+	expectInts(t, "areas of item 3", d.ItemAreas, 3, []int{1})
+	expectInts(t, "areas of list 2", d.ListAreas, 2, []int{1})
+	expectInts(t, "lists of item 5", d.ItemLists, 5, nil)
+	focus, err := d.ItemFocus(3)
+	if err != nil || !reflect.DeepEqual(focus, map[int]int{1: stored.FocusLater}) {
+		t.Error("wrong focus of item 3", focus, err)
+	}
+
+	// changes update the index
+	err = d.SetListItemPosition(2, 5, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectInts(t, "lists of item 5 after adding", d.ItemLists, 5, []int{2})
+	err = d.DeleteList(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectInts(t, "lists of item 3 after deleting list 1", d.ItemLists, 3, []int{2})
+	err = d.SetUserFocus(1, 3, stored.FocusNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	focus, err = d.ItemFocus(3)
+	if err != nil || len(focus) != 0 {
+		t.Error("item 3 shouldn't be focused anymore", focus, err)
+	}
+	problems, err := d.Check(false)
+	if err != nil || len(problems) != 0 {
+		t.Error("index should be consistent", problems, err)
+	}
+}
+
+func expectInts(t *testing.T, name string, fn func(int) ([]int, error), id int, want []int) {
+	got, err := fn(id)
+	if err != nil {
+		t.Error(name, err)
+		return
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Error(name, "is", got, "should be", want)
+	}
+}
+
+// benchDB has 5000 items in 50 lists of 100 items each.
+func benchDB(b *testing.B) *DB {
+	d := Open()
+	err := d.Batch(func(d *DB) error {
+		for l := 0; l < 50; l++ {
+			list := stored.List{}
+			for i := 0; i < 100; i++ {
+				id, err := d.NewItem(stored.Item{Title: "item", State: i % 3})
+				if err != nil {
+					return err
+				}
+				list.Items = append(list.Items, id)
+			}
+			_, err := d.NewList(list)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	return d
+}
+
+func BenchmarkItemsByStateIndex(b *testing.B) {
+	d := benchDB(b)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		items, err := d.ItemsByState(stored.ItemArchived)
+		if err != nil || len(items) == 0 {
+			b.Fatal(len(items), err)
+		}
+	}
+}
+
+func BenchmarkItemsByStateScan(b *testing.B) {
+	d := benchDB(b)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		all, err := d.Items()
+		if err != nil {
+			b.Fatal(err)
+		}
+		var items []stored.Item
+		for _, i := range all {
+			if i.State == stored.ItemArchived {
+				items = append(items, i)
+			}
+		}
+		if len(items) == 0 {
+			b.Fatal("no items")
+		}
+	}
+}
+
+func BenchmarkItemListsIndex(b *testing.B) {
+	d := benchDB(b)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		lists, err := d.ItemLists(4321)
+		if err != nil || len(lists) != 1 {
+			b.Fatal(lists, err)
+		}
+	}
+}
+
+func BenchmarkItemListsScan(b *testing.B) {
+	d := benchDB(b)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		all, err := d.Lists()
+		if err != nil {
+			b.Fatal(err)
+		}
+		var lists []int
+		for _, l := range all {
+			if _, ok := findInArray(l.Items, 4321); ok {
+				lists = append(lists, l.ID)
+			}
+		}
+		if len(lists) != 1 {
+			b.Fatal(lists)
+		}
+	}
+}
@@ -44,7 +44,7 @@ func (t *itemsTx) Get(id int) (stored.Item, error) {
 	var item stored.Item
 	val, err := t.tx.Get(t.Key(id))
 	if err != nil {
-		return item, err
+		return item, storageErr(err)
 	}
 	err = decode(val, &item)
 	return item, err
@@ -60,23 +60,45 @@ func (t *itemsTx) UserItem(user, item int) (stored.Item, error) {
 	return i, err
 }
 
+// UserItems returns the items with the focus of the user. The focus
+// map of the user is only decoded once. Items that don't exist are
+// skipped like in the paged reader.
+func (t *itemsTx) UserItems(user int, ids []int) ([]stored.Item, error) {
+	focus, err := t.parent.users.FocusMap(user)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]stored.Item, 0, len(ids))
+	for _, id := range ids {
+		item, err := t.Get(id)
+		if err != nil {
+			log.Println("skipping missing item:", user, id, err)
+			continue
+		}
+		item.Focus = focus[id]
+		out = append(out, item)
+	}
+	return out, nil
+}
+
 func (t *itemsTx) Set(i stored.Item) error {
 	val, err := encode(i)
 	if err != nil {
 		return err
 	}
+	err = observeID(t.tx, itemPrefix, i.ID)
+	if err != nil {
+		return err
+	}
 	_, _, err = t.tx.Set(t.Key(i.ID), val, nil)
 	return err
 }
 
 func (t *itemsTx) New(i stored.Item) (int, error) {
-	id := 0
-	err := t.tx.DescendKeys(itemPrefix+"*",
-		func(key, val string) bool {
-			id = t.ID(key)
-			return false
-		})
-	id++
+	id, err := nextID(t.tx, itemPrefix)
+	if err != nil {
+		return 0, err
+	}
 	i.ID = id
 	err = t.Set(i)
 	return id, err
@@ -84,5 +106,20 @@ func (t *itemsTx) New(i stored.Item) (int, error) {
 
 func (t *itemsTx) Delete(id int) error {
 	_, err := t.tx.Delete(t.Key(id))
-	return err
+	return storageErr(err)
+}
+
+func (t *itemsTx) All() ([]stored.Item, error) {
+	var out []stored.Item
+	var err error
+	iterErr := t.tx.AscendKeys(itemPrefix+"*", func(key, val string) bool {
+		var item stored.Item
+		err = decode(val, &item)
+		out = append(out, item)
+		return err == nil
+	})
+	if iterErr != nil {
+		return out, iterErr
+	}
+	return out, err
 }
@@ -44,12 +44,29 @@ func (t *listsTx) Get(id int) (stored.List, error) {
 	var list stored.List
 	val, err := t.tx.Get(t.Key(id))
 	if err != nil {
-		return list, err
+		return list, storageErr(err)
 	}
 	err = decode(val, &list)
+	if err != nil {
+		return list, err
+	}
+	list.Items, err = t.items(id)
 	return list, err
 }
 
+// items returns the IDs of the items in the list in their order.
+func (t *listsTx) items(list int) ([]int, error) {
+	things, err := orderedThings(t.tx, listPrefix, list)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for _, thing := range things {
+		ids = append(ids, thing.ID)
+	}
+	return ids, nil
+}
+
 func (t *listsTx) UserItems(user, list int) ([]stored.Item, error) {
 	_, err := t.parent.users.Get(user)
 	if err != nil {
@@ -59,17 +76,11 @@ func (t *listsTx) UserItems(user, list int) ([]stored.Item, error) {
 	if err != nil {
 		return nil, err
 	}
-	var out []stored.Item
-	for _, id := range l.Items {
-		item, err := t.parent.items.UserItem(user, id)
-		if err != nil {
-			log.Println("oh no, error in a loop :(", user, id, err)
-		}
-		out = append(out, item)
-	}
-	return out, err
+	return t.parent.items.UserItems(user, l.Items)
 }
 
+// Items returns the items of the list. Items that don't exist
+// anymore are skipped.
 func (t *listsTx) Items(list int) ([]stored.Item, error) {
 	l, err := t.Get(list)
 	if err != nil {
@@ -79,40 +90,88 @@ func (t *listsTx) Items(list int) ([]stored.Item, error) {
 	for _, id := range l.Items {
 		item, err := t.parent.items.Get(id)
 		if err != nil {
-			log.Println("oh no, error in a loop :(", id, err)
+			log.Println("skipping missing item in list:", list, id, err)
+			continue
 		}
 		out = append(out, item)
 	}
-	return out, err
+	return out, nil
 }
 
+// Set stores the list and makes its items match l.Items. The order
+// of the items is kept in order records instead of the list value.
 func (t *listsTx) Set(l stored.List) error {
+	items := l.Items
+	l.Items = nil
 	val, err := encode(l)
 	if err != nil {
 		return err
 	}
+	err = observeID(t.tx, listPrefix, l.ID)
+	if err != nil {
+		return err
+	}
 	_, _, err = t.tx.Set(t.Key(l.ID), val, nil)
-	return err
+	if err != nil {
+		return err
+	}
+	things := make([]stored.ThingID, len(items))
+	for i, id := range items {
+		things[i] = stored.ThingID{Type: stored.TypeItem, ID: id}
+	}
+	return setOrder(t.tx, listPrefix, l.ID, things)
 }
 
+// SetItemPos moves the item to pos of the list, 1 is the top. Items
+// that are not in the list yet are added.
 func (t *listsTx) SetItemPos(list, item, pos int) error {
 	// TODO: remove from other lists
-	l, err := t.Get(list)
+	_, err := t.tx.Get(t.Key(list))
 	if err != nil {
-		return err
+		return storageErr(err)
 	}
-	i, ok := findInArray(l.Items, item)
-	if !ok {
-		i = len(l.Items)
-		l.Items = append(l.Items, item)
-	}
-	l.Items, err = sortArray(l.Items, i, pos-1) // 0 indexed not 1
+	thing := stored.ThingID{Type: stored.TypeItem, ID: item}
+	return moveMember(t.tx, listPrefix, list, thing, pos-1) // 0 indexed not 1
+}
+
+func (t *listsTx) New(l stored.List) (int, error) {
+	id, err := nextID(t.tx, listPrefix)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	return t.Set(l)
+	l.ID = id
+	err = t.Set(l)
+	return id, err
 }
 
 func (t *listsTx) Delete(id int) error {
-	return nil
+	_, err := t.tx.Delete(t.Key(id))
+	if err != nil {
+		return storageErr(err)
+	}
+	return setOrder(t.tx, listPrefix, id, nil)
+}
+
+func (t *listsTx) All() ([]stored.List, error) {
+	var out []stored.List
+	var err error
+	iterErr := t.tx.AscendKeys(listPrefix+"*", func(key, val string) bool {
+		var list stored.List
+		err = decode(val, &list)
+		out = append(out, list)
+		return err == nil
+	})
+	if iterErr != nil {
+		return out, iterErr
+	}
+	if err != nil {
+		return out, err
+	}
+	for i := range out {
+		out[i].Items, err = t.items(out[i].ID)
+		if err != nil {
+			return out, err
+		}
+	}
+	return out, nil
 }
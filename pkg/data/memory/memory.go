@@ -14,7 +14,11 @@
 package memory
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/mbertschler/bunny/pkg/data/stored"
+	"github.com/tidwall/buntdb"
 )
 
 func (d *DB) UserByID(id int) (stored.User, error) {
@@ -138,17 +142,23 @@ func (d *DB) FocusList(user int) ([]stored.Item, error) {
 	return tx.users.AllByUser(user)
 }
 
-func (d *DB) SetItem(i stored.Item) error {
+// SetItem saves the item if its Version matches the stored one
+// and returns the item as it was before.
+func (d *DB) SetItem(i stored.Item) (stored.Item, error) {
 	tx, err := d.Update()
 	if err != nil {
-		return err
+		return stored.Item{}, err
 	}
 	defer tx.Close()
-	_, err = tx.items.Get(i.ID)
+	old, err := tx.items.Get(i.ID)
 	if err != nil {
-		return err
+		return old, err
 	}
-	return tx.items.Set(i)
+	if old.Version != i.Version {
+		return old, conflictErr("item", i.ID, i.Version, old.Version)
+	}
+	i.Version++
+	return old, tx.items.Set(i)
 }
 
 func (d *DB) ForceSetItem(i stored.Item) error {
@@ -166,10 +176,15 @@ func (d *DB) SetList(l stored.List) error {
 		return err
 	}
 	defer tx.Close()
-	_, err = tx.lists.Get(l.ID)
+	old, err := tx.lists.Get(l.ID)
 	if err != nil {
 		return err
 	}
+	if old.Version != l.Version {
+		return conflictErr("list", l.ID, l.Version, old.Version)
+	}
+	l.Items = old.Items
+	l.Version++
 	return tx.lists.Set(l)
 }
 
@@ -218,6 +233,81 @@ func (d *DB) NewItem(i stored.Item) (int, error) {
 	return tx.items.New(i)
 }
 
+// NewListItem creates the item and adds it to the top of the list.
+func (d *DB) NewListItem(list int, i stored.Item) (int, error) {
+	tx, err := d.Update()
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.lists.Get(list)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	id, err := tx.items.New(i)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	err = tx.lists.SetItemPos(list, id, 1)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	tx.Close()
+	return id, nil
+}
+
+// NewAreaItem creates the item and adds it to the top of the area.
+func (d *DB) NewAreaItem(area int, i stored.Item) (int, error) {
+	tx, err := d.Update()
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.areas.Get(area)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	id, err := tx.items.New(i)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	err = tx.areas.SetThingPos(area, stored.TypeItem, id, 1)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	tx.Close()
+	return id, nil
+}
+
+// NewAreaList creates the list and adds it to the top of the area.
+func (d *DB) NewAreaList(area int, l stored.List) (int, error) {
+	tx, err := d.Update()
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.areas.Get(area)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	id, err := tx.lists.New(l)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	err = tx.areas.SetThingPos(area, stored.TypeList, id, 1)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	tx.Close()
+	return id, nil
+}
+
 func (d *DB) SetListItemPosition(list, item, pos int) error {
 	tx, err := d.Update()
 	if err != nil {
@@ -261,6 +351,64 @@ func (d *DB) SetUserFocus(user, item, focus int) error {
 	return err
 }
 
+// SetUserListOrder remembers the order in which the user views
+// the list.
+func (d *DB) SetUserListOrder(user, list, order int) error {
+	tx, err := d.Update()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	_, err = tx.lists.Get(list)
+	if err != nil {
+		return err
+	}
+	return tx.users.SetListOrder(user, list, order)
+}
+
+// SetUserAreaOrder is SetUserListOrder for areas.
+func (d *DB) SetUserAreaOrder(user, area, order int) error {
+	tx, err := d.Update()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	_, err = tx.areas.Get(area)
+	if err != nil {
+		return err
+	}
+	return tx.users.SetAreaOrder(user, area, order)
+}
+
+func (d *DB) SetUserPomodoroLengths(user, work, brk int) error {
+	tx, err := d.Update()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	return tx.users.SetPomodoroLengths(user, work, brk)
+}
+
+func (d *DB) SetUserSession(user int, s *stored.Session) error {
+	tx, err := d.Update()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	return tx.users.SetSession(user, s)
+}
+
+// FinishUserSession ends the running session of the user if it
+// ended before the given time and returns it.
+func (d *DB) FinishUserSession(user int, ended time.Time) (stored.Session, error) {
+	tx, err := d.Update()
+	if err != nil {
+		return stored.Session{}, err
+	}
+	defer tx.Close()
+	return tx.users.FinishSession(user, ended)
+}
+
 func (d *DB) ListByID(id int) (stored.List, error) {
 	var list stored.List
 	tx, err := d.View()
@@ -272,6 +420,15 @@ func (d *DB) ListByID(id int) (stored.List, error) {
 	return list, err
 }
 
+func (d *DB) NewUser(u stored.User) (int, error) {
+	tx, err := d.Update()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Close()
+	return tx.users.New(u)
+}
+
 func (d *DB) ForceSetUser(u stored.User) error {
 	tx, err := d.Update()
 	if err != nil {
@@ -281,3 +438,334 @@ func (d *DB) ForceSetUser(u stored.User) error {
 	tx.Close()
 	return err
 }
+
+func (d *DB) Items() ([]stored.Item, error) {
+	tx, err := d.View()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	return tx.items.All()
+}
+
+func (d *DB) Lists() ([]stored.List, error) {
+	tx, err := d.View()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	return tx.lists.All()
+}
+
+func (d *DB) Areas() ([]stored.Area, error) {
+	tx, err := d.View()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	return tx.areas.All()
+}
+
+func (d *DB) Users() ([]stored.User, error) {
+	tx, err := d.View()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	return tx.users.All()
+}
+
+// Snapshot holds all users, items, lists, areas and time entries
+// at one point in time.
+type Snapshot struct {
+	Users   []stored.User
+	Items   []stored.Item
+	Lists   []stored.List
+	Areas   []stored.Area
+	Entries []stored.TimeEntry
+}
+
+// Snapshot reads everything in a single transaction.
+func (d *DB) Snapshot() (Snapshot, error) {
+	var s Snapshot
+	tx, err := d.View()
+	if err != nil {
+		return s, err
+	}
+	defer tx.Close()
+	s.Users, err = tx.users.All()
+	if err != nil {
+		return s, err
+	}
+	s.Items, err = tx.items.All()
+	if err != nil {
+		return s, err
+	}
+	s.Lists, err = tx.lists.All()
+	if err != nil {
+		return s, err
+	}
+	s.Areas, err = tx.areas.All()
+	if err != nil {
+		return s, err
+	}
+	s.Entries, err = tx.entries.All()
+	return s, err
+}
+
+func (d *DB) NewList(l stored.List) (int, error) {
+	tx, err := d.Update()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Close()
+	return tx.lists.New(l)
+}
+
+func (d *DB) NewArea(a stored.Area) (int, error) {
+	tx, err := d.Update()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Close()
+	return tx.areas.New(a)
+}
+
+func (d *DB) SetArea(a stored.Area) error {
+	tx, err := d.Update()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	old, err := tx.areas.Get(a.ID)
+	if err != nil {
+		return err
+	}
+	a.Things = old.Things
+	a.Fields = old.Fields
+	return tx.areas.Set(a)
+}
+
+// SetAreaFields replaces the custom fields of the area with the
+// ones that fn returns for the current fields. The saved area is
+// returned.
+func (d *DB) SetAreaFields(area int, fn func([]stored.Field) ([]stored.Field, error)) (stored.Area, error) {
+	tx, err := d.Update()
+	if err != nil {
+		return stored.Area{}, err
+	}
+	defer tx.Close()
+	a, err := tx.areas.Get(area)
+	if err != nil {
+		return a, err
+	}
+	a.Fields, err = fn(a.Fields)
+	if err != nil {
+		return a, err
+	}
+	err = tx.areas.Set(a)
+	if err != nil {
+		return a, err
+	}
+	return tx.areas.Get(area)
+}
+
+func (d *DB) DeleteArea(id int) error {
+	tx, err := d.Update()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	return tx.areas.Delete(id)
+}
+
+func (d *DB) NewToken(t stored.Token) (int, error) {
+	tx, err := d.Update()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Close()
+	return tx.tokens.New(t)
+}
+
+func (d *DB) TokenByHash(hash string) (stored.Token, error) {
+	tx, err := d.View()
+	if err != nil {
+		return stored.Token{}, err
+	}
+	defer tx.Close()
+	return tx.tokens.ByHash(hash)
+}
+
+func (d *DB) UserTokens(user int) ([]stored.Token, error) {
+	tx, err := d.View()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	return tx.tokens.UserTokens(user)
+}
+
+// DeleteToken deletes the token if it belongs to the user.
+func (d *DB) DeleteToken(user, id int) error {
+	tx, err := d.Update()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	t, err := tx.tokens.Get(id)
+	if err != nil {
+		return err
+	}
+	if t.User != user {
+		return storageErr(buntdb.ErrNotFound)
+	}
+	return tx.tokens.Delete(id)
+}
+
+func (d *DB) NewWebhook(w stored.Webhook) (int, error) {
+	tx, err := d.Update()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Close()
+	return tx.webhooks.New(w)
+}
+
+func (d *DB) Webhooks() ([]stored.Webhook, error) {
+	tx, err := d.View()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	return tx.webhooks.All()
+}
+
+func (d *DB) WebhookByID(id int) (stored.Webhook, error) {
+	tx, err := d.View()
+	if err != nil {
+		return stored.Webhook{}, err
+	}
+	defer tx.Close()
+	return tx.webhooks.Get(id)
+}
+
+// DeleteWebhook deletes the webhook if it belongs to the user.
+func (d *DB) DeleteWebhook(user, id int) error {
+	tx, err := d.Update()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	w, err := tx.webhooks.Get(id)
+	if err != nil {
+		return err
+	}
+	if w.User != user {
+		return storageErr(buntdb.ErrNotFound)
+	}
+	return tx.webhooks.Delete(id)
+}
+
+func (d *DB) NewDelivery(del stored.Delivery) (int, error) {
+	tx, err := d.Update()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Close()
+	return tx.deliveries.New(del)
+}
+
+func (d *DB) WebhookDeliveries(webhook int) ([]stored.Delivery, error) {
+	tx, err := d.View()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	return tx.deliveries.WebhookDeliveries(webhook)
+}
+
+func (d *DB) TimeEntryByID(id int) (stored.TimeEntry, error) {
+	tx, err := d.View()
+	if err != nil {
+		return stored.TimeEntry{}, err
+	}
+	defer tx.Close()
+	return tx.entries.Get(id)
+}
+
+// UserTimeEntries returns the time entries of the user sorted
+// by start.
+func (d *DB) UserTimeEntries(user int) ([]stored.TimeEntry, error) {
+	tx, err := d.View()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	return tx.entries.UserEntries(user)
+}
+
+func (d *DB) TimeEntries() ([]stored.TimeEntry, error) {
+	tx, err := d.View()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	return tx.entries.All()
+}
+
+func (d *DB) NewTimeEntry(e stored.TimeEntry) (int, error) {
+	tx, err := d.Update()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Close()
+	return tx.entries.New(e)
+}
+
+// SetTimeEntry stores the entry if it already exists for the same
+// user. Whether the entry is running can't be changed.
+func (d *DB) SetTimeEntry(e stored.TimeEntry) error {
+	tx, err := d.Update()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	old, err := tx.entries.Get(e.ID)
+	if err != nil {
+		return err
+	}
+	if old.User != e.User {
+		return storageErr(buntdb.ErrNotFound)
+	}
+	if old.End.IsZero() != e.End.IsZero() {
+		return stored.WithCause(fmt.Errorf("time entry %d was started or stopped meanwhile", e.ID), stored.CauseConflict)
+	}
+	return tx.entries.Set(e)
+}
+
+func (d *DB) ForceSetTimeEntry(e stored.TimeEntry) error {
+	tx, err := d.Update()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	return tx.entries.Set(e)
+}
+
+// DeleteTimeEntry deletes the time entry if it belongs to the user.
+func (d *DB) DeleteTimeEntry(user, id int) error {
+	tx, err := d.Update()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	e, err := tx.entries.Get(id)
+	if err != nil {
+		return err
+	}
+	if e.User != user {
+		return storageErr(buntdb.ErrNotFound)
+	}
+	return tx.entries.Delete(id)
+}
@@ -0,0 +1,524 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mbertschler/bunny/pkg/data/stored"
+	"github.com/tidwall/buntdb"
+)
+
+// schemaKey holds the schema version of the stored values.
+const schemaKey = "meta/schema"
+
+// migration upgrades the stored values from the previous schema
+// version to version. All migrations run in one transaction.
+type migration struct {
+	version int
+	name    string
+	up      func(tx *buntdb.Tx) error
+}
+
+// migrations are run in order when a database is opened. New
+// migrations are added at the end with the next version number,
+// migrations that were released must not be changed anymore. So
+// they only work on the raw keys and values, with their own copies
+// of the value shapes, ranks and index keys of their version, and
+// don't use the stored types, transactions or order code of this
+// package, which change with later versions.
+var migrations = []migration{
+	{
+		// Data files from before the schema version was stored
+		// can have items and lists without Version and items
+		// without Due. They are all version 0.
+		version: 1,
+		name:    "store all values in the current format",
+		up: func(tx *buntdb.Tx) error {
+			for prefix, v := range valuesV1 {
+				err := rewriteAs(tx, prefix, v)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		// New IDs used to be derived from the last key, which
+		// is wrong from ID 10 on. The sequences start at the
+		// highest ID that is in use.
+		version: 2,
+		name:    "add ID sequences",
+		up: func(tx *buntdb.Tx) error {
+			return addSequences(tx, itemPrefix, listPrefix, areaPrefix,
+				userPrefix, tokenPrefix, webhookPrefix, deliveryPrefix)
+		},
+	},
+	{
+		version: 3,
+		name:    "build the membership and focus index",
+		up: func(tx *buntdb.Tx) error {
+			m, err := membersV3(tx)
+			if err != nil {
+				return err
+			}
+			return replaceKeys(tx, memberPrefix, m)
+		},
+	},
+	{
+		// The items of lists and the things of areas used to be
+		// arrays in the list and area values, so every move
+		// rewrote the whole array. The arrays are moved to order
+		// records and the index gets the ranks.
+		version: 4,
+		name:    "store the order of lists and areas as ranks",
+		up: func(tx *buntdb.Tx) error {
+			order := map[string]string{}
+			index, err := focusMembersV3(tx)
+			if err != nil {
+				return err
+			}
+			err = orderAsRanks(tx, listPrefix, "Items", order, index, func(raw json.RawMessage) ([]string, error) {
+				var ids []int
+				err := json.Unmarshal(raw, &ids)
+				keys := make([]string, len(ids))
+				for i, id := range ids {
+					keys[i] = itemPrefix + strconv.Itoa(id)
+				}
+				return keys, err
+			})
+			if err != nil {
+				return err
+			}
+			err = orderAsRanks(tx, areaPrefix, "Things", order, index, func(raw json.RawMessage) ([]string, error) {
+				var things []struct {
+					Type int
+					ID   int
+				}
+				err := json.Unmarshal(raw, &things)
+				keys := make([]string, len(things))
+				for i, t := range things {
+					keys[i] = itemPrefix + strconv.Itoa(t.ID)
+					if t.Type == 2 { // stored.TypeList
+						keys[i] = listPrefix + strconv.Itoa(t.ID)
+					}
+				}
+				return keys, err
+			})
+			if err != nil {
+				return err
+			}
+			err = replaceKeys(tx, orderPrefix, order)
+			if err != nil {
+				return err
+			}
+			return replaceKeys(tx, memberPrefix, index)
+		},
+	},
+	{
+		// Time entries got their own sequence after migration 2
+		// was released.
+		version: 5,
+		name:    "add the time entry sequence",
+		up: func(tx *buntdb.Tx) error {
+			return addSequences(tx, entryPrefix)
+		},
+	},
+}
+
+// valuesV1 are the values as they were stored in schema version 1.
+var valuesV1 = map[string]func() interface{}{
+	itemPrefix: func() interface{} {
+		return &struct {
+			ID      int
+			Version int
+			State   int
+			Title   string
+			Body    string
+			Due     time.Time
+			Focus   int
+		}{}
+	},
+	listPrefix: func() interface{} {
+		return &struct {
+			ID      int
+			Version int
+			State   int
+			Title   string
+			Body    string
+			Items   []int
+		}{}
+	},
+	areaPrefix: func() interface{} {
+		return &struct {
+			ID     int
+			Title  string
+			Body   string
+			Things []struct {
+				Type int
+				ID   int
+			}
+		}{}
+	},
+	userPrefix: func() interface{} {
+		return &struct {
+			ID    int
+			Name  string
+			Focus map[int][]int
+		}{}
+	},
+}
+
+// membersV3 returns the membership and focus index of schema version
+// 3. List and area members have an empty value, focused items the
+// focus state. Malformed values have no members.
+func membersV3(tx *buntdb.Tx) (map[string]string, error) {
+	m := map[string]string{}
+	key := func(typ string, id int, container string, cid int) string {
+		return memberPrefix + typ + strconv.Itoa(id) + "/" + container + strconv.Itoa(cid)
+	}
+	err := eachKey(tx, listPrefix, func(_, val string) error {
+		var l struct {
+			ID    int
+			Items []int
+		}
+		if decode(val, &l) != nil {
+			return nil
+		}
+		for _, id := range l.Items {
+			m[key(itemPrefix, id, listPrefix, l.ID)] = ""
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = eachKey(tx, areaPrefix, func(_, val string) error {
+		var a struct {
+			ID     int
+			Things []struct {
+				Type int
+				ID   int
+			}
+		}
+		if decode(val, &a) != nil {
+			return nil
+		}
+		for _, t := range a.Things {
+			typ := itemPrefix
+			if t.Type == 2 { // stored.TypeList
+				typ = listPrefix
+			}
+			m[key(typ, t.ID, areaPrefix, a.ID)] = ""
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	focus, err := focusMembersV3(tx)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range focus {
+		m[k] = v
+	}
+	return m, nil
+}
+
+// focusMembersV3 returns the focus entries of the index of schema
+// version 3, they didn't change in version 4.
+func focusMembersV3(tx *buntdb.Tx) (map[string]string, error) {
+	m := map[string]string{}
+	err := eachKey(tx, userPrefix, func(_, val string) error {
+		var u struct {
+			ID    int
+			Focus map[int][]int
+		}
+		if decode(val, &u) != nil {
+			return nil
+		}
+		for state, ids := range u.Focus {
+			for _, id := range ids {
+				m[memberPrefix+itemPrefix+strconv.Itoa(id)+"/"+userPrefix+strconv.Itoa(u.ID)] = strconv.Itoa(state)
+			}
+		}
+		return nil
+	})
+	return m, err
+}
+
+// replaceKeys replaces all values with prefix with the values of m.
+func replaceKeys(tx *buntdb.Tx, prefix string, m map[string]string) error {
+	err := eachKey(tx, prefix, func(key, _ string) error {
+		if _, ok := m[key]; ok {
+			return nil
+		}
+		_, err := tx.Delete(key)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	for key, val := range m {
+		_, _, err = tx.Set(key, val, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewriteAs decodes the values with prefix into a new value of v
+// and stores them again. Malformed values are left alone.
+func rewriteAs(tx *buntdb.Tx, prefix string, v func() interface{}) error {
+	return eachKey(tx, prefix, func(key, val string) error {
+		value := v()
+		if decode(val, value) != nil {
+			return nil
+		}
+		out, err := encode(value)
+		if err != nil {
+			return err
+		}
+		_, _, err = tx.Set(key, out, nil)
+		return err
+	})
+}
+
+// addSequences starts the sequences of the prefixes at the highest
+// ID that is in use, unless they are already further.
+func addSequences(tx *buntdb.Tx, prefixes ...string) error {
+	for _, prefix := range prefixes {
+		var max int
+		err := tx.AscendKeys(prefix+"*", func(key, val string) bool {
+			id, err := strconv.Atoi(strings.TrimPrefix(key, prefix))
+			if err == nil && id > max {
+				max = id
+			}
+			return true
+		})
+		if err != nil {
+			return err
+		}
+		var seq int
+		val, err := tx.Get(seqPrefix + prefix)
+		if err == nil {
+			seq, err = strconv.Atoi(val)
+			if err != nil {
+				return stored.WithCause(err, stored.CauseMalformed)
+			}
+		} else if err != buntdb.ErrNotFound {
+			return err
+		}
+		if max <= seq {
+			continue
+		}
+		_, _, err = tx.Set(seqPrefix+prefix, strconv.Itoa(max), nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// orderAsRanks moves the order array in field of the values with
+// prefix to the order records and index entries of schema version 4,
+// which are added to order and index. things returns the keys of the
+// things in the array. The values are changed as raw JSON, so that
+// other fields are kept as they are. Malformed values are left alone.
+func orderAsRanks(tx *buntdb.Tx, prefix, field string, order, index map[string]string, things func(json.RawMessage) ([]string, error)) error {
+	return eachKey(tx, prefix, func(key, val string) error {
+		id := strings.TrimPrefix(key, prefix)
+		if _, err := strconv.Atoi(id); err != nil {
+			return nil
+		}
+		var v map[string]json.RawMessage
+		if decode(val, &v) != nil {
+			return nil
+		}
+		var keys []string
+		if raw, ok := v[field]; ok {
+			var err error
+			keys, err = things(raw)
+			if err != nil {
+				return nil
+			}
+			delete(v, field)
+		}
+		out, err := encode(v)
+		if err != nil {
+			return err
+		}
+		_, _, err = tx.Set(key, out, nil)
+		if err != nil {
+			return err
+		}
+		seen := map[string]bool{}
+		for _, thing := range keys {
+			if seen[thing] {
+				continue
+			}
+			rank := rankV4(len(seen))
+			seen[thing] = true
+			order[orderPrefix+prefix+id+"/"+rank] = thing
+			index[memberPrefix+thing+"/"+prefix+id] = rank
+		}
+		return nil
+	})
+}
+
+// rankV4 returns the rank of the member at index i of a container
+// in schema version 4: "a0" to "az", then "b00" to "bzz" and so on.
+func rankV4(i int) string {
+	const digits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	head, width := byte('a'), 1
+	for size := len(digits); i >= size; size *= len(digits) {
+		i -= size
+		head++
+		width++
+	}
+	rank := make([]byte, width)
+	for j := width - 1; j >= 0; j-- {
+		rank[j] = digits[i%len(digits)]
+		i /= len(digits)
+	}
+	return string(head) + string(rank)
+}
+
+// SchemaVersion is the schema version of the values that are
+// written by this version of the package.
+func SchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// SchemaVersion returns the schema version that is stored in the database.
+func (d *DB) SchemaVersion() (int, error) {
+	var version int
+	err := d.db.View(func(tx *buntdb.Tx) error {
+		var err error
+		version, err = storedSchemaVersion(tx)
+		return err
+	})
+	return version, err
+}
+
+// migrate runs all migrations that are newer than the stored
+// schema version. It returns the version before and after.
+func (d *DB) migrate() (from, to int, err error) {
+	to = SchemaVersion()
+	err = d.db.Update(func(tx *buntdb.Tx) error {
+		from, err = storedSchemaVersion(tx)
+		if err != nil {
+			return err
+		}
+		if from > to {
+			return fmt.Errorf("the data has schema version %d, but only versions up to %d are supported", from, to)
+		}
+		for _, m := range migrations {
+			if m.version <= from {
+				continue
+			}
+			err = m.up(tx)
+			if err != nil {
+				return fmt.Errorf("migration to schema version %d (%s) failed: %v", m.version, m.name, err)
+			}
+		}
+		_, _, err = tx.Set(schemaKey, strconv.Itoa(to), nil)
+		return err
+	})
+	return from, to, err
+}
+
+// storedSchemaVersion returns the stored schema version. New databases
+// have the current version, databases with values but without a stored
+// version are version 0.
+func storedSchemaVersion(tx *buntdb.Tx) (int, error) {
+	val, err := tx.Get(schemaKey)
+	if err == nil {
+		v, err := strconv.Atoi(val)
+		if err != nil {
+			return 0, stored.WithCause(fmt.Errorf("invalid schema version %q", val), stored.CauseMalformed)
+		}
+		return v, nil
+	}
+	if err != buntdb.ErrNotFound {
+		return 0, err
+	}
+	for _, prefix := range []string{itemPrefix, listPrefix, areaPrefix, userPrefix} {
+		var found bool
+		err = tx.AscendKeys(prefix+"*", func(key, val string) bool {
+			found = true
+			return false
+		})
+		if err != nil || found {
+			return 0, err
+		}
+	}
+	return SchemaVersion(), nil
+}
+
+// Rewrite decodes all items, lists, areas and users and stores them
+// again in the current format. It returns the number of rewritten
+// values. Malformed values are left alone, they are found by Check.
+func (d *DB) Rewrite() (int, error) {
+	tx, err := d.Update()
+	if err != nil {
+		return 0, err
+	}
+	n, err := rewriteAll(tx.rawTx)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	tx.Close()
+	return n, nil
+}
+
+func rewriteAll(tx *buntdb.Tx) (int, error) {
+	var n int
+	all := []struct {
+		prefix string
+		new    func() interface{}
+	}{
+		{itemPrefix, func() interface{} { return &stored.Item{} }},
+		{listPrefix, func() interface{} { return &stored.List{} }},
+		{areaPrefix, func() interface{} { return &stored.Area{} }},
+		{userPrefix, func() interface{} { return &stored.User{} }},
+	}
+	for _, a := range all {
+		err := eachKey(tx, a.prefix, func(key, val string) error {
+			v := a.new()
+			if decode(val, v) != nil {
+				return nil
+			}
+			out, err := encode(v)
+			if err != nil {
+				return err
+			}
+			n++
+			_, _, err = tx.Set(key, out, nil)
+			return err
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
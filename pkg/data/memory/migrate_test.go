@@ -0,0 +1,361 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mbertschler/bunny/pkg/data/stored"
+	"github.com/tidwall/buntdb"
+)
+
+// openFixture loads the raw values of a testdata/schema fixture
+// into a new database without running the migrations.
+func openFixture(t *testing.T, name string) *DB {
+	buf, err := ioutil.ReadFile(filepath.Join("testdata", "schema", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var values map[string]json.RawMessage
+	err = json.Unmarshal(buf, &values)
+	if err != nil {
+		t.Fatal(name, err)
+	}
+	db, err := buntdb.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &DB{db: db}
+	raw := map[string]string{}
+	for k, v := range values {
+		// index and order records are strings, other values JSON
+		var str string
+		if json.Unmarshal(v, &str) == nil {
+			raw[k] = str
+		} else {
+			raw[k] = string(v)
+		}
+	}
+	setRaw(t, d, raw)
+	return d
+}
+
+// the areas, focus, index and sequences of the v0 fixtures
+var (
+	v0Things = []stored.ThingID{{Type: stored.TypeList, ID: 1}}
+	v0Focus  = map[int][]int{stored.FocusNow: {1}}
+	v0Index  = map[string]string{
+		"m/i/2/l/1": "a0",
+		"m/i/1/l/1": "a1",
+		"m/l/1/a/1": "a0",
+		"m/i/1/u/1": "1",
+	}
+	v0Sequences = map[string]int{itemPrefix: 2, listPrefix: 1, areaPrefix: 1, userPrefix: 1, entryPrefix: 0}
+)
+
+// the expected values of the fixtures from version 2 on
+var (
+	v2Items = []stored.Item{
+		{ID: 1, Version: 2, Title: "one"},
+		{ID: 2, State: stored.ItemComplete, Title: "two"},
+		{ID: 3, Version: 1, Title: "three", Due: time.Date(2019, 1, 31, 0, 0, 0, 0, time.UTC)},
+	}
+	v2Lists = []stored.List{
+		{ID: 1, Version: 1, Title: "first", Items: []int{3, 1}},
+		{ID: 2, Title: "second"},
+	}
+	v2Things = []stored.ThingID{{Type: stored.TypeItem, ID: 2}, {Type: stored.TypeList, ID: 1}}
+	v2Focus  = map[int][]int{stored.FocusNow: {1}, stored.FocusWatch: {2}}
+	v2Index  = map[string]string{
+		"m/i/3/l/1": "a0",
+		"m/i/1/l/1": "a1",
+		"m/i/2/a/1": "a0",
+		"m/l/1/a/1": "a1",
+		"m/i/1/u/1": "1",
+		"m/i/2/u/1": "3",
+	}
+	// the item sequence is ahead of the highest item, the entry
+	// sequence is added
+	v2Sequences = map[string]int{itemPrefix: 5, listPrefix: 2, areaPrefix: 1, userPrefix: 1, entryPrefix: 4}
+)
+
+func TestMigrateFixtures(t *testing.T) {
+	cases := []struct {
+		fixture   string
+		version   int // stored version of the fixture
+		items     []stored.Item
+		lists     []stored.List
+		things    []stored.ThingID // of area 1
+		focus     map[int][]int    // of user 1
+		index     map[string]string
+		sequences map[string]int // last ID by prefix
+	}{
+		{
+			fixture: "v0-baseline.json",
+			items: []stored.Item{
+				{ID: 1, Title: "Hello world!", Body: "Let's have some fun"},
+				{ID: 2, State: stored.ItemComplete, Title: "Done"},
+			},
+			lists: []stored.List{
+				{ID: 1, Title: "Testlist", Body: "just for testing", Items: []int{2, 1}},
+			},
+			things:    v0Things,
+			focus:     v0Focus,
+			index:     v0Index,
+			sequences: v0Sequences,
+		},
+		{
+			fixture: "v0-versions.json",
+			items: []stored.Item{
+				{ID: 1, Version: 3, Title: "Hello world!", Body: "Let's have some fun"},
+				{ID: 2, Version: 1, State: stored.ItemComplete, Title: "Done"},
+			},
+			lists: []stored.List{
+				{ID: 1, Version: 2, Title: "Testlist", Body: "just for testing", Items: []int{2, 1}},
+			},
+			things:    v0Things,
+			focus:     v0Focus,
+			index:     v0Index,
+			sequences: v0Sequences,
+		},
+		{
+			fixture: "v0-due.json",
+			items: []stored.Item{
+				{ID: 1, Version: 3, Title: "Hello world!", Body: "Let's have some fun",
+					Due: time.Date(2018, 12, 31, 0, 0, 0, 0, time.UTC)},
+				{ID: 2, Version: 1, State: stored.ItemComplete, Title: "Done"},
+			},
+			lists: []stored.List{
+				{ID: 1, Version: 2, Title: "Testlist", Body: "just for testing", Items: []int{2, 1}},
+			},
+			things:    v0Things,
+			focus:     v0Focus,
+			index:     v0Index,
+			sequences: v0Sequences,
+		},
+		{
+			fixture:   "v2-sequences.json",
+			version:   2,
+			items:     v2Items,
+			lists:     v2Lists,
+			things:    v2Things,
+			focus:     v2Focus,
+			index:     v2Index,
+			sequences: v2Sequences,
+		},
+		{
+			fixture:   "v3-index.json",
+			version:   3,
+			items:     v2Items,
+			lists:     v2Lists,
+			things:    v2Things,
+			focus:     v2Focus,
+			index:     v2Index,
+			sequences: v2Sequences,
+		},
+		{
+			// the ranks of the fixture are kept
+			fixture: "v4-ranks.json",
+			version: 4,
+			items:   v2Items,
+			lists:   v2Lists,
+			things:  v2Things,
+			focus:   v2Focus,
+			index: map[string]string{
+				"m/i/3/l/1": "a0",
+				"m/i/1/l/1": "a0V",
+				"m/i/2/a/1": "a0",
+				"m/l/1/a/1": "a1",
+				"m/i/1/u/1": "1",
+				"m/i/2/u/1": "3",
+			},
+			sequences: v2Sequences,
+		},
+	}
+	for _, c := range cases {
+		d := openFixture(t, c.fixture)
+		from, to, err := d.migrate()
+		if err != nil {
+			t.Fatal(c.fixture, err)
+		}
+		if from != c.version || to != SchemaVersion() {
+			t.Error(c.fixture, "migrated from", from, "to", to)
+		}
+		version, err := d.SchemaVersion()
+		if err != nil || version != SchemaVersion() {
+			t.Error(c.fixture, "stored schema version is", version, err)
+		}
+
+		items, err := d.Items()
+		if err != nil {
+			t.Fatal(c.fixture, err)
+		}
+		if !reflect.DeepEqual(items, c.items) {
+			t.Errorf("%s: got items\n%+v\nwant\n%+v", c.fixture, items, c.items)
+		}
+		lists, err := d.Lists()
+		if err != nil {
+			t.Fatal(c.fixture, err)
+		}
+		if !reflect.DeepEqual(lists, c.lists) {
+			t.Errorf("%s: got lists\n%+v\nwant\n%+v", c.fixture, lists, c.lists)
+		}
+		user, err := d.UserByID(1)
+		if err != nil || !reflect.DeepEqual(user.Focus, c.focus) {
+			t.Error(c.fixture, "wrong user", user, err)
+		}
+		area, err := d.AreaByID(1)
+		if err != nil || !reflect.DeepEqual(area.Things, c.things) {
+			t.Error(c.fixture, "wrong area", area, err)
+		}
+		raw := rawValues(t, d)
+		index := map[string]string{}
+		for k, v := range raw {
+			if strings.HasPrefix(k, memberPrefix) {
+				index[k] = v
+			}
+		}
+		if !reflect.DeepEqual(index, c.index) {
+			t.Errorf("%s: got index\n%v\nwant\n%v", c.fixture, index, c.index)
+		}
+		for prefix, want := range c.sequences {
+			got, _ := strconv.Atoi(raw[seqPrefix+prefix])
+			if got != want {
+				t.Error(c.fixture, "sequence of", prefix, "is", got, "want", want)
+			}
+		}
+		// the values are stored in the current format
+		if val := raw["i/1"]; !strings.Contains(val, `"Version":`) || !strings.Contains(val, `"Due":`) {
+			t.Error(c.fixture, "item wasn't rewritten:", val)
+		}
+		if val := raw["l/1"]; strings.Contains(val, `"Items"`) {
+			t.Error(c.fixture, "list still has the items array:", val)
+		}
+
+		// migrating again does nothing
+		from, _, err = d.migrate()
+		if err != nil || from != SchemaVersion() {
+			t.Error(c.fixture, "second migration started at", from, err)
+		}
+	}
+}
+
+func TestMigrateNew(t *testing.T) {
+	d := Open()
+	version, err := d.SchemaVersion()
+	if err != nil || version != SchemaVersion() {
+		t.Error("new database has schema version", version, err)
+	}
+}
+
+func TestMigrateNewer(t *testing.T) {
+	d := openFixture(t, "v0-baseline.json")
+	setRaw(t, d, map[string]string{schemaKey: "9999"})
+	_, _, err := d.migrate()
+	if err == nil {
+		t.Error("newer schema versions should be rejected")
+	}
+}
+
+// migrateTo runs only the migration to version.
+func migrateTo(t *testing.T, d *DB, version int) {
+	for _, m := range migrations {
+		if m.version == version {
+			err := d.db.Update(m.up)
+			if err != nil {
+				t.Fatal("migration", version, err)
+			}
+			return
+		}
+	}
+	t.Fatal("no migration to version", version)
+}
+
+func TestMigrateMembersV3(t *testing.T) {
+	d := &DB{}
+	var err error
+	d.db, err = buntdb.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	setRaw(t, d, map[string]string{
+		"l/1":       `{"ID":1,"Items":[2,1]}`,
+		"l/2":       `{"ID":2,"Items":"malformed"}`,
+		"a/1":       `{"ID":1,"Things":[{"Type":2,"ID":1},{"Type":1,"ID":3}]}`,
+		"u/1":       `{"ID":1,"Focus":{"1":[1],"2":[3]}}`,
+		"m/i/9/l/9": "",
+	})
+	migrateTo(t, d, 3)
+	want := map[string]string{
+		"m/i/1/l/1": "",
+		"m/i/2/l/1": "",
+		"m/l/1/a/1": "",
+		"m/i/3/a/1": "",
+		"m/i/1/u/1": "1",
+		"m/i/3/u/1": "2",
+	}
+	got := map[string]string{}
+	for k, v := range rawValues(t, d) {
+		if strings.HasPrefix(k, memberPrefix) {
+			got[k] = v
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got index\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestMigrateRanksV4(t *testing.T) {
+	d := &DB{}
+	var err error
+	d.db, err = buntdb.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	setRaw(t, d, map[string]string{
+		"l/1":       `{"ID":1,"Title":"list","Items":[2,1,2]}`,
+		"a/1":       `{"ID":1,"Things":[{"Type":2,"ID":1},{"Type":1,"ID":3}]}`,
+		"u/1":       `{"ID":1,"Focus":{"1":[1]}}`,
+		"m/i/1/l/1": "",
+		"m/i/2/l/1": "",
+		"m/l/1/a/1": "",
+		"m/i/3/a/1": "",
+		"m/i/1/u/1": "1",
+	})
+	migrateTo(t, d, 4)
+	want := map[string]string{
+		"l/1":       `{"ID":1,"Title":"list"}`,
+		"a/1":       `{"ID":1}`,
+		"u/1":       `{"ID":1,"Focus":{"1":[1]}}`,
+		"o/l/1/a0":  "i/2",
+		"o/l/1/a1":  "i/1",
+		"o/a/1/a0":  "l/1",
+		"o/a/1/a1":  "i/3",
+		"m/i/2/l/1": "a0",
+		"m/i/1/l/1": "a1",
+		"m/l/1/a/1": "a0",
+		"m/i/3/a/1": "a1",
+		"m/i/1/u/1": "1",
+	}
+	if got := rawValues(t, d); !reflect.DeepEqual(got, want) {
+		t.Errorf("got values\n%v\nwant\n%v", got, want)
+	}
+}
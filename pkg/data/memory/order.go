@@ -0,0 +1,243 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mbertschler/bunny/pkg/data/stored"
+	"github.com/tidwall/buntdb"
+)
+
+// orderPrefix holds the members of lists and areas in their order.
+// There is a key "o/l/<list>/<rank>" with the key of an item like
+// "i/12" as value for every item in a list, and "o/a/<area>/<rank>"
+// with the key of an item or list for every thing in an area. The
+// rank is also the value of the membership index entry of the thing,
+// so that moving it only changes its own keys.
+const orderPrefix = "o/"
+
+// member is a thing at its rank in the order of a container.
+type member struct {
+	rank  string
+	thing stored.ThingID
+}
+
+func orderKey(container string, cid int, rank string) string {
+	return orderPrefix + container + strconv.Itoa(cid) + "/" + rank
+}
+
+func thingPrefix(t stored.ThingID) string {
+	if t.Type == stored.TypeList {
+		return listPrefix
+	}
+	return itemPrefix
+}
+
+// thingKey returns the key of the thing, like "i/12".
+func thingKey(t stored.ThingID) string {
+	return thingPrefix(t) + strconv.Itoa(t.ID)
+}
+
+func parseThingKey(key string) (stored.ThingID, bool) {
+	var t stored.ThingID
+	switch {
+	case strings.HasPrefix(key, itemPrefix):
+		t.Type = stored.TypeItem
+	case strings.HasPrefix(key, listPrefix):
+		t.Type = stored.TypeList
+	default:
+		return t, false
+	}
+	id, err := strconv.Atoi(key[len(itemPrefix):])
+	t.ID = id
+	return t, err == nil && id > 0
+}
+
+func orderMemberKey(t stored.ThingID, container string, cid int) string {
+	return memberKey(thingPrefix(t), t.ID, container, cid)
+}
+
+// parseOrderKey splits an order key into the container and the rank.
+func parseOrderKey(key string) (container string, cid int, rank string, ok bool) {
+	rest := strings.TrimPrefix(key, orderPrefix)
+	switch {
+	case strings.HasPrefix(rest, listPrefix):
+		container = listPrefix
+	case strings.HasPrefix(rest, areaPrefix):
+		container = areaPrefix
+	default:
+		return "", 0, "", false
+	}
+	parts := strings.SplitN(rest[len(container):], "/", 2)
+	if len(parts) != 2 {
+		return "", 0, "", false
+	}
+	cid, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return "", 0, "", false
+	}
+	return container, cid, parts[1], true
+}
+
+// eachMember calls fn for the members of the container in their
+// order until fn returns false. Malformed values are skipped, they
+// are found by Check.
+func eachMember(tx *buntdb.Tx, container string, cid int, fn func(m member) bool) error {
+	prefix := orderKey(container, cid, "")
+	return tx.AscendKeys(prefix+"*", func(key, val string) bool {
+		thing, ok := parseThingKey(val)
+		if !ok {
+			return true
+		}
+		return fn(member{rank: strings.TrimPrefix(key, prefix), thing: thing})
+	})
+}
+
+// orderedThings returns the members of the container in their order.
+func orderedThings(tx *buntdb.Tx, container string, cid int) ([]stored.ThingID, error) {
+	var out []stored.ThingID
+	err := eachMember(tx, container, cid, func(m member) bool {
+		out = append(out, m.thing)
+		return true
+	})
+	return out, err
+}
+
+// setOrder replaces the members of the container with things in
+// their order. Nothing is changed if the order stays the same.
+// Things that appear more than once are only added the first time.
+func setOrder(tx *buntdb.Tx, container string, cid int, things []stored.ThingID) error {
+	seen := map[stored.ThingID]bool{}
+	var unique []stored.ThingID
+	for _, t := range things {
+		if !seen[t] {
+			seen[t] = true
+			unique = append(unique, t)
+		}
+	}
+	var current []member
+	err := eachMember(tx, container, cid, func(m member) bool {
+		current = append(current, m)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if sameThings(current, unique) {
+		return nil
+	}
+	for _, m := range current {
+		err = deleteKeys(tx, orderKey(container, cid, m.rank), orderMemberKey(m.thing, container, cid))
+		if err != nil {
+			return err
+		}
+	}
+	var rank string
+	for _, t := range unique {
+		rank, err = rankBetween(rank, "")
+		if err != nil {
+			return err
+		}
+		err = setMember(tx, container, cid, member{rank: rank, thing: t})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sameThings(members []member, things []stored.ThingID) bool {
+	if len(members) != len(things) {
+		return false
+	}
+	for i, m := range members {
+		if m.thing != things[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func setMember(tx *buntdb.Tx, container string, cid int, m member) error {
+	_, _, err := tx.Set(orderKey(container, cid, m.rank), thingKey(m.thing), nil)
+	if err != nil {
+		return err
+	}
+	_, _, err = tx.Set(orderMemberKey(m.thing, container, cid), m.rank, nil)
+	return err
+}
+
+func deleteKeys(tx *buntdb.Tx, keys ...string) error {
+	for _, key := range keys {
+		_, err := tx.Delete(key)
+		if err != nil && err != buntdb.ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// moveMember moves the thing to index pos of the container, or adds
+// it there if it isn't a member yet. The result is the same as that
+// of sortArray, but only the keys of the thing are changed and only
+// the members up to pos are read. Like sortArray it fails with an
+// invalid error if pos is out of range.
+func moveMember(tx *buntdb.Tx, container string, cid int, thing stored.ThingID, pos int) error {
+	old, err := tx.Get(orderMemberKey(thing, container, cid))
+	if err != nil && err != buntdb.ErrNotFound {
+		return err
+	}
+	isMember := err == nil
+	var before, after string
+	var others int
+	err = eachMember(tx, container, cid, func(m member) bool {
+		if m.thing == thing {
+			return true
+		}
+		if others == pos-1 {
+			before = m.rank
+		}
+		if others == pos {
+			after = m.rank
+			return false
+		}
+		others++
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if pos < 0 || (after == "" && pos > others) {
+		return stored.WithCause(errors.New(fmt.Sprintln(
+			"invalid position", pos, "max", others)), stored.CauseInvalid)
+	}
+	if isMember && (before == "" || before < old) && (after == "" || old < after) {
+		return nil // already at pos
+	}
+	rank, err := rankBetween(before, after)
+	if err != nil {
+		return err
+	}
+	if isMember {
+		err = deleteKeys(tx, orderKey(container, cid, old))
+		if err != nil {
+			return err
+		}
+	}
+	return setMember(tx, container, cid, member{rank: rank, thing: thing})
+}
@@ -0,0 +1,240 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	"github.com/mbertschler/bunny/pkg/data/stored"
+	"github.com/tidwall/buntdb"
+)
+
+func TestRankBetween(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want string
+	}{
+		{"", "", "a0"},
+		{"a0", "", "a1"},
+		{"az", "", "b00"},
+		{"", "a0", "Zz"},
+		{"", "b00", "az"},
+		{"a0", "a1", "a0V"},
+		{"a0V", "a1", "a0k"},
+		{"a1", "a1V", "a1F"},
+	}
+	for _, c := range cases {
+		got, err := rankBetween(c.a, c.b)
+		if err != nil || got != c.want {
+			t.Errorf("rankBetween(%q, %q) = %q, %v, want %q", c.a, c.b, got, err, c.want)
+		}
+	}
+	for _, c := range [][2]string{{"a1", "a0"}, {"a0", "a0"}, {"a10", ""}, {"", "x"}, {"a!", ""}} {
+		_, err := rankBetween(c[0], c[1])
+		if err == nil {
+			t.Errorf("rankBetween(%q, %q) should fail", c[0], c[1])
+		}
+	}
+}
+
+// TestRankProperties inserts ranks at random places and checks that
+// they are valid, sort between their neighbours and stay short when
+// they are added at the start or the end.
+func TestRankProperties(t *testing.T) {
+	f := func(places []uint8) bool {
+		var ranks []string
+		for _, p := range places {
+			i := int(p) % (len(ranks) + 1)
+			var a, b string
+			if i > 0 {
+				a = ranks[i-1]
+			}
+			if i < len(ranks) {
+				b = ranks[i]
+			}
+			r, err := rankBetween(a, b)
+			if err != nil || !validRank(r) || (a != "" && r <= a) || (b != "" && r >= b) {
+				t.Logf("rankBetween(%q, %q) = %q, %v", a, b, r, err)
+				return false
+			}
+			ranks = append(ranks[:i], append([]string{r}, ranks[i:]...)...)
+		}
+		return true
+	}
+	err := quick.Check(f, nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	first, last := firstRank, firstRank
+	for i := 0; i < 10000; i++ {
+		var err error
+		first, err = rankBetween("", first)
+		if err != nil {
+			t.Fatal(err)
+		}
+		last, err = rankBetween(last, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(first) > 4 || len(last) > 4 {
+		t.Error("ranks grow too fast:", first, last)
+	}
+}
+
+// TestMovesMatchSortArray applies random moves to a list and to an
+// array with sortArray, the reference of the order before ranks.
+func TestMovesMatchSortArray(t *testing.T) {
+	const items = 16
+	d := Open()
+	for i := 0; i < items; i++ {
+		_, err := d.NewItem(stored.Item{Title: fmt.Sprint("item ", i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	f := func(initial uint8, moves []uint16) bool {
+		var want []int
+		for i := 1; i <= int(initial)%items; i++ {
+			want = append(want, i)
+		}
+		list, err := d.NewList(stored.List{Items: want})
+		if err != nil {
+			t.Log(err)
+			return false
+		}
+		for _, m := range moves {
+			item := int(m)%items + 1
+			pos := int(m/items) % (len(want) + 3) // includes invalid positions
+			arr := append([]int{}, want...)
+			i, ok := findInArray(arr, item)
+			if !ok {
+				i = len(arr)
+				arr = append(arr, item)
+			}
+			arr, sortErr := sortArray(arr, i, pos-1)
+			if sortErr == nil {
+				want = arr
+			}
+			err = d.SetListItemPosition(list, item, pos)
+			if (err == nil) != (sortErr == nil) {
+				t.Log("moving", item, "to", pos, "returned", err, "sortArray returned", sortErr)
+				return false
+			}
+			l, err := d.ListByID(list)
+			if err != nil || !reflect.DeepEqual(l.Items, want) {
+				t.Log("moving", item, "to", pos, "resulted in", l.Items, err, "want", want)
+				return false
+			}
+		}
+		return true
+	}
+	err := quick.Check(f, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	problems, err := d.Check(false)
+	if err != nil || len(problems) != 0 {
+		t.Error("moves left problems", problems, err)
+	}
+}
+
+// TestMoveTouchesOneRecord checks that moving an item only changes
+// the keys of the moved item and leaves the list value alone.
+func TestMoveTouchesOneRecord(t *testing.T) {
+	d := Open()
+	var ids []int
+	for i := 0; i < 5; i++ {
+		id, err := d.NewItem(stored.Item{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	list, err := d.NewList(stored.List{Items: ids})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := rawValues(t, d)
+	err = d.SetListItemPosition(list, ids[4], 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := rawValues(t, d)
+	var changed []string
+	for k, v := range after {
+		if before[k] != v {
+			changed = append(changed, k)
+		}
+	}
+	for k := range before {
+		if _, ok := after[k]; !ok {
+			changed = append(changed, k)
+		}
+	}
+	for _, k := range changed {
+		if !strings.HasPrefix(k, orderPrefix+"l/") && k != memberKey(itemPrefix, ids[4], listPrefix, list) {
+			t.Error("move changed", k)
+		}
+	}
+	if len(changed) != 3 {
+		t.Error("move should change 3 keys, changed", changed)
+	}
+}
+
+func rawValues(t *testing.T, d *DB) map[string]string {
+	out := map[string]string{}
+	err := d.db.View(func(tx *buntdb.Tx) error {
+		return tx.Ascend("", func(key, val string) bool {
+			out[key] = val
+			return true
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func BenchmarkMoveInLargeList(b *testing.B) {
+	d := Open()
+	list := stored.List{}
+	err := d.Batch(func(d *DB) error {
+		for i := 0; i < 5000; i++ {
+			id, err := d.NewItem(stored.Item{Title: "item"})
+			if err != nil {
+				return err
+			}
+			list.Items = append(list.Items, id)
+		}
+		var err error
+		list.ID, err = d.NewList(list)
+		return err
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		err = d.SetListItemPosition(list.ID, list.Items[n%5000], 1)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/mbertschler/bunny/pkg/data/stored"
+)
+
+// Page selects a part of the ordered things of a list or area.
+type Page struct {
+	Cursor   string // Next of the previous page, empty for the first page
+	Limit    int    // maximum number of returned things
+	Archived bool   // select archived things instead of the other ones
+}
+
+// Cursors name the last thing of a page, like "i12" for item 12 or
+// "l3" for list 3. The next page continues after that thing, so
+// things that are added or moved above it don't shift the page.
+func cursor(id stored.ThingID) string {
+	prefix := "i"
+	if id.Type == stored.TypeList {
+		prefix = "l"
+	}
+	return prefix + strconv.Itoa(id.ID)
+}
+
+func parseCursor(str string) (stored.ThingID, error) {
+	var id stored.ThingID
+	if len(str) < 2 {
+		return id, invalidCursor(str)
+	}
+	switch str[0] {
+	case 'i':
+		id.Type = stored.TypeItem
+	case 'l':
+		id.Type = stored.TypeList
+	default:
+		return id, invalidCursor(str)
+	}
+	n, err := strconv.Atoi(str[1:])
+	if err != nil || n < 1 {
+		return id, invalidCursor(str)
+	}
+	id.ID = n
+	return id, nil
+}
+
+func invalidCursor(str string) error {
+	return stored.WithCause(fmt.Errorf("invalid cursor %q", str), stored.CauseInvalid)
+}
+
+// thingPage reads the things of ids that belong to page p, in the
+// order of ids. Items get the focus of the user. Only the things of
+// the page and one following thing are read, so the cost of a page
+// doesn't grow with the length of ids. Things that are missing are
+// logged and skipped.
+func (t *Tx) thingPage(user int, ids []stored.ThingID, p Page) ([]stored.Thing, string, error) {
+	if p.Limit < 1 {
+		err := fmt.Errorf("page limit has to be at least 1, not %d", p.Limit)
+		return nil, "", stored.WithCause(err, stored.CauseInvalid)
+	}
+	start := 0
+	if p.Cursor != "" {
+		after, err := parseCursor(p.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		i, ok := findInThingArray(ids, after)
+		if !ok {
+			err = fmt.Errorf("cursor %q is not part of the list anymore", p.Cursor)
+			return nil, "", stored.WithCause(err, stored.CauseInvalid)
+		}
+		start = i + 1
+	}
+	focus, err := t.users.FocusMap(user)
+	if err != nil {
+		return nil, "", err
+	}
+	var out []stored.Thing
+	var last stored.ThingID
+	for _, id := range ids[start:] {
+		var thing stored.Thing
+		var state int
+		switch id.Type {
+		case stored.TypeItem:
+			item, err := t.items.Get(id.ID)
+			if err != nil {
+				log.Println("skipping missing item in page:", user, id, err)
+				continue
+			}
+			item.Focus = focus[id.ID]
+			thing, state = item, item.State
+		case stored.TypeList:
+			list, err := t.lists.Get(id.ID)
+			if err != nil {
+				log.Println("skipping missing list in page:", user, id, err)
+				continue
+			}
+			thing, state = list, list.State
+		default:
+			log.Println("skipping unknown type in page:", user, id)
+			continue
+		}
+		if (state == stored.ItemArchived) != p.Archived {
+			continue
+		}
+		if len(out) == p.Limit {
+			return out, cursor(last), nil
+		}
+		out = append(out, thing)
+		last = id
+	}
+	return out, "", nil
+}
+
+// UserItemsPage returns page p of the items of the list with the
+// focus of the user, and the cursor of the next page. The cursor is
+// empty if this is the last page.
+func (t *listsTx) UserItemsPage(user, list int, p Page) ([]stored.Item, string, error) {
+	_, err := t.parent.users.Get(user)
+	if err != nil {
+		return nil, "", err
+	}
+	l, err := t.Get(list)
+	if err != nil {
+		return nil, "", err
+	}
+	ids := make([]stored.ThingID, len(l.Items))
+	for i, id := range l.Items {
+		ids[i] = stored.ThingID{Type: stored.TypeItem, ID: id}
+	}
+	things, next, err := t.parent.thingPage(user, ids, p)
+	if err != nil {
+		return nil, "", err
+	}
+	out := make([]stored.Item, len(things))
+	for i, thing := range things {
+		out[i] = thing.(stored.Item)
+	}
+	return out, next, nil
+}
+
+// UserThingsPage is UserItemsPage for the lists and items of an area.
+func (t *areasTx) UserThingsPage(user, area int, p Page) ([]stored.Thing, string, error) {
+	_, err := t.parent.users.Get(user)
+	if err != nil {
+		return nil, "", err
+	}
+	a, err := t.Get(area)
+	if err != nil {
+		return nil, "", err
+	}
+	return t.parent.thingPage(user, a.Things, p)
+}
+
+func (d *DB) UserItemListPage(user, id int, p Page) (stored.List, []stored.Item, string, error) {
+	var list stored.List
+	tx, err := d.View()
+	if err != nil {
+		return list, nil, "", err
+	}
+	defer tx.Close()
+	list, err = tx.lists.Get(id)
+	if err != nil {
+		return list, nil, "", err
+	}
+	items, next, err := tx.lists.UserItemsPage(user, id, p)
+	return list, items, next, err
+}
+
+func (d *DB) UserAreaPage(user, id int, p Page) (stored.Area, []stored.Thing, string, error) {
+	var area stored.Area
+	tx, err := d.View()
+	if err != nil {
+		return area, nil, "", err
+	}
+	defer tx.Close()
+	area, err = tx.areas.Get(id)
+	if err != nil {
+		return area, nil, "", err
+	}
+	things, next, err := tx.areas.UserThingsPage(user, id, p)
+	return area, things, next, err
+}
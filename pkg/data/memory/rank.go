@@ -0,0 +1,243 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mbertschler/bunny/pkg/data/stored"
+)
+
+// Ranks order the members of lists and areas. They are compared as
+// strings and there is always room for a rank between two others,
+// so moving a member only changes its own rank.
+//
+// A rank is an integer part followed by an optional fraction. The
+// first byte of the integer part encodes its length: "a0" to "az"
+// have one digit, "b00" to "bzz" two and so on, "Z" to "A" are the
+// negative integers. Adding members at the start or the end
+// decrements or increments the integer part, so ranks only grow
+// logarithmically with the number of members. Between two ranks
+// with the same integer part a fraction is used. Fractions never
+// end with the smallest digit, otherwise there could be no rank
+// before them.
+const rankDigits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// firstRank is the rank of the first member of an empty container.
+const firstRank = "a0"
+
+// smallestInteger is not a valid rank, there would be no room before it.
+const smallestInteger = "A00000000000000000000000000"
+
+func rankDigit(c byte) int {
+	return strings.IndexByte(rankDigits, c)
+}
+
+// integerLength returns the length of an integer part that starts with head.
+func integerLength(head byte) (int, bool) {
+	switch {
+	case head >= 'a' && head <= 'z':
+		return int(head-'a') + 2, true
+	case head >= 'A' && head <= 'Z':
+		return int('Z'-head) + 2, true
+	}
+	return 0, false
+}
+
+// validRank reports whether r is well formed.
+func validRank(r string) bool {
+	if r == "" || r == smallestInteger {
+		return false
+	}
+	n, ok := integerLength(r[0])
+	if !ok || len(r) < n {
+		return false
+	}
+	for i := 1; i < len(r); i++ {
+		if rankDigit(r[i]) < 0 {
+			return false
+		}
+	}
+	return len(r) == n || r[len(r)-1] != rankDigits[0]
+}
+
+// splitRank splits a valid rank into its integer part and fraction.
+func splitRank(r string) (string, string) {
+	n, _ := integerLength(r[0])
+	return r[:n], r[n:]
+}
+
+// rankBetween returns a rank that sorts after a and before b. An
+// empty a is the start and an empty b the end of the order.
+func rankBetween(a, b string) (string, error) {
+	if a != "" && !validRank(a) {
+		return "", rankErr("invalid rank %q", a)
+	}
+	if b != "" && !validRank(b) {
+		return "", rankErr("invalid rank %q", b)
+	}
+	if a != "" && b != "" && a >= b {
+		return "", rankErr("rank %q is not before %q", a, b)
+	}
+	if a == "" {
+		if b == "" {
+			return firstRank, nil
+		}
+		ib, fb := splitRank(b)
+		if ib == smallestInteger {
+			return ib + midpoint("", fb), nil
+		}
+		if ib < b {
+			return ib, nil
+		}
+		r, ok := decrementInteger(ib)
+		if !ok {
+			return "", rankErr("no rank before %q", b)
+		}
+		return r, nil
+	}
+	ia, fa := splitRank(a)
+	if b == "" {
+		r, ok := incrementInteger(ia)
+		if !ok {
+			return ia + midpoint(fa, ""), nil
+		}
+		return r, nil
+	}
+	ib, fb := splitRank(b)
+	if ia == ib {
+		return ia + midpoint(fa, fb), nil
+	}
+	r, ok := incrementInteger(ia)
+	if !ok {
+		return "", rankErr("no rank after %q", a)
+	}
+	if r < b {
+		return r, nil
+	}
+	return ia + midpoint(fa, ""), nil
+}
+
+func rankErr(format string, args ...interface{}) error {
+	return stored.WithCause(fmt.Errorf(format, args...), stored.CauseMalformed)
+}
+
+// midpoint returns a fraction between the fractions a and b, where
+// an empty b is the end. a has to be smaller than b.
+func midpoint(a, b string) string {
+	if b != "" {
+		// skip the common prefix, a is padded with the smallest digit
+		n := 0
+		for n < len(b) {
+			c := rankDigits[0]
+			if n < len(a) {
+				c = a[n]
+			}
+			if c != b[n] {
+				break
+			}
+			n++
+		}
+		if n > 0 {
+			var rest string
+			if n < len(a) {
+				rest = a[n:]
+			}
+			return b[:n] + midpoint(rest, b[n:])
+		}
+	}
+	da, db := 0, len(rankDigits)
+	if a != "" {
+		da = rankDigit(a[0])
+	}
+	if b != "" {
+		db = rankDigit(b[0])
+	}
+	if db-da > 1 {
+		return rankDigits[(da+db)/2 : (da+db)/2+1]
+	}
+	// the first digits are consecutive
+	if len(b) > 1 {
+		return b[:1]
+	}
+	var rest string
+	if len(a) > 1 {
+		rest = a[1:]
+	}
+	return rankDigits[da:da+1] + midpoint(rest, "")
+}
+
+// incrementInteger returns the integer part after x, or false if x
+// is the largest one.
+func incrementInteger(x string) (string, bool) {
+	head, digits := x[0], []byte(x[1:])
+	carry := true
+	for i := len(digits) - 1; carry && i >= 0; i-- {
+		d := rankDigit(digits[i]) + 1
+		if d == len(rankDigits) {
+			digits[i] = rankDigits[0]
+		} else {
+			digits[i] = rankDigits[d]
+			carry = false
+		}
+	}
+	if carry {
+		switch head {
+		case 'Z':
+			return "a" + rankDigits[:1], true
+		case 'z':
+			return "", false
+		}
+		head++
+		if head > 'a' {
+			digits = append(digits, rankDigits[0])
+		} else {
+			digits = digits[:len(digits)-1]
+		}
+	}
+	return string(head) + string(digits), true
+}
+
+// decrementInteger returns the integer part before x, or false if
+// x is the smallest one.
+func decrementInteger(x string) (string, bool) {
+	last := rankDigits[len(rankDigits)-1]
+	head, digits := x[0], []byte(x[1:])
+	borrow := true
+	for i := len(digits) - 1; borrow && i >= 0; i-- {
+		d := rankDigit(digits[i]) - 1
+		if d < 0 {
+			digits[i] = last
+		} else {
+			digits[i] = rankDigits[d]
+			borrow = false
+		}
+	}
+	if borrow {
+		switch head {
+		case 'a':
+			return "Z" + string(last), true
+		case 'A':
+			return "", false
+		}
+		head--
+		if head < 'Z' {
+			digits = append(digits, last)
+		} else {
+			digits = digits[:len(digits)-1]
+		}
+	}
+	return string(head) + string(digits), true
+}
@@ -0,0 +1,80 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/mbertschler/bunny/pkg/data/stored"
+	"github.com/tidwall/buntdb"
+)
+
+// seqPrefix is the prefix of the ID sequences. There is one sequence
+// per type, for items it is stored at "seq/i/". The next ID can't be
+// derived from the keys, because they are sorted as strings and
+// "i/9" comes after "i/10".
+const seqPrefix = "seq/"
+
+// nextID increments the sequence of prefix and returns the new ID.
+func nextID(tx *buntdb.Tx, prefix string) (int, error) {
+	id, err := sequence(tx, prefix)
+	if err != nil {
+		return 0, err
+	}
+	id++
+	_, _, err = tx.Set(seqPrefix+prefix, strconv.Itoa(id), nil)
+	return id, err
+}
+
+// observeID advances the sequence of prefix to id if it is behind,
+// so that IDs of values that are stored with a given ID, for example
+// by an import, are never handed out again.
+func observeID(tx *buntdb.Tx, prefix string, id int) error {
+	seq, err := sequence(tx, prefix)
+	if err != nil || id <= seq {
+		return err
+	}
+	_, _, err = tx.Set(seqPrefix+prefix, strconv.Itoa(id), nil)
+	return err
+}
+
+// sequence returns the last ID that was handed out for prefix.
+func sequence(tx *buntdb.Tx, prefix string) (int, error) {
+	val, err := tx.Get(seqPrefix + prefix)
+	if err == buntdb.ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.Atoi(val)
+	if err != nil {
+		return 0, stored.WithCause(err, stored.CauseMalformed)
+	}
+	return id, nil
+}
+
+// maxID returns the highest numeric ID of the keys with prefix.
+func maxID(tx *buntdb.Tx, prefix string) (int, error) {
+	var max int
+	err := tx.AscendKeys(prefix+"*", func(key, val string) bool {
+		id, err := strconv.Atoi(strings.TrimPrefix(key, prefix))
+		if err == nil && id > max {
+			max = id
+		}
+		return true
+	})
+	return max, err
+}
@@ -0,0 +1,115 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"testing"
+
+	"github.com/mbertschler/bunny/pkg/data/stored"
+)
+
+// More than 10 values are created, because the IDs used to be
+// derived from the last key and "i/9" is sorted after "i/10".
+const pastTen = 25
+
+func TestSequences(t *testing.T) {
+	d := Open()
+	types := map[string]func(i int) (int, error){
+		"item":     func(i int) (int, error) { return d.NewItem(stored.Item{Title: "item"}) },
+		"list":     func(i int) (int, error) { return d.NewList(stored.List{Title: "list"}) },
+		"area":     func(i int) (int, error) { return d.NewArea(stored.Area{Title: "area"}) },
+		"user":     func(i int) (int, error) { return d.NewUser(stored.User{Name: "user"}) },
+		"token":    func(i int) (int, error) { return d.NewToken(stored.Token{User: 1, Name: "token"}) },
+		"webhook":  func(i int) (int, error) { return d.NewWebhook(stored.Webhook{User: 1, URL: "http://example.com"}) },
+		"delivery": func(i int) (int, error) { return d.NewDelivery(stored.Delivery{Webhook: 1}) },
+	}
+	for name, create := range types {
+		for i := 1; i <= pastTen; i++ {
+			id, err := create(i)
+			if err != nil {
+				t.Fatal(name, err)
+			}
+			if id != i {
+				t.Fatalf("%s %d got ID %d", name, i, id)
+			}
+		}
+	}
+
+	items, err := d.Items()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != pastTen {
+		t.Error("items were overwritten, there are only", len(items))
+	}
+}
+
+func TestSequenceAfterForceSet(t *testing.T) {
+	d := Open()
+	err := d.ForceSetItem(stored.Item{ID: 12, Title: "imported"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := d.NewItem(stored.Item{Title: "new"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 13 {
+		t.Error("new item after imported item 12 got ID", id)
+	}
+	err = d.DeleteItem(13)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err = d.NewItem(stored.Item{Title: "newer"})
+	if err != nil || id != 14 {
+		t.Error("IDs of deleted items shouldn't be reused, got", id, err)
+	}
+}
+
+func TestMigrateSequences(t *testing.T) {
+	d := openFixture(t, "v1-ids.json")
+	from, _, err := d.migrate()
+	if err != nil || from != 1 {
+		t.Fatal("migrated from", from, err)
+	}
+	id, err := d.NewItem(stored.Item{Title: "new"})
+	if err != nil || id != 12 {
+		t.Error("new item got ID", id, err)
+	}
+	id, err = d.NewList(stored.List{Title: "new"})
+	if err != nil || id != 3 {
+		t.Error("new list got ID", id, err)
+	}
+	item, err := d.ItemByID(9)
+	if err != nil || item.Title != "nine" {
+		t.Error("item 9 was overwritten", item, err)
+	}
+}
+
+func TestMigrateEntrySequence(t *testing.T) {
+	d := openFixture(t, "v1-ids.json")
+	setRaw(t, d, map[string]string{
+		schemaKey: "4",
+		"e/9":     `{"ID":9,"User":1,"Item":1,"Start":"2018-12-31T10:00:00Z","End":"2018-12-31T11:00:00Z"}`,
+	})
+	from, _, err := d.migrate()
+	if err != nil || from != 4 {
+		t.Fatal("migrated from", from, err)
+	}
+	id, err := d.NewTimeEntry(stored.TimeEntry{User: 1, Item: 2})
+	if err != nil || id != 10 {
+		t.Error("new time entry got ID", id, err)
+	}
+}
@@ -72,6 +72,9 @@ func deleteFromThingArray(in []stored.ThingID, idx int) []stored.ThingID {
 	return out
 }
 
+// sortArray moves the element at index old to index new. Lists and
+// areas are ordered by ranks now, moveMember has to keep the same
+// semantics.
 func sortArray(in []int, old, new int) ([]int, error) {
 	if old == new {
 		return in, nil
@@ -79,8 +82,8 @@ func sortArray(in []int, old, new int) ([]int, error) {
 	max := len(in)
 	if !(old < max && old >= 0 &&
 		new < max && new >= 0) {
-		return in, errors.New(fmt.Sprintln(
-			"invalid sorting from", old, "to", new, "max", max))
+		return in, stored.WithCause(errors.New(fmt.Sprintln(
+			"invalid sorting from", old, "to", new, "max", max)), stored.CauseInvalid)
 	}
 	out := make([]int, len(in))
 	i, j := 0, 0
@@ -98,30 +101,3 @@ func sortArray(in []int, old, new int) ([]int, error) {
 	}
 	return out, nil
 }
-
-func sortThingArray(in []stored.ThingID, old, new int) ([]stored.ThingID, error) {
-	if old == new {
-		return in, nil
-	}
-	max := len(in)
-	if !(old < max && old >= 0 &&
-		new < max && new >= 0) {
-		return in, errors.New(fmt.Sprintln(
-			"invalid sorting from", old, "to", new, "max", max))
-	}
-	out := make([]stored.ThingID, len(in))
-	i, j := 0, 0
-	for j < max {
-		if j == new {
-			out[j] = in[old]
-			j++
-			continue
-		}
-		if i != old {
-			out[j] = in[i]
-			j++
-		}
-		i++
-	}
-	return out, nil
-}
@@ -0,0 +1,119 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/mbertschler/bunny/pkg/data/stored"
+	"github.com/tidwall/buntdb"
+)
+
+type tokensTx struct {
+	parent *Tx
+	tx     *buntdb.Tx
+}
+
+func (t *tokensTx) Key(id int) string {
+	return tokenPrefix + strconv.Itoa(id)
+}
+
+func (t *tokensTx) ID(key string) int {
+	key = strings.TrimPrefix(key, tokenPrefix)
+	i, err := strconv.Atoi(key)
+	if err != nil {
+		log.Println("KEY ERROR:", err)
+	}
+	return i
+}
+
+func (t *tokensTx) Get(id int) (stored.Token, error) {
+	var tok stored.Token
+	val, err := t.tx.Get(t.Key(id))
+	if err != nil {
+		return tok, storageErr(err)
+	}
+	err = decode(val, &tok)
+	return tok, err
+}
+
+func (t *tokensTx) Set(tok stored.Token) error {
+	val, err := encode(tok)
+	if err != nil {
+		return err
+	}
+	err = observeID(t.tx, tokenPrefix, tok.ID)
+	if err != nil {
+		return err
+	}
+	_, _, err = t.tx.Set(t.Key(tok.ID), val, nil)
+	return err
+}
+
+func (t *tokensTx) New(tok stored.Token) (int, error) {
+	id, err := nextID(t.tx, tokenPrefix)
+	if err != nil {
+		return 0, err
+	}
+	tok.ID = id
+	err = t.Set(tok)
+	return id, err
+}
+
+func (t *tokensTx) Delete(id int) error {
+	_, err := t.tx.Delete(t.Key(id))
+	return storageErr(err)
+}
+
+// ByHash looks up a token by the hash of its secret using the
+// token_hash index.
+func (t *tokensTx) ByHash(hash string) (stored.Token, error) {
+	var tok stored.Token
+	var val string
+	pivot, err := encode(stored.Token{Hash: hash})
+	if err != nil {
+		return tok, err
+	}
+	err = t.tx.AscendEqual(tokenHashIndex, pivot, func(key, v string) bool {
+		val = v
+		return false
+	})
+	if err != nil {
+		return tok, err
+	}
+	if val == "" {
+		return tok, storageErr(buntdb.ErrNotFound)
+	}
+	err = decode(val, &tok)
+	return tok, err
+}
+
+func (t *tokensTx) UserTokens(user int) ([]stored.Token, error) {
+	var out []stored.Token
+	var err error
+	iterErr := t.tx.AscendKeys(tokenPrefix+"*", func(key, val string) bool {
+		var tok stored.Token
+		err = decode(val, &tok)
+		if tok.User == user {
+			out = append(out, tok)
+		}
+		return err == nil
+	})
+	if iterErr != nil {
+		return out, iterErr
+	}
+	return out, err
+}
@@ -14,9 +14,12 @@
 package memory
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/mbertschler/bunny/pkg/data/stored"
 	"github.com/tidwall/buntdb"
@@ -44,7 +47,7 @@ func (t *usersTx) Get(id int) (stored.User, error) {
 	var user stored.User
 	val, err := t.tx.Get(t.Key(id))
 	if err != nil {
-		return user, err
+		return user, storageErr(err)
 	}
 	err = decode(val, &user)
 	return user, err
@@ -55,8 +58,40 @@ func (t *usersTx) Set(user stored.User) error {
 	if err != nil {
 		return err
 	}
-	_, _, err = t.tx.Set(t.Key(user.ID), val, nil)
-	return err
+	err = observeID(t.tx, userPrefix, user.ID)
+	if err != nil {
+		return err
+	}
+	prev, replaced, err := t.tx.Set(t.Key(user.ID), val, nil)
+	if err != nil {
+		return err
+	}
+	return updateMembers(t.tx, previousMembers(prev, replaced, decodedUserMembers), userMembers(user))
+}
+
+func (t *usersTx) New(user stored.User) (int, error) {
+	id, err := nextID(t.tx, userPrefix)
+	if err != nil {
+		return 0, err
+	}
+	user.ID = id
+	err = t.Set(user)
+	return id, err
+}
+
+func (t *usersTx) All() ([]stored.User, error) {
+	var out []stored.User
+	var err error
+	iterErr := t.tx.AscendKeys(userPrefix+"*", func(key, val string) bool {
+		var user stored.User
+		err = decode(val, &user)
+		out = append(out, user)
+		return err == nil
+	})
+	if iterErr != nil {
+		return out, iterErr
+	}
+	return out, err
 }
 
 func (t *usersTx) ItemFocus(user, item int) (int, error) {
@@ -68,6 +103,109 @@ func (t *usersTx) ItemFocus(user, item int) (int, error) {
 	return focus, nil
 }
 
+// FocusMap returns the focus state of all items
+// that the user focuses on, keyed by item ID.
+func (t *usersTx) FocusMap(user int) (map[int]int, error) {
+	u, err := t.Get(user)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[int]int)
+	// like findItemInFocusmap the first state wins if an
+	// item has more than one
+	for _, focus := range []int{3, 2, 1} {
+		for _, id := range u.Focus[focus] {
+			out[id] = focus
+		}
+	}
+	return out, nil
+}
+
+// SetListOrder remembers the order in which the user views the list.
+func (t *usersTx) SetListOrder(user, list, order int) error {
+	u, err := t.Get(user)
+	if err != nil {
+		return err
+	}
+	u.ListOrder = setOrderEntry(u.ListOrder, list, order)
+	return t.Set(u)
+}
+
+// SetAreaOrder is SetListOrder for areas.
+func (t *usersTx) SetAreaOrder(user, area, order int) error {
+	u, err := t.Get(user)
+	if err != nil {
+		return err
+	}
+	u.AreaOrder = setOrderEntry(u.AreaOrder, area, order)
+	return t.Set(u)
+}
+
+// SetPomodoroLengths stores the lengths of pomodoros and breaks
+// in minutes.
+func (t *usersTx) SetPomodoroLengths(user, work, brk int) error {
+	u, err := t.Get(user)
+	if err != nil {
+		return err
+	}
+	u.PomodoroWork, u.PomodoroBreak = work, brk
+	return t.Set(u)
+}
+
+// SetSession starts a pomodoro or break session, nil stops it.
+// A pomodoro has to be on the item that the user focuses on now.
+func (t *usersTx) SetSession(user int, s *stored.Session) error {
+	u, err := t.Get(user)
+	if err != nil {
+		return err
+	}
+	if s != nil && !s.Break && s.Item != nowItem(u) {
+		return stored.WithCause(fmt.Errorf("item %d is not in focus now", s.Item), stored.CauseConflict)
+	}
+	u.Session = s
+	return t.Set(u)
+}
+
+// FinishSession ends the running session if it ended before the
+// given time and returns it. A pomodoro is counted for its item.
+func (t *usersTx) FinishSession(user int, ended time.Time) (stored.Session, error) {
+	u, err := t.Get(user)
+	if err != nil {
+		return stored.Session{}, err
+	}
+	if u.Session == nil {
+		return stored.Session{}, stored.WithCause(errors.New("no pomodoro is running"), stored.CauseInvalid)
+	}
+	s := *u.Session
+	if s.End.After(ended) {
+		return s, stored.WithCause(errors.New("the pomodoro isn't over yet"), stored.CauseInvalid)
+	}
+	if !s.Break {
+		if u.Pomodoros == nil {
+			u.Pomodoros = make(map[int]int)
+		}
+		u.Pomodoros[s.Item]++
+	}
+	u.Session = nil
+	return s, t.Set(u)
+}
+
+// setOrderEntry only keeps orders that aren't OrderManual.
+func setOrderEntry(m map[int]int, id, order int) map[int]int {
+	if order == stored.OrderManual {
+		delete(m, id)
+		if len(m) == 0 {
+			return nil
+		}
+		return m
+	}
+	if m == nil {
+		m = make(map[int]int)
+	}
+	m[id] = order
+	return m
+}
+
 func (t *usersTx) SetFocus(user, item, focus int) error {
 	u, err := t.Get(user)
 	if err != nil {
@@ -76,6 +214,7 @@ func (t *usersTx) SetFocus(user, item, focus int) error {
 	if u.Focus == nil {
 		u.Focus = make(map[int][]int)
 	}
+	before := nowItem(u)
 	oldFocus, index := findItemInFocusmap(u.Focus, item)
 	if oldFocus != 0 {
 		u.Focus[oldFocus] = deleteFromArray(u.Focus[oldFocus], index)
@@ -90,8 +229,35 @@ func (t *usersTx) SetFocus(user, item, focus int) error {
 			return err
 		}
 	}
-	u.Focus[focus] = append(u.Focus[focus], item)
-	return t.Set(u)
+	if focus != stored.FocusNone {
+		u.Focus[focus] = append(u.Focus[focus], item)
+	}
+	after := nowItem(u)
+	if after != before && u.Session != nil && !u.Session.Break {
+		// a pomodoro ends when its item leaves the focus
+		u.Session = nil
+	}
+	err = t.Set(u)
+	if err != nil {
+		return err
+	}
+	// the timer runs while an item is the focus now
+	switch {
+	case after == before:
+		return nil
+	case after == 0:
+		return t.parent.entries.Stop(user)
+	default:
+		return t.parent.entries.Start(user, after)
+	}
+}
+
+// nowItem returns the item that the user focuses on now, or 0.
+func nowItem(u stored.User) int {
+	if len(u.Focus[stored.FocusNow]) == 0 {
+		return 0
+	}
+	return u.Focus[stored.FocusNow][0]
 }
 
 func findItemInFocusmap(m map[int][]int, id int) (focus, index int) {
@@ -105,6 +271,8 @@ func findItemInFocusmap(m map[int][]int, id int) (focus, index int) {
 	return 0, 0
 }
 
+// AllByUser returns the items in the focus of the user. Items
+// that don't exist anymore are skipped.
 func (t *usersTx) AllByUser(user int) ([]stored.Item, error) {
 	var items []stored.Item
 	u, err := t.Get(user)
@@ -115,7 +283,8 @@ func (t *usersTx) AllByUser(user int) ([]stored.Item, error) {
 		for _, focusID := range u.Focus[focus] {
 			i, err := t.parent.items.Get(focusID)
 			if err != nil {
-				log.Println("oh no, inner loop err :(", i)
+				log.Println("skipping missing item in focus:", user, focusID, err)
+				continue
 			}
 			i.Focus = focus
 			items = append(items, i)
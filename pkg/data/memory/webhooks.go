@@ -0,0 +1,145 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/mbertschler/bunny/pkg/data/stored"
+	"github.com/tidwall/buntdb"
+)
+
+type webhooksTx struct {
+	parent *Tx
+	tx     *buntdb.Tx
+}
+
+func (t *webhooksTx) Key(id int) string {
+	return webhookPrefix + strconv.Itoa(id)
+}
+
+func (t *webhooksTx) ID(key string) int {
+	key = strings.TrimPrefix(key, webhookPrefix)
+	i, err := strconv.Atoi(key)
+	if err != nil {
+		log.Println("KEY ERROR:", err)
+	}
+	return i
+}
+
+func (t *webhooksTx) Get(id int) (stored.Webhook, error) {
+	var w stored.Webhook
+	val, err := t.tx.Get(t.Key(id))
+	if err != nil {
+		return w, storageErr(err)
+	}
+	err = decode(val, &w)
+	return w, err
+}
+
+func (t *webhooksTx) Set(w stored.Webhook) error {
+	val, err := encode(w)
+	if err != nil {
+		return err
+	}
+	err = observeID(t.tx, webhookPrefix, w.ID)
+	if err != nil {
+		return err
+	}
+	_, _, err = t.tx.Set(t.Key(w.ID), val, nil)
+	return err
+}
+
+func (t *webhooksTx) New(w stored.Webhook) (int, error) {
+	id, err := nextID(t.tx, webhookPrefix)
+	if err != nil {
+		return 0, err
+	}
+	w.ID = id
+	err = t.Set(w)
+	return id, err
+}
+
+func (t *webhooksTx) Delete(id int) error {
+	_, err := t.tx.Delete(t.Key(id))
+	return storageErr(err)
+}
+
+func (t *webhooksTx) All() ([]stored.Webhook, error) {
+	var out []stored.Webhook
+	var err error
+	iterErr := t.tx.AscendKeys(webhookPrefix+"*", func(key, val string) bool {
+		var w stored.Webhook
+		err = decode(val, &w)
+		out = append(out, w)
+		return err == nil
+	})
+	if iterErr != nil {
+		return out, iterErr
+	}
+	return out, err
+}
+
+type deliveriesTx struct {
+	parent *Tx
+	tx     *buntdb.Tx
+}
+
+func (t *deliveriesTx) Key(id int) string {
+	return deliveryPrefix + strconv.Itoa(id)
+}
+
+func (t *deliveriesTx) ID(key string) int {
+	key = strings.TrimPrefix(key, deliveryPrefix)
+	i, err := strconv.Atoi(key)
+	if err != nil {
+		log.Println("KEY ERROR:", err)
+	}
+	return i
+}
+
+// New adds a delivery to the log. Deliveries expire after deliveryTTL.
+func (t *deliveriesTx) New(d stored.Delivery) (int, error) {
+	id, err := nextID(t.tx, deliveryPrefix)
+	if err != nil {
+		return 0, err
+	}
+	d.ID = id
+	val, err := encode(d)
+	if err != nil {
+		return 0, err
+	}
+	opts := &buntdb.SetOptions{Expires: true, TTL: deliveryTTL}
+	_, _, err = t.tx.Set(t.Key(d.ID), val, opts)
+	return id, err
+}
+
+func (t *deliveriesTx) WebhookDeliveries(webhook int) ([]stored.Delivery, error) {
+	var out []stored.Delivery
+	var err error
+	iterErr := t.tx.AscendKeys(deliveryPrefix+"*", func(key, val string) bool {
+		var d stored.Delivery
+		err = decode(val, &d)
+		if d.Webhook == webhook {
+			out = append(out, d)
+		}
+		return err == nil
+	})
+	if iterErr != nil {
+		return out, iterErr
+	}
+	return out, err
+}
@@ -0,0 +1,128 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mbertschler/bunny/pkg/data/stored"
+)
+
+// ViewOrder is the order in which a list or area is shown.
+type ViewOrder int8
+
+const (
+	OrderManual   ViewOrder = stored.OrderManual // the order of the list or area
+	OrderPriority ViewOrder = stored.OrderPriority
+	OrderDue      ViewOrder = stored.OrderDue
+	OrderUpdated  ViewOrder = stored.OrderUpdated
+)
+
+// UserListOrder returns the order in which the user views the list.
+func (tx Tx) UserListOrder(user, list int) (ViewOrder, error) {
+	u, err := tx.db().UserByID(user)
+	return ViewOrder(u.ListOrder[list]), err
+}
+
+// SetUserListOrder remembers the order in which the user views the list.
+func (tx Tx) SetUserListOrder(user, list int, o ViewOrder) error {
+	if _, ok := viewOrderNames[o]; !ok {
+		return invalidOrder(o)
+	}
+	return tx.db().SetUserListOrder(user, list, int(o))
+}
+
+// UserAreaOrder is UserListOrder for areas.
+func (tx Tx) UserAreaOrder(user, area int) (ViewOrder, error) {
+	u, err := tx.db().UserByID(user)
+	return ViewOrder(u.AreaOrder[area]), err
+}
+
+// SetUserAreaOrder is SetUserListOrder for areas.
+func (tx Tx) SetUserAreaOrder(user, area int, o ViewOrder) error {
+	if _, ok := viewOrderNames[o]; !ok {
+		return invalidOrder(o)
+	}
+	return tx.db().SetUserAreaOrder(user, area, int(o))
+}
+
+func invalidOrder(o ViewOrder) error {
+	return stored.WithCause(fmt.Errorf("unknown order %d", o), stored.CauseInvalid)
+}
+
+// SortItems sorts the items in the order, keeping the
+// manual order for items that are equal.
+func SortItems(items []Item, o ViewOrder) {
+	sort.SliceStable(items, func(i, j int) bool {
+		return thingLess(items[i], items[j], o)
+	})
+}
+
+// SortThings is SortItems for the lists and items of an area.
+// Lists have no priority and no due date.
+func SortThings(things []Thing, o ViewOrder) {
+	sort.SliceStable(things, func(i, j int) bool {
+		return thingLess(things[i], things[j], o)
+	})
+}
+
+// thingLess sorts higher priorities, earlier due dates and later
+// updates first. Things without a due date or update time come last.
+func thingLess(a, b Thing, o ViewOrder) bool {
+	switch o {
+	case OrderPriority:
+		return thingPriority(a) > thingPriority(b)
+	case OrderDue:
+		return timeLess(thingDue(a), thingDue(b), false)
+	case OrderUpdated:
+		return timeLess(thingUpdated(a), thingUpdated(b), true)
+	}
+	return false
+}
+
+func timeLess(a, b time.Time, latest bool) bool {
+	if a.IsZero() || b.IsZero() {
+		return !a.IsZero() && b.IsZero()
+	}
+	if latest {
+		return a.After(b)
+	}
+	return a.Before(b)
+}
+
+func thingPriority(t Thing) Priority {
+	if i, ok := t.(Item); ok {
+		return i.Priority
+	}
+	return PriorityNone
+}
+
+func thingDue(t Thing) time.Time {
+	if i, ok := t.(Item); ok {
+		return i.Due
+	}
+	return time.Time{}
+}
+
+func thingUpdated(t Thing) time.Time {
+	switch t := t.(type) {
+	case Item:
+		return t.Updated
+	case List:
+		return t.Updated
+	}
+	return time.Time{}
+}
@@ -0,0 +1,142 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mbertschler/bunny/pkg/data/memory"
+)
+
+func TestSortThings(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2018, 12, d, 0, 0, 0, 0, time.UTC) }
+	things := []Thing{
+		Item{ID: 1},
+		Item{ID: 2, Priority: PriorityHigh, Due: day(20), Updated: day(1)},
+		List{ID: 3, Updated: day(5)},
+		Item{ID: 4, Priority: PriorityUrgent, Updated: day(3)},
+		Item{ID: 5, Priority: PriorityHigh, Due: day(10)},
+	}
+	ids := func(things []Thing) []int {
+		var out []int
+		for _, t := range things {
+			switch t := t.(type) {
+			case Item:
+				out = append(out, t.ID)
+			case List:
+				out = append(out, t.ID)
+			}
+		}
+		return out
+	}
+	cases := []struct {
+		order ViewOrder
+		want  []int
+	}{
+		{OrderManual, []int{1, 2, 3, 4, 5}},
+		{OrderPriority, []int{4, 2, 5, 1, 3}},
+		{OrderDue, []int{5, 2, 1, 3, 4}},
+		{OrderUpdated, []int{3, 4, 2, 1, 5}},
+	}
+	for _, c := range cases {
+		sorted := append([]Thing(nil), things...)
+		SortThings(sorted, c.order)
+		if got := ids(sorted); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("order %d: got %v, want %v", c.order, got, c.want)
+		}
+	}
+}
+
+func TestUserListOrder(t *testing.T) {
+	resetDB()
+	item, err := ItemByID(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item.Priority = PriorityUrgent
+	err = SetItem(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, err = ItemByID(3)
+	if err != nil || item.Priority != PriorityUrgent || item.Updated.IsZero() {
+		t.Fatal("expected an urgent item with an update time", item, err)
+	}
+
+	err = SetUserListOrder(1, 1, OrderPriority)
+	if err != nil {
+		t.Fatal(err)
+	}
+	order, err := UserListOrder(1, 1)
+	if err != nil || order != OrderPriority {
+		t.Fatal("expected the order to be remembered", order, err)
+	}
+	if o, _ := UserListOrder(2, 1); o != OrderManual {
+		t.Error("other users should see the manual order", o)
+	}
+	if o, _ := UserAreaOrder(1, 1); o != OrderManual {
+		t.Error("the area should keep the manual order", o)
+	}
+	page, err := UserItemListPage(1, 1, PageQuery{Order: order, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 4 || page.Items[0].ID != 3 || page.Next != "" {
+		t.Error("expected all open items on one page with item 3 first", page)
+	}
+	archived, err := UserItemListPage(1, 1, PageQuery{Order: order, Archived: true})
+	if err != nil || len(archived.Items) != 1 || archived.Items[0].ID != 4 {
+		t.Error("expected archived items to be paged as before", archived, err)
+	}
+	if !IsInvalid(SetUserAreaOrder(1, 1, ViewOrder(9))) {
+		t.Error("expected an unknown order to be invalid")
+	}
+	if !IsNotFound(SetUserListOrder(1, 99, OrderDue)) {
+		t.Error("expected a missing list to be not found")
+	}
+
+	var buf bytes.Buffer
+	err = WriteExport(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exported, err := ReadExport(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db = memory.Open()
+	err = ImportWorkspace(exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	order, err = UserListOrder(1, 1)
+	if err != nil || order != OrderPriority {
+		t.Error("the order should survive export and import", order, err)
+	}
+	imported, err := ItemByID(3)
+	if err != nil || imported.Priority != PriorityUrgent || !imported.Updated.Equal(item.Updated) {
+		t.Error("priority and update time should survive export and import", imported, err)
+	}
+	err = SetUserListOrder(1, 1, OrderManual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := db.UserByID(1)
+	if err != nil || u.ListOrder != nil {
+		t.Error("the manual order should not be stored", u.ListOrder, err)
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mbertschler/bunny/pkg/data/stored"
+)
+
+// default and maximum lengths of pomodoros and breaks
+const (
+	DefaultPomodoroWork  = 25 * time.Minute
+	DefaultPomodoroBreak = 5 * time.Minute
+	MaxPomodoroWork      = 120 * time.Minute
+	MaxPomodoroBreak     = 60 * time.Minute
+)
+
+// sessionSlack is how much earlier than its end a session may be
+// finished, the clock of the browser can be a little ahead.
+const sessionSlack = 5 * time.Second
+
+// Pomodoro is the pomodoro state of a user.
+type Pomodoro struct {
+	Work    time.Duration
+	Break   time.Duration
+	Counts  map[int]int      // completed pomodoros by item ID
+	Session *PomodoroSession // nil if no session runs
+}
+
+// PomodoroSession is a running pomodoro on the item, or a break.
+type PomodoroSession struct {
+	Item  int
+	Break bool
+	Start time.Time
+	End   time.Time
+}
+
+// Remaining returns how long the session still runs.
+func (s PomodoroSession) Remaining(now time.Time) time.Duration {
+	if now.After(s.End) {
+		return 0
+	}
+	return s.End.Sub(now)
+}
+
+func restorePomodoro(u stored.User) Pomodoro {
+	p := Pomodoro{
+		Work:   time.Duration(u.PomodoroWork) * time.Minute,
+		Break:  time.Duration(u.PomodoroBreak) * time.Minute,
+		Counts: map[int]int{},
+	}
+	if p.Work == 0 {
+		p.Work = DefaultPomodoroWork
+	}
+	if p.Break == 0 {
+		p.Break = DefaultPomodoroBreak
+	}
+	for id, n := range u.Pomodoros {
+		p.Counts[id] = n
+	}
+	if u.Session != nil {
+		p.Session = &PomodoroSession{
+			Item:  u.Session.Item,
+			Break: u.Session.Break,
+			Start: u.Session.Start,
+			End:   u.Session.End,
+		}
+	}
+	return p
+}
+
+// UserPomodoro returns the pomodoro lengths, counts and the
+// running session of the user.
+func (tx Tx) UserPomodoro(user int) (Pomodoro, error) {
+	u, err := tx.db().UserByID(user)
+	if err != nil {
+		return Pomodoro{}, err
+	}
+	return restorePomodoro(u), nil
+}
+
+// SetPomodoroLengths sets the lengths of pomodoros and breaks of
+// the user in whole minutes. A running session keeps its length.
+func (tx Tx) SetPomodoroLengths(user int, work, brk time.Duration) error {
+	switch {
+	case work < time.Minute || work > MaxPomodoroWork || work%time.Minute != 0:
+		return stored.WithCause(fmt.Errorf("a pomodoro has to be 1 to %d minutes", MaxPomodoroWork/time.Minute), stored.CauseInvalid)
+	case brk < time.Minute || brk > MaxPomodoroBreak || brk%time.Minute != 0:
+		return stored.WithCause(fmt.Errorf("a break has to be 1 to %d minutes", MaxPomodoroBreak/time.Minute), stored.CauseInvalid)
+	}
+	return tx.db().SetUserPomodoroLengths(user, int(work/time.Minute), int(brk/time.Minute))
+}
+
+// StartPomodoro starts a pomodoro on the item that the user
+// focuses on now. It replaces a running session.
+func (tx Tx) StartPomodoro(user int) (PomodoroSession, error) {
+	focus, err := tx.FocusList(user)
+	if err != nil {
+		return PomodoroSession{}, err
+	}
+	if len(focus.Focus) == 0 {
+		return PomodoroSession{}, stored.WithCause(errors.New("a pomodoro needs an item in focus now"), stored.CauseInvalid)
+	}
+	return tx.startSession(user, focus.Focus[0].ID, false)
+}
+
+// StartPomodoroBreak starts a break. It replaces a running session.
+func (tx Tx) StartPomodoroBreak(user int) (PomodoroSession, error) {
+	return tx.startSession(user, 0, true)
+}
+
+func (tx Tx) startSession(user, item int, brk bool) (PomodoroSession, error) {
+	p, err := tx.UserPomodoro(user)
+	if err != nil {
+		return PomodoroSession{}, err
+	}
+	length := p.Work
+	if brk {
+		length = p.Break
+	}
+	start := time.Now().UTC().Truncate(time.Second)
+	s := PomodoroSession{Item: item, Break: brk, Start: start, End: start.Add(length)}
+	err = tx.db().SetUserSession(user, &stored.Session{Item: s.Item, Break: s.Break, Start: s.Start, End: s.End})
+	return s, err
+}
+
+// StopPomodoro cancels the running session without counting it.
+func (tx Tx) StopPomodoro(user int) error {
+	return tx.db().SetUserSession(user, nil)
+}
+
+// FinishPomodoro ends the running session once its time is up and
+// returns it. A finished pomodoro is counted for its item.
+func (tx Tx) FinishPomodoro(user int) (PomodoroSession, error) {
+	s, err := tx.db().FinishUserSession(user, time.Now().Add(sessionSlack))
+	return PomodoroSession{Item: s.Item, Break: s.Break, Start: s.Start, End: s.End}, err
+}
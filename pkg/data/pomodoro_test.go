@@ -0,0 +1,116 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/mbertschler/bunny/pkg/data/memory"
+	"github.com/mbertschler/bunny/pkg/data/stored"
+)
+
+func TestPomodoro(t *testing.T) {
+	resetDB()
+	p, err := UserPomodoro(1)
+	if err != nil || p.Work != DefaultPomodoroWork || p.Break != DefaultPomodoroBreak || p.Session != nil {
+		t.Fatal("expected the default lengths", p, err)
+	}
+	for _, l := range [][2]time.Duration{{0, time.Minute}, {3 * time.Hour, time.Minute}, {90 * time.Second, time.Minute}, {time.Minute, 0}} {
+		if err := SetPomodoroLengths(1, l[0], l[1]); !IsInvalid(err) {
+			t.Error("expected invalid lengths", l, err)
+		}
+	}
+	err = SetPomodoroLengths(1, 50*time.Minute, 10*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the test data focuses on item 1 now
+	s, err := StartPomodoro(1)
+	if err != nil || s.Item != 1 || s.Break || s.End.Sub(s.Start) != 50*time.Minute {
+		t.Fatal("expected a pomodoro on item 1", s, err)
+	}
+	if _, err := FinishPomodoro(1); !IsInvalid(err) {
+		t.Error("a pomodoro can't be finished before its end", err)
+	}
+	past := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	err = db.SetUserSession(1, &stored.Session{Item: 1, Start: past, End: past.Add(50 * time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err = FinishPomodoro(1)
+	if err != nil || s.Item != 1 {
+		t.Fatal("expected the pomodoro to finish", s, err)
+	}
+	p, err = UserPomodoro(1)
+	if err != nil || p.Counts[1] != 1 || p.Session != nil {
+		t.Error("expected one pomodoro on item 1", p, err)
+	}
+	if _, err := FinishPomodoro(1); !IsInvalid(err) {
+		t.Error("nothing should be running", err)
+	}
+
+	_, err = StartPomodoro(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = SetFocus(1, 1, FocusLater)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err = UserPomodoro(1)
+	if err != nil || p.Session != nil {
+		t.Error("the pomodoro should end when its item leaves the focus", p.Session, err)
+	}
+	if _, err := StartPomodoro(1); !IsInvalid(err) {
+		t.Error("a pomodoro needs an item in focus now", err)
+	}
+	s, err = StartPomodoroBreak(1)
+	if err != nil || !s.Break || s.End.Sub(s.Start) != 10*time.Minute {
+		t.Fatal("expected a break", s, err)
+	}
+	err = SetFocus(1, 3, FocusNow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err = UserPomodoro(1)
+	if err != nil || p.Session == nil || !p.Session.Break {
+		t.Error("a break should keep running when the focus changes", p.Session, err)
+	}
+	err = StopPomodoro(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	err = WriteExport(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exported, err := ReadExport(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db = memory.Open()
+	err = ImportWorkspace(exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err = UserPomodoro(1)
+	if err != nil || p.Counts[1] != 1 || p.Work != 50*time.Minute {
+		t.Error("lengths and counts should survive export and import", p, err)
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mbertschler/bunny/pkg/data/stored"
+)
+
+// names of the states as they are used in URLs and JSON
+var (
+	itemStateNames = map[ItemState]string{
+		ItemOpen:     "open",
+		ItemComplete: "complete",
+		ItemArchived: "archived",
+	}
+	focusStateNames = map[FocusState]string{
+		FocusNone:  "none",
+		FocusNow:   "now",
+		FocusLater: "later",
+		FocusWatch: "watch",
+	}
+	thingTypeNames = map[ThingType]string{
+		TypeItem: "item",
+		TypeList: "list",
+	}
+	priorityNames = map[Priority]string{
+		PriorityNone:   "none",
+		PriorityLow:    "low",
+		PriorityMedium: "medium",
+		PriorityHigh:   "high",
+		PriorityUrgent: "urgent",
+	}
+	viewOrderNames = map[ViewOrder]string{
+		OrderManual:   "manual",
+		OrderPriority: "priority",
+		OrderDue:      "due",
+		OrderUpdated:  "updated",
+	}
+	fieldTypeNames = map[FieldType]string{
+		FieldText:   "text",
+		FieldNumber: "number",
+		FieldDate:   "date",
+		FieldSelect: "select",
+		FieldURL:    "url",
+	}
+)
+
+func ParseItemState(name string) (ItemState, error) {
+	for s, n := range itemStateNames {
+		if n == name {
+			return s, nil
+		}
+	}
+	return 0, stored.WithCause(fmt.Errorf("unknown item state %q", name), stored.CauseInvalid)
+}
+
+func (s ItemState) MarshalText() ([]byte, error) {
+	name, ok := itemStateNames[s]
+	if !ok {
+		return nil, fmt.Errorf("unknown item state %d", s)
+	}
+	return []byte(name), nil
+}
+
+func (s *ItemState) UnmarshalText(text []byte) error {
+	var err error
+	*s, err = ParseItemState(string(text))
+	return err
+}
+
+func ParseFocusState(name string) (FocusState, error) {
+	for s, n := range focusStateNames {
+		if n == name {
+			return s, nil
+		}
+	}
+	return 0, stored.WithCause(fmt.Errorf("unknown focus state %q", name), stored.CauseInvalid)
+}
+
+func (s FocusState) MarshalText() ([]byte, error) {
+	name, ok := focusStateNames[s]
+	if !ok {
+		return nil, fmt.Errorf("unknown focus state %d", s)
+	}
+	return []byte(name), nil
+}
+
+func (s *FocusState) UnmarshalText(text []byte) error {
+	var err error
+	*s, err = ParseFocusState(string(text))
+	return err
+}
+
+// DueFormat is the format of due dates in forms and URLs.
+const DueFormat = "2006-01-02"
+
+// ParseDue parses a due date in DueFormat. An empty string
+// means that there is no due date.
+func ParseDue(str string) (time.Time, error) {
+	if str == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(DueFormat, str)
+	if err != nil {
+		return t, stored.WithCause(fmt.Errorf("invalid due date %q", str), stored.CauseInvalid)
+	}
+	return t, nil
+}
+
+// dueDay strips the time of day from t, so that the same day
+// always has the same representation.
+func dueDay(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func ParseThingType(name string) (ThingType, error) {
+	for t, n := range thingTypeNames {
+		if n == name {
+			return t, nil
+		}
+	}
+	return 0, stored.WithCause(fmt.Errorf("unknown thing type %q", name), stored.CauseInvalid)
+}
+
+func (t ThingType) MarshalText() ([]byte, error) {
+	name, ok := thingTypeNames[t]
+	if !ok {
+		return nil, fmt.Errorf("unknown thing type %d", t)
+	}
+	return []byte(name), nil
+}
+
+func (t *ThingType) UnmarshalText(text []byte) error {
+	var err error
+	*t, err = ParseThingType(string(text))
+	return err
+}
+
+func ParseFieldType(name string) (FieldType, error) {
+	for t, n := range fieldTypeNames {
+		if n == name {
+			return t, nil
+		}
+	}
+	return 0, stored.WithCause(fmt.Errorf("unknown field type %q", name), stored.CauseInvalid)
+}
+
+func (t FieldType) MarshalText() ([]byte, error) {
+	name, ok := fieldTypeNames[t]
+	if !ok {
+		return nil, fmt.Errorf("unknown field type %d", t)
+	}
+	return []byte(name), nil
+}
+
+func (t *FieldType) UnmarshalText(text []byte) error {
+	var err error
+	*t, err = ParseFieldType(string(text))
+	return err
+}
+
+func ParsePriority(name string) (Priority, error) {
+	for p, n := range priorityNames {
+		if n == name {
+			return p, nil
+		}
+	}
+	return 0, stored.WithCause(fmt.Errorf("unknown priority %q", name), stored.CauseInvalid)
+}
+
+func (p Priority) MarshalText() ([]byte, error) {
+	name, ok := priorityNames[p]
+	if !ok {
+		return nil, fmt.Errorf("unknown priority %d", p)
+	}
+	return []byte(name), nil
+}
+
+func (p *Priority) UnmarshalText(text []byte) error {
+	var err error
+	*p, err = ParsePriority(string(text))
+	return err
+}
+
+func ParseViewOrder(name string) (ViewOrder, error) {
+	for o, n := range viewOrderNames {
+		if n == name {
+			return o, nil
+		}
+	}
+	return 0, stored.WithCause(fmt.Errorf("unknown order %q", name), stored.CauseInvalid)
+}
+
+func (o ViewOrder) MarshalText() ([]byte, error) {
+	name, ok := viewOrderNames[o]
+	if !ok {
+		return nil, fmt.Errorf("unknown order %d", o)
+	}
+	return []byte(name), nil
+}
+
+func (o *ViewOrder) UnmarshalText(text []byte) error {
+	var err error
+	*o, err = ParseViewOrder(string(text))
+	return err
+}
@@ -13,12 +13,17 @@
 
 package stored
 
+import "time"
+
 type Cause int8
 
 const (
 	CauseNotFound Cause = iota + 1
 	CauseMalformed
 	CauseSerialize
+	CauseConflict
+	CauseInvalid
+	CauseForbidden
 )
 
 type CauseError struct {
@@ -37,6 +42,19 @@ func WithCause(err error, cause Cause) CauseError {
 	}
 }
 
+func HasCause(err error, cause Cause) bool {
+	return CauseOf(err) == cause
+}
+
+// CauseOf returns the cause of err, or 0 if it has none.
+func CauseOf(err error) Cause {
+	e, ok := err.(CauseError)
+	if !ok {
+		return 0
+	}
+	return e.Cause
+}
+
 type MultiError struct {
 	Errors []error
 }
@@ -68,11 +86,35 @@ const (
 	FocusWatch
 )
 
+const (
+	PriorityNone = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+	PriorityUrgent
+)
+
+// orders in which lists and areas can be shown
+const (
+	OrderManual = iota
+	OrderPriority
+	OrderDue
+	OrderUpdated
+)
+
 type Item struct {
-	ID    int
-	State int
-	Title string
-	Body  string
+	ID      int
+	Version int
+	State   int
+	Title   string
+	Body    string
+	Due     time.Time // midnight UTC of the due day, zero if none
+	Updated time.Time // last change of the contents, zero if unknown
+
+	Priority int `json:",omitempty"`
+
+	// values of custom fields by field ID
+	Fields map[int]string `json:",omitempty"`
 
 	// foreign fields
 	Focus int
@@ -81,13 +123,16 @@ type Item struct {
 func (Item) Type() ThingType { return TypeItem }
 
 type List struct {
-	ID    int
-	State int
-	Title string
-	Body  string
+	ID      int
+	Version int
+	State   int
+	Title   string
+	Body    string
+	Updated time.Time // like Item.Updated
 
-	// internal stored fields
-	Items []int
+	// internal stored fields, the order is kept in separate
+	// records since schema version 4
+	Items []int `json:",omitempty"`
 }
 
 func (List) Type() ThingType { return TypeList }
@@ -112,18 +157,105 @@ type Area struct {
 	ID     int
 	Title  string
 	Body   string
-	Things []ThingID
+	Things []ThingID `json:",omitempty"` // kept like List.Items
+	Fields []Field   `json:",omitempty"` // custom fields of the items
 }
 
+// Field is a custom field that the items in an area can have.
+// Field IDs are unique across all areas.
+type Field struct {
+	ID      int
+	Name    string
+	Type    int
+	Options []string `json:",omitempty"` // choices of select fields
+}
+
+const (
+	FieldText = iota + 1
+	FieldNumber
+	FieldDate
+	FieldSelect
+	FieldURL
+)
+
 type User struct {
 	ID   int
 	Name string
 
 	// internal stored fields
 	Focus map[int][]int
+
+	// order in which the user views lists and areas by ID,
+	// missing entries are OrderManual
+	ListOrder map[int]int `json:",omitempty"`
+	AreaOrder map[int]int `json:",omitempty"`
+
+	// pomodoro lengths in minutes, zero for the default
+	PomodoroWork  int `json:",omitempty"`
+	PomodoroBreak int `json:",omitempty"`
+
+	// completed pomodoros by item ID and the running session
+	Pomodoros map[int]int `json:",omitempty"`
+	Session   *Session    `json:",omitempty"`
+}
+
+// Session is a running pomodoro or break. A pomodoro is bound to
+// the item that the user focuses on now.
+type Session struct {
+	Item  int
+	Break bool
+	Start time.Time
+	End   time.Time
 }
 
 type OrderedListItem struct {
 	Position int
 	Item
 }
+
+const (
+	ScopeRead = iota + 1
+	ScopeWrite
+)
+
+type Token struct {
+	ID      int
+	User    int
+	Name    string
+	Hash    string // hex encoded SHA-256 of the secret
+	Scope   int
+	Created time.Time
+	Expires time.Time // zero if the token doesn't expire
+}
+
+type Webhook struct {
+	ID      int
+	User    int
+	URL     string
+	Events  []string
+	Secret  string // key for the HMAC signature of the payload
+	Created time.Time
+}
+
+// TimeEntry is a span of time that a user worked on an item.
+// The entry of the item that the user focuses on now is running
+// and has no End yet.
+type TimeEntry struct {
+	ID    int
+	User  int
+	Item  int
+	Start time.Time
+	End   time.Time
+}
+
+type Delivery struct {
+	ID       int
+	Webhook  int
+	Event    string
+	EventID  string
+	Attempt  int
+	Status   int // HTTP status code, 0 if the request failed
+	Error    string
+	Time     time.Time
+	Duration time.Duration
+}
@@ -0,0 +1,323 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mbertschler/bunny/pkg/data/stored"
+)
+
+// TimeEntry is a span of time that a user worked on an item. The
+// timer starts when an item becomes the focus now and stops when
+// the focus moves away. The running entry has no End yet.
+type TimeEntry struct {
+	ID    int
+	User  int
+	Item  int
+	Start time.Time
+	End   time.Time // zero while the timer runs
+}
+
+func (e TimeEntry) Running() bool {
+	return e.End.IsZero()
+}
+
+// Duration returns how long the entry ran, running entries
+// are counted until now.
+func (e TimeEntry) Duration(now time.Time) time.Duration {
+	return e.overlap(e.Start, now)
+}
+
+// overlap returns how much of the entry lies between from and to.
+func (e TimeEntry) overlap(from, to time.Time) time.Duration {
+	start, end := e.Start, e.End
+	if e.Running() || end.After(to) {
+		end = to
+	}
+	if start.Before(from) {
+		start = from
+	}
+	if !end.After(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+func storedTimeEntry(in TimeEntry) stored.TimeEntry {
+	return stored.TimeEntry{
+		ID:    in.ID,
+		User:  in.User,
+		Item:  in.Item,
+		Start: in.Start,
+		End:   in.End,
+	}
+}
+
+func restoreTimeEntry(in stored.TimeEntry) TimeEntry {
+	return TimeEntry{
+		ID:    in.ID,
+		User:  in.User,
+		Item:  in.Item,
+		Start: in.Start,
+		End:   in.End,
+	}
+}
+
+// UserTimeEntries returns the entries of the user that overlap
+// the time between from and to, sorted by start.
+func (tx Tx) UserTimeEntries(user int, from, to time.Time) ([]TimeEntry, error) {
+	entries, err := tx.db().UserTimeEntries(user)
+	if err != nil {
+		return nil, err
+	}
+	var out []TimeEntry
+	for _, e := range entries {
+		if !e.Start.Before(to) || (!e.End.IsZero() && !e.End.After(from)) {
+			continue
+		}
+		out = append(out, restoreTimeEntry(e))
+	}
+	return out, nil
+}
+
+// TimeEntryByID returns a time entry of the user. Entries of
+// other users are reported as not found.
+func (tx Tx) TimeEntryByID(user, id int) (TimeEntry, error) {
+	e, err := tx.db().TimeEntryByID(id)
+	if err != nil {
+		return TimeEntry{}, err
+	}
+	if e.User != user {
+		return TimeEntry{}, stored.WithCause(errors.New("time entry not found"), stored.CauseNotFound)
+	}
+	return restoreTimeEntry(e), nil
+}
+
+// NewTimeEntry adds time that was tracked without the timer.
+func (tx Tx) NewTimeEntry(user int, in TimeEntry) (TimeEntry, error) {
+	in.ID, in.User = 0, user
+	err := validateTimeEntry(in, false)
+	if err != nil {
+		return in, err
+	}
+	if _, err := tx.db().UserByID(user); err != nil {
+		return in, err
+	}
+	if _, err := tx.db().ItemByID(in.Item); err != nil {
+		return in, err
+	}
+	in.ID, err = tx.db().NewTimeEntry(storedTimeEntry(in))
+	return in, err
+}
+
+// SetTimeEntry corrects the start and end of a time entry of the
+// user. The end of the running entry can't be set, it ends when
+// the item leaves the focus.
+func (tx Tx) SetTimeEntry(user int, in TimeEntry) error {
+	e, err := tx.TimeEntryByID(user, in.ID)
+	if err != nil {
+		return err
+	}
+	if e.Running() != in.Running() {
+		return stored.WithCause(errors.New("the running time entry ends when the item leaves the focus"), stored.CauseInvalid)
+	}
+	e.Start, e.End = in.Start.UTC(), in.End.UTC()
+	err = validateTimeEntry(e, e.Running())
+	if err != nil {
+		return err
+	}
+	return tx.db().SetTimeEntry(storedTimeEntry(e))
+}
+
+// DeleteTimeEntry deletes a time entry of the user. Entries
+// of other users are reported as not found.
+func (tx Tx) DeleteTimeEntry(user, id int) error {
+	return tx.db().DeleteTimeEntry(user, id)
+}
+
+func validateTimeEntry(e TimeEntry, running bool) error {
+	now := time.Now().UTC()
+	switch {
+	case e.Start.IsZero():
+		return stored.WithCause(errors.New("the start of a time entry is required"), stored.CauseInvalid)
+	case e.End.IsZero() && !running:
+		return stored.WithCause(errors.New("the end of a time entry is required"), stored.CauseInvalid)
+	case !e.End.IsZero() && !e.End.After(e.Start):
+		return stored.WithCause(errors.New("a time entry has to end after it starts"), stored.CauseInvalid)
+	case e.Start.After(now) || e.End.After(now):
+		return stored.WithCause(errors.New("a time entry can't be in the future"), stored.CauseInvalid)
+	}
+	return nil
+}
+
+// EntryTimeFormat is the format of the start and end of time
+// entries in forms. The times are in UTC.
+const EntryTimeFormat = "2006-01-02T15:04"
+
+// ParseEntryTime parses a time in EntryTimeFormat. An empty
+// string is the zero time.
+func ParseEntryTime(str string) (time.Time, error) {
+	if str == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(EntryTimeFormat, str)
+	if err != nil {
+		return t, stored.WithCause(fmt.Errorf("invalid time %q", str), stored.CauseInvalid)
+	}
+	return t, nil
+}
+
+// ParseWeek returns the start of the week of a day in DueFormat,
+// or of the current week if str is empty.
+func ParseWeek(str string) (time.Time, error) {
+	if str == "" {
+		return WeekStart(time.Now()), nil
+	}
+	t, err := time.Parse(DueFormat, str)
+	if err != nil {
+		return t, stored.WithCause(fmt.Errorf("invalid week %q", str), stored.CauseInvalid)
+	}
+	return WeekStart(t), nil
+}
+
+// Timesheet sums up the time of a user in one week per area
+// and list.
+type Timesheet struct {
+	Week    time.Time    // Monday midnight UTC
+	Days    [7]time.Time // midnight UTC of each day
+	Rows    []TimesheetRow
+	Total   TimesheetRow // sum of all rows, without area and list
+	Entries []TimeEntry  // entries of the week sorted by start
+	Items   map[int]Item // items of the entries, deleted items are missing
+}
+
+// TimesheetRow is the time of the items in the list, or of the
+// items directly in the area if List has no ID. Items that are
+// in no area have a row without Area ID.
+type TimesheetRow struct {
+	Area  Area
+	List  List
+	Days  [7]time.Duration
+	Total time.Duration
+}
+
+// WeekStart returns Monday midnight UTC of the week of t.
+func WeekStart(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+}
+
+// UserTimesheet returns the timesheet of the user for the week
+// of t. Time of an item that is in several lists or areas counts
+// for the one with the lowest ID.
+func (tx Tx) UserTimesheet(user int, t time.Time) (Timesheet, error) {
+	ts := Timesheet{Week: WeekStart(t), Items: map[int]Item{}}
+	for d := range ts.Days {
+		ts.Days[d] = ts.Week.AddDate(0, 0, d)
+	}
+	end := ts.Week.AddDate(0, 0, 7)
+	var err error
+	ts.Entries, err = tx.UserTimeEntries(user, ts.Week, end)
+	if err != nil {
+		return ts, err
+	}
+	now := time.Now().UTC()
+	rows := map[[2]int]*TimesheetRow{}
+	for _, e := range ts.Entries {
+		area, list, err := tx.timesheetRow(e.Item)
+		if err != nil {
+			return ts, err
+		}
+		row := rows[[2]int{area, list}]
+		if row == nil {
+			row = &TimesheetRow{}
+			if area != 0 {
+				a, err := tx.db().AreaByID(area)
+				if err != nil {
+					return ts, err
+				}
+				row.Area = restoreArea(a)
+			}
+			if list != 0 {
+				l, err := tx.db().ListByID(list)
+				if err != nil {
+					return ts, err
+				}
+				row.List = restoreList(l)
+			}
+			rows[[2]int{area, list}] = row
+		}
+		if i, err := tx.db().ItemByID(e.Item); err == nil {
+			ts.Items[e.Item] = restoreItem(i)
+		} else if !IsNotFound(err) {
+			return ts, err
+		}
+		if e.Running() && now.Before(end) {
+			e.End = now
+		}
+		for d, day := range ts.Days {
+			dur := e.overlap(day, day.AddDate(0, 0, 1))
+			row.Days[d] += dur
+			row.Total += dur
+			ts.Total.Days[d] += dur
+			ts.Total.Total += dur
+		}
+	}
+	for _, row := range rows {
+		ts.Rows = append(ts.Rows, *row)
+	}
+	sort.Slice(ts.Rows, func(i, j int) bool {
+		a, b := ts.Rows[i], ts.Rows[j]
+		if (a.Area.ID == 0) != (b.Area.ID == 0) {
+			return b.Area.ID == 0
+		}
+		if a.Area.ID != b.Area.ID {
+			return a.Area.ID < b.Area.ID
+		}
+		return a.List.ID < b.List.ID
+	})
+	return ts, nil
+}
+
+// timesheetRow returns the area and list that the time of the
+// item counts for.
+func (tx Tx) timesheetRow(item int) (area, list int, err error) {
+	lists, err := tx.db().ItemLists(item)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(lists) > 0 {
+		sort.Ints(lists)
+		areas, err := tx.db().ListAreas(lists[0])
+		if err != nil {
+			return 0, 0, err
+		}
+		return lowest(areas), lists[0], nil
+	}
+	areas, err := tx.db().ItemAreas(item)
+	return lowest(areas), 0, err
+}
+
+func lowest(ids []int) int {
+	if len(ids) == 0 {
+		return 0
+	}
+	sort.Ints(ids)
+	return ids[0]
+}
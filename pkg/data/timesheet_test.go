@@ -0,0 +1,120 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimesheet(t *testing.T) {
+	resetDB()
+	at := func(day, hour int) time.Time {
+		return time.Date(2018, 3, day, hour, 0, 0, 0, time.UTC)
+	}
+	if w := WeekStart(at(11, 23)); !w.Equal(at(5, 0)) {
+		t.Error("the week of Sunday should start on Monday", w)
+	}
+	if w := WeekStart(at(5, 0)); !w.Equal(at(5, 0)) {
+		t.Error("the week of Monday should start the same day", w)
+	}
+	entries := []TimeEntry{
+		{Item: 1, Start: at(5, 9), End: at(5, 11)},   // list 1 in area 1
+		{Item: 7, Start: at(6, 23), End: at(7, 1)},   // directly in area 1, split at midnight
+		{Item: 1, Start: at(4, 23), End: at(5, 1)},   // starts in the week before
+		{Item: 7, Start: at(12, 9), End: at(12, 10)}, // in the next week
+	}
+	for i, e := range entries {
+		var err error
+		entries[i], err = NewTimeEntry(1, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	ts, err := UserTimesheet(1, at(7, 12))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ts.Entries) != 3 || ts.Items[7].ID != 7 {
+		t.Error("expected the 3 entries of the week and their items", ts.Entries, ts.Items)
+	}
+	if len(ts.Rows) != 2 {
+		t.Fatal("expected 2 rows", ts.Rows)
+	}
+	direct, list := ts.Rows[0], ts.Rows[1]
+	if list.Area.ID != 1 || list.List.ID != 1 || list.Days[0] != 3*time.Hour || list.Total != 3*time.Hour {
+		t.Error("unexpected list row", list)
+	}
+	if direct.Area.ID != 1 || direct.List.ID != 0 || direct.Days[1] != time.Hour || direct.Days[2] != time.Hour {
+		t.Error("unexpected area row", direct)
+	}
+	if ts.Total.Total != 5*time.Hour {
+		t.Error("expected 5 hours in total", ts.Total)
+	}
+
+	e := entries[0]
+	e.End = at(5, 10)
+	err = SetTimeEntry(1, e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err = TimeEntryByID(1, e.ID)
+	if err != nil || e.Duration(time.Now()) != time.Hour {
+		t.Error("the correction should be stored", e, err)
+	}
+	bad := []TimeEntry{
+		{ID: e.ID, Start: at(5, 10), End: at(5, 9)},
+		{ID: e.ID, Start: at(5, 10), End: time.Now().Add(time.Hour)},
+		{ID: e.ID, Start: at(5, 10)},
+	}
+	for _, b := range bad {
+		if err := SetTimeEntry(1, b); !IsInvalid(err) {
+			t.Error("expected an invalid entry", b, err)
+		}
+	}
+	if _, err := NewTimeEntry(1, TimeEntry{Item: 1, Start: at(5, 9)}); !IsInvalid(err) {
+		t.Error("new entries need an end", err)
+	}
+	if _, err := NewTimeEntry(1, TimeEntry{Item: 99, Start: at(5, 9), End: at(5, 10)}); !IsNotFound(err) {
+		t.Error("expected a missing item to be not found", err)
+	}
+	if err := DeleteTimeEntry(2, e.ID); !IsNotFound(err) {
+		t.Error("entries of other users should not be found", err)
+	}
+
+	// the test data focuses on item 1 now
+	running, err := UserTimeEntries(1, time.Now().Add(-time.Minute), time.Now().Add(time.Minute))
+	if err != nil || len(running) != 1 || !running[0].Running() || running[0].Item != 1 {
+		t.Fatal("expected the running timer of item 1", running, err)
+	}
+	r := running[0]
+	r.End = time.Now()
+	if err := SetTimeEntry(1, r); !IsInvalid(err) {
+		t.Error("the end of the running entry can't be set", err)
+	}
+	err = SetFocus(1, 1, FocusLater)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := TimeEntryByID(1, r.ID); err == nil {
+		t.Error("a timer that stops in the same second should be dropped")
+	}
+	err = DeleteTimeEntry(1, e.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := TimeEntryByID(1, e.ID); !IsNotFound(err) {
+		t.Error("the entry should be deleted", err)
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mbertschler/bunny/pkg/data/stored"
+)
+
+// tokenPrefix makes API tokens recognizable, for example in logs
+// or by secret scanners.
+const tokenPrefix = "bunny_"
+
+type TokenScope int8
+
+const (
+	ScopeRead  TokenScope = stored.ScopeRead
+	ScopeWrite TokenScope = stored.ScopeWrite
+)
+
+var tokenScopeNames = map[TokenScope]string{
+	ScopeRead:  "read",
+	ScopeWrite: "write",
+}
+
+func ParseTokenScope(name string) (TokenScope, error) {
+	for s, n := range tokenScopeNames {
+		if n == name {
+			return s, nil
+		}
+	}
+	return 0, stored.WithCause(fmt.Errorf("unknown token scope %q", name), stored.CauseInvalid)
+}
+
+func (s TokenScope) String() string {
+	return tokenScopeNames[s]
+}
+
+// Allows reports if a token with this scope may be used for
+// requests that need the scope need. Write implies read.
+func (s TokenScope) Allows(need TokenScope) bool {
+	return s >= need
+}
+
+// Token is an API token of a user. Only the hash of the secret
+// is stored, the secret itself is returned once by NewToken.
+type Token struct {
+	ID      int
+	User    int
+	Name    string
+	Scope   TokenScope
+	Created time.Time
+	Expires time.Time // zero if the token doesn't expire
+}
+
+func (t Token) Expired(now time.Time) bool {
+	return !t.Expires.IsZero() && !now.Before(t.Expires)
+}
+
+func storedToken(in Token, hash string) stored.Token {
+	return stored.Token{
+		ID:      in.ID,
+		User:    in.User,
+		Name:    in.Name,
+		Hash:    hash,
+		Scope:   int(in.Scope),
+		Created: in.Created,
+		Expires: in.Expires,
+	}
+}
+
+func restoreToken(in stored.Token) Token {
+	return Token{
+		ID:      in.ID,
+		User:    in.User,
+		Name:    in.Name,
+		Scope:   TokenScope(in.Scope),
+		Created: in.Created,
+		Expires: in.Expires,
+	}
+}
+
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewToken creates an API token for the user and returns it
+// together with its secret. A zero expires means the token
+// doesn't expire.
+func (tx Tx) NewToken(user int, name string, scope TokenScope, expires time.Time) (Token, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return Token{}, "", stored.WithCause(errors.New("token name is required"), stored.CauseInvalid)
+	}
+	if _, ok := tokenScopeNames[scope]; !ok {
+		return Token{}, "", stored.WithCause(fmt.Errorf("unknown token scope %d", scope), stored.CauseInvalid)
+	}
+	if _, err := tx.db().UserByID(user); err != nil {
+		return Token{}, "", err
+	}
+	buf := make([]byte, 32)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return Token{}, "", err
+	}
+	secret := tokenPrefix + hex.EncodeToString(buf)
+	t := Token{
+		User:    user,
+		Name:    name,
+		Scope:   scope,
+		Created: time.Now().UTC().Truncate(time.Second),
+	}
+	if !expires.IsZero() {
+		t.Expires = expires.UTC()
+	}
+	t.ID, err = tx.db().NewToken(storedToken(t, hashToken(secret)))
+	return t, secret, err
+}
+
+// UserTokens returns all tokens of the user sorted by ID.
+func (tx Tx) UserTokens(user int) ([]Token, error) {
+	tokens, err := tx.db().UserTokens(user)
+	if err != nil {
+		return nil, err
+	}
+	var out []Token
+	for _, t := range tokens {
+		out = append(out, restoreToken(t))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+// RevokeToken deletes a token of the user. Tokens of other
+// users are reported as not found.
+func (tx Tx) RevokeToken(user, id int) error {
+	return tx.db().DeleteToken(user, id)
+}
+
+// Authenticate returns the token that belongs to the secret.
+// Unknown secrets return a not found error, expired tokens
+// a forbidden error.
+func (tx Tx) Authenticate(secret string) (Token, error) {
+	if !strings.HasPrefix(secret, tokenPrefix) {
+		return Token{}, stored.WithCause(errors.New("unknown token"), stored.CauseNotFound)
+	}
+	st, err := tx.db().TokenByHash(hashToken(secret))
+	if stored.HasCause(err, stored.CauseNotFound) {
+		return Token{}, stored.WithCause(errors.New("unknown token"), stored.CauseNotFound)
+	}
+	if err != nil {
+		return Token{}, err
+	}
+	t := restoreToken(st)
+	if t.Expired(time.Now()) {
+		return t, stored.WithCause(errors.New("token expired"), stored.CauseForbidden)
+	}
+	return t, nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokens(t *testing.T) {
+	resetDB()
+	tok, secret, err := NewToken(1, "script", ScopeRead, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.ID != 1 || tok.User != 1 || tok.Scope != ScopeRead {
+		t.Error("unexpected token", tok)
+	}
+	got, err := Authenticate(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != tok {
+		t.Error("authenticated token differs", got, tok)
+	}
+	_, err = Authenticate(secret + "x")
+	if !IsNotFound(err) {
+		t.Error("unknown secret should be not found", err)
+	}
+	_, err = Authenticate("")
+	if !IsNotFound(err) {
+		t.Error("empty secret should be not found", err)
+	}
+
+	list, err := UserTokens(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0] != tok {
+		t.Error("unexpected user tokens", list)
+	}
+
+	err = RevokeToken(2, tok.ID)
+	if !IsNotFound(err) {
+		t.Error("other users shouldn't be able to revoke the token", err)
+	}
+	err = RevokeToken(1, tok.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Authenticate(secret)
+	if !IsNotFound(err) {
+		t.Error("revoked token should be not found", err)
+	}
+}
+
+func TestTokenExpired(t *testing.T) {
+	resetDB()
+	_, secret, err := NewToken(1, "old", ScopeWrite, time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Authenticate(secret)
+	if !IsForbidden(err) {
+		t.Error("expired token should be forbidden", err)
+	}
+}
+
+func TestNewTokenInvalid(t *testing.T) {
+	resetDB()
+	_, _, err := NewToken(1, " ", ScopeRead, time.Time{})
+	if !IsInvalid(err) {
+		t.Error("empty name should be invalid", err)
+	}
+	_, _, err = NewToken(1, "x", TokenScope(7), time.Time{})
+	if !IsInvalid(err) {
+		t.Error("unknown scope should be invalid", err)
+	}
+	_, _, err = NewToken(42, "x", ScopeRead, time.Time{})
+	if !IsNotFound(err) {
+		t.Error("unknown user should be not found", err)
+	}
+}
@@ -0,0 +1,342 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mbertschler/bunny/pkg/data/stored"
+)
+
+// Headers of webhook requests. The signature is the hex encoded
+// HMAC-SHA256 of the request body with the secret of the webhook,
+// prefixed with "sha256=".
+const (
+	WebhookEventHeader     = "X-Bunny-Event"
+	WebhookDeliveryHeader  = "X-Bunny-Delivery"
+	WebhookSignatureHeader = "X-Bunny-Signature"
+)
+
+// Webhook posts events that match one of its Events filters
+// to URL.
+type Webhook struct {
+	ID      int
+	User    int
+	URL     string
+	Events  []string
+	Secret  string
+	Created time.Time
+}
+
+// Delivery is one attempt to deliver an event to a webhook.
+type Delivery struct {
+	ID       int
+	Webhook  int
+	Event    string
+	EventID  string
+	Attempt  int
+	Status   int // HTTP status code, 0 if the request failed
+	Error    string
+	Time     time.Time
+	Duration time.Duration
+}
+
+func (w Webhook) matches(e Event) bool {
+	if e.User != 0 && e.User != w.User {
+		return false
+	}
+	for _, f := range w.Events {
+		if matchEvent(f, e.Type) {
+			return true
+		}
+	}
+	return false
+}
+
+func storedWebhook(in Webhook) stored.Webhook {
+	return stored.Webhook{
+		ID:      in.ID,
+		User:    in.User,
+		URL:     in.URL,
+		Events:  in.Events,
+		Secret:  in.Secret,
+		Created: in.Created,
+	}
+}
+
+func restoreWebhook(in stored.Webhook) Webhook {
+	return Webhook{
+		ID:      in.ID,
+		User:    in.User,
+		URL:     in.URL,
+		Events:  in.Events,
+		Secret:  in.Secret,
+		Created: in.Created,
+	}
+}
+
+func storedDelivery(in Delivery) stored.Delivery {
+	return stored.Delivery{
+		ID:       in.ID,
+		Webhook:  in.Webhook,
+		Event:    in.Event,
+		EventID:  in.EventID,
+		Attempt:  in.Attempt,
+		Status:   in.Status,
+		Error:    in.Error,
+		Time:     in.Time,
+		Duration: in.Duration,
+	}
+}
+
+func restoreDelivery(in stored.Delivery) Delivery {
+	return Delivery{
+		ID:       in.ID,
+		Webhook:  in.Webhook,
+		Event:    in.Event,
+		EventID:  in.EventID,
+		Attempt:  in.Attempt,
+		Status:   in.Status,
+		Error:    in.Error,
+		Time:     in.Time,
+		Duration: in.Duration,
+	}
+}
+
+func invalidWebhook(format string, args ...interface{}) error {
+	return stored.WithCause(fmt.Errorf(format, args...), stored.CauseInvalid)
+}
+
+// NewWebhook subscribes the URL to the events. If in.Secret is
+// empty, a random secret is generated.
+func (tx Tx) NewWebhook(in Webhook) (Webhook, error) {
+	u, err := url.Parse(in.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return in, invalidWebhook("webhook URL %q has to be an absolute http or https URL", in.URL)
+	}
+	if len(in.Events) == 0 {
+		return in, invalidWebhook("webhook needs at least one event")
+	}
+	for _, e := range in.Events {
+		if !validEventFilter(e) {
+			return in, invalidWebhook("unknown event %q", e)
+		}
+	}
+	if _, err := tx.db().UserByID(in.User); err != nil {
+		return in, err
+	}
+	if in.Secret == "" {
+		buf := make([]byte, 32)
+		_, err = rand.Read(buf)
+		if err != nil {
+			return in, err
+		}
+		in.Secret = hex.EncodeToString(buf)
+	}
+	in.Created = time.Now().UTC().Truncate(time.Second)
+	in.ID, err = tx.db().NewWebhook(storedWebhook(in))
+	return in, err
+}
+
+// UserWebhooks returns the webhooks of the user sorted by ID.
+func (tx Tx) UserWebhooks(user int) ([]Webhook, error) {
+	hooks, err := tx.db().Webhooks()
+	if err != nil {
+		return nil, err
+	}
+	var out []Webhook
+	for _, w := range hooks {
+		if w.User == user {
+			out = append(out, restoreWebhook(w))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func (tx Tx) UserWebhookByID(user, id int) (Webhook, error) {
+	w, err := tx.db().WebhookByID(id)
+	if err != nil {
+		return Webhook{}, err
+	}
+	if w.User != user {
+		return Webhook{}, stored.WithCause(errors.New("webhook not found"), stored.CauseNotFound)
+	}
+	return restoreWebhook(w), nil
+}
+
+// DeleteWebhook removes a webhook of the user. Deliveries that are
+// already running are not stopped.
+func (tx Tx) DeleteWebhook(user, id int) error {
+	return tx.db().DeleteWebhook(user, id)
+}
+
+// WebhookDeliveries returns the delivery log of the webhook,
+// newest first. Deliveries are kept for 7 days.
+func (tx Tx) WebhookDeliveries(user, id int) ([]Delivery, error) {
+	_, err := tx.UserWebhookByID(user, id)
+	if err != nil {
+		return nil, err
+	}
+	list, err := tx.db().WebhookDeliveries(id)
+	if err != nil {
+		return nil, err
+	}
+	var out []Delivery
+	for _, d := range list {
+		out = append(out, restoreDelivery(d))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID > out[j].ID })
+	return out, nil
+}
+
+// WaitWebhooks blocks until all running webhook deliveries
+// including their retries are finished.
+func WaitWebhooks() {
+	webhooks.wg.Wait()
+}
+
+// SignWebhook returns the signature of a webhook payload
+// as it is sent in the WebhookSignatureHeader.
+func SignWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+var webhooks = &dispatcher{
+	client:   &http.Client{Timeout: 10 * time.Second},
+	attempts: 5,
+	backoff:  time.Second,
+}
+
+// dispatcher delivers events to webhooks in the background.
+// Failed deliveries are retried with exponential backoff. Events
+// are only dispatched once they are committed, and deliveries run
+// in their own goroutines, so their log is written in their own
+// transactions and never joins the batch of a request.
+type dispatcher struct {
+	client   *http.Client
+	attempts int
+	backoff  time.Duration // wait before the first retry, doubled each time
+	wg       sync.WaitGroup
+}
+
+func (d *dispatcher) dispatch(e Event) {
+	hooks, err := db.Webhooks()
+	if err != nil {
+		log.Println("webhook error:", err)
+		return
+	}
+	var body []byte
+	for _, h := range hooks {
+		w := restoreWebhook(h)
+		if !w.matches(e) {
+			continue
+		}
+		if body == nil {
+			body, err = json.Marshal(e)
+			if err != nil {
+				log.Println("webhook error:", err)
+				return
+			}
+		}
+		d.wg.Add(1)
+		go d.deliver(w, e, body)
+	}
+}
+
+func (d *dispatcher) deliver(w Webhook, e Event, body []byte) {
+	defer d.wg.Done()
+	wait := d.backoff
+	for attempt := 1; attempt <= d.attempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(wait)
+			wait *= 2
+		}
+		del := Delivery{
+			Webhook: w.ID,
+			Event:   e.Type,
+			EventID: e.ID,
+			Attempt: attempt,
+			Time:    time.Now().UTC(),
+		}
+		var err error
+		del.Status, err = d.post(w, e, body)
+		del.Duration = time.Since(del.Time)
+		if err != nil {
+			del.Error = err.Error()
+		}
+		_, err = db.NewDelivery(storedDelivery(del))
+		if err != nil {
+			log.Println("webhook error:", err)
+		}
+		if !retryDelivery(del.Status) {
+			return
+		}
+	}
+	log.Printf("webhook error: giving up delivery of %s event=%s after %d attempts", w.URL, e.ID, d.attempts)
+}
+
+func (d *dispatcher) post(w Webhook, e Event, body []byte) (int, error) {
+	req, err := http.NewRequest("POST", w.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "bunny-webhook")
+	req.Header.Set(WebhookEventHeader, e.Type)
+	req.Header.Set(WebhookDeliveryHeader, e.ID)
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(w.Secret, body))
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// retryDelivery reports if a delivery with the status should be
+// retried. Requests that failed without response, server errors
+// and rate limits are retried, other client errors are not.
+func retryDelivery(status int) bool {
+	switch {
+	case status == 0:
+		return true
+	case status >= 200 && status <= 299:
+		return false
+	case status == http.StatusRequestTimeout, status == http.StatusTooManyRequests:
+		return true
+	}
+	return status >= 500
+}
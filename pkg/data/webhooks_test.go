@@ -0,0 +1,351 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// receiver is a webhook endpoint that records all requests.
+type receiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []*http.Request
+	events   []Event
+	bodies   [][]byte
+	fail     int // number of requests to answer with 500
+}
+
+func newReceiver() *receiver {
+	r := &receiver{}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.fail > 0 {
+			r.fail--
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var e Event
+		json.Unmarshal(body, &e)
+		r.requests = append(r.requests, req)
+		r.events = append(r.events, e)
+		r.bodies = append(r.bodies, body)
+	}))
+	return r
+}
+
+func (r *receiver) eventTypes() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []string
+	for _, e := range r.events {
+		out = append(out, e.Type)
+	}
+	return out
+}
+
+func setupWebhook(t *testing.T, events ...string) (*receiver, Webhook) {
+	resetDB()
+	webhooks.backoff = time.Millisecond
+	r := newReceiver()
+	w, err := NewWebhook(Webhook{User: 1, URL: r.URL, Events: events})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r, w
+}
+
+func TestWebhookItemCompleted(t *testing.T) {
+	r, w := setupWebhook(t, EventItemCompleted)
+	defer r.Close()
+
+	item, err := ItemByID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item.Title = "only the title changed"
+	err = SetItem(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item.Version++
+	item.State = ItemComplete
+	err = SetItem(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	WaitWebhooks()
+
+	if got := r.eventTypes(); len(got) != 1 || got[0] != EventItemCompleted {
+		t.Fatal("expected one item.completed event, got", got)
+	}
+	e := r.events[0]
+	if e.Item == nil || e.Item.ID != 1 || e.Item.State != ItemComplete || e.ID == "" {
+		t.Error("unexpected event", e)
+	}
+	req := r.requests[0]
+	if req.Header.Get(WebhookEventHeader) != EventItemCompleted {
+		t.Error("wrong event header", req.Header)
+	}
+	if req.Header.Get(WebhookDeliveryHeader) != e.ID {
+		t.Error("delivery header should be the event ID", req.Header)
+	}
+	if sig := req.Header.Get(WebhookSignatureHeader); sig != SignWebhook(w.Secret, r.bodies[0]) {
+		t.Error("invalid signature", sig)
+	}
+
+	log, err := WebhookDeliveries(1, w.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log) != 1 || log[0].Status != http.StatusOK || log[0].Attempt != 1 || log[0].EventID != e.ID {
+		t.Error("unexpected delivery log", log)
+	}
+}
+
+func TestWebhookRetry(t *testing.T) {
+	r, w := setupWebhook(t, "item.*")
+	defer r.Close()
+	r.fail = 2
+
+	_, err := NewItem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	WaitWebhooks()
+
+	if got := r.eventTypes(); len(got) != 1 || got[0] != EventItemCreated {
+		t.Fatal("expected one item.created event, got", got)
+	}
+	log, err := WebhookDeliveries(1, w.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log) != 3 {
+		t.Fatal("expected 3 attempts, got", log)
+	}
+	// newest first
+	if log[0].Attempt != 3 || log[0].Status != 200 || log[2].Status != 500 || log[2].Error == "" {
+		t.Error("unexpected delivery log", log)
+	}
+}
+
+func TestWebhookGiveUp(t *testing.T) {
+	r, w := setupWebhook(t, "*")
+	defer r.Close()
+	r.fail = 100
+
+	_, err := NewList(List{Title: "unreachable"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	WaitWebhooks()
+
+	log, err := WebhookDeliveries(1, w.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log) != webhooks.attempts {
+		t.Error("expected", webhooks.attempts, "attempts, got", len(log))
+	}
+}
+
+func TestWebhookBatchRollback(t *testing.T) {
+	r, _ := setupWebhook(t, "*")
+	defer r.Close()
+
+	errRollback := errors.New("rollback")
+	err := Batch(func(tx Tx) error {
+		_, err := tx.NewItem()
+		if err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if err != errRollback {
+		t.Fatal(err)
+	}
+	err = Batch(func(tx Tx) error {
+		_, err := tx.NewItem()
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	WaitWebhooks()
+
+	if got := r.eventTypes(); len(got) != 1 || got[0] != EventItemCreated {
+		t.Error("only the committed batch should send an event, got", got)
+	}
+}
+
+// TestWebhookBatchOtherGoroutine creates an item outside of a
+// running batch. The batch is rolled back, the item and its event
+// are not.
+func TestWebhookBatchOtherGoroutine(t *testing.T) {
+	r, _ := setupWebhook(t, "*")
+	defer r.Close()
+
+	errRollback := errors.New("rollback")
+	var item Item
+	var itemErr error
+	done := make(chan struct{})
+	err := Batch(func(tx Tx) error {
+		go func() {
+			item, itemErr = NewItem()
+			close(done)
+		}()
+		return errRollback
+	})
+	if err != errRollback {
+		t.Fatal(err)
+	}
+	<-done
+	if itemErr != nil {
+		t.Fatal(itemErr)
+	}
+	if _, err := ItemByID(item.ID); err != nil {
+		t.Error("the item should not be rolled back with the batch", err)
+	}
+	WaitWebhooks()
+	if got := r.eventTypes(); len(got) != 1 || got[0] != EventItemCreated {
+		t.Error("the event of the other goroutine should be sent, got", got)
+	}
+}
+
+// TestWebhookConcurrentDeliveries creates items and rolls back
+// batches while deliveries are written, run it with -race.
+func TestWebhookConcurrentDeliveries(t *testing.T) {
+	r, w := setupWebhook(t, EventItemCreated)
+	defer r.Close()
+
+	errRollback := errors.New("rollback")
+	var wg sync.WaitGroup
+	for n := 0; n < 8; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 5; i++ {
+				_, err := NewItem()
+				if err != nil {
+					t.Error(err)
+				}
+				err = Batch(func(tx Tx) error {
+					_, err := tx.NewItem()
+					if err != nil {
+						return err
+					}
+					return errRollback
+				})
+				if err != errRollback {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	WaitWebhooks()
+	if got := len(r.eventTypes()); got != 40 {
+		t.Error("expected 40 events, got", got)
+	}
+	log, err := WebhookDeliveries(1, w.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log) != 40 {
+		t.Error("expected 40 logged deliveries, got", len(log))
+	}
+}
+
+func TestWebhookFocusUser(t *testing.T) {
+	r, _ := setupWebhook(t, EventItemFocusChanged, EventListSorted)
+	defer r.Close()
+
+	err := SetFocus(1, 3, FocusNow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// focus changes of other users are not sent
+	err = forceSetUser(User{ID: 2, Name: "other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = SetFocus(2, 3, FocusNow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = SetListItemPosition(1, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	WaitWebhooks()
+
+	got := r.eventTypes()
+	if len(got) != 2 {
+		t.Fatal("expected 2 events, got", got)
+	}
+	for _, e := range r.events {
+		switch e.Type {
+		case EventItemFocusChanged:
+			if e.User != 1 || e.Item.Focus != FocusNow {
+				t.Error("unexpected focus event", e)
+			}
+		case EventListSorted:
+			if e.List.ID != 1 || e.Item.ID != 3 || e.Position != 2 {
+				t.Error("unexpected sort event", e)
+			}
+		}
+	}
+}
+
+func TestNewWebhookInvalid(t *testing.T) {
+	resetDB()
+	cases := []Webhook{
+		{User: 1, URL: "ftp://example.com", Events: []string{"*"}},
+		{User: 1, URL: "/relative", Events: []string{"*"}},
+		{User: 1, URL: "http://example.com"},
+		{User: 1, URL: "http://example.com", Events: []string{"item.exploded"}},
+		{User: 1, URL: "http://example.com", Events: []string{"user.*"}},
+	}
+	for _, c := range cases {
+		_, err := NewWebhook(c)
+		if !IsInvalid(err) {
+			t.Error(c, "should be invalid, got", err)
+		}
+	}
+	w, err := NewWebhook(Webhook{User: 1, URL: "https://example.com/hook", Events: []string{"list.*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(w.Secret) != 64 {
+		t.Error("a secret should be generated", w.Secret)
+	}
+	err = DeleteWebhook(2, w.ID)
+	if !IsNotFound(err) {
+		t.Error("other users shouldn't be able to delete the webhook", err)
+	}
+	err = DeleteWebhook(1, w.ID)
+	if err != nil {
+		t.Error(err)
+	}
+}
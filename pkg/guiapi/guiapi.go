@@ -15,9 +15,13 @@ package guiapi
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+
+	"github.com/mbertschler/bunny/pkg/data"
+	"github.com/mbertschler/bunny/pkg/data/stored"
 )
 
 // ============================================
@@ -25,9 +29,13 @@ import (
 // ============================================
 
 func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		h.describe(w)
+		return
+	}
 	if r.Method != "POST" {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintln(w, "guiapi request needs to use the POST method")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		fmt.Fprintln(w, "guiapi request needs to use the GET or POST method")
 		return
 	}
 	var req Request
@@ -46,38 +54,114 @@ func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// describe writes the schemas of all actions as JSON.
+func (h Handler) describe(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	err := enc.Encode(Description{Actions: h.Describe()})
+	if err != nil {
+		log.Println("encoding error:", err)
+	}
+}
+
 func (h Handler) Handle(req *Request) *Response {
+	if req.Atomic {
+		return h.handleAtomic(req)
+	}
 	var resp Response
 	for _, action := range req.Actions {
-		var res = Result{
-			ID:   action.ID,
-			Name: action.Name,
-		}
-		fn, ok := h.Functions[action.Name]
-		if !ok {
-			res.Error = &Error{
-				Code:    "undefinedFunction",
-				Message: fmt.Sprint(action.Name, " is not defined"),
-			}
+		resp.Results = append(resp.Results, h.call(data.Tx{}, action))
+	}
+	return &resp
+}
 
-		} else {
-			r, err := fn(action.Args)
-			if err != nil {
-				res.Error = &Error{
-					Code:    "error",
-					Message: err.Error(),
-				}
-			}
-			if r != nil {
-				res.HTML = r.HTML
-				res.JS = r.JS
+var errBatchFailed = errors.New("batch failed")
+
+// handleAtomic runs all actions inside of one storage transaction
+// and stops at the first action that returns an error.
+func (h Handler) handleAtomic(req *Request) *Response {
+	var resp Response
+	if h.Batch == nil {
+		resp.Results = append(resp.Results, Result{
+			Error: &Error{
+				Code:    CodeError,
+				Message: "atomic requests are not supported",
+			},
+		})
+		return &resp
+	}
+	err := h.Batch(func(tx data.Tx) error {
+		for _, action := range req.Actions {
+			res := h.call(tx, action)
+			resp.Results = append(resp.Results, res)
+			if res.Error != nil {
+				return errBatchFailed
 			}
 		}
-		resp.Results = append(resp.Results, res)
+		return nil
+	})
+	if err != nil && err != errBatchFailed {
+		resp.Results = append(resp.Results, Result{
+			Error: &Error{
+				Code:    errorCode(err),
+				Message: err.Error(),
+			},
+		})
+	}
+	committed := err == nil
+	for i := range resp.Results {
+		resp.Results[i].Committed = &committed
 	}
 	return &resp
 }
 
+func (h Handler) call(tx data.Tx, action Action) Result {
+	var res = Result{
+		ID:   action.ID,
+		Name: action.Name,
+	}
+	fn, ok := h.Functions[action.Name]
+	if !ok {
+		res.Error = &Error{
+			Code:    CodeUndefinedFunction,
+			Message: fmt.Sprint(action.Name, " is not defined"),
+		}
+		return res
+	}
+	r, err := fn(tx, action.Args)
+	if err != nil {
+		res.Error = &Error{
+			Code:    errorCode(err),
+			Message: err.Error(),
+		}
+	}
+	if r != nil {
+		res.HTML = r.HTML
+		res.JS = r.JS
+	}
+	return res
+}
+
+// errorCode maps the cause of an error to the Code that is
+// returned to the client.
+func errorCode(err error) string {
+	switch err.(type) {
+	case ArgsError, *json.SyntaxError, *json.UnmarshalTypeError:
+		return CodeInvalidArgs
+	}
+	switch stored.CauseOf(err) {
+	case stored.CauseNotFound:
+		return CodeNotFound
+	case stored.CauseInvalid:
+		return CodeInvalidArgs
+	case stored.CauseConflict:
+		return CodeConflict
+	case stored.CauseForbidden:
+		return CodeForbidden
+	}
+	return CodeError
+}
+
 // ============================================
 // Types
 // ============================================
@@ -85,6 +169,9 @@ func (h Handler) Handle(req *Request) *Response {
 // Request is the sent body of a GUI API call
 type Request struct {
 	Actions []Action
+	// Atomic runs all actions in one storage transaction that is
+	// rolled back if one of the actions fails
+	Atomic bool `json:",omitempty"`
 }
 
 type Action struct {
@@ -96,9 +183,13 @@ type Action struct {
 
 type Handler struct {
 	Functions map[string]Callable
+	Schemas   map[string]ActionSchema // added by Register
+	// Batch runs fn in one storage transaction, needed for atomic requests
+	Batch func(fn func(tx data.Tx) error) error
 }
 
-type Callable func(args json.RawMessage) (*Result, error)
+// Callable runs an action, tx is the batch of an atomic request
+type Callable func(tx data.Tx, args json.RawMessage) (*Result, error)
 
 // Response is the returned body of a GUI API call
 type Response struct {
@@ -111,6 +202,8 @@ type Result struct {
 	Error *Error       `json:",omitempty"`
 	HTML  []HTMLUpdate `json:",omitempty"` // DOM updates to apply
 	JS    []JSCall     `json:",omitempty"` // JS calls to execute
+	// Committed reports if the batch of an atomic request was committed
+	Committed *bool `json:",omitempty"`
 }
 
 type Error struct {
@@ -118,6 +211,16 @@ type Error struct {
 	Message string
 }
 
+// Error codes that are returned to the client
+const (
+	CodeError             = "error"
+	CodeUndefinedFunction = "undefinedFunction"
+	CodeNotFound          = "notFound"
+	CodeInvalidArgs       = "invalidArgs"
+	CodeConflict          = "conflict"
+	CodeForbidden         = "forbidden"
+)
+
 type HTMLOp int8
 
 const (
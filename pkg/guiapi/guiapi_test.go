@@ -0,0 +1,385 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package guiapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mbertschler/bunny/pkg/data"
+)
+
+// completeAndView completes one item and then views another one.
+func completeAndView(complete, view int, atomic bool) *Request {
+	return &Request{
+		Atomic: atomic,
+		Actions: []Action{
+			{
+				Name: "itemState",
+				Args: json.RawMessage(fmt.Sprintf(`{"ID":%d,"State":"complete"}`, complete)),
+			},
+			{
+				Name: "itemView",
+				Args: json.RawMessage(fmt.Sprintf(`{"ID":%d}`, view)),
+			},
+		},
+	}
+}
+
+func TestAtomicRollback(t *testing.T) {
+	h := Handlers()
+	item, err := data.ItemByID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.State != data.ItemOpen {
+		t.Fatal("expected item 1 to be open")
+	}
+
+	resp := h.Handle(completeAndView(1, 9999, true))
+	if len(resp.Results) != 2 {
+		t.Fatal("expected 2 results", resp.Results)
+	}
+	if resp.Results[0].Error != nil {
+		t.Error("first action should succeed", resp.Results[0].Error)
+	}
+	last := resp.Results[1]
+	if last.Error == nil || last.Error.Code != CodeNotFound {
+		t.Error("expected a notFound error", last.Error)
+	}
+	for _, res := range resp.Results {
+		if res.Committed == nil || *res.Committed {
+			t.Error("expected batch not to be committed", res)
+		}
+	}
+	item, err = data.ItemByID(1)
+	if err != nil {
+		t.Error(err)
+	}
+	if item.State != data.ItemOpen {
+		t.Error("expected state change to be rolled back", item.State)
+	}
+
+	resp = h.Handle(completeAndView(1, 1, true))
+	for _, res := range resp.Results {
+		if res.Error != nil {
+			t.Error(res.Error)
+		}
+		if res.Committed == nil || !*res.Committed {
+			t.Error("expected batch to be committed", res)
+		}
+	}
+	item, err = data.ItemByID(1)
+	if err != nil {
+		t.Error(err)
+	}
+	if item.State != data.ItemComplete {
+		t.Error("expected item to be completed", item.State)
+	}
+}
+
+func TestNonAtomic(t *testing.T) {
+	h := Handlers()
+	resp := h.Handle(completeAndView(5, 9999, false))
+	if len(resp.Results) != 2 {
+		t.Fatal("expected 2 results", resp.Results)
+	}
+	for _, res := range resp.Results {
+		if res.Committed != nil {
+			t.Error("non atomic results should not report a commit", res)
+		}
+	}
+	item, err := data.ItemByID(5)
+	if err != nil {
+		t.Error(err)
+	}
+	if item.State != data.ItemComplete {
+		t.Error("expected first action to be applied", item.State)
+	}
+}
+
+func TestItemFields(t *testing.T) {
+	h := Handlers()
+	call := func(action, args string) Result {
+		return h.Handle(&Request{
+			Actions: []Action{{Name: action, Args: json.RawMessage(args)}},
+		}).Results[0]
+	}
+	res := call("fieldAdd", `{"Area":1,"Name":"Stage","Type":"select","Options":"todo, done"}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	area, err := data.AreaByID(1)
+	if err != nil || len(area.Fields) == 0 {
+		t.Fatal("expected a field", area.Fields, err)
+	}
+	field := area.Fields[len(area.Fields)-1]
+	if !reflect.DeepEqual(field.Options, []string{"todo", "done"}) {
+		t.Error("unexpected options", field.Options)
+	}
+
+	item, err := data.ItemByID(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res = call("itemSave", fmt.Sprintf(`{"ID":3,"Version":%d,"Fields":{"%d":"maybe"}}`, item.Version, field.ID))
+	if res.Error == nil || res.Error.Code != CodeInvalidArgs {
+		t.Error("expected an invalid option to be rejected", res.Error)
+	}
+	res = call("itemSave", fmt.Sprintf(`{"ID":3,"Version":%d,"Fields":{"%d":"done"}}`, item.Version, field.ID))
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	item, err = data.ItemByID(3)
+	if err != nil || item.Fields[field.ID] != "done" {
+		t.Error("expected the field to be saved", item.Fields, err)
+	}
+
+	res = call("listView", fmt.Sprintf(`{"Filter":%d,"Value":"done"}`, field.ID))
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if len(res.HTML) != 1 || !strings.Contains(res.HTML[0].Content, `data-item-id="3"`) ||
+		strings.Contains(res.HTML[0].Content, `data-item-id="1"`) {
+		t.Error("expected only item 3 in the filtered list", res.HTML)
+	}
+
+	res = call("fieldDelete", fmt.Sprintf(`{"Area":1,"ID":%d}`, field.ID))
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+}
+
+func TestPriorityAndOrder(t *testing.T) {
+	h := Handlers()
+	call := func(action, args string) Result {
+		return h.Handle(&Request{
+			Actions: []Action{{Name: action, Args: json.RawMessage(args)}},
+		}).Results[0]
+	}
+	res := call("itemPriority", `{"ID":5,"Priority":"urgent"}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	item, err := data.ItemByID(5)
+	if err != nil || item.Priority != data.PriorityUrgent {
+		t.Error("expected item 5 to be urgent", item.Priority, err)
+	}
+	res = call("itemPriority", `{"ID":5,"Priority":"later"}`)
+	if res.Error == nil || res.Error.Code != CodeInvalidArgs {
+		t.Error("expected an unknown priority to be rejected", res.Error)
+	}
+
+	res = call("listOrder", `{"ID":1,"Order":"priority"}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	order, err := data.UserListOrder(1, 1)
+	if err != nil || order != data.OrderPriority {
+		t.Error("expected the order to be saved", order, err)
+	}
+	for _, js := range res.JS {
+		if js.Name == "enableSorting" {
+			t.Error("sorted lists should not be draggable")
+		}
+	}
+	res = call("listOrder", `{"ID":1,"Order":"manual"}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	res = call("areaOrder", `{"ID":1,"Order":"due"}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	res = call("areaOrder", `{"ID":1,"Order":"manual"}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+}
+
+func TestTimesheet(t *testing.T) {
+	h := Handlers()
+	call := func(action, args string) Result {
+		return h.Handle(&Request{
+			Actions: []Action{{Name: action, Args: json.RawMessage(args)}},
+		}).Results[0]
+	}
+	res := call("entryAdd", `{"Item":1,"Start":"2018-03-05T09:00","End":"2018-03-05T10:30"}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	week := time.Date(2018, 3, 5, 0, 0, 0, 0, time.UTC)
+	entries, err := data.UserTimeEntries(1, week, week.AddDate(0, 0, 7))
+	if err != nil || len(entries) != 1 {
+		t.Fatal("expected the new entry", entries, err)
+	}
+	id := entries[0].ID
+	res = call("timesheetView", `{"Week":"2018-03-07"}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if !strings.Contains(res.HTML[0].Content, "1:30") || !strings.Contains(string(res.JS[0].Arguments), "/timesheet/?week=2018-03-05") {
+		t.Error("expected the timesheet of the week", res.HTML[0].Content, res.JS)
+	}
+	res = call("entryEdit", fmt.Sprintf(`{"ID":%d}`, id))
+	if res.Error != nil || !strings.Contains(res.HTML[0].Content, fmt.Sprintf("entrySave(%d)", id)) {
+		t.Error("expected a form for the entry", res.Error)
+	}
+	res = call("entrySave", fmt.Sprintf(`{"ID":%d,"Start":"2018-03-05T09:00","End":"2018-03-05T10:00"}`, id))
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	e, err := data.TimeEntryByID(1, id)
+	if err != nil || e.Duration(time.Now()) != time.Hour {
+		t.Error("expected the corrected entry", e, err)
+	}
+	for _, args := range []string{
+		fmt.Sprintf(`{"ID":%d,"Start":"yesterday","End":"2018-03-05T10:00"}`, id),
+		fmt.Sprintf(`{"ID":%d,"Start":"2018-03-05T09:00","End":"2018-03-05T08:00"}`, id),
+	} {
+		res = call("entrySave", args)
+		if res.Error == nil || res.Error.Code != CodeInvalidArgs {
+			t.Error("expected invalid args for", args, res.Error)
+		}
+	}
+	res = call("timesheetView", `{"Week":"March"}`)
+	if res.Error == nil || res.Error.Code != CodeInvalidArgs {
+		t.Error("expected an invalid week to be rejected", res.Error)
+	}
+	res = call("entryDelete", fmt.Sprintf(`{"ID":%d}`, id))
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if _, err := data.TimeEntryByID(1, id); !data.IsNotFound(err) {
+		t.Error("expected the entry to be deleted", err)
+	}
+}
+
+func TestPomodoro(t *testing.T) {
+	h := Handlers()
+	call := func(action, args string) Result {
+		return h.Handle(&Request{
+			Actions: []Action{{Name: action, Args: json.RawMessage(args)}},
+		}).Results[0]
+	}
+	res := call("pomodoroLengths", `{"Work":0,"Break":5}`)
+	if res.Error == nil || res.Error.Code != CodeInvalidArgs {
+		t.Error("expected invalid lengths to be rejected", res.Error)
+	}
+	res = call("pomodoroLengths", `{"Work":30,"Break":5}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+
+	// the test data focuses on item 1 now
+	res = call("pomodoroStart", `null`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if !strings.Contains(res.HTML[0].Content, "data-end=") || !strings.Contains(res.HTML[0].Content, "pomodoroStop()") {
+		t.Error("expected a running pomodoro", res.HTML[0].Content)
+	}
+	p, err := data.UserPomodoro(1)
+	if err != nil || p.Session == nil || p.Session.End.Sub(p.Session.Start) != 30*time.Minute {
+		t.Error("expected a pomodoro of 30 minutes", p.Session, err)
+	}
+	var timer bool
+	for _, js := range res.JS {
+		timer = timer || js.Name == "startPomodoroTimer"
+	}
+	if !timer {
+		t.Error("expected the timer to be started", res.JS)
+	}
+	res = call("pomodoroDone", `null`)
+	if res.Error == nil || res.Error.Code != CodeInvalidArgs {
+		t.Error("a pomodoro can't be done before its end", res.Error)
+	}
+
+	res = call("pomodoroNext", `{"ID":6,"Next":"switch"}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	p, err = data.UserPomodoro(1)
+	if err != nil || p.Session != nil {
+		t.Error("switching the focus should end the pomodoro", p.Session, err)
+	}
+	res = call("pomodoroNext", `{"ID":6,"Next":"complete"}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	item, err := data.UserItemByID(1, 6)
+	if err != nil || item.State != data.ItemComplete || item.Focus != data.FocusNone {
+		t.Error("expected item 6 to be complete and out of focus", item, err)
+	}
+
+	res = call("pomodoroBreak", `null`)
+	if res.Error != nil || !strings.Contains(res.HTML[0].Content, "Break") {
+		t.Error("expected a break", res.Error)
+	}
+	res = call("pomodoroStop", `null`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	p, err = data.UserPomodoro(1)
+	if err != nil || p.Session != nil {
+		t.Error("the break should be stopped", p.Session, err)
+	}
+}
+
+func moreRequest(args string) *Request {
+	return &Request{
+		Actions: []Action{{Name: "listMore", Args: json.RawMessage(args)}},
+	}
+}
+
+func TestListMore(t *testing.T) {
+	h := Handlers()
+	for i := 0; i < data.PageSize; i++ {
+		_, err := data.NewListItem(1, data.Item{Title: "more"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	page, err := data.UserItemListPage(1, 1, data.PageQuery{})
+	if err != nil || page.Next == "" {
+		t.Fatal("expected a second page", page.Next, err)
+	}
+
+	res := h.Handle(moreRequest(fmt.Sprintf(`{"ID":1,"Cursor":%q}`, page.Next))).Results[0]
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if len(res.HTML) != 2 ||
+		res.HTML[0].Operation != HTMLAppend || res.HTML[0].Selector != "#item-list" ||
+		res.HTML[1].Operation != HTMLReplace || res.HTML[1].Selector != "#item-more" {
+		t.Error("unexpected updates", res.HTML)
+	}
+
+	res = h.Handle(moreRequest(`{"ID":1,"Archived":true}`)).Results[0]
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if len(res.HTML) != 3 || res.HTML[0].Selector != "#archive-list" || res.HTML[2].Selector != "#archive-head" {
+		t.Error("unexpected archived updates", res.HTML)
+	}
+
+	res = h.Handle(moreRequest(`{"ID":1,"Cursor":"i9999"}`)).Results[0]
+	if res.Error == nil || res.Error.Code != CodeInvalidArgs {
+		t.Error("expected a stale cursor to be invalid", res.Error)
+	}
+}
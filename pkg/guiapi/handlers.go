@@ -11,45 +11,76 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+//go:generate go run ../../cmd/jsclient/main.go -o ../../js/src/guiapi.js
+
 package guiapi
 
 import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
+	"time"
 
 	"github.com/mbertschler/blocks/html"
 
 	"github.com/mbertschler/bunny/pkg/blocks"
 	"github.com/mbertschler/bunny/pkg/data"
+	"github.com/mbertschler/bunny/pkg/importer"
 )
 
 func Handlers() Handler {
-	handler := Handler{
-		Functions: map[string]Callable{
-			"areaView":   areaViewHandler,
-			"listView":   listViewHandler,
-			"listSort":   listSortHandler,
-			"itemNew":    itemNewHandler,
-			"itemView":   itemViewHandler,
-			"itemEdit":   itemEditHandler,
-			"itemSave":   itemSaveHandler,
-			"itemState":  itemStateHandler,
-			"itemFocus":  itemFocusHandler,
-			"itemDelete": itemDeleteHandler,
-			"focusView":  focusViewHandler,
-			"focusSort":  focusSortHandler,
-		},
-	}
-	return handler
+	h := NewHandler()
+	h.Batch = data.Batch
+	h.Register("areaView", areaViewHandler)
+	h.Register("listView", listViewHandler)
+	h.Register("listSort", listSortHandler)
+	h.Register("listOrder", listOrderHandler)
+	h.Register("areaOrder", areaOrderHandler)
+	h.Register("listMore", listMoreHandler)
+	h.Register("areaMore", areaMoreHandler)
+	h.Register("areaFields", areaFieldsHandler)
+	h.Register("fieldAdd", fieldAddHandler)
+	h.Register("fieldDelete", fieldDeleteHandler)
+	h.Register("itemNew", itemNewHandler)
+	h.Register("itemView", itemViewHandler)
+	h.Register("itemEdit", itemEditHandler)
+	h.Register("itemSave", itemSaveHandler)
+	h.Register("itemState", itemStateHandler)
+	h.Register("itemFocus", itemFocusHandler)
+	h.Register("itemPriority", itemPriorityHandler)
+	h.Register("itemDelete", itemDeleteHandler)
+	h.Register("focusView", focusViewHandler)
+	h.Register("focusSort", focusSortHandler)
+	h.Register("pomodoroStart", pomodoroStartHandler)
+	h.Register("pomodoroBreak", pomodoroBreakHandler)
+	h.Register("pomodoroStop", pomodoroStopHandler)
+	h.Register("pomodoroDone", pomodoroDoneHandler)
+	h.Register("pomodoroNext", pomodoroNextHandler)
+	h.Register("pomodoroLengths", pomodoroLengthsHandler)
+	h.Register("timesheetView", timesheetViewHandler)
+	h.Register("entryEdit", entryEditHandler)
+	h.Register("entrySave", entrySaveHandler)
+	h.Register("entryAdd", entryAddHandler)
+	h.Register("entryDelete", entryDeleteHandler)
+	h.Register("settingsView", settingsViewHandler)
+	h.Register("tokenCreate", tokenCreateHandler)
+	h.Register("tokenRevoke", tokenRevokeHandler)
+	h.Register("importPreview", importPreviewHandler)
+	h.Register("importApply", importApplyHandler)
+	return h
 }
 
-func areaViewHandler(_ json.RawMessage) (*Result, error) {
-	_, things, err := data.UserArea(1, 1)
+func areaViewHandler(tx data.Tx) (*Result, error) {
+	order, err := tx.UserAreaOrder(1, 1)
+	if err != nil {
+		return nil, err
+	}
+	_, page, err := tx.UserAreaPage(1, 1, data.PageQuery{Order: order})
 	if err != nil {
 		return nil, err
 	}
-	res, err := replaceContainer(blocks.ViewAreaPage(things))
+	res, err := replaceContainer(blocks.ViewAreaPage(1, order, page))
 	if res != nil {
 		args, err := json.Marshal([]interface{}{nil, "Bunny List", "/"})
 		if err != nil {
@@ -60,19 +91,73 @@ func areaViewHandler(_ json.RawMessage) (*Result, error) {
 			Name:      "setURL",
 			Arguments: args,
 		})
-		res.JS = append(res.JS, JSCall{
-			Name: "enableSorting",
-		})
+		if order == data.OrderManual {
+			res.JS = append(res.JS, JSCall{
+				Name: "enableSorting",
+			})
+		}
 	}
 	return res, err
 }
 
-func listViewHandler(_ json.RawMessage) (*Result, error) {
-	list, err := data.UserItemList(1, 1)
+// orderArgs choose the order in which the user views a list or area.
+type orderArgs struct {
+	ID    int    `guiapi:"required,min=1"`
+	Order string `guiapi:"required,enum=manual|priority|due|updated"`
+}
+
+func listOrderHandler(tx data.Tx, args *orderArgs) (*Result, error) {
+	order, err := data.ParseViewOrder(args.Order)
 	if err != nil {
 		return nil, err
 	}
-	res, err := replaceContainer(blocks.ViewListPage(list))
+	err = tx.SetUserListOrder(1, args.ID, order)
+	if err != nil {
+		return nil, err
+	}
+	return listViewHandler(tx, &listViewArgs{ID: args.ID})
+}
+
+func areaOrderHandler(tx data.Tx, args *orderArgs) (*Result, error) {
+	order, err := data.ParseViewOrder(args.Order)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.SetUserAreaOrder(1, args.ID, order)
+	if err != nil {
+		return nil, err
+	}
+	return areaViewHandler(tx)
+}
+
+// listViewArgs sort and filter the list by custom fields,
+// without them the list is shown in its own order.
+type listViewArgs struct {
+	ID     int `guiapi:"min=0"` // defaults to list 1
+	Sort   int `guiapi:"min=0"` // field ID
+	Desc   bool
+	Filter int    `guiapi:"min=0"` // field ID
+	Value  string `guiapi:"max=1000"`
+}
+
+func listViewHandler(tx data.Tx, args *listViewArgs) (*Result, error) {
+	if args.ID == 0 {
+		args.ID = 1
+	}
+	view := data.ListView{Sort: args.Sort, Desc: args.Desc, Filter: args.Filter, Value: args.Value}
+	if view.Active() {
+		var err error
+		view.Order, err = tx.UserListOrder(1, args.ID)
+		if err != nil {
+			return nil, err
+		}
+		_, fields, items, err := tx.UserListView(1, args.ID, view)
+		if err != nil {
+			return nil, err
+		}
+		return replaceContainer(blocks.ViewListPage(args.ID, fields, view, data.ItemPage{Items: items}))
+	}
+	res, err := listPage(tx, args.ID)
 	if res != nil {
 		args, err := json.Marshal([]interface{}{nil, "Bunny List", "/"})
 		if err != nil {
@@ -83,6 +168,27 @@ func listViewHandler(_ json.RawMessage) (*Result, error) {
 			Name:      "setURL",
 			Arguments: args,
 		})
+	}
+	return res, err
+}
+
+// listPage shows the first page of the list in the order of the
+// user. Items can be dragged if the order is the manual one.
+func listPage(tx data.Tx, list int) (*Result, error) {
+	order, err := tx.UserListOrder(1, list)
+	if err != nil {
+		return nil, err
+	}
+	page, err := tx.UserItemListPage(1, list, data.PageQuery{Order: order})
+	if err != nil {
+		return nil, err
+	}
+	fields, err := tx.ListFields(list)
+	if err != nil {
+		return nil, err
+	}
+	res, err := replaceContainer(blocks.ViewListPage(list, fields, data.ListView{Order: order}, page))
+	if res != nil && order == data.OrderManual {
 		res.JS = append(res.JS, JSCall{
 			Name: "enableSorting",
 		})
@@ -90,12 +196,118 @@ func listViewHandler(_ json.RawMessage) (*Result, error) {
 	return res, err
 }
 
-func focusViewHandler(_ json.RawMessage) (*Result, error) {
-	focus, err := data.FocusList(1)
+type moreArgs struct {
+	ID       int    `guiapi:"required,min=1"`
+	Cursor   string `guiapi:"max=32"` // Next of the previous page
+	Archived bool
+}
+
+func (a *moreArgs) query() data.PageQuery {
+	return data.PageQuery{Cursor: a.Cursor, Archived: a.Archived}
+}
+
+func listMoreHandler(tx data.Tx, args *moreArgs) (*Result, error) {
+	page, err := tx.UserItemListPage(1, args.ID, args.query())
+	if err != nil {
+		return nil, err
+	}
+	return appendPage("listMore", args, blocks.ItemsBlock(page.Items), len(page.Items) == 0, page.Next)
+}
+
+func areaMoreHandler(tx data.Tx, args *moreArgs) (*Result, error) {
+	_, page, err := tx.UserAreaPage(1, args.ID, args.query())
+	if err != nil {
+		return nil, err
+	}
+	return appendPage("areaMore", args, blocks.ThingsBlock(page.Things), len(page.Things) == 0, page.Next)
+}
+
+type areaFieldsArgs struct {
+	ID int `guiapi:"required,min=1"`
+}
+
+func areaFieldsHandler(tx data.Tx, args *areaFieldsArgs) (*Result, error) {
+	area, err := tx.AreaByID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	return replaceContainer(blocks.ViewAreaFieldsPage(area))
+}
+
+type fieldAddArgs struct {
+	Area    int    `guiapi:"required,min=1"`
+	Name    string `guiapi:"required,max=100"`
+	Type    string `guiapi:"required,enum=text|number|date|select|url"`
+	Options string `guiapi:"max=1000"` // comma separated choices of select fields
+}
+
+func fieldAddHandler(tx data.Tx, args *fieldAddArgs) (*Result, error) {
+	typ, err := data.ParseFieldType(args.Type)
+	if err != nil {
+		return nil, err
+	}
+	f := data.Field{Name: strings.TrimSpace(args.Name), Type: typ}
+	for _, o := range strings.Split(args.Options, ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			f.Options = append(f.Options, o)
+		}
+	}
+	_, err = tx.AddAreaField(args.Area, f)
+	if err != nil {
+		return nil, err
+	}
+	return areaFieldsHandler(tx, &areaFieldsArgs{ID: args.Area})
+}
+
+type fieldDeleteArgs struct {
+	Area int `guiapi:"required,min=1"`
+	ID   int `guiapi:"required,min=1"`
+}
+
+func fieldDeleteHandler(tx data.Tx, args *fieldDeleteArgs) (*Result, error) {
+	err := tx.DeleteAreaField(args.Area, args.ID)
+	if err != nil {
+		return nil, err
+	}
+	return areaFieldsHandler(tx, &areaFieldsArgs{ID: args.Area})
+}
+
+// appendPage appends a page to #item-list or #archive-list and
+// replaces the load more button. The first archived page also
+// replaces the "Show archived" button with a header.
+func appendPage(action string, args *moreArgs, content html.Block, empty bool, next string) (*Result, error) {
+	list, more := "#item-list", "#item-more"
+	if args.Archived {
+		list, more = "#archive-list", "#archive-more"
+	}
+	res := &Result{}
+	err := res.addHTML(HTMLAppend, list, content)
+	if err != nil {
+		return nil, err
+	}
+	err = res.addHTML(HTMLReplace, more, blocks.MoreButton(action, args.ID, next, args.Archived))
+	if err != nil {
+		return nil, err
+	}
+	if args.Archived && args.Cursor == "" {
+		err = res.addHTML(HTMLReplace, "#archive-head", blocks.ArchivedHeader(empty))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func focusViewHandler(tx data.Tx) (*Result, error) {
+	focus, err := tx.FocusList(1)
 	if err != nil {
-		log.Println(err)
+		return nil, err
 	}
-	res, err := replaceContainer(blocks.ViewFocusPage(focus))
+	pomodoro, err := tx.UserPomodoro(1)
+	if err != nil {
+		return nil, err
+	}
+	res, err := replaceContainer(blocks.ViewFocusPage(focus, pomodoro))
 	if res != nil {
 		args, err := json.Marshal([]interface{}{nil, "Bunny Focus", "/focus/"})
 		if err != nil {
@@ -109,33 +321,156 @@ func focusViewHandler(_ json.RawMessage) (*Result, error) {
 		res.JS = append(res.JS, JSCall{
 			Name: "enableSorting",
 		})
+		res.JS = append(res.JS, JSCall{
+			Name: "startPomodoroTimer",
+		})
+	}
+	return res, err
+}
+
+func pomodoroStartHandler(tx data.Tx) (*Result, error) {
+	_, err := tx.StartPomodoro(1)
+	if err != nil {
+		return nil, err
+	}
+	return focusViewHandler(tx)
+}
+
+func pomodoroBreakHandler(tx data.Tx) (*Result, error) {
+	_, err := tx.StartPomodoroBreak(1)
+	if err != nil {
+		return nil, err
+	}
+	return focusViewHandler(tx)
+}
+
+func pomodoroStopHandler(tx data.Tx) (*Result, error) {
+	err := tx.StopPomodoro(1)
+	if err != nil {
+		return nil, err
+	}
+	return focusViewHandler(tx)
+}
+
+// pomodoroPrompt are the arguments of the pomodoroPrompt JS call,
+// which asks what to do next when a session is over. Next is the
+// first item that the user focuses on later, 0 if there is none.
+type pomodoroPrompt struct {
+	Break     bool
+	Item      int
+	Title     string
+	Count     int
+	Next      int
+	NextTitle string
+}
+
+// pomodoroDoneHandler is called by the timer in the browser when
+// the session is over.
+func pomodoroDoneHandler(tx data.Tx) (*Result, error) {
+	s, err := tx.FinishPomodoro(1)
+	if err != nil {
+		return nil, err
+	}
+	prompt := pomodoroPrompt{Break: s.Break, Item: s.Item}
+	if !s.Break {
+		item, err := tx.ItemByID(s.Item)
+		if err != nil {
+			return nil, err
+		}
+		prompt.Title = item.Title
+		p, err := tx.UserPomodoro(1)
+		if err != nil {
+			return nil, err
+		}
+		prompt.Count = p.Counts[s.Item]
+		focus, err := tx.FocusList(1)
+		if err != nil {
+			return nil, err
+		}
+		if len(focus.Later) > 0 {
+			prompt.Next, prompt.NextTitle = focus.Later[0].ID, focus.Later[0].Title
+		}
+	}
+	res, err := focusViewHandler(tx)
+	if res != nil {
+		args, err := json.Marshal(prompt)
+		if err != nil {
+			log.Println(err)
+		}
+		res.JS = append(res.JS, JSCall{
+			Name:      "pomodoroPrompt",
+			Arguments: args,
+		})
 	}
 	return res, err
 }
 
-func listSortHandler(in json.RawMessage) (*Result, error) {
-	var args = struct {
-		Item int
-		Pos  int
-	}{}
-	err := json.Unmarshal(in, &args)
+// pomodoroNextArgs answer the prompt after a pomodoro, either by
+// completing its item or by switching the focus now to another item.
+type pomodoroNextArgs struct {
+	ID   int    `guiapi:"required,min=1"`
+	Next string `guiapi:"required,enum=complete|switch"`
+}
+
+func pomodoroNextHandler(tx data.Tx, args *pomodoroNextArgs) (*Result, error) {
+	var err error
+	switch args.Next {
+	case "complete":
+		var d data.Item
+		d, err = tx.UserItemByID(1, args.ID)
+		if err != nil {
+			return nil, err
+		}
+		d.State = data.ItemComplete
+		err = tx.SetItem(d)
+		if err != nil {
+			return nil, err
+		}
+		err = tx.SetFocus(1, args.ID, data.FocusNone)
+	case "switch":
+		err = tx.SetFocus(1, args.ID, data.FocusNow)
+	}
 	if err != nil {
 		return nil, err
 	}
-	data.SetListItemPosition(1, args.Item, args.Pos)
-	return listViewHandler(nil)
+	return focusViewHandler(tx)
+}
+
+// pomodoroLengthsArgs are the lengths of pomodoros and breaks
+// in minutes.
+type pomodoroLengthsArgs struct {
+	Work  int `guiapi:"required,min=1,max=120"`
+	Break int `guiapi:"required,min=1,max=60"`
 }
 
-func focusSortHandler(in json.RawMessage) (*Result, error) {
-	var args = struct {
-		Type string
-		Old  int
-		New  int
-	}{}
-	err := json.Unmarshal(in, &args)
+func pomodoroLengthsHandler(tx data.Tx, args *pomodoroLengthsArgs) (*Result, error) {
+	err := tx.SetPomodoroLengths(1, time.Duration(args.Work)*time.Minute, time.Duration(args.Break)*time.Minute)
 	if err != nil {
 		return nil, err
 	}
+	return focusViewHandler(tx)
+}
+
+type listSortArgs struct {
+	Item int `guiapi:"required,min=1"`
+	Pos  int `guiapi:"required,min=1"`
+}
+
+func listSortHandler(tx data.Tx, args *listSortArgs) (*Result, error) {
+	err := tx.SetListItemPosition(1, args.Item, args.Pos)
+	if err != nil {
+		return nil, err
+	}
+	return listViewHandler(tx, &listViewArgs{})
+}
+
+type focusSortArgs struct {
+	Type string
+	Old  int `guiapi:"min=0"`
+	New  int `guiapi:"min=0"`
+}
+
+func focusSortHandler(tx data.Tx, args *focusSortArgs) (*Result, error) {
 	// var type
 	// switch args.Type {
 	// case "pause":
@@ -148,91 +483,149 @@ func focusSortHandler(in json.RawMessage) (*Result, error) {
 	// 	data.Focus = FocusNone
 	// }
 	// sortFocusItem(0, args.Old, args.New)
-	return focusViewHandler(nil)
+	return focusViewHandler(tx)
 }
 
-func itemNewHandler(in json.RawMessage) (*Result, error) {
-	return replaceContainer(blocks.EditItemPage(data.Item{}, true))
+func itemNewHandler(tx data.Tx) (*Result, error) {
+	fields, err := tx.ListFields(1)
+	if err != nil {
+		return nil, err
+	}
+	return replaceContainer(blocks.EditItemPage(data.Item{}, fields, true))
 }
 
-func itemViewHandler(in json.RawMessage) (*Result, error) {
-	var id int
-	err := json.Unmarshal(in, &id)
+// itemArgs are the arguments of actions that only need an item ID
+type itemArgs struct {
+	ID int `guiapi:"required,min=1"`
+}
+
+func itemViewHandler(tx data.Tx, args *itemArgs) (*Result, error) {
+	ui, err := tx.UserItemByID(1, args.ID)
 	if err != nil {
 		return nil, err
 	}
-	ui, _ := data.UserItemByID(1, id)
-	res, err := replaceContainer(blocks.ViewItemPage(ui))
+	res, err := itemPage(tx, ui)
 	if res != nil {
-		args, err := json.Marshal([]interface{}{nil, "Bunny Item", fmt.Sprint("/item/", id)})
+		url, err := json.Marshal([]interface{}{nil, "Bunny Item", fmt.Sprint("/item/", args.ID)})
 		if err != nil {
 			log.Println(err)
 		}
 		res.JS = append(res.JS, JSCall{
 			Name:      "setURL",
-			Arguments: args,
+			Arguments: url,
 		})
 	}
 	return res, err
 }
 
-func itemEditHandler(in json.RawMessage) (*Result, error) {
-	var id int
-	err := json.Unmarshal(in, &id)
+func itemEditHandler(tx data.Tx, args *itemArgs) (*Result, error) {
+	ui, err := tx.UserItemByID(1, args.ID)
 	if err != nil {
 		return nil, err
 	}
-	ui, _ := data.UserItemByID(1, id)
-	return replaceContainer(blocks.EditItemPage(ui, false))
+	fields, err := tx.ItemFields(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	return replaceContainer(blocks.EditItemPage(ui, fields, false))
 }
 
-func itemSaveHandler(in json.RawMessage) (*Result, error) {
-	var arg struct {
-		ID    int
-		New   bool
-		Title string
-		Body  string
-	}
-	err := json.Unmarshal(in, &arg)
+// itemPage shows the item with its custom fields.
+func itemPage(tx data.Tx, d data.Item) (*Result, error) {
+	fields, err := tx.ItemFields(d.ID)
 	if err != nil {
 		return nil, err
 	}
+	return replaceContainer(blocks.ViewItemPage(d, fields))
+}
+
+type itemSaveArgs struct {
+	ID      int `guiapi:"min=0"`
+	New     bool
+	Version int    `guiapi:"min=0"`
+	Title   string `guiapi:"max=500"`
+	Body    string
+	Due     string `guiapi:"max=10"` // YYYY-MM-DD, empty removes the due date
+
+	// values of custom fields by field ID, empty values remove them
+	Fields map[int]string
+}
+
+func itemSaveHandler(tx data.Tx, arg *itemSaveArgs) (*Result, error) {
+	if !arg.New && arg.ID == 0 {
+		return nil, ArgsError{Action: "itemSave", Field: "ID", Problem: "is required for existing items"}
+	}
 
+	due, err := data.ParseDue(arg.Due)
+	if err != nil {
+		return nil, ArgsError{Action: "itemSave", Field: "Due", Problem: "has to be a date like 2018-12-31"}
+	}
+	var fields []data.Field
+	if arg.New {
+		fields, err = tx.ListFields(1)
+	} else {
+		fields, err = tx.ItemFields(arg.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
 	if arg.New {
 		if len(arg.Title) == 0 {
-			list, err := data.UserItemList(1, 1)
-			if err != nil {
-				return nil, err
-			}
-			return replaceContainer(blocks.ViewListPage(list))
+			return listPage(tx, 1)
+		}
+		var item data.Item
+		err = item.SetFields(fields, arg.Fields)
+		if err != nil {
+			return nil, err
 		}
-		newItem, err := data.NewItem()
+		newItem, err := tx.NewListItem(1, item)
 		if err != nil {
 			return nil, err
 		}
 		arg.ID = newItem.ID
+		arg.Version = newItem.Version
 	}
-	d, _ := data.UserItemByID(1, arg.ID)
+	current, err := tx.UserItemByID(1, arg.ID)
+	if err != nil {
+		return nil, err
+	}
+	d := current
+	d.Version = arg.Version
 	if len(arg.Title) > 0 {
 		d.Title = arg.Title
 	}
 	if len(arg.Body) > 0 {
 		d.Body = arg.Body
 	}
-	data.SetItem(d)
-	return replaceContainer(blocks.ViewItemPage(d))
+	d.Due = due
+	err = d.SetFields(fields, arg.Fields)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.SetItem(d)
+	if data.IsConflict(err) {
+		return replaceContainer(blocks.ConflictItemPage(d, current, fields))
+	}
+	if err != nil {
+		return nil, err
+	}
+	d, err = tx.UserItemByID(1, arg.ID)
+	if err != nil {
+		return nil, err
+	}
+	return itemPage(tx, d)
 }
 
-func itemStateHandler(in json.RawMessage) (*Result, error) {
-	var args = struct {
-		ID    int
-		State string
-	}{}
-	err := json.Unmarshal(in, &args)
+type itemStateArgs struct {
+	ID    int    `guiapi:"required,min=1"`
+	State string `guiapi:"required,enum=open|complete|archived"`
+}
+
+func itemStateHandler(tx data.Tx, args *itemStateArgs) (*Result, error) {
+	d, err := tx.UserItemByID(1, args.ID)
 	if err != nil {
 		return nil, err
 	}
-	d, _ := data.UserItemByID(1, args.ID)
 	switch args.State {
 	case "open":
 		d.State = data.ItemOpen
@@ -241,72 +634,305 @@ func itemStateHandler(in json.RawMessage) (*Result, error) {
 	case "archived":
 		d.State = data.ItemArchived
 	}
-	data.SetItem(d)
-	return replaceContainer(blocks.ViewItemPage(d))
+	err = tx.SetItem(d)
+	if err != nil {
+		return nil, err
+	}
+	return itemPage(tx, d)
+}
+
+type itemFocusArgs struct {
+	ID    int    `guiapi:"required,min=1"`
+	Focus string `guiapi:"required,enum=later|focus|watch"`
 }
 
-func itemFocusHandler(in json.RawMessage) (*Result, error) {
-	var args = struct {
-		ID    int
-		Focus string
-	}{}
-	err := json.Unmarshal(in, &args)
+func itemFocusHandler(tx data.Tx, args *itemFocusArgs) (*Result, error) {
+	d, err := tx.UserItemByID(1, args.ID)
 	if err != nil {
 		return nil, err
 	}
-
-	d, _ := data.UserItemByID(1, args.ID)
 	switch args.Focus {
 	case "later":
 		if d.Focus == data.FocusLater {
-			data.SetFocus(1, args.ID, data.FocusNone)
+			err = tx.SetFocus(1, args.ID, data.FocusNone)
 		} else {
-			data.SetFocus(1, args.ID, data.FocusLater)
+			err = tx.SetFocus(1, args.ID, data.FocusLater)
 		}
 	case "focus":
 		if d.Focus == data.FocusNow {
-			data.SetFocus(1, args.ID, data.FocusNone)
+			err = tx.SetFocus(1, args.ID, data.FocusNone)
 		} else {
-			data.SetFocus(1, args.ID, data.FocusNow)
+			err = tx.SetFocus(1, args.ID, data.FocusNow)
 		}
 	case "watch":
 		if d.Focus == data.FocusWatch {
-			data.SetFocus(1, args.ID, data.FocusNone)
+			err = tx.SetFocus(1, args.ID, data.FocusNone)
 		} else {
-			data.SetFocus(1, args.ID, data.FocusWatch)
+			err = tx.SetFocus(1, args.ID, data.FocusWatch)
 		}
 	}
-	d, _ = data.UserItemByID(1, args.ID)
-	return replaceContainer(blocks.ViewItemPage(d))
+	if err != nil {
+		return nil, err
+	}
+	d, err = tx.UserItemByID(1, args.ID)
+	if err != nil {
+		return nil, err
+	}
+	return itemPage(tx, d)
 }
 
-func itemDeleteHandler(in json.RawMessage) (*Result, error) {
-	var arg int
-	err := json.Unmarshal(in, &arg)
+type itemPriorityArgs struct {
+	ID       int    `guiapi:"required,min=1"`
+	Priority string `guiapi:"required,enum=none|low|medium|high|urgent"`
+}
+
+func itemPriorityHandler(tx data.Tx, args *itemPriorityArgs) (*Result, error) {
+	priority, err := data.ParsePriority(args.Priority)
+	if err != nil {
+		return nil, err
+	}
+	d, err := tx.UserItemByID(1, args.ID)
+	if err != nil {
+		return nil, err
+	}
+	d.Priority = priority
+	err = tx.SetItem(d)
 	if err != nil {
 		return nil, err
 	}
-	data.DeleteItem(arg)
-	list, err := data.UserItemList(1, 1)
+	d, err = tx.UserItemByID(1, args.ID)
 	if err != nil {
 		return nil, err
 	}
-	return replaceContainer(blocks.ViewListPage(list))
+	return itemPage(tx, d)
 }
 
-func replaceContainer(block html.Block) (*Result, error) {
-	out, err := html.RenderString(block)
+func itemDeleteHandler(tx data.Tx, args *itemArgs) (*Result, error) {
+	err := tx.DeleteItem(args.ID)
 	if err != nil {
 		return nil, err
 	}
-	ret := &Result{
-		HTML: []HTMLUpdate{
-			{
-				Operation: HTMLReplace,
-				Selector:  "#container",
-				Content:   out,
-			},
-		},
+	return listPage(tx, 1)
+}
+
+type timesheetArgs struct {
+	Week string `guiapi:"max=10"` // any day of the week, empty for this week
+}
+
+func timesheetViewHandler(tx data.Tx, args *timesheetArgs) (*Result, error) {
+	week, err := data.ParseWeek(args.Week)
+	if err != nil {
+		return nil, err
+	}
+	return timesheetPage(tx, week, 0)
+}
+
+// timesheetPage shows the timesheet of the week of t with the
+// time entry edit as a form.
+func timesheetPage(tx data.Tx, t time.Time, edit int) (*Result, error) {
+	ts, err := tx.UserTimesheet(1, t)
+	if err != nil {
+		return nil, err
+	}
+	items, err := tx.ItemsByState(data.ItemOpen)
+	if err != nil {
+		return nil, err
+	}
+	res, err := replaceContainer(blocks.ViewTimesheetPage(ts, items, edit))
+	if res != nil {
+		url := "/timesheet/?week=" + ts.Week.Format(data.DueFormat)
+		args, err := json.Marshal([]interface{}{nil, "Bunny Timesheet", url})
+		if err != nil {
+			log.Println(err)
+		}
+		res.JS = append(res.JS, JSCall{
+			Name:      "setURL",
+			Arguments: args,
+		})
+	}
+	return res, err
+}
+
+type entryArgs struct {
+	ID int `guiapi:"required,min=1"`
+}
+
+func entryEditHandler(tx data.Tx, args *entryArgs) (*Result, error) {
+	e, err := tx.TimeEntryByID(1, args.ID)
+	if err != nil {
+		return nil, err
+	}
+	return timesheetPage(tx, e.Start, e.ID)
+}
+
+// entrySaveArgs correct a time entry, the times are in UTC in the
+// format of datetime-local inputs. End is empty for the running entry.
+type entrySaveArgs struct {
+	ID    int    `guiapi:"required,min=1"`
+	Start string `guiapi:"required,max=20"`
+	End   string `guiapi:"max=20"`
+}
+
+func entrySaveHandler(tx data.Tx, args *entrySaveArgs) (*Result, error) {
+	e := data.TimeEntry{ID: args.ID}
+	var err error
+	e.Start, e.End, err = entryTimes(args.Start, args.End)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.SetTimeEntry(1, e)
+	if err != nil {
+		return nil, err
+	}
+	return timesheetPage(tx, e.Start, 0)
+}
+
+type entryAddArgs struct {
+	Item  int    `guiapi:"required,min=1"`
+	Start string `guiapi:"required,max=20"`
+	End   string `guiapi:"required,max=20"`
+}
+
+func entryAddHandler(tx data.Tx, args *entryAddArgs) (*Result, error) {
+	e := data.TimeEntry{Item: args.Item}
+	var err error
+	e.Start, e.End, err = entryTimes(args.Start, args.End)
+	if err != nil {
+		return nil, err
+	}
+	e, err = tx.NewTimeEntry(1, e)
+	if err != nil {
+		return nil, err
+	}
+	return timesheetPage(tx, e.Start, 0)
+}
+
+func entryDeleteHandler(tx data.Tx, args *entryArgs) (*Result, error) {
+	e, err := tx.TimeEntryByID(1, args.ID)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.DeleteTimeEntry(1, args.ID)
+	if err != nil {
+		return nil, err
+	}
+	return timesheetPage(tx, e.Start, 0)
+}
+
+// entryTimes parses the start and end of a time entry form.
+func entryTimes(start, end string) (s, e time.Time, err error) {
+	s, err = data.ParseEntryTime(start)
+	if err != nil {
+		return s, e, err
+	}
+	e, err = data.ParseEntryTime(end)
+	return s, e, err
+}
+
+func settingsViewHandler(tx data.Tx) (*Result, error) {
+	return settingsPage(tx, "")
+}
+
+func settingsPage(tx data.Tx, secret string) (*Result, error) {
+	tokens, err := tx.UserTokens(1)
+	if err != nil {
+		return nil, err
+	}
+	res, err := replaceContainer(blocks.ViewSettingsPage(tokens, secret))
+	if res != nil {
+		args, err := json.Marshal([]interface{}{nil, "Bunny Settings", "/settings/"})
+		if err != nil {
+			log.Println(err)
+		}
+		res.JS = append(res.JS, JSCall{
+			Name:      "setURL",
+			Arguments: args,
+		})
+	}
+	return res, err
+}
+
+type tokenCreateArgs struct {
+	Name  string `guiapi:"required,max=100"`
+	Scope string `guiapi:"required,enum=read|write"`
+	Days  int    `guiapi:"min=0,max=3650"` // 0 means the token doesn't expire
+}
+
+func tokenCreateHandler(tx data.Tx, args *tokenCreateArgs) (*Result, error) {
+	scope, err := data.ParseTokenScope(args.Scope)
+	if err != nil {
+		return nil, err
+	}
+	var expires time.Time
+	if args.Days > 0 {
+		expires = time.Now().AddDate(0, 0, args.Days)
+	}
+	_, secret, err := tx.NewToken(1, args.Name, scope, expires)
+	if err != nil {
+		return nil, err
+	}
+	return settingsPage(tx, secret)
+}
+
+type tokenRevokeArgs struct {
+	ID int `guiapi:"required,min=1"`
+}
+
+func tokenRevokeHandler(tx data.Tx, args *tokenRevokeArgs) (*Result, error) {
+	err := tx.RevokeToken(1, args.ID)
+	if err != nil {
+		return nil, err
+	}
+	return settingsPage(tx, "")
+}
+
+// importArgs carry the whole file, the client sends it
+// again to apply the import after the preview.
+type importArgs struct {
+	Format  string `guiapi:"required,enum=markdown|todoist|trello"`
+	Name    string `guiapi:"max=255"` // file name
+	Content string `guiapi:"required,max=5000000"`
+}
+
+func importPreviewHandler(tx data.Tx, args *importArgs) (*Result, error) {
+	res, err := importer.Parse(args.Format, args.Name, strings.NewReader(args.Content))
+	if err != nil {
+		return nil, err
+	}
+	return replaceContainer(blocks.ViewImportPage(res, false))
+}
+
+func importApplyHandler(tx data.Tx, args *importArgs) (*Result, error) {
+	res, err := importer.Parse(args.Format, args.Name, strings.NewReader(args.Content))
+	if err != nil {
+		return nil, err
+	}
+	_, err = res.Apply(tx)
+	if err != nil {
+		return nil, err
+	}
+	return replaceContainer(blocks.ViewImportPage(res, true))
+}
+
+func replaceContainer(block html.Block) (*Result, error) {
+	ret := &Result{}
+	err := ret.addHTML(HTMLReplace, "#container", block)
+	if err != nil {
+		return nil, err
 	}
 	return ret, nil
 }
+
+// addHTML renders block and adds it as an update of the selected element.
+func (r *Result) addHTML(op HTMLOp, selector string, block html.Block) error {
+	out, err := html.RenderString(block)
+	if err != nil {
+		return err
+	}
+	r.HTML = append(r.HTML, HTMLUpdate{
+		Operation: op,
+		Selector:  selector,
+		Content:   out,
+	})
+	return nil
+}
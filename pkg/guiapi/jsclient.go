@@ -0,0 +1,106 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package guiapi
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Description is returned from a GET request to the GUI API
+type Description struct {
+	Actions []ActionSchema
+}
+
+// WriteJSClient writes a JS module that defines a guiapi object with
+// one function per action. The argument types are documented with
+// JSDoc so that editors can check the calls.
+func WriteJSClient(w io.Writer, actions []ActionSchema) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintln(b, `// Code generated by "go generate github.com/mbertschler/bunny/pkg/guiapi"; DO NOT EDIT.`)
+	fmt.Fprintln(b)
+	fmt.Fprintln(b, "var guiapi = {}")
+	for _, a := range actions {
+		fmt.Fprintln(b)
+		typ := argsTypeName(a.Name)
+		if a.Args != nil {
+			fmt.Fprintln(b, "/**")
+			fmt.Fprintf(b, " * @typedef {Object} %s\n", typ)
+			for _, arg := range a.Args {
+				name := arg.Name
+				if !arg.Required {
+					name = "[" + name + "]"
+				}
+				fmt.Fprintf(b, " * @property {%s} %s%s\n", jsType(arg), name, jsConstraints(arg))
+			}
+			fmt.Fprintln(b, " */")
+			fmt.Fprintln(b)
+			fmt.Fprintln(b, "/**")
+			fmt.Fprintf(b, " * @param {%s} args\n", typ)
+			fmt.Fprintln(b, " */")
+			fmt.Fprintf(b, "guiapi.%s = function(args) {\n", a.Name)
+			fmt.Fprintf(b, "\tcallGuiAPI(%q, args)\n", a.Name)
+		} else {
+			fmt.Fprintf(b, "guiapi.%s = function() {\n", a.Name)
+			fmt.Fprintf(b, "\tcallGuiAPI(%q, null)\n", a.Name)
+		}
+		fmt.Fprintln(b, "}")
+	}
+	return b.Flush()
+}
+
+func argsTypeName(action string) string {
+	if action == "" {
+		return "Args"
+	}
+	return strings.ToUpper(action[:1]) + action[1:] + "Args"
+}
+
+func jsType(arg ArgSchema) string {
+	if len(arg.Enum) > 0 {
+		var values []string
+		for _, e := range arg.Enum {
+			values = append(values, strconv.Quote(e))
+		}
+		return "(" + strings.Join(values, "|") + ")"
+	}
+	switch arg.Type {
+	case "int", "float":
+		return "number"
+	case "string":
+		return "string"
+	case "bool":
+		return "boolean"
+	case "array":
+		return "Array"
+	}
+	return "Object"
+}
+
+func jsConstraints(arg ArgSchema) string {
+	var out []string
+	if arg.Min != nil {
+		out = append(out, fmt.Sprint("min ", *arg.Min))
+	}
+	if arg.Max != nil {
+		out = append(out, fmt.Sprint("max ", *arg.Max))
+	}
+	if len(out) == 0 {
+		return ""
+	}
+	return " - " + strings.Join(out, ", ")
+}
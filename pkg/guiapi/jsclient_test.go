@@ -0,0 +1,63 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package guiapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// TestJSClientUpToDate fails if js/src/guiapi.js doesn't match the
+// registered actions. Run go generate to update it.
+func TestJSClientUpToDate(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteJSClient(&buf, Handlers().Describe())
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := ioutil.ReadFile("../../js/src/guiapi.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), file) {
+		t.Error("js/src/guiapi.js is outdated, run go generate")
+	}
+}
+
+func TestDescribeEndpoint(t *testing.T) {
+	h := Handlers()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/gui/", nil))
+	if w.Code != 200 {
+		t.Fatal("unexpected status", w.Code)
+	}
+	var desc Description
+	err := json.NewDecoder(w.Body).Decode(&desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := h.Describe()
+	for i := range want {
+		for j := range want[i].Args {
+			want[i].Args[j].index = 0
+		}
+	}
+	if !reflect.DeepEqual(desc.Actions, want) {
+		t.Error("description doesn't match the registered actions")
+	}
+}
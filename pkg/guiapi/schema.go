@@ -0,0 +1,285 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package guiapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mbertschler/bunny/pkg/data"
+)
+
+// NewHandler returns an empty Handler that actions can be registered on.
+func NewHandler() Handler {
+	return Handler{
+		Functions: map[string]Callable{},
+		Schemas:   map[string]ActionSchema{},
+	}
+}
+
+// Register adds an action with a typed argument struct to the handler.
+// fn has to be of the form
+//
+//	func(tx data.Tx, args *T) (*Result, error)
+//	func(tx data.Tx) (*Result, error)
+//
+// where T is a struct. fn reads and writes data through tx, which is
+// the batch of an atomic request. Before fn is called, the JSON
+// arguments are decoded into a new T and validated according to the
+// `guiapi` tags of its fields. Supported tags are:
+//
+//	required         the field must not be the zero value
+//	enum=a|b|c       the value has to be one of the listed strings
+//	min=1 max=10     range for numbers, length range for strings
+//
+// Multiple tags are separated by commas: `guiapi:"required,min=1"`.
+// Invalid arguments are returned to the client as invalidArgs errors.
+// Register panics if fn or the tags of T are invalid.
+func (h Handler) Register(name string, fn interface{}) {
+	v := reflect.ValueOf(fn)
+	t := v.Type()
+	if t.Kind() != reflect.Func || t.NumIn() < 1 || t.NumIn() > 2 ||
+		t.In(0) != txType || t.NumOut() != 2 ||
+		t.Out(0) != resultType || t.Out(1) != errorType {
+		panic(fmt.Sprintf("guiapi: invalid function %s for action %s", t, name))
+	}
+	schema := ActionSchema{Name: name}
+	var argType reflect.Type
+	if t.NumIn() == 2 {
+		argType = t.In(1)
+		if argType.Kind() != reflect.Ptr || argType.Elem().Kind() != reflect.Struct {
+			panic(fmt.Sprintf("guiapi: arguments of action %s need to be a struct pointer", name))
+		}
+		schema.Args = argSchemas(name, argType.Elem())
+	}
+	h.Schemas[name] = schema
+	h.Functions[name] = func(tx data.Tx, in json.RawMessage) (*Result, error) {
+		args := []reflect.Value{reflect.ValueOf(tx)}
+		if argType != nil {
+			arg := reflect.New(argType.Elem())
+			if len(in) > 0 && string(in) != "null" {
+				err := json.Unmarshal(in, arg.Interface())
+				if err != nil {
+					return nil, ArgsError{Action: name, Problem: err.Error()}
+				}
+			}
+			err := validateArgs(name, schema.Args, arg.Elem())
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+		}
+		out := v.Call(args)
+		res, _ := out[0].Interface().(*Result)
+		err, _ := out[1].Interface().(error)
+		return res, err
+	}
+}
+
+// Describe returns the schemas of all registered actions sorted by name.
+func (h Handler) Describe() []ActionSchema {
+	var out []ActionSchema
+	for _, s := range h.Schemas {
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+var (
+	txType     = reflect.TypeOf(data.Tx{})
+	resultType = reflect.TypeOf(&Result{})
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// ActionSchema is the machine-readable description of an action
+type ActionSchema struct {
+	Name string
+	Args []ArgSchema `json:",omitempty"` // nil if the action takes no arguments
+}
+
+// ArgSchema describes one field of the arguments of an action
+type ArgSchema struct {
+	Name     string   // JSON name of the field
+	Type     string   // int, float, string, bool, array or object
+	Required bool     `json:",omitempty"`
+	Enum     []string `json:",omitempty"` // allowed values
+	Min      *int     `json:",omitempty"` // minimum value or length
+	Max      *int     `json:",omitempty"` // maximum value or length
+
+	index int
+}
+
+// ArgsError is returned if the arguments of an action are invalid
+type ArgsError struct {
+	Action  string
+	Field   string `json:",omitempty"`
+	Problem string
+}
+
+func (e ArgsError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("invalid arguments for %s: %s", e.Action, e.Problem)
+	}
+	return fmt.Sprintf("invalid argument %s for %s: %s", e.Field, e.Action, e.Problem)
+}
+
+func argSchemas(action string, t reflect.Type) []ArgSchema {
+	var out []ArgSchema
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue // unexported
+		}
+		name := f.Name
+		if tag := strings.Split(f.Tag.Get("json"), ",")[0]; tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+		arg := ArgSchema{
+			Name:  name,
+			Type:  typeName(f.Type),
+			index: i,
+		}
+		err := parseTag(&arg, f.Tag.Get("guiapi"))
+		if err != nil {
+			panic(fmt.Sprintf("guiapi: action %s field %s: %v", action, f.Name, err))
+		}
+		out = append(out, arg)
+	}
+	return out
+}
+
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "int"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "bool"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	}
+	return "object"
+}
+
+func parseTag(arg *ArgSchema, tag string) error {
+	if tag == "" {
+		return nil
+	}
+	for _, part := range strings.Split(tag, ",") {
+		kv := strings.SplitN(part, "=", 2)
+		switch kv[0] {
+		case "required":
+			arg.Required = true
+			continue
+		}
+		if len(kv) != 2 {
+			return fmt.Errorf("tag %q needs a value", kv[0])
+		}
+		switch kv[0] {
+		case "enum":
+			if arg.Type != "string" {
+				return fmt.Errorf("enum is only supported for strings")
+			}
+			arg.Enum = strings.Split(kv[1], "|")
+		case "min", "max":
+			n, err := strconv.Atoi(kv[1])
+			if err != nil {
+				return err
+			}
+			if kv[0] == "min" {
+				arg.Min = &n
+			} else {
+				arg.Max = &n
+			}
+		default:
+			return fmt.Errorf("unknown tag %q", kv[0])
+		}
+	}
+	return nil
+}
+
+func validateArgs(action string, args []ArgSchema, v reflect.Value) error {
+	for _, arg := range args {
+		problem := validateArg(arg, v.Field(arg.index))
+		if problem != "" {
+			return ArgsError{
+				Action:  action,
+				Field:   arg.Name,
+				Problem: problem,
+			}
+		}
+	}
+	return nil
+}
+
+func validateArg(arg ArgSchema, v reflect.Value) string {
+	if arg.Required && isZero(v) {
+		return "is required"
+	}
+	if len(arg.Enum) > 0 && v.String() != "" {
+		found := false
+		for _, e := range arg.Enum {
+			if v.String() == e {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Sprintf("%q is not one of %s", v.String(), strings.Join(arg.Enum, ", "))
+		}
+	}
+	var n float64
+	switch arg.Type {
+	case "int":
+		if v.Kind() >= reflect.Uint && v.Kind() <= reflect.Uint64 {
+			n = float64(v.Uint())
+		} else {
+			n = float64(v.Int())
+		}
+	case "float":
+		n = v.Float()
+	case "string":
+		n = float64(len(v.String()))
+	default:
+		return ""
+	}
+	unit := ""
+	if arg.Type == "string" {
+		unit = " characters long"
+	}
+	if arg.Min != nil && n < float64(*arg.Min) {
+		return fmt.Sprintf("must be at least %d%s", *arg.Min, unit)
+	}
+	if arg.Max != nil && n > float64(*arg.Max) {
+		return fmt.Sprintf("must be at most %d%s", *arg.Max, unit)
+	}
+	return ""
+}
+
+func isZero(v reflect.Value) bool {
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
@@ -0,0 +1,153 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package guiapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/mbertschler/bunny/pkg/data"
+)
+
+type testArgs struct {
+	ID    int    `guiapi:"required,min=1,max=100"`
+	State string `json:"state" guiapi:"enum=open|done"`
+	Note  string `guiapi:"max=5"`
+	Flag  bool
+}
+
+func testHandler(called *testArgs) Handler {
+	h := NewHandler()
+	h.Register("test", func(tx data.Tx, args *testArgs) (*Result, error) {
+		*called = *args
+		return &Result{}, nil
+	})
+	h.Register("noArgs", func(tx data.Tx) (*Result, error) {
+		return &Result{}, nil
+	})
+	return h
+}
+
+var validationCases = []struct {
+	Args  string
+	Field string // empty if valid
+}{
+	{Args: `{"ID":1}`},
+	{Args: `{"ID":100,"state":"done","Note":"hello","Flag":true}`},
+	{Args: `{}`, Field: "ID"},
+	{Args: `null`, Field: "ID"},
+	{Args: `{"ID":0}`, Field: "ID"},
+	{Args: `{"ID":101}`, Field: "ID"},
+	{Args: `{"ID":1,"state":"maybe"}`, Field: "state"},
+	{Args: `{"ID":1,"Note":"too long"}`, Field: "Note"},
+	{Args: `{"ID":"1"}`, Field: "-"},
+	{Args: `[1]`, Field: "-"},
+}
+
+func TestValidation(t *testing.T) {
+	var called testArgs
+	h := testHandler(&called)
+	for _, c := range validationCases {
+		called = testArgs{}
+		_, err := h.Functions["test"](data.Tx{}, json.RawMessage(c.Args))
+		if c.Field == "" {
+			if err != nil {
+				t.Error(c.Args, "should be valid:", err)
+			}
+			if called.ID == 0 {
+				t.Error(c.Args, "function was not called")
+			}
+			continue
+		}
+		e, ok := err.(ArgsError)
+		if !ok {
+			t.Error(c.Args, "expected an ArgsError, got", err)
+			continue
+		}
+		if c.Field != "-" && e.Field != c.Field {
+			t.Error(c.Args, "expected error for", c.Field, "got", e)
+		}
+		if errorCode(err) != CodeInvalidArgs {
+			t.Error(c.Args, "expected code invalidArgs, got", errorCode(err))
+		}
+		if called.ID != 0 {
+			t.Error(c.Args, "function should not be called")
+		}
+	}
+	_, err := h.Functions["noArgs"](data.Tx{}, json.RawMessage(`{"ignored":true}`))
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	h := testHandler(nil)
+	one, hundred, five := 1, 100, 5
+	want := []ActionSchema{
+		{Name: "noArgs"},
+		{Name: "test", Args: []ArgSchema{
+			{Name: "ID", Type: "int", Required: true, Min: &one, Max: &hundred, index: 0},
+			{Name: "state", Type: "string", Enum: []string{"open", "done"}, index: 1},
+			{Name: "Note", Type: "string", Max: &five, index: 2},
+			{Name: "Flag", Type: "bool", index: 3},
+		}},
+	}
+	got := h.Describe()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong description\n%+v\nshould be\n%+v", got, want)
+	}
+}
+
+func TestRegisterPanics(t *testing.T) {
+	invalid := []interface{}{
+		func(tx data.Tx, args testArgs) (*Result, error) { return nil, nil },
+		func(tx data.Tx, id *int) (*Result, error) { return nil, nil },
+		func(tx data.Tx, args *testArgs) error { return nil },
+		func(args *testArgs) (*Result, error) { return nil, nil },
+		func() (*Result, error) { return nil, nil },
+		func(tx data.Tx, args *struct {
+			ID int `guiapi:"enum=a|b"`
+		}) (*Result, error) {
+			return nil, nil
+		},
+		func(tx data.Tx, args *struct {
+			ID int `guiapi:"unknown"`
+		}) (*Result, error) {
+			return nil, nil
+		},
+	}
+	for i, fn := range invalid {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("case", i, "should panic")
+				}
+			}()
+			NewHandler().Register("invalid", fn)
+		}()
+	}
+}
+
+func TestHandlersRegistered(t *testing.T) {
+	h := Handlers()
+	if len(h.Functions) != len(h.Schemas) {
+		t.Error("every function should have a schema")
+	}
+	for name := range h.Functions {
+		if _, ok := h.Schemas[name]; !ok {
+			t.Error(name, "has no schema")
+		}
+	}
+}
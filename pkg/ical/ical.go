@@ -0,0 +1,142 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ical writes calendars in the iCalendar format
+// described in RFC 5545.
+package ical
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Calendar is a VCALENDAR object.
+type Calendar struct {
+	ProdID     string // identifies the product that created the calendar
+	Name       string // optional display name
+	Components []*Component
+}
+
+// Component is a calendar component like VEVENT or VTODO.
+// Properties are written in the order in which they were added.
+type Component struct {
+	Name  string
+	props []property
+}
+
+type property struct {
+	name  string // including parameters
+	value string
+}
+
+// NewEvent returns a new VEVENT component.
+func NewEvent() *Component {
+	return &Component{Name: "VEVENT"}
+}
+
+// NewTodo returns a new VTODO component.
+func NewTodo() *Component {
+	return &Component{Name: "VTODO"}
+}
+
+// Add adds a property with a value that is written as it is.
+func (c *Component) Add(name, value string) {
+	c.props = append(c.props, property{name: name, value: value})
+}
+
+// AddText adds a property of type TEXT. The value is escaped.
+func (c *Component) AddText(name, value string) {
+	c.Add(name, EscapeText(value))
+}
+
+// AddDate adds a property of type DATE.
+func (c *Component) AddDate(name string, t time.Time) {
+	c.Add(name+";VALUE=DATE", t.Format("20060102"))
+}
+
+// AddTime adds a property of type DATE-TIME in UTC.
+func (c *Component) AddTime(name string, t time.Time) {
+	c.Add(name, t.UTC().Format("20060102T150405Z"))
+}
+
+// EscapeText escapes backslashes, semicolons, commas and line
+// breaks of a TEXT value. Other control characters are not allowed
+// in TEXT values and are removed.
+func EscapeText(s string) string {
+	s = strings.Replace(s, "\r\n", "\n", -1)
+	var b bytes.Buffer
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == ';' || r == ',':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteRune(r)
+		case r < 0x20 || r == 0x7f:
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Encode writes the calendar to w. Lines end with CRLF and are
+// folded after 75 octets.
+func (c *Calendar) Encode(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	writeLine(bw, "BEGIN:VCALENDAR")
+	writeLine(bw, "VERSION:2.0")
+	writeLine(bw, "PRODID:"+c.ProdID)
+	writeLine(bw, "CALSCALE:GREGORIAN")
+	if c.Name != "" {
+		writeLine(bw, "X-WR-CALNAME:"+EscapeText(c.Name))
+	}
+	for _, comp := range c.Components {
+		writeLine(bw, "BEGIN:"+comp.Name)
+		for _, p := range comp.props {
+			writeLine(bw, p.name+":"+p.value)
+		}
+		writeLine(bw, "END:"+comp.Name)
+	}
+	writeLine(bw, "END:VCALENDAR")
+	return bw.Flush()
+}
+
+// maxLine is the maximum length of a line in octets,
+// without the line break.
+const maxLine = 75
+
+// writeLine folds line so that no line is longer than maxLine.
+// Continuation lines start with a space. Lines are only
+// folded between UTF-8 sequences.
+func writeLine(w *bufio.Writer, line string) {
+	limit := maxLine
+	for len(line) > limit {
+		n := limit
+		for n > 0 && !utf8.RuneStart(line[n]) {
+			n--
+		}
+		w.WriteString(line[:n])
+		w.WriteString("\r\n ")
+		line = line[n:]
+		limit = maxLine - 1 // the leading space counts
+	}
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
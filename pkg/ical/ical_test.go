@@ -0,0 +1,90 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEscapeText(t *testing.T) {
+	cases := map[string]string{
+		"plain":             "plain",
+		"a, b; c":           `a\, b\; c`,
+		`back\slash`:        `back\\slash`,
+		"one\ntwo\r\nthree": `one\ntwo\nthree`,
+		"tab\tbell\x07":     "tab\tbell",
+		"ünïcödé":           "ünïcödé",
+	}
+	for in, want := range cases {
+		if got := EscapeText(in); got != want {
+			t.Errorf("EscapeText(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	ev := NewEvent()
+	ev.Add("UID", "item-1@bunny")
+	ev.AddTime("DTSTAMP", time.Date(2018, 10, 20, 12, 30, 0, 0, time.FixedZone("", 2*3600)))
+	ev.AddDate("DTSTART", time.Date(2018, 10, 21, 0, 0, 0, 0, time.UTC))
+	ev.AddText("SUMMARY", "Buy milk, eggs")
+	cal := Calendar{ProdID: "-//test//EN", Components: []*Component{ev}}
+	var buf bytes.Buffer
+	err := cal.Encode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"PRODID:-//test//EN\r\n" +
+		"CALSCALE:GREGORIAN\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:item-1@bunny\r\n" +
+		"DTSTAMP:20181020T103000Z\r\n" +
+		"DTSTART;VALUE=DATE:20181021\r\n" +
+		"SUMMARY:Buy milk\\, eggs\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+	if buf.String() != want {
+		t.Errorf("got\n%q\nwant\n%q", buf.String(), want)
+	}
+}
+
+func TestFolding(t *testing.T) {
+	ev := NewTodo()
+	ev.AddText("DESCRIPTION", strings.Repeat("äb", 100))
+	cal := Calendar{Components: []*Component{ev}}
+	var buf bytes.Buffer
+	err := cal.Encode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var unfolded string
+	for i, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line %d is %d octets long", i, len(line))
+		}
+		if strings.HasPrefix(line, " ") {
+			unfolded += line[1:]
+			continue
+		}
+		unfolded += "\n" + line
+	}
+	if !strings.Contains(unfolded, "\nDESCRIPTION:"+strings.Repeat("äb", 100)+"\n") {
+		t.Errorf("unfolded description is broken:%s", unfolded)
+	}
+}
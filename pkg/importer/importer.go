@@ -0,0 +1,259 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package importer reads exports of other tools and maps them onto
+// areas, lists and items. Parsing a file only returns a preview,
+// nothing is written until the Result is applied.
+package importer
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/mbertschler/bunny/pkg/data"
+	"github.com/mbertschler/bunny/pkg/data/stored"
+)
+
+// Formats that can be imported.
+const (
+	FormatTrello   = "trello"   // board JSON export
+	FormatTodoist  = "todoist"  // project CSV export
+	FormatMarkdown = "markdown" // "- [ ]" checklists
+)
+
+// Formats returns the names of all supported formats.
+func Formats() []string {
+	return []string{FormatMarkdown, FormatTodoist, FormatTrello}
+}
+
+// Result is the preview of an import.
+type Result struct {
+	Format   string
+	Areas    []Area
+	Skipped  map[string]int // description of a skipped field to the number of occurrences
+	Warnings []string       // things that couldn't be imported at all
+}
+
+// Area is imported as a data.Area. Its lists come before its items.
+type Area struct {
+	Title string
+	Body  string
+	Lists []List
+	Items []Item
+}
+
+type List struct {
+	Title string
+	Body  string
+	State data.ItemState
+	Items []Item
+}
+
+type Item struct {
+	Title string
+	Body  string
+	State data.ItemState
+}
+
+// Parse reads a file in the given format. name is used as the
+// title if the format doesn't contain one, usually it is the
+// file name.
+func Parse(format, name string, r io.Reader) (*Result, error) {
+	res := &Result{
+		Format:  format,
+		Skipped: map[string]int{},
+	}
+	var err error
+	switch format {
+	case FormatTrello:
+		err = parseTrello(res, r)
+	case FormatTodoist:
+		err = parseTodoist(res, name, r)
+	case FormatMarkdown:
+		err = parseMarkdown(res, name, r)
+	default:
+		return nil, invalid("unknown import format %q, use one of %s",
+			format, strings.Join(Formats(), ", "))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func invalid(format string, args ...interface{}) error {
+	return stored.WithCause(fmt.Errorf(format, args...), stored.CauseInvalid)
+}
+
+func (r *Result) skip(field string) {
+	r.Skipped[field]++
+}
+
+func (r *Result) warn(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// Counts returns the number of areas, lists and items
+// that will be created.
+func (r *Result) Counts() (areas, lists, items int) {
+	for _, a := range r.Areas {
+		areas++
+		items += len(a.Items)
+		for _, l := range a.Lists {
+			lists++
+			items += len(l.Items)
+		}
+	}
+	return areas, lists, items
+}
+
+// SkippedFields returns the skipped fields sorted by name.
+func (r *Result) SkippedFields() []string {
+	var out []string
+	for f := range r.Skipped {
+		out = append(out, f)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// WriteReport writes a human readable preview of the import
+// and the skipped fields to w.
+func (r *Result) WriteReport(w io.Writer) error {
+	areas, lists, items := r.Counts()
+	b := &errWriter{w: w}
+	b.printf("%s import: %d areas, %d lists, %d items\n", r.Format, areas, lists, items)
+	for _, a := range r.Areas {
+		b.printf("\narea %q\n", a.Title)
+		for _, l := range a.Lists {
+			b.printf("  list %q%s\n", l.Title, stateSuffix(l.State))
+			for _, i := range l.Items {
+				b.printf("    %s %s%s\n", checkbox(i.State), i.Title, stateSuffix(i.State))
+			}
+		}
+		for _, i := range a.Items {
+			b.printf("  %s %s%s\n", checkbox(i.State), i.Title, stateSuffix(i.State))
+		}
+	}
+	if len(r.Skipped) > 0 {
+		b.printf("\nskipped fields:\n")
+		for _, f := range r.SkippedFields() {
+			b.printf("  %dx %s\n", r.Skipped[f], f)
+		}
+	}
+	if len(r.Warnings) > 0 {
+		b.printf("\nwarnings:\n")
+		for _, warning := range r.Warnings {
+			b.printf("  %s\n", warning)
+		}
+	}
+	return b.err
+}
+
+func checkbox(s data.ItemState) string {
+	if s == data.ItemOpen {
+		return "[ ]"
+	}
+	return "[x]"
+}
+
+func stateSuffix(s data.ItemState) string {
+	if s == data.ItemArchived {
+		return " (archived)"
+	}
+	return ""
+}
+
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) printf(format string, args ...interface{}) {
+	if e.err == nil {
+		_, e.err = fmt.Fprintf(e.w, format, args...)
+	}
+}
+
+// Apply creates the areas, lists and items of the result in one
+// batch and returns the created areas. The batch is the one of tx,
+// or a new one if tx isn't part of a batch.
+func (r *Result) Apply(tx data.Tx) ([]data.Area, error) {
+	var out []data.Area
+	err := tx.Batch(func(tx data.Tx) error {
+		for _, a := range r.Areas {
+			area, err := applyArea(tx, a)
+			if err != nil {
+				return err
+			}
+			out = append(out, area)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func applyArea(tx data.Tx, a Area) (data.Area, error) {
+	area, err := tx.NewArea(data.Area{Title: a.Title, Body: a.Body})
+	if err != nil {
+		return area, err
+	}
+	pos := 1
+	for _, l := range a.Lists {
+		list, err := tx.NewList(data.List{
+			Title: l.Title,
+			Body:  l.Body,
+			State: l.State,
+		})
+		if err != nil {
+			return area, err
+		}
+		// items are added to the top, so the last one comes first
+		for i := len(l.Items) - 1; i >= 0; i-- {
+			_, err = tx.NewListItem(list.ID, dataItem(l.Items[i]))
+			if err != nil {
+				return area, err
+			}
+		}
+		err = tx.SetAreaThingPosition(area.ID, data.TypeList, list.ID, pos)
+		if err != nil {
+			return area, err
+		}
+		pos++
+	}
+	for _, i := range a.Items {
+		item, err := tx.NewAreaItem(area.ID, dataItem(i))
+		if err != nil {
+			return area, err
+		}
+		err = tx.SetAreaThingPosition(area.ID, data.TypeItem, item.ID, pos)
+		if err != nil {
+			return area, err
+		}
+		pos++
+	}
+	return area, nil
+}
+
+func dataItem(i Item) data.Item {
+	return data.Item{
+		Title: i.Title,
+		Body:  i.Body,
+		State: i.State,
+	}
+}
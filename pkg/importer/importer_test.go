@@ -0,0 +1,183 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package importer
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mbertschler/bunny/pkg/data"
+)
+
+func parseFile(t *testing.T, format, path string) *Result {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	res, err := Parse(format, path, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestTrello(t *testing.T) {
+	res := parseFile(t, FormatTrello, "testdata/trello.json")
+	want := []Area{{
+		Title: "Website relaunch",
+		Body:  "Everything for the new website",
+		Lists: []List{
+			{Title: "To Do", State: data.ItemOpen, Items: []Item{
+				{Title: "Pick a domain", Body: "bunny.example.com?", State: data.ItemOpen},
+				{Title: "Write copy", Body: "### Pages\n- [x] Home\n- [ ] About", State: data.ItemOpen},
+			}},
+			{Title: "Ideas", State: data.ItemArchived, Items: []Item{
+				{Title: "Blog", State: data.ItemArchived},
+			}},
+			{Title: "Done", State: data.ItemOpen, Items: []Item{
+				{Title: "Launch", State: data.ItemComplete},
+			}},
+		},
+	}}
+	if !reflect.DeepEqual(res.Areas, want) {
+		t.Errorf("got %+v\nwant %+v", res.Areas, want)
+	}
+	wantSkipped := map[string]int{
+		"card due dates":   2,
+		"card labels":      1,
+		"card members":     1,
+		"card attachments": 1,
+		"card comments":    1,
+	}
+	if !reflect.DeepEqual(res.Skipped, wantSkipped) {
+		t.Error("skipped", res.Skipped, "want", wantSkipped)
+	}
+	if len(res.Warnings) != 1 || !strings.Contains(res.Warnings[0], "Lost card") {
+		t.Error("expected a warning about the lost card", res.Warnings)
+	}
+}
+
+func TestTodoist(t *testing.T) {
+	res := parseFile(t, FormatTodoist, "testdata/Home.csv")
+	want := []Area{{
+		Title: "Home",
+		Items: []Item{{Title: "Buy milk", State: data.ItemOpen}},
+		Lists: []List{
+			{Title: "Weekend", State: data.ItemOpen, Items: []Item{
+				{Title: "Clean the garage", Body: "Start with the shelves\n\nBring boxes, lots of them", State: data.ItemOpen},
+				{Title: "Sort screws", State: data.ItemOpen},
+			}},
+			{Title: "Later", State: data.ItemOpen, Items: []Item{
+				{Title: "Paint the fence", State: data.ItemOpen},
+			}},
+		},
+	}}
+	if !reflect.DeepEqual(res.Areas, want) {
+		t.Errorf("got %+v\nwant %+v", res.Areas, want)
+	}
+	for _, f := range []string{"task priorities", "task due dates", "task assignees"} {
+		if res.Skipped[f] != 1 {
+			t.Error("expected", f, "to be skipped once", res.Skipped)
+		}
+	}
+
+	_, err := Parse(FormatTodoist, "x.csv", strings.NewReader("NAME,VALUE\n"))
+	if !data.IsInvalid(err) {
+		t.Error("CSV without TYPE column should be invalid", err)
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	res := parseFile(t, FormatMarkdown, "testdata/checklist.md")
+	want := []Area{{
+		Title: "Moving",
+		Items: []Item{
+			{Title: "Cancel the internet contract", Body: "Call before the 15th", State: data.ItemOpen},
+			{Title: "Find a van", State: data.ItemComplete},
+		},
+		Lists: []List{
+			{Title: "Packing", State: data.ItemOpen, Items: []Item{
+				{Title: "Kitchen", State: data.ItemOpen},
+				{Title: "Plates", State: data.ItemComplete},
+				{Title: "Books", State: data.ItemOpen},
+			}},
+		},
+	}}
+	if !reflect.DeepEqual(res.Areas, want) {
+		t.Errorf("got %+v\nwant %+v", res.Areas, want)
+	}
+	if len(res.Skipped) != 2 {
+		t.Error("expected the note and the nesting to be skipped", res.Skipped)
+	}
+
+	_, err := Parse(FormatMarkdown, "x.md", strings.NewReader("just text\n"))
+	if !data.IsInvalid(err) {
+		t.Error("Markdown without checklist should be invalid", err)
+	}
+}
+
+func TestReport(t *testing.T) {
+	res := parseFile(t, FormatMarkdown, "testdata/checklist.md")
+	var buf bytes.Buffer
+	err := res.WriteReport(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"markdown import: 1 areas, 1 lists, 5 items",
+		`list "Packing"`, "[x] Find a van", "skipped fields:"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("report should contain %q:\n%s", s, buf.String())
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	res := parseFile(t, FormatTrello, "testdata/trello.json")
+	areas, err := res.Apply(data.Tx{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(areas) != 1 {
+		t.Fatal("expected one area", areas)
+	}
+	_, things, err := data.UserArea(1, areas[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(things) != 3 {
+		t.Fatal("expected 3 lists in the area", things)
+	}
+	todo, ok := things[0].(data.List)
+	if !ok || todo.Title != "To Do" {
+		t.Fatal("first thing should be the To Do list", things[0])
+	}
+	items, err := data.ItemList(todo.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].Title != "Pick a domain" || items[1].Title != "Write copy" {
+		t.Error("items should keep their order", items)
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+	_, err := Parse("asana", "x", strings.NewReader(""))
+	if !data.IsInvalid(err) {
+		t.Error("unknown formats should be invalid", err)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package importer
+
+import (
+	"bufio"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/mbertschler/bunny/pkg/data"
+)
+
+var (
+	checkboxLine = regexp.MustCompile(`^(\s*)[-*+] \[([ xX])\] (.*)$`)
+	headingLine  = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+)
+
+// parseMarkdown maps a Markdown checklist to an area. The first
+// top level heading is the title of the area, other headings start
+// a new list. Indented lines after a checklist item are added to
+// its description.
+func parseMarkdown(res *Result, name string, r io.Reader) error {
+	area := Area{}
+	var last *Item
+	lineNo := 0
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimRight(sc.Text(), " \t\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if m := checkboxLine.FindStringSubmatch(line); m != nil {
+			if m[1] != "" {
+				res.skip("checklist nesting, nested items are imported as normal items")
+			}
+			item := Item{Title: strings.TrimSpace(m[3]), State: data.ItemOpen}
+			if m[2] != " " {
+				item.State = data.ItemComplete
+			}
+			if n := len(area.Lists); n > 0 {
+				area.Lists[n-1].Items = append(area.Lists[n-1].Items, item)
+				last = &area.Lists[n-1].Items[len(area.Lists[n-1].Items)-1]
+			} else {
+				area.Items = append(area.Items, item)
+				last = &area.Items[len(area.Items)-1]
+			}
+			continue
+		}
+		if m := headingLine.FindStringSubmatch(line); m != nil {
+			last = nil
+			if len(m[1]) == 1 && area.Title == "" && len(area.Lists) == 0 && len(area.Items) == 0 {
+				area.Title = m[2]
+				continue
+			}
+			area.Lists = append(area.Lists, List{Title: m[2], State: data.ItemOpen})
+			continue
+		}
+		if last != nil && (line[0] == ' ' || line[0] == '\t') {
+			if last.Body != "" {
+				last.Body += "\n"
+			}
+			last.Body += strings.TrimSpace(line)
+			continue
+		}
+		res.skip("lines that are not checklist items or headings")
+	}
+	if err := sc.Err(); err != nil {
+		return invalid("invalid Markdown: %v", err)
+	}
+	if area.Title == "" {
+		area.Title = strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+	}
+	if area.Title == "" || area.Title == "." {
+		area.Title = "Markdown import"
+	}
+	if len(area.Lists) == 0 && len(area.Items) == 0 {
+		return invalid("no checklist items found")
+	}
+	res.Areas = append(res.Areas, area)
+	return nil
+}
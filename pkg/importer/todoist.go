@@ -0,0 +1,127 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package importer
+
+import (
+	"encoding/csv"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mbertschler/bunny/pkg/data"
+)
+
+// parseTodoist maps a Todoist project CSV export to an area. Tasks
+// before the first section become items of the area, sections become
+// lists and notes are added to the description of their task.
+func parseTodoist(res *Result, name string, r io.Reader) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err == io.EOF {
+		return invalid("invalid Todoist export: the file is empty")
+	}
+	if err != nil {
+		return invalid("invalid Todoist export: %v", err)
+	}
+	cols := map[string]int{}
+	for i, h := range header {
+		cols[strings.ToUpper(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))] = i
+	}
+	if _, ok := cols["TYPE"]; !ok {
+		return invalid("invalid Todoist export: TYPE column is missing")
+	}
+	if _, ok := cols["CONTENT"]; !ok {
+		return invalid("invalid Todoist export: CONTENT column is missing")
+	}
+
+	area := Area{Title: projectName(name)}
+	// last is the item that notes are added to
+	var last *Item
+	line := 1
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			return invalid("invalid Todoist export: %v", err)
+		}
+		get := func(col string) string {
+			i, ok := cols[col]
+			if !ok || i >= len(rec) {
+				return ""
+			}
+			return strings.TrimSpace(rec[i])
+		}
+		content := get("CONTENT")
+		switch strings.ToLower(get("TYPE")) {
+		case "":
+			continue
+		case "section":
+			area.Lists = append(area.Lists, List{Title: content, State: data.ItemOpen})
+			last = nil
+		case "task":
+			item := Item{
+				Title: content,
+				Body:  get("DESCRIPTION"),
+				State: data.ItemOpen,
+			}
+			if p := get("PRIORITY"); p != "" && p != "4" {
+				res.skip("task priorities")
+			}
+			if get("DATE") != "" {
+				res.skip("task due dates")
+			}
+			if get("RESPONSIBLE") != "" {
+				res.skip("task assignees")
+			}
+			if indent, _ := strconv.Atoi(get("INDENT")); indent > 1 {
+				res.skip("sub-task nesting, sub-tasks are imported as normal items")
+			}
+			if n := len(area.Lists); n > 0 {
+				area.Lists[n-1].Items = append(area.Lists[n-1].Items, item)
+				last = &area.Lists[n-1].Items[len(area.Lists[n-1].Items)-1]
+			} else {
+				area.Items = append(area.Items, item)
+				last = &area.Items[len(area.Items)-1]
+			}
+		case "note":
+			if last == nil {
+				res.warn("line %d: note without a task", line)
+				continue
+			}
+			if last.Body != "" {
+				last.Body += "\n\n"
+			}
+			last.Body += content
+		default:
+			res.warn("line %d: unknown type %q", line, get("TYPE"))
+		}
+	}
+	res.Areas = append(res.Areas, area)
+	return nil
+}
+
+// projectName returns the project name from the name of an
+// exported file like "Groceries.csv".
+func projectName(name string) string {
+	name = strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+	if name == "" || name == "." {
+		return "Todoist import"
+	}
+	return name
+}
@@ -0,0 +1,169 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package importer
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/mbertschler/bunny/pkg/data"
+)
+
+// trelloBoard is the part of a Trello board JSON export that is
+// read by the importer.
+type trelloBoard struct {
+	Name       string
+	Desc       string
+	Closed     bool
+	Lists      []trelloList
+	Cards      []trelloCard
+	Checklists []trelloChecklist
+	Actions    []struct {
+		Type string
+	}
+}
+
+type trelloList struct {
+	ID     string
+	Name   string
+	Closed bool
+	Pos    float64
+}
+
+type trelloCard struct {
+	ID           string
+	Name         string
+	Desc         string
+	Closed       bool
+	IDList       string
+	Pos          float64
+	Due          string
+	DueComplete  bool
+	Labels       []json.RawMessage
+	IDMembers    []string
+	IDChecklists []string
+	Badges       struct {
+		Attachments int
+	}
+}
+
+type trelloChecklist struct {
+	ID         string
+	IDCard     string
+	Name       string
+	Pos        float64
+	CheckItems []struct {
+		Name  string
+		State string
+		Pos   float64
+	}
+}
+
+// parseTrello maps a board to an area, its lists to lists and its
+// cards to items. Checklists are added to the item description.
+func parseTrello(res *Result, r io.Reader) error {
+	var b trelloBoard
+	err := json.NewDecoder(r).Decode(&b)
+	if err != nil {
+		return invalid("invalid Trello export: %v", err)
+	}
+	if b.Name == "" && len(b.Lists) == 0 {
+		return invalid("invalid Trello export: no board name and no lists")
+	}
+	if b.Closed {
+		res.skip("board closed state")
+	}
+
+	checklists := map[string][]trelloChecklist{}
+	for _, c := range b.Checklists {
+		checklists[c.IDCard] = append(checklists[c.IDCard], c)
+	}
+	for _, a := range b.Actions {
+		if a.Type == "commentCard" {
+			res.skip("card comments")
+		}
+	}
+
+	sort.SliceStable(b.Lists, func(i, j int) bool { return b.Lists[i].Pos < b.Lists[j].Pos })
+	sort.SliceStable(b.Cards, func(i, j int) bool { return b.Cards[i].Pos < b.Cards[j].Pos })
+
+	area := Area{Title: b.Name, Body: b.Desc}
+	index := map[string]int{}
+	for _, l := range b.Lists {
+		list := List{Title: l.Name, State: data.ItemOpen}
+		if l.Closed {
+			list.State = data.ItemArchived
+		}
+		index[l.ID] = len(area.Lists)
+		area.Lists = append(area.Lists, list)
+	}
+	for _, c := range b.Cards {
+		i, ok := index[c.IDList]
+		if !ok {
+			res.warn("card %q is in unknown list %q", c.Name, c.IDList)
+			continue
+		}
+		item := Item{
+			Title: c.Name,
+			Body:  c.Desc,
+			State: data.ItemOpen,
+		}
+		switch {
+		case c.Closed:
+			item.State = data.ItemArchived
+		case c.DueComplete:
+			item.State = data.ItemComplete
+		}
+		if c.Due != "" {
+			res.skip("card due dates")
+		}
+		if len(c.Labels) > 0 {
+			res.skip("card labels")
+		}
+		if len(c.IDMembers) > 0 {
+			res.skip("card members")
+		}
+		if c.Badges.Attachments > 0 {
+			res.skip("card attachments")
+		}
+		item.Body = appendChecklists(item.Body, checklists[c.ID])
+		area.Lists[i].Items = append(area.Lists[i].Items, item)
+	}
+	res.Areas = append(res.Areas, area)
+	return nil
+}
+
+// appendChecklists adds the checklists as Markdown to the body.
+func appendChecklists(body string, lists []trelloChecklist) string {
+	sort.SliceStable(lists, func(i, j int) bool { return lists[i].Pos < lists[j].Pos })
+	var parts []string
+	if body != "" {
+		parts = append(parts, body)
+	}
+	for _, l := range lists {
+		sort.SliceStable(l.CheckItems, func(i, j int) bool { return l.CheckItems[i].Pos < l.CheckItems[j].Pos })
+		lines := []string{"### " + l.Name}
+		for _, ci := range l.CheckItems {
+			box := "- [ ] "
+			if ci.State == "complete" {
+				box = "- [x] "
+			}
+			lines = append(lines, box+ci.Name)
+		}
+		parts = append(parts, strings.Join(lines, "\n"))
+	}
+	return strings.Join(parts, "\n\n")
+}
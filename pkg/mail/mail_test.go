@@ -0,0 +1,200 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mail
+
+import (
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/mbertschler/bunny/pkg/data"
+)
+
+func TestParseMailboxes(t *testing.T) {
+	got, err := ParseMailboxes(" Inbox=1:list/1, ideas=2:area/3 ,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Mailbox{
+		{Address: "inbox", User: 1, List: 1},
+		{Address: "ideas", User: 2, Area: 3},
+	}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Error("got", got, "want", want)
+	}
+	for _, str := range []string{"inbox", "=1:list/1", "inbox=1", "inbox=x:list/1",
+		"inbox=1:list", "inbox=1:list/0", "inbox=1:focus/1"} {
+		_, err := ParseMailboxes(str)
+		if err == nil {
+			t.Error(str, "should be invalid")
+		}
+	}
+}
+
+func TestParseSenders(t *testing.T) {
+	got, err := ParseSenders("Martin@Example.com=1,ops@example.com=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got["martin@example.com"] != 1 || got["ops@example.com"] != 2 {
+		t.Error("unexpected senders", got)
+	}
+	for _, str := range []string{"martin@example.com", "martin=1", "a@b.c=0"} {
+		_, err := ParseSenders(str)
+		if err == nil {
+			t.Error(str, "should be invalid")
+		}
+	}
+}
+
+func TestParseMessage(t *testing.T) {
+	cases := []struct {
+		name, raw   string
+		title, body string
+	}{
+		{
+			name:  "plain",
+			raw:   "From: Martin <martin@example.com>\r\nSubject: Call the plumber\r\n\r\nThe sink is leaking.\r\n",
+			title: "Call the plumber",
+			body:  "The sink is leaking.",
+		},
+		{
+			name:  "encoded subject and quoted-printable",
+			raw:   "From: martin@example.com\r\nSubject: =?UTF-8?Q?Gr=C3=BC=C3=9Fe?=\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\nSch=C3=B6ne =\r\nGr=C3=BC=C3=9Fe\r\n",
+			title: "Grüße",
+			body:  "Schöne Grüße",
+		},
+		{
+			name: "multipart",
+			raw: "From: martin@example.com\r\nSubject: Report\r\nMIME-Version: 1.0\r\nContent-Type: multipart/alternative; boundary=XYZ\r\n\r\n" +
+				"--XYZ\r\nContent-Type: text/html\r\n\r\n<p>html</p>\r\n" +
+				"--XYZ\r\nContent-Type: text/plain\r\nContent-Transfer-Encoding: base64\r\n\r\ncGxhaW4g\r\ndGV4dA==\r\n" +
+				"--XYZ--\r\n",
+			title: "Report",
+			body:  "plain text",
+		},
+		{
+			name:  "no subject",
+			raw:   "From: martin@example.com\r\n\r\nbody\r\n",
+			title: "(no subject)",
+			body:  "body",
+		},
+	}
+	for _, c := range cases {
+		m, err := parseMessage(strings.NewReader(c.raw))
+		if err != nil {
+			t.Error(c.name, err)
+			continue
+		}
+		if m.From != "martin@example.com" || m.Title != c.title || m.Body != c.body {
+			t.Errorf("%s: got %+v", c.name, m)
+		}
+	}
+	_, err := parseMessage(strings.NewReader("From: a@b.c\r\nContent-Type: text/html\r\n\r\n<p>x</p>"))
+	if err != errNoText {
+		t.Error("html only mail should fail, got", err)
+	}
+}
+
+func startServer(t *testing.T, maxSize int64) (*Server, string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{
+		Domain:  "bunny.test",
+		MaxSize: maxSize,
+		Mailboxes: []Mailbox{
+			{Address: "inbox", User: 1, List: 1},
+			{Address: "area", User: 1, Area: 1},
+			{Address: "other", User: 2, List: 1},
+		},
+		Senders: map[string]int{
+			"martin@example.com": 1,
+			"eve@example.com":    2,
+		},
+	}
+	go s.Serve(l)
+	return s, l.Addr().String()
+}
+
+func send(addr, from, to, msg string) error {
+	return smtp.SendMail(addr, nil, from, []string{to}, []byte(msg))
+}
+
+func TestServer(t *testing.T) {
+	s, addr := startServer(t, 0)
+	defer s.Close()
+
+	msg := "From: martin@example.com\r\nSubject: From the inbox\r\n\r\nSent by mail.\r\n"
+	err := send(addr, "martin@example.com", "Inbox@bunny.test", msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := data.ItemList(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if items[0].Title != "From the inbox" || items[0].Body != "Sent by mail." {
+		t.Error("mail should be the first item of list 1", items[0])
+	}
+
+	msg = "From: martin@example.com\r\nSubject: To the area\r\n\r\nx\r\n"
+	err = send(addr, "martin@example.com", "area@bunny.test", msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, things, err := data.UserArea(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item, ok := things[0].(data.Item); !ok || item.Title != "To the area" {
+		t.Error("mail should be the first thing of area 1", things[0])
+	}
+}
+
+func TestServerRejects(t *testing.T) {
+	s, addr := startServer(t, 0)
+	defer s.Close()
+
+	msg := "From: martin@example.com\r\nSubject: x\r\n\r\nx\r\n"
+	cases := []struct {
+		name, from, to, msg string
+	}{
+		{"unknown sender", "mallory@example.com", "inbox@bunny.test", msg},
+		{"unknown mailbox", "martin@example.com", "nope@bunny.test", msg},
+		{"other domain", "martin@example.com", "inbox@example.com", msg},
+		{"mailbox of other user", "eve@example.com", "inbox@bunny.test", msg},
+		{"From header of other user", "eve@example.com", "other@bunny.test", msg},
+	}
+	for _, c := range cases {
+		err := send(addr, c.from, c.to, c.msg)
+		if e, ok := err.(*textproto.Error); !ok || e.Code/100 != 5 {
+			t.Error(c.name, "should be rejected permanently, got", err)
+		}
+	}
+}
+
+func TestServerMaxSize(t *testing.T) {
+	s, addr := startServer(t, 100)
+	defer s.Close()
+
+	msg := "From: martin@example.com\r\nSubject: big\r\n\r\n" + strings.Repeat("x", 200) + "\r\n"
+	err := send(addr, "martin@example.com", "inbox@bunny.test", msg)
+	if e, ok := err.(*textproto.Error); !ok || e.Code != 552 {
+		t.Error("big mail should be rejected with 552, got", err)
+	}
+}
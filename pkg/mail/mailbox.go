@@ -0,0 +1,104 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mail implements an SMTP listener that turns incoming mail
+// into items. Each mailbox address belongs to a user and routes the
+// mail to a list or an area. Only mail from addresses that are
+// registered as senders of the mailbox user is accepted.
+//
+// The sender is not authenticated, so the listener should only be
+// reachable through a mail server that checks SPF and DKIM.
+package mail
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Mailbox routes mail that is sent to Address to a list or an area.
+type Mailbox struct {
+	Address string // local part of the address, "inbox" for inbox@domain
+	User    int
+	List    int // either List or Area is set
+	Area    int
+}
+
+// ParseMailboxes parses a comma separated list of mailboxes in
+// the form address=user:list/id or address=user:area/id.
+func ParseMailboxes(str string) ([]Mailbox, error) {
+	var out []Mailbox
+	for _, part := range splitList(str) {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("mailbox %q has to be address=user:list/id or address=user:area/id", part)
+		}
+		m := Mailbox{Address: strings.ToLower(kv[0])}
+		userTarget := strings.SplitN(kv[1], ":", 2)
+		if len(userTarget) != 2 {
+			return nil, fmt.Errorf("mailbox %q has no user", part)
+		}
+		var err error
+		m.User, err = strconv.Atoi(userTarget[0])
+		if err != nil || m.User < 1 {
+			return nil, fmt.Errorf("mailbox %q has an invalid user", part)
+		}
+		target := strings.SplitN(userTarget[1], "/", 2)
+		var id int
+		if len(target) == 2 {
+			id, err = strconv.Atoi(target[1])
+		}
+		if len(target) != 2 || err != nil || id < 1 {
+			return nil, fmt.Errorf("mailbox %q has an invalid target", part)
+		}
+		switch target[0] {
+		case "list":
+			m.List = id
+		case "area":
+			m.Area = id
+		default:
+			return nil, fmt.Errorf("mailbox %q has to target a list or an area", part)
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+// ParseSenders parses a comma separated list of sender=user pairs
+// into a map from the lower case sender address to the user ID.
+func ParseSenders(str string) (map[string]int, error) {
+	out := map[string]int{}
+	for _, part := range splitList(str) {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 || !strings.Contains(kv[0], "@") {
+			return nil, fmt.Errorf("sender %q has to be address=user", part)
+		}
+		user, err := strconv.Atoi(kv[1])
+		if err != nil || user < 1 {
+			return nil, fmt.Errorf("sender %q has an invalid user", part)
+		}
+		out[strings.ToLower(kv[0])] = user
+	}
+	return out, nil
+}
+
+func splitList(str string) []string {
+	var out []string
+	for _, part := range strings.Split(str, ",") {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -0,0 +1,136 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mail
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+)
+
+// maxTitle is the maximum length of an item title in characters.
+const maxTitle = 500
+
+// message is the part of a mail that is used for an item.
+type message struct {
+	From  string // lower case address from the From header
+	Title string
+	Body  string
+}
+
+var errNoText = errors.New("mail has no text/plain part")
+
+func parseMessage(r io.Reader) (message, error) {
+	var m message
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return m, err
+	}
+	from, err := mail.ParseAddress(msg.Header.Get("From"))
+	if err != nil {
+		return m, err
+	}
+	m.From = strings.ToLower(from.Address)
+
+	dec := new(mime.WordDecoder)
+	subject := msg.Header.Get("Subject")
+	if decoded, err := dec.DecodeHeader(subject); err == nil {
+		subject = decoded
+	}
+	m.Title = truncate(strings.TrimSpace(subject), maxTitle)
+	if m.Title == "" {
+		m.Title = "(no subject)"
+	}
+
+	body, err := textBody(msg.Header.Get("Content-Type"),
+		msg.Header.Get("Content-Transfer-Encoding"), msg.Body)
+	if err != nil {
+		return m, err
+	}
+	m.Body = strings.TrimSpace(strings.Replace(body, "\r\n", "\n", -1))
+	return m, nil
+}
+
+// textBody returns the first text/plain part of the body.
+func textBody(contentType, encoding string, body io.Reader) (string, error) {
+	if contentType == "" {
+		contentType = "text/plain"
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return "", errNoText
+			}
+			if err != nil {
+				return "", err
+			}
+			text, err := textBody(part.Header.Get("Content-Type"),
+				part.Header.Get("Content-Transfer-Encoding"), part)
+			if err == errNoText {
+				continue
+			}
+			return text, err
+		}
+	}
+	if mediaType != "text/plain" {
+		return "", errNoText
+	}
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, &newlineSkipper{r: body})
+	}
+	buf, err := ioutil.ReadAll(body)
+	return string(buf), err
+}
+
+// newlineSkipper removes line breaks from base64 encoded bodies.
+type newlineSkipper struct {
+	r io.Reader
+}
+
+func (n *newlineSkipper) Read(p []byte) (int, error) {
+	c, err := n.r.Read(p)
+	out := p[:0]
+	for _, b := range p[:c] {
+		if b != '\r' && b != '\n' {
+			out = append(out, b)
+		}
+	}
+	return len(out), err
+}
+
+func truncate(s string, max int) string {
+	n := 0
+	for i := range s {
+		if n == max {
+			return s[:i]
+		}
+		n++
+	}
+	return s
+}
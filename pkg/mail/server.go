@@ -0,0 +1,294 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mail
+
+import (
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mbertschler/bunny/pkg/data"
+)
+
+// DefaultMaxSize is the default size limit of a mail in bytes.
+const DefaultMaxSize = 10 << 20
+
+// idleTimeout closes connections that don't send a command.
+const idleTimeout = 5 * time.Minute
+
+// Server is an SMTP server that only accepts mail for its mailboxes.
+type Server struct {
+	Domain    string         // domain of the mailbox addresses, any domain if empty
+	Mailboxes []Mailbox      // addresses that accept mail
+	Senders   map[string]int // sender address to user ID
+	MaxSize   int64          // defaults to DefaultMaxSize
+
+	mu       sync.Mutex
+	listener net.Listener
+}
+
+// ListenAndServe listens on the TCP address addr and serves
+// SMTP connections until Close is called.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l until Close is called.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	s.listener = l
+	s.mu.Unlock()
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.serveConn(c)
+	}
+}
+
+// Close stops accepting new connections.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+func (s *Server) domain() string {
+	if s.Domain == "" {
+		return "localhost"
+	}
+	return s.Domain
+}
+
+func (s *Server) maxSize() int64 {
+	if s.MaxSize <= 0 {
+		return DefaultMaxSize
+	}
+	return s.MaxSize
+}
+
+// mailbox returns the mailbox for a recipient address.
+func (s *Server) mailbox(rcpt string) (Mailbox, bool) {
+	at := strings.LastIndex(rcpt, "@")
+	if at < 0 {
+		return Mailbox{}, false
+	}
+	local, domain := strings.ToLower(rcpt[:at]), rcpt[at+1:]
+	if s.Domain != "" && !strings.EqualFold(domain, s.Domain) {
+		return Mailbox{}, false
+	}
+	for _, m := range s.Mailboxes {
+		if m.Address == local {
+			return m, true
+		}
+	}
+	return Mailbox{}, false
+}
+
+// session is the state of one SMTP transaction.
+type session struct {
+	helo  bool
+	from  string
+	user  int
+	rcpts []Mailbox
+}
+
+func (s *session) reset() {
+	s.from = ""
+	s.user = 0
+	s.rcpts = nil
+}
+
+func (s *Server) serveConn(c net.Conn) {
+	defer c.Close()
+	tp := textproto.NewConn(c)
+	var sess session
+	reply := func(format string, args ...interface{}) bool {
+		err := tp.PrintfLine(format, args...)
+		if err != nil {
+			log.Println("mail error:", err)
+		}
+		return err == nil
+	}
+	if !reply("220 %s ESMTP bunny", s.domain()) {
+		return
+	}
+	for {
+		c.SetDeadline(time.Now().Add(idleTimeout))
+		line, err := tp.ReadLine()
+		if err != nil {
+			if err != io.EOF {
+				log.Println("mail error:", err)
+			}
+			return
+		}
+		verb, arg := line, ""
+		if i := strings.IndexByte(line, ' '); i >= 0 {
+			verb, arg = line[:i], strings.TrimSpace(line[i+1:])
+		}
+		var ok bool
+		switch strings.ToUpper(verb) {
+		case "HELO":
+			sess.helo = true
+			sess.reset()
+			ok = reply("250 %s", s.domain())
+		case "EHLO":
+			sess.helo = true
+			sess.reset()
+			ok = reply("250-%s\r\n250-SIZE %d\r\n250 8BITMIME", s.domain(), s.maxSize())
+		case "MAIL":
+			ok = reply("%s", s.mail(&sess, arg))
+		case "RCPT":
+			ok = reply("%s", s.rcpt(&sess, arg))
+		case "DATA":
+			ok = s.data(tp, &sess, reply)
+		case "RSET":
+			sess.reset()
+			ok = reply("250 2.0.0 OK")
+		case "NOOP":
+			ok = reply("250 2.0.0 OK")
+		case "VRFY":
+			ok = reply("252 2.1.5 Cannot verify user")
+		case "QUIT":
+			reply("221 2.0.0 Bye")
+			return
+		default:
+			ok = reply("502 5.5.2 Command not implemented")
+		}
+		if !ok {
+			return
+		}
+	}
+}
+
+func (s *Server) mail(sess *session, arg string) string {
+	if !sess.helo {
+		return "503 5.5.1 Send HELO or EHLO first"
+	}
+	if sess.from != "" {
+		return "503 5.5.1 Sender already specified"
+	}
+	from, ok := pathArg(arg, "FROM:")
+	if !ok {
+		return "501 5.5.4 Syntax: MAIL FROM:<address>"
+	}
+	user, ok := s.Senders[strings.ToLower(from)]
+	if !ok {
+		log.Printf("mail: rejected unknown sender %q", from)
+		return "550 5.7.1 Sender is not allowed to send mail here"
+	}
+	sess.from = strings.ToLower(from)
+	sess.user = user
+	return "250 2.1.0 OK"
+}
+
+func (s *Server) rcpt(sess *session, arg string) string {
+	if sess.from == "" {
+		return "503 5.5.1 Send MAIL first"
+	}
+	to, ok := pathArg(arg, "TO:")
+	if !ok {
+		return "501 5.5.4 Syntax: RCPT TO:<address>"
+	}
+	m, ok := s.mailbox(to)
+	if !ok {
+		return "550 5.1.1 Mailbox does not exist"
+	}
+	if m.User != sess.user {
+		log.Printf("mail: rejected sender %q for mailbox %q", sess.from, to)
+		return "550 5.7.1 Sender is not allowed to send mail to this mailbox"
+	}
+	sess.rcpts = append(sess.rcpts, m)
+	return "250 2.1.5 OK"
+}
+
+func (s *Server) data(tp *textproto.Conn, sess *session, reply func(string, ...interface{}) bool) bool {
+	if len(sess.rcpts) == 0 {
+		return reply("503 5.5.1 Send RCPT first")
+	}
+	if !reply("354 End data with <CR><LF>.<CR><LF>") {
+		return false
+	}
+	dot := tp.DotReader()
+	body := io.LimitReader(dot, s.maxSize()+1)
+	buf, err := ioutil.ReadAll(body)
+	if err != nil {
+		return false
+	}
+	// read the rest of the message to stay in sync with the client
+	n, _ := io.Copy(ioutil.Discard, dot)
+	defer sess.reset()
+	if int64(len(buf)) > s.maxSize() || n > 0 {
+		return reply("552 5.3.4 Message too big")
+	}
+	msg, err := parseMessage(strings.NewReader(string(buf)))
+	if err != nil {
+		log.Println("mail: invalid message:", err)
+		return reply("554 5.6.0 Invalid message: %s", err)
+	}
+	if user, ok := s.Senders[msg.From]; !ok || user != sess.user {
+		log.Printf("mail: rejected From header %q of sender %q", msg.From, sess.from)
+		return reply("550 5.7.1 From address is not allowed")
+	}
+	for _, m := range sess.rcpts {
+		item, err := deliver(m, msg)
+		if err != nil {
+			log.Println("mail error:", err)
+			return reply("451 4.3.0 Could not save the message")
+		}
+		log.Printf("mail: created item %d from %q", item.ID, sess.from)
+	}
+	return reply("250 2.0.0 OK")
+}
+
+func deliver(m Mailbox, msg message) (data.Item, error) {
+	in := data.Item{
+		Title: msg.Title,
+		Body:  msg.Body,
+	}
+	if m.Area != 0 {
+		return data.NewAreaItem(m.Area, in)
+	}
+	return data.NewListItem(m.List, in)
+}
+
+// pathArg parses arguments like "FROM:<a@b.c> SIZE=123".
+func pathArg(arg, prefix string) (string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", false
+	}
+	arg = strings.TrimSpace(arg[len(prefix):])
+	if !strings.HasPrefix(arg, "<") {
+		return "", false
+	}
+	end := strings.IndexByte(arg, '>')
+	if end < 0 {
+		return "", false
+	}
+	addr := arg[1:end]
+	return addr, addr != ""
+}
@@ -0,0 +1,220 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package router
+
+import (
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/mbertschler/bunny/pkg/api"
+	"github.com/mbertschler/bunny/pkg/data"
+)
+
+// tokenAuth only lets requests through that carry a valid API token
+// in an "Authorization: Bearer <token>" header. The token is passed
+// on in the request context. Tokens with the read scope can only be
+// used for GET and HEAD requests.
+func tokenAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret, _ := bearerToken(r)
+		token, ok := authenticate(w, r, secret)
+		if !ok {
+			return
+		}
+		if !token.Scope.Allows(requiredScope(r)) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="bunny", error="insufficient_scope"`)
+			api.WriteError(w, r, api.Error{
+				Status:  http.StatusForbidden,
+				Code:    "insufficientScope",
+				Message: "the API token is read only",
+			})
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(api.WithToken(r.Context(), token)))
+	})
+}
+
+// localOrToken protects the GUI API, which can create API tokens,
+// and the export of the whole workspace. The GUI has no login, so
+// only requests from the machine that runs the server and from the
+// trusted networks are trusted. Other requests need an API token like
+// requests to the API.
+func localOrToken(next http.Handler, trusted []*net.IPNet) http.Handler {
+	auth := tokenAuth(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isLocal(r, trusted) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		auth.ServeHTTP(w, r)
+	})
+}
+
+// isLocal reports if the request comes from a loopback address or a
+// trusted network. Requests forwarded by a proxy are never local,
+// even if the proxy runs on the same machine. The Host has to be
+// localhost or a trusted address too, so that pages of other hosts
+// can't use DNS rebinding. Requests that change data have to be JSON
+// and can only come from pages of the same host, so that other pages
+// in the browser can't send them.
+func isLocal(r *http.Request, trusted []*net.IPNet) bool {
+	if r.Header.Get("X-Forwarded-For") != "" || r.Header.Get("Forwarded") != "" {
+		return false
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil || !trustedIP(net.ParseIP(host), trusted) || !trustedHost(r.Host, trusted) {
+		return false
+	}
+	switch r.Method {
+	case "GET", "HEAD":
+		return true
+	}
+	return sameOrigin(r) && isJSON(r)
+}
+
+func trustedIP(ip net.IP, trusted []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	if ip.IsLoopback() {
+		return true
+	}
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// trustedHost reports if the Host of a request is localhost or a
+// trusted IP address.
+func trustedHost(host string, trusted []*net.IPNet) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	return trustedIP(net.ParseIP(host), trusted)
+}
+
+// sameOrigin reports if the request has no Origin or one with the
+// host of the request.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+// isJSON reports if the body of the request is JSON. Browsers only
+// send other content types than form data and plain text to other
+// hosts if the server allows it.
+func isJSON(r *http.Request) bool {
+	typ, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && typ == "application/json"
+}
+
+// ParseNetworks parses a comma separated list of networks in CIDR
+// notation like "172.17.0.0/16" or single IP addresses.
+func ParseNetworks(str string) ([]*net.IPNet, error) {
+	var out []*net.IPNet
+	for _, part := range strings.Split(str, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if !strings.Contains(part, "/") {
+			ip := net.ParseIP(part)
+			if ip == nil {
+				return nil, fmt.Errorf("trusted network %q is not an IP address", part)
+			}
+			bits := 8 * len(ip)
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			out = append(out, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(part)
+		if err != nil {
+			return nil, fmt.Errorf("trusted network %q is invalid: %v", part, err)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+// authenticate looks up the token for secret. If the secret is
+// missing or the token is invalid, it writes an error response
+// and returns false.
+func authenticate(w http.ResponseWriter, r *http.Request, secret string) (data.Token, bool) {
+	if secret == "" {
+		unauthorized(w, r, "", "an API token is required")
+		return data.Token{}, false
+	}
+	token, err := data.Authenticate(secret)
+	switch {
+	case data.IsNotFound(err):
+		unauthorized(w, r, "invalid_token", "the API token is invalid")
+		return token, false
+	case data.IsForbidden(err):
+		unauthorized(w, r, "invalid_token", "the API token is expired")
+		return token, false
+	case err != nil:
+		api.WriteError(w, r, err)
+		return token, false
+	}
+	return token, true
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+		return "", false
+	}
+	token := strings.TrimSpace(parts[1])
+	return token, token != ""
+}
+
+func requiredScope(r *http.Request) data.TokenScope {
+	switch r.Method {
+	case "GET", "HEAD", "OPTIONS":
+		return data.ScopeRead
+	}
+	return data.ScopeWrite
+}
+
+// unauthorized responds with 401 Unauthorized and a challenge as
+// described in RFC 6750. code is the optional OAuth error code.
+func unauthorized(w http.ResponseWriter, r *http.Request, code, message string) {
+	challenge := `Bearer realm="bunny"`
+	if code != "" {
+		challenge += `, error="` + code + `"`
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	api.WriteError(w, r, api.Error{
+		Status:  http.StatusUnauthorized,
+		Code:    "unauthorized",
+		Message: message,
+	})
+}
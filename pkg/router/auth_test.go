@@ -0,0 +1,173 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mbertschler/bunny/pkg/data"
+)
+
+func TestTokenAuth(t *testing.T) {
+	r := Router("/")
+	_, read, err := data.NewToken(1, "read", data.ScopeRead, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, write, err := data.NewToken(1, "write", data.ScopeWrite, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, expired, err := data.NewToken(1, "expired", data.ScopeWrite, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, revokedSecret, err := data.NewToken(1, "revoked", data.ScopeWrite, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = data.RevokeToken(1, revoked.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		method, auth string
+		status       int
+	}{
+		{"GET", "", http.StatusUnauthorized},
+		{"GET", "Basic abc", http.StatusUnauthorized},
+		{"GET", "Bearer bunny_wrong", http.StatusUnauthorized},
+		{"GET", "Bearer " + expired, http.StatusUnauthorized},
+		{"GET", "Bearer " + revokedSecret, http.StatusUnauthorized},
+		{"GET", "Bearer " + read, http.StatusOK},
+		{"GET", "bearer " + write, http.StatusOK},
+		{"POST", "Bearer " + read, http.StatusForbidden},
+		{"POST", "Bearer " + write, http.StatusCreated},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, "/api/v1/lists", strings.NewReader(`{"Title":"api"}`))
+		if c.auth != "" {
+			req.Header.Set("Authorization", c.auth)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != c.status {
+			t.Error(c.method, c.auth, "returned", w.Code, "should be", c.status, w.Body.String())
+		}
+		if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+			t.Error(c.method, c.auth, "should set a WWW-Authenticate header")
+		}
+	}
+}
+
+func TestLocalOrToken(t *testing.T) {
+	trusted, err := ParseNetworks("172.17.0.1, 10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := Router("/", trusted...)
+	_, read, err := data.NewToken(1, "read", data.ScopeRead, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, write, err := data.NewToken(1, "write", data.ScopeWrite, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	createToken := `{"Actions":[{"Name":"tokenCreate","Args":{"Name":"gui","Scope":"write"}}]}`
+
+	type request struct {
+		method, path, remote, host, auth string
+		header                           map[string]string
+	}
+	jsonBody := map[string]string{"Content-Type": "application/json"}
+	cases := []struct {
+		request
+		status int
+	}{
+		{request{"GET", "/export", "192.0.2.1:1234", "localhost:3080", "", nil}, http.StatusUnauthorized},
+		{request{"GET", "/export", "192.0.2.1:1234", "localhost:3080", "Bearer " + read, nil}, http.StatusOK},
+		{request{"GET", "/export", "127.0.0.1:1234", "localhost:3080", "", nil}, http.StatusOK},
+		{request{"GET", "/export", "[::1]:1234", "[::1]:3080", "", nil}, http.StatusOK},
+		{request{"GET", "/export", "127.0.0.1:1234", "127.0.0.1", "", nil}, http.StatusOK},
+		{request{"GET", "/export", "127.0.0.1:1234", "localhost:3080", "", map[string]string{"X-Forwarded-For": "192.0.2.1"}}, http.StatusUnauthorized},
+		// DNS rebinding
+		{request{"GET", "/export", "127.0.0.1:1234", "evil.example.com:3080", "", nil}, http.StatusUnauthorized},
+		{request{"GET", "/gui/", "192.0.2.1:1234", "localhost:3080", "", nil}, http.StatusOK},
+		{request{"POST", "/gui/", "192.0.2.1:1234", "localhost:3080", "", jsonBody}, http.StatusUnauthorized},
+		{request{"POST", "/gui/", "192.0.2.1:1234", "localhost:3080", "Bearer " + read, jsonBody}, http.StatusForbidden},
+		{request{"POST", "/gui/", "192.0.2.1:1234", "localhost:3080", "Bearer " + write, jsonBody}, http.StatusOK},
+		{request{"POST", "/gui/", "127.0.0.1:1234", "localhost:3080", "", jsonBody}, http.StatusOK},
+		{request{"POST", "/gui/", "127.0.0.1:1234", "localhost:3080", "", map[string]string{
+			"Content-Type": "application/json; charset=utf-8", "Origin": "http://localhost:3080"}}, http.StatusOK},
+		{request{"POST", "/gui/", "127.0.0.1:1234", "localhost:3080", "", map[string]string{
+			"Content-Type": "application/json", "X-Forwarded-For": "192.0.2.1"}}, http.StatusUnauthorized},
+		{request{"POST", "/gui/", "127.0.0.1:1234", "evil.example.com", "", jsonBody}, http.StatusUnauthorized},
+		// other pages in the browser
+		{request{"POST", "/gui/", "127.0.0.1:1234", "localhost:3080", "", nil}, http.StatusUnauthorized},
+		{request{"POST", "/gui/", "127.0.0.1:1234", "localhost:3080", "", map[string]string{"Content-Type": "text/plain"}}, http.StatusUnauthorized},
+		{request{"POST", "/gui/", "127.0.0.1:1234", "localhost:3080", "", map[string]string{
+			"Content-Type": "application/json", "Origin": "http://example.com"}}, http.StatusUnauthorized},
+		{request{"POST", "/gui/", "127.0.0.1:1234", "localhost:3080", "", map[string]string{
+			"Content-Type": "application/json", "Origin": "null"}}, http.StatusUnauthorized},
+		// trusted networks, like the Docker bridge
+		{request{"POST", "/gui/", "172.17.0.1:1234", "localhost:3080", "", jsonBody}, http.StatusOK},
+		{request{"POST", "/gui/", "10.1.2.3:1234", "10.0.0.5:3080", "", jsonBody}, http.StatusOK},
+		{request{"POST", "/gui/", "172.17.0.2:1234", "localhost:3080", "", jsonBody}, http.StatusUnauthorized},
+		{request{"POST", "/gui/", "10.1.2.3:1234", "192.0.2.1:3080", "", jsonBody}, http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, c.path, strings.NewReader(createToken))
+		req.RemoteAddr = c.remote
+		req.Host = c.host
+		if c.auth != "" {
+			req.Header.Set("Authorization", c.auth)
+		}
+		for k, v := range c.header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != c.status {
+			t.Error(c.request, "returned", w.Code, "should be", c.status)
+		}
+	}
+}
+
+func TestParseNetworks(t *testing.T) {
+	nets, err := ParseNetworks(" 172.17.0.1, 10.0.0.0/8,,::1 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"172.17.0.1/32", "10.0.0.0/8", "::1/128"}
+	if len(nets) != len(want) {
+		t.Fatal("got networks", nets)
+	}
+	for i, n := range nets {
+		if n.String() != want[i] {
+			t.Error("got network", n, "want", want[i])
+		}
+	}
+	for _, invalid := range []string{"localhost", "10.0.0.0/33", "10.0.0"} {
+		_, err := ParseNetworks(invalid)
+		if err == nil {
+			t.Error(invalid, "should be invalid")
+		}
+	}
+}
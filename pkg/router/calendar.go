@@ -0,0 +1,117 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package router
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mbertschler/bunny/pkg/data"
+	"github.com/mbertschler/bunny/pkg/ical"
+)
+
+// calendarHandler serves the due items and the focus of a user as
+// an iCalendar feed. Calendar apps can't send headers, so the token
+// can also be passed in the token query parameter.
+func calendarHandler(w http.ResponseWriter, r *http.Request) {
+	secret := r.URL.Query().Get("token")
+	if secret == "" {
+		secret, _ = bearerToken(r)
+	}
+	token, ok := authenticate(w, r, secret)
+	if !ok {
+		return
+	}
+	cal, err := userCalendar(token.User, baseURL(r), time.Now())
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	var buf bytes.Buffer
+	err = cal.Encode(&buf)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="bunny.ics"`)
+	_, err = buf.WriteTo(w)
+	if err != nil {
+		logError(r, http.StatusOK, err)
+	}
+}
+
+// userCalendar has an all-day event for every open item with a
+// due date and a todo for every item the user focuses on now.
+// UIDs are derived from item IDs, so that calendar apps update
+// the entries instead of duplicating them.
+func userCalendar(user int, base string, now time.Time) (*ical.Calendar, error) {
+	due, err := data.DueItems()
+	if err != nil {
+		return nil, err
+	}
+	focus, err := data.FocusList(user)
+	if err != nil {
+		return nil, err
+	}
+	cal := &ical.Calendar{
+		ProdID: "-//Bunny//Bunny Calendar//EN",
+		Name:   "Bunny",
+	}
+	for _, i := range due {
+		ev := ical.NewEvent()
+		ev.Add("UID", fmt.Sprintf("item-%d@bunny", i.ID))
+		ev.AddTime("DTSTAMP", now)
+		ev.AddDate("DTSTART", i.Due)
+		ev.AddDate("DTEND", i.Due.AddDate(0, 0, 1))
+		addItemText(ev, i, base)
+		ev.Add("TRANSP", "TRANSPARENT")
+		cal.Components = append(cal.Components, ev)
+	}
+	for _, i := range focus.Focus {
+		todo := ical.NewTodo()
+		todo.Add("UID", fmt.Sprintf("focus-%d@bunny", i.ID))
+		todo.AddTime("DTSTAMP", now)
+		if i.HasDue() {
+			todo.AddDate("DUE", i.Due)
+		}
+		addItemText(todo, i, base)
+		if i.State == data.ItemOpen {
+			todo.Add("STATUS", "IN-PROCESS")
+		} else {
+			todo.Add("STATUS", "COMPLETED")
+		}
+		cal.Components = append(cal.Components, todo)
+	}
+	return cal, nil
+}
+
+func addItemText(c *ical.Component, i data.Item, base string) {
+	c.AddText("SUMMARY", i.Title)
+	if i.Body != "" {
+		c.AddText("DESCRIPTION", i.Body)
+	}
+	c.Add("URL", fmt.Sprintf("%s/item/%d", base, i.ID))
+}
+
+// baseURL returns the scheme and host that the request was sent to.
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
@@ -0,0 +1,81 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mbertschler/bunny/pkg/data"
+)
+
+func TestCalendar(t *testing.T) {
+	r := Router("/")
+	_, secret, err := data.NewToken(1, "calendar", data.ScopeRead, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	due, err := data.NewListItem(1, data.Item{
+		Title: "Pay rent, today",
+		Body:  "line one\nline two",
+		Due:   time.Date(2018, 11, 1, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	focus, err := data.NewListItem(1, data.Item{Title: "Write report"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = data.SetFocus(1, focus.ID, data.FocusNow)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/calendar.ics", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Error("calendar without token returned", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/calendar.ics?token="+secret, nil))
+	if w.Code != http.StatusOK {
+		t.Fatal("calendar returned", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/calendar") {
+		t.Error("wrong content type", ct)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		fmt.Sprintf("UID:item-%d@bunny\r\n", due.ID),
+		"DTSTART;VALUE=DATE:20181101\r\n",
+		"DTEND;VALUE=DATE:20181102\r\n",
+		`SUMMARY:Pay rent\, today` + "\r\n",
+		`DESCRIPTION:line one\nline two` + "\r\n",
+		"BEGIN:VTODO\r\n",
+		fmt.Sprintf("UID:focus-%d@bunny\r\n", focus.ID),
+		"STATUS:IN-PROCESS\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("calendar should contain %q:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2018 Martin Bertschler.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package router
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/mbertschler/blocks/html"
+	"github.com/mbertschler/bunny/pkg/blocks"
+	"github.com/mbertschler/bunny/pkg/data"
+)
+
+// pageHandler renders the returned block inside the page layout.
+// If it returns an error, an error page is rendered instead.
+type pageHandler func(r *http.Request) (html.Block, error)
+
+func (h pageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	block, err := h(r)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	err = html.Render(blocks.LayoutBlock(block), w)
+	if err != nil {
+		logError(r, http.StatusOK, err)
+	}
+}
+
+// httpError is an error with the HTTP status and message
+// that should be shown to the user.
+type httpError struct {
+	Status  int
+	Message string
+	Err     error
+}
+
+func (e httpError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func badRequest(message string, err error) httpError {
+	return httpError{
+		Status:  http.StatusBadRequest,
+		Message: message,
+		Err:     err,
+	}
+}
+
+// toHTTPError converts errors from the data package
+// to an httpError with a matching status.
+func toHTTPError(err error) httpError {
+	if e, ok := err.(httpError); ok {
+		return e
+	}
+	e := httpError{
+		Status:  http.StatusInternalServerError,
+		Message: "something went wrong",
+		Err:     err,
+	}
+	switch {
+	case data.IsNotFound(err):
+		e.Status = http.StatusNotFound
+		e.Message = "this page doesn't exist"
+	case data.IsInvalid(err):
+		e.Status = http.StatusBadRequest
+		e.Message = "the request is invalid"
+	case data.IsForbidden(err):
+		e.Status = http.StatusForbidden
+		e.Message = "you are not allowed to see this page"
+	}
+	return e
+}
+
+func renderError(w http.ResponseWriter, r *http.Request, err error) {
+	e := toHTTPError(err)
+	logError(r, e.Status, e)
+	w.WriteHeader(e.Status)
+	err = html.Render(blocks.LayoutBlock(blocks.ErrorPage(e.Status, e.Message)), w)
+	if err != nil {
+		logError(r, e.Status, err)
+	}
+}
+
+func logError(r *http.Request, status int, err error) {
+	log.Printf("page error: method=%s path=%q status=%d error=%q",
+		r.Method, r.URL.Path, status, err)
+}
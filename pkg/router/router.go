@@ -14,35 +14,51 @@
 package router
 
 import (
-	"log"
+	"bytes"
+	"net"
 	"net/http"
 	"path/filepath"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 	"github.com/mbertschler/blocks/html"
+	"github.com/mbertschler/bunny/pkg/api"
 	"github.com/mbertschler/bunny/pkg/blocks"
 	"github.com/mbertschler/bunny/pkg/data"
 	"github.com/mbertschler/bunny/pkg/guiapi"
 )
 
-func Router(root string) *chi.Mux {
+// Router returns the handler of the server. Requests from the
+// trusted networks can use the GUI like local ones.
+func Router(root string, trusted ...*net.IPNet) *chi.Mux {
 	r := chi.NewRouter()
 	r.Use(middleware.Recoverer)
 	mountFileServer(r, "/static/", root, "js", "node_modules")
 	mountFileServer(r, "/js/", root, "js", "src")
-	r.Method("POST", "/gui/", guiapi.Handlers())
-	r.Mount("/", pages())
+	gui := guiapi.Handlers()
+	r.Method("GET", "/gui/", gui)
+	r.Method("POST", "/gui/", localOrToken(gui, trusted))
+	r.Route("/api/v1", func(r chi.Router) {
+		r.Use(tokenAuth)
+		r.Mount("/", api.Router())
+	})
+	r.Mount("/", pages(trusted))
 	return r
 }
 
-func pages() *chi.Mux {
+func pages(trusted []*net.IPNet) *chi.Mux {
 	r := chi.NewRouter()
-	r.Get("/item/{id}", viewItemPage)
-	r.Get("/list/{id}", viewListPage)
-	r.Get("/focus/", viewFocusPage)
-	r.Get("/", viewAreaPage)
+	r.Method("GET", "/item/{id}", pageHandler(viewItemPage))
+	r.Method("GET", "/list/{id}", pageHandler(viewListPage))
+	r.Method("GET", "/focus/", pageHandler(viewFocusPage))
+	r.Method("GET", "/timesheet/", pageHandler(viewTimesheetPage))
+	r.Method("GET", "/settings/", pageHandler(viewSettingsPage))
+	r.Method("GET", "/export", localOrToken(http.HandlerFunc(exportHandler), trusted))
+	r.Get("/calendar.ics", calendarHandler)
+	r.Method("GET", "/", pageHandler(viewAreaPage))
+	r.NotFound(pageHandler(notFoundPage).ServeHTTP)
 	return r
 }
 
@@ -53,55 +69,111 @@ func mountFileServer(router *chi.Mux, url string, path ...string) {
 	router.Mount(url, fs)
 }
 
-func viewItemPage(w http.ResponseWriter, r *http.Request) {
+func viewItemPage(r *http.Request) (html.Block, error) {
 	id, err := intFromUrl(r, "id")
 	if err != nil {
-		log.Println(err)
+		return nil, badRequest("invalid item ID", err)
 	}
 	item, err := data.UserItemByID(1, id)
 	if err != nil {
-		log.Println(err)
+		return nil, err
 	}
-	err = html.Render(blocks.LayoutBlock(blocks.ViewItemPage(item)), w)
+	fields, err := data.ItemFields(id)
 	if err != nil {
-		log.Println(err)
+		return nil, err
 	}
+	return blocks.ViewItemPage(item, fields), nil
 }
 
-func viewAreaPage(w http.ResponseWriter, r *http.Request) {
-	_, things, err := data.UserArea(1, 1)
+func viewAreaPage(r *http.Request) (html.Block, error) {
+	order, err := data.UserAreaOrder(1, 1)
 	if err != nil {
-		log.Println(err)
+		return nil, err
 	}
-	err = html.Render(blocks.LayoutBlock(blocks.ViewAreaPage(things)), w)
+	_, page, err := data.UserAreaPage(1, 1, data.PageQuery{Order: order})
 	if err != nil {
-		log.Println(err)
+		return nil, err
 	}
+	return blocks.ViewAreaPage(1, order, page), nil
 }
 
-func viewListPage(w http.ResponseWriter, r *http.Request) {
+func viewListPage(r *http.Request) (html.Block, error) {
 	id, err := intFromUrl(r, "id")
 	if err != nil {
-		log.Println(err)
+		return nil, badRequest("invalid list ID", err)
 	}
-	list, err := data.UserItemList(1, id)
+	order, err := data.UserListOrder(1, id)
 	if err != nil {
-		log.Println(err)
+		return nil, err
 	}
-	err = html.Render(blocks.LayoutBlock(blocks.ViewListPage(list)), w)
+	page, err := data.UserItemListPage(1, id, data.PageQuery{Order: order})
 	if err != nil {
-		log.Println(err)
+		return nil, err
 	}
+	fields, err := data.ListFields(id)
+	if err != nil {
+		return nil, err
+	}
+	return blocks.ViewListPage(id, fields, data.ListView{Order: order}, page), nil
 }
 
-func viewFocusPage(w http.ResponseWriter, r *http.Request) {
+func viewFocusPage(r *http.Request) (html.Block, error) {
 	focus, err := data.FocusList(1)
 	if err != nil {
-		log.Println(err)
+		return nil, err
+	}
+	pomodoro, err := data.UserPomodoro(1)
+	if err != nil {
+		return nil, err
+	}
+	return blocks.ViewFocusPage(focus, pomodoro), nil
+}
+
+func viewTimesheetPage(r *http.Request) (html.Block, error) {
+	week, err := data.ParseWeek(r.URL.Query().Get("week"))
+	if err != nil {
+		return nil, badRequest("invalid week", err)
+	}
+	ts, err := data.UserTimesheet(1, week)
+	if err != nil {
+		return nil, err
 	}
-	err = html.Render(blocks.LayoutBlock(blocks.ViewFocusPage(focus)), w)
+	items, err := data.ItemsByState(data.ItemOpen)
 	if err != nil {
-		log.Println(err)
+		return nil, err
+	}
+	return blocks.ViewTimesheetPage(ts, items, 0), nil
+}
+
+func viewSettingsPage(r *http.Request) (html.Block, error) {
+	tokens, err := data.UserTokens(1)
+	if err != nil {
+		return nil, err
+	}
+	return blocks.ViewSettingsPage(tokens, ""), nil
+}
+
+// exportHandler downloads a JSON export of the whole workspace.
+func exportHandler(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	err := data.WriteExport(&buf)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	name := "bunny-" + time.Now().Format("2006-01-02") + ".json"
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	_, err = buf.WriteTo(w)
+	if err != nil {
+		logError(r, http.StatusOK, err)
+	}
+}
+
+func notFoundPage(r *http.Request) (html.Block, error) {
+	return nil, httpError{
+		Status:  http.StatusNotFound,
+		Message: "there is nothing at " + r.URL.Path,
 	}
 }
 
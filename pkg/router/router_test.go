@@ -15,6 +15,7 @@ package router
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"runtime"
 	"strings"
@@ -27,14 +28,46 @@ func TestRoutes(t *testing.T) {
 	r := Router("/")
 	shouldMatch(t, r, "GET", "/js/app.js")
 	shouldMatch(t, r, "GET", "/static/jquery/dist/jquery.min.js")
+	shouldMatch(t, r, "GET", "/gui/")
 	shouldMatch(t, r, "POST", "/gui/")
+	shouldMatch(t, r, "GET", "/api/v1/items")
+	shouldMatch(t, r, "PUT", "/api/v1/items/123")
 	shouldMatch(t, r, "GET", "/item/123")
 	shouldMatch(t, r, "GET", "/list/123")
 	shouldMatch(t, r, "GET", "/focus/")
+	shouldMatch(t, r, "GET", "/settings/")
+	shouldMatch(t, r, "GET", "/export")
+	shouldMatch(t, r, "GET", "/calendar.ics")
 	shouldNotMatch(t, r, "GET", "/x/focus/")
 	shouldMatch(t, r, "GET", "/")
 }
 
+func TestErrorPages(t *testing.T) {
+	r := Router("/")
+	shouldHaveStatus(t, r, "/item/1", http.StatusOK)
+	shouldHaveStatus(t, r, "/item/abc", http.StatusBadRequest)
+	shouldHaveStatus(t, r, "/item/9999", http.StatusNotFound)
+	shouldHaveStatus(t, r, "/list/1", http.StatusOK)
+	shouldHaveStatus(t, r, "/list/abc", http.StatusBadRequest)
+	shouldHaveStatus(t, r, "/list/9999", http.StatusNotFound)
+	shouldHaveStatus(t, r, "/focus/", http.StatusOK)
+	shouldHaveStatus(t, r, "/settings/", http.StatusOK)
+	shouldHaveStatus(t, r, "/export", http.StatusOK)
+	shouldHaveStatus(t, r, "/", http.StatusOK)
+	shouldHaveStatus(t, r, "/does/not/exist", http.StatusNotFound)
+}
+
+func shouldHaveStatus(t *testing.T, r *chi.Mux, path string, status int) {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", path, nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	req.Host = "localhost:3080"
+	r.ServeHTTP(w, req)
+	if w.Code != status {
+		t.Error("GET", path, "returned", w.Code, "should be", status)
+	}
+}
+
 func shouldMatch(t *testing.T, r *chi.Mux, method, path string) {
 	ctx := chi.NewRouteContext()
 	if !r.Match(ctx, method, path) {
@@ -88,16 +121,26 @@ func TestHandlers(t *testing.T) {
 			route:    "/list/{id}",
 			funcName: "github.com/mbertschler/bunny/pkg/router.viewListPage",
 		},
+		testCase{
+			method:   "GET",
+			route:    "/settings/",
+			funcName: "github.com/mbertschler/bunny/pkg/router.viewSettingsPage",
+		},
 		testCase{
 			method:   "GET",
 			route:    "/",
 			funcName: "github.com/mbertschler/bunny/pkg/router.viewAreaPage",
 		},
 		testCase{
-			method:   "POST",
+			method:   "GET",
 			route:    "/gui/",
 			typeName: "github.com/mbertschler/bunny/pkg/guiapi.Handler",
 		},
+		testCase{
+			method:   "POST",
+			route:    "/gui/",
+			funcName: "github.com/mbertschler/bunny/pkg/router.localOrToken.func1",
+		},
 	}
 	for _, tc := range cases {
 		testOneCase(t, tree, tc)